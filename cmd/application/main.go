@@ -41,11 +41,20 @@ func main() {
 	defer db.Close()
 	root.AddValue(models.DATABASE_CONTEXT_KEY, db)
 
+	// Load secrets master key and encrypt plaintext secrets
+	keeper := application.InitSecrets(config)
+	application.MigrateSecrets(db, keeper)
+	root.AddValue(models.SECRETS_CONTEXT_KEY, keeper)
+
 	// Inititalize MQTT connection for app
 	mqtt := application.InitMqtt(root.Context, 10*time.Minute, config)
 	defer mqtt.Close()
 	root.AddValue(models.MESSAGE_BROKER_CONTEXT_KEY, mqtt)
 
+	sender := application.InitMqttSender(root.Context, config)
+	defer sender.Exit()
+	root.AddValue(models.MESSAGE_SENDER_CONTEXT_KEY, sender)
+
 	// Init application cache singletone
 	cache.Init()
 	defer cache.EraseValues()
@@ -0,0 +1,89 @@
+// rotate-key re-encrypts all stored secrets with new master key.
+//
+// Usage:
+//
+//	rotate-key -new-key-file desky.new.key
+//
+// Current master key is resolved like in application: DESKY_MASTER_KEY variable,
+// 'DB.master-key' setting or 'DB.key-file'. Missing new key file is generated.
+// After rotation point 'DB.key-file' (or DESKY_MASTER_KEY) to the new key.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/eterline/desky-backend/internal/application"
+	"github.com/eterline/desky-backend/internal/configuration"
+	"github.com/eterline/desky-backend/internal/repository"
+	"github.com/eterline/desky-backend/pkg/envelope"
+	"github.com/eterline/desky-backend/pkg/logger"
+)
+
+func main() {
+
+	configFile := flag.String("config", configuration.FileName, "configuration file")
+	newKeyFile := flag.String("new-key-file", "", "file of new master key, generated when missing")
+	flag.Parse()
+
+	if *newKeyFile == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := configuration.Init(*configFile); err != nil {
+		fail("configuration error: %v", err)
+	}
+	config := configuration.GetConfig()
+
+	if err := logger.InitLogger(logger.WithPath("./logs")); err != nil {
+		fail("logger error: %v", err)
+	}
+
+	oldKey, err := config.MasterKey()
+	if err != nil {
+		fail("current master key error: %v", err)
+	}
+
+	newKey, err := loadOrGenerate(*newKeyFile)
+	if err != nil {
+		fail("new master key error: %v", err)
+	}
+
+	keeper, err := envelope.New(newKey, oldKey)
+	if err != nil {
+		fail("master key error: %v", err)
+	}
+
+	db := application.InitDatabase()
+	defer db.Close()
+
+	count, err := repository.NewSecretsRepository(db).Rewrap(keeper)
+	if err != nil {
+		fail("secrets re-encryption error: %v", err)
+	}
+
+	fmt.Printf("re-encrypted rows: %d\nnew master key id: %s\nset 'DB.key-file: %s' and unset 'DB.master-key' and DESKY_MASTER_KEY before next start\n",
+		count, envelope.KeyID(newKey), *newKeyFile,
+	)
+}
+
+func loadOrGenerate(path string) ([]byte, error) {
+
+	if _, err := os.Stat(path); err == nil {
+		return envelope.ReadKeyFile(path)
+	}
+
+	key, err := envelope.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return key, envelope.WriteKeyFile(path, key)
+}
+
+func fail(format string, v ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", v...)
+	os.Exit(1)
+}
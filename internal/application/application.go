@@ -2,13 +2,17 @@ package application
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/eterline/desky-backend/internal/configuration"
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/internal/repository"
 	"github.com/eterline/desky-backend/internal/server"
 	"github.com/eterline/desky-backend/internal/services/cache"
 	"github.com/eterline/desky-backend/pkg/broker"
+	"github.com/eterline/desky-backend/pkg/envelope"
 	"github.com/eterline/desky-backend/pkg/logger"
 	"github.com/eterline/desky-backend/pkg/storage"
 	"github.com/eterline/desky-backend/pkg/toolkit"
@@ -24,6 +28,7 @@ func Exec(root *toolkit.AppStarter, config *configuration.Configuration) {
 	settings := new(ApplicationSettings)
 	settings.SetLanguage(LangEN)
 	settings.SetBG("none")
+	settings.SetAuth(config.Auth.Enable)
 
 	// ================= Server parameters =================
 
@@ -120,6 +125,38 @@ func InitMqtt(ctx context.Context, testInterval time.Duration, config *configura
 	return mqttBroker
 }
 
+// InitMqttSender - creates MQTT client publishing messages to agents and notification topics.
+// Client reconnects in background, so unavailable broker doesn't stop startup
+func InitMqttSender(ctx context.Context, config *configuration.Configuration) *broker.SenderMQTT {
+
+	log := logger.ReturnEntry()
+
+	sender := broker.NewSenderWithContext(ctx,
+		broker.OptionInsecureCerts(),
+
+		broker.OptionClientIDString(config.Agent.UUID+"-sender"),
+		broker.OptionServer(
+			config.Agent.Server.Protocol,
+			config.Agent.Server.Host,
+			config.Agent.Server.Port,
+		),
+
+		broker.OptionCredentials(
+			config.Agent.Username,
+			config.Agent.Password,
+		),
+
+		broker.OptionDefaultQoS(config.Agent.DefaultQoS),
+		broker.OptionReconnecting(),
+	)
+
+	if err := sender.Connect(30 * time.Second); err != nil {
+		log.Errorf("mqtt sender connection error: %v", err)
+	}
+
+	return sender
+}
+
 func InitDatabase() *storage.DB {
 	db := storage.New(
 		storage.NewStorageSQLite("desky.db"),
@@ -130,5 +167,55 @@ func InitDatabase() *storage.DB {
 		panic(err)
 	}
 
+	if err := db.MigrateTables(models.Tables()...); err != nil {
+		panic(err)
+	}
+
 	return db
 }
+
+// InitSecrets - loads master key of secrets encryption.
+// Missing key file is generated on first start
+func InitSecrets(config *configuration.Configuration) *envelope.Keeper {
+
+	log := logger.ReturnEntry()
+
+	key, err := config.MasterKey()
+	if errors.Is(err, envelope.ErrNoMasterKey) {
+
+		if key, err = envelope.GenerateKey(); err != nil {
+			panic(err)
+		}
+
+		if err := envelope.WriteKeyFile(config.KeyFile(), key); err != nil {
+			panic(err)
+		}
+
+		log.Warnf("master key is not configured, new key generated: %s. keep it apart from database backups", config.KeyFile())
+	}
+	if err != nil {
+		log.Fatalf("master key loading error: %v", err)
+	}
+
+	keeper, err := envelope.New(key)
+	if err != nil {
+		log.Fatalf("master key error: %v", err)
+	}
+
+	return keeper
+}
+
+// MigrateSecrets - encrypts plaintext secrets left from previous versions
+func MigrateSecrets(db *storage.DB, keeper *envelope.Keeper) {
+
+	log := logger.ReturnEntry()
+
+	count, err := repository.NewSecretsRepository(db).Rewrap(keeper)
+	if err != nil {
+		log.Fatalf("secrets encryption error: %v", err)
+	}
+
+	if count > 0 {
+		log.Infof("secrets encrypted in %d rows", count)
+	}
+}
@@ -32,5 +32,78 @@ var DefaultParameters = &Configuration{
 			Host:           "localhost",
 			Port:           1883,
 		},
+		History: HistoryOptions{
+			Raw:    "24h",
+			Minute: "168h",
+			Hour:   "8760h",
+		},
+		Presence: PresenceOptions{
+			Heartbeat: "5s",
+			Forget:    "24h",
+		},
+		Registry: RegistryOptions{
+			AutoApprove:      false,
+			RequireSignature: false,
+		},
+	},
+
+	DB: DB{
+		KeyFile: "desky.key",
+	},
+
+	Auth: AuthOptions{
+		Enable:       true,
+		Secret:       "",
+		SessionTTL:   "24h",
+		PasswordHash: "argon2id",
+
+		BootstrapLogin: "admin",
+		TOTPIssuer:     "desky",
+
+		Lockout: LockoutOptions{
+			LoginAttempts: 5,
+			IPAttempts:    20,
+			Window:        "15m",
+			Duration:      "1m",
+			MaxDuration:   "1h",
+		},
+
+		OIDC: OIDCOptions{
+			Enable:      false,
+			Scopes:      []string{"openid", "profile", "email"},
+			LoginClaim:  "preferred_username",
+			RoleClaim:   "groups",
+			RoleMapping: map[string]string{},
+			DefaultRole: "",
+		},
+	},
+
+	SSH: SSHOptions{
+		ConnectTimeout: "15s",
+		Sessions: SessionsOptions{
+			ReattachGrace: "1m",
+			Scrollback:    65536,
+		},
+		Recording: RecordingOptions{
+			Enable:    false,
+			Dir:       "recordings",
+			Input:     false,
+			Retention: "720h",
+		},
+		Files: FilesOptions{
+			Root:      "/",
+			MaxUpload: 1 << 30,
+		},
+		Exec: ExecOptions{
+			Timeout:   "30s",
+			MaxOutput: 1 << 20,
+			Parallel:  8,
+		},
+	},
+
+	Alerts: AlertsOptions{
+		Interval:   "15s",
+		StaleAfter: "5m",
+		Notifiers:  []NotifierOptions{},
 	},
 }
@@ -4,16 +4,19 @@ import "github.com/eterline/desky-backend/pkg/broker"
 
 // ============================= Main app config struct =============================
 type Configuration struct {
-	DevelopEnv bool         `yaml:"dev-env" validate:"boolean"`
-	DB         DB           `yaml:"DB"`
-	Server     Server       `yaml:"HTTP-Server" validate:"required"`
-	Agent      AgentOptions `yaml:AgentMQTT"`
+	DevelopEnv bool          `yaml:"dev-env" validate:"boolean"`
+	DB         DB            `yaml:"DB"`
+	Server     Server        `yaml:"HTTP-Server" validate:"required"`
+	Agent      AgentOptions  `yaml:"agent"`
+	Auth       AuthOptions   `yaml:"Auth"`
+	SSH        SSHOptions    `yaml:"SSH"`
+	Alerts     AlertsOptions `yaml:"Alerts"`
 }
 
 // Server config struct =============================
 type (
 	Server struct {
-		Name    string     `yaml:Name"`
+		Name    string     `yaml:"name"`
 		Address ServerAddr `yaml:"Address" validate:"required"`
 		SSL     ServerSSL  `yaml:"SSL"`
 	}
@@ -40,6 +43,9 @@ type AgentOptions struct {
 	Username   string          `yaml:"Username"`
 	Password   string          `yaml:"Password"`
 	Server     AgentServer     `yaml:"Server"`
+	History    HistoryOptions  `yaml:"history"`
+	Presence   PresenceOptions `yaml:"presence"`
+	Registry   RegistryOptions `yaml:"registry"`
 }
 
 type AgentServer struct {
@@ -49,6 +55,93 @@ type AgentServer struct {
 	ConnectTimeout string             `yaml:"connect-timeout" validate:"required"`
 }
 
+// PresenceOptions - agent reporting period and time after which offline agent is forgotten.
+// Zero forget-after keeps offline agents forever
+type PresenceOptions struct {
+	Heartbeat string `yaml:"heartbeat"`
+	Forget    string `yaml:"forget-after"`
+}
+
+// RegistryOptions - admission of agents. Unknown agents wait for approval unless auto-approve is set
+type RegistryOptions struct {
+	AutoApprove      bool `yaml:"auto-approve"`
+	RequireSignature bool `yaml:"require-signature"`
+}
+
+// HistoryOptions - retention periods of agent metrics tiers
+type HistoryOptions struct {
+	Raw    string `yaml:"raw-retention"`
+	Minute string `yaml:"minute-retention"`
+	Hour   string `yaml:"hour-retention"`
+}
+
+// ============================= Alerts config struct =============================
+
+type AlertsOptions struct {
+	// Interval - period of desky host metrics evaluation
+	Interval string `yaml:"interval"`
+	// StaleAfter - alert is resolved when it's metric isn't reported for this period
+	StaleAfter string            `yaml:"stale-after"`
+	Notifiers  []NotifierOptions `yaml:"notifiers" validate:"dive"`
+}
+
+type NotifierOptions struct {
+	Name string `yaml:"name" validate:"required,excludesall=0x2C"`
+	Type string `yaml:"type" validate:"required,oneof=webhook smtp mqtt"`
+
+	// webhook
+	URL     string            `yaml:"url" validate:"required_if=Type webhook,omitempty,url"`
+	Headers map[string]string `yaml:"headers"`
+
+	// smtp
+	Host     string   `yaml:"host" validate:"required_if=Type smtp"`
+	Port     uint16   `yaml:"port"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	From     string   `yaml:"from" validate:"required_if=Type smtp,omitempty,email"`
+	To       []string `yaml:"to" validate:"required_if=Type smtp,dive,email"`
+
+	// mqtt
+	Topic string `yaml:"topic" validate:"required_if=Type mqtt"`
+}
+
+// ============================= Authorization config struct =============================
+
+type AuthOptions struct {
+	Enable       bool   `yaml:"enable" validate:"boolean"`
+	Secret       string `yaml:"session-secret"`
+	SessionTTL   string `yaml:"session-ttl"`
+	PasswordHash string `yaml:"password-hash" validate:"omitempty,oneof=argon2id bcrypt"`
+
+	BootstrapLogin string `yaml:"bootstrap-login" validate:"omitempty,alphanum"`
+	TOTPIssuer     string `yaml:"totp-issuer"`
+
+	Lockout LockoutOptions `yaml:"lockout"`
+	OIDC    OIDCOptions    `yaml:"oidc"`
+}
+
+type OIDCOptions struct {
+	Enable       bool     `yaml:"enable" validate:"boolean"`
+	Issuer       string   `yaml:"issuer" validate:"required_if=Enable true,omitempty,url"`
+	ClientID     string   `yaml:"client-id" validate:"required_if=Enable true"`
+	ClientSecret string   `yaml:"client-secret"`
+	RedirectURL  string   `yaml:"redirect-url" validate:"required_if=Enable true,omitempty,url"`
+	Scopes       []string `yaml:"scopes"`
+
+	LoginClaim  string            `yaml:"login-claim"`
+	RoleClaim   string            `yaml:"role-claim"`
+	RoleMapping map[string]string `yaml:"role-mapping" validate:"dive,oneof=viewer operator admin"`
+	DefaultRole string            `yaml:"default-role" validate:"omitempty,oneof=viewer operator admin"`
+}
+
+type LockoutOptions struct {
+	LoginAttempts int    `yaml:"login-attempts" validate:"gte=0"`
+	IPAttempts    int    `yaml:"ip-attempts" validate:"gte=0"`
+	Window        string `yaml:"window"`
+	Duration      string `yaml:"duration"`
+	MaxDuration   string `yaml:"max-duration"`
+}
+
 // Services config struct =============================
 
 // -----Can be used with api pooling-----
@@ -76,5 +169,53 @@ type (
 	DB struct {
 		File string `yaml:"file"`
 		Sync bool   `yaml:"sync"`
+
+		// MasterKey - base64 or hex encoded 32 bytes key of secrets encryption.
+		// Can be overridden with DESKY_MASTER_KEY environment variable
+		MasterKey string `yaml:"master-key"`
+		KeyFile   string `yaml:"key-file"`
 	}
 )
+
+// ============================= SSH lander config struct =============================
+
+type SSHOptions struct {
+	// ConnectTimeout - timeout of connection to host and each of it's jump hosts
+	ConnectTimeout string `yaml:"connect-timeout"`
+
+	Sessions  SessionsOptions  `yaml:"sessions"`
+	Recording RecordingOptions `yaml:"recording"`
+	Files     FilesOptions     `yaml:"files"`
+	Exec      ExecOptions      `yaml:"exec"`
+}
+
+type ExecOptions struct {
+	// Timeout - default timeout of non-interactive command, also upper bound of requested timeout
+	Timeout string `yaml:"timeout"`
+	// MaxOutput - bytes kept of command stdout and stderr each
+	MaxOutput int `yaml:"max-output" validate:"gte=0"`
+	// Parallel - hosts running command at the same time
+	Parallel int `yaml:"parallel" validate:"gte=0"`
+}
+
+type FilesOptions struct {
+	// Root - remote directory available in file browser
+	Root string `yaml:"root"`
+	// MaxUpload - size limit of uploaded file in bytes
+	MaxUpload int64 `yaml:"max-upload" validate:"gte=0"`
+}
+
+type SessionsOptions struct {
+	// ReattachGrace - time terminal stays open without connected clients
+	ReattachGrace string `yaml:"reattach-grace"`
+	// Scrollback - bytes of output replayed to reattached clients
+	Scrollback int `yaml:"scrollback" validate:"gte=0"`
+}
+
+type RecordingOptions struct {
+	Enable bool   `yaml:"enable" validate:"boolean"`
+	Dir    string `yaml:"dir"`
+	// Input - records terminal input. Input can contain typed secrets
+	Input     bool   `yaml:"input" validate:"boolean"`
+	Retention string `yaml:"retention"`
+}
@@ -1,12 +1,18 @@
 package configuration
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"os"
 	"time"
 
+	"github.com/eterline/desky-backend/pkg/envelope"
 	"github.com/eterline/desky-backend/pkg/iptool"
 )
 
+const MasterKeyEnv = "DESKY_MASTER_KEY"
+
 func (c *Configuration) ServerSocket() string {
 
 	ip, ok := iptool.ParseIPv4(c.Server.Address.IP)
@@ -53,3 +59,144 @@ func (c *Configuration) MQTTSocket() string {
 	s := c.Agent.Server
 	return fmt.Sprintf("%s://%s:%d", s.Protocol, s.Host, s.Port)
 }
+
+func (c *Configuration) SessionTTL() time.Duration {
+
+	tm, err := time.ParseDuration(c.Auth.SessionTTL)
+	if err != nil || tm <= 0 {
+		return 24 * time.Hour
+	}
+
+	return tm
+}
+
+func (c *Configuration) LockoutWindow() time.Duration {
+	return parseDurationOr(c.Auth.Lockout.Window, 15*time.Minute)
+}
+
+func (c *Configuration) LockoutDuration() time.Duration {
+	return parseDurationOr(c.Auth.Lockout.Duration, time.Minute)
+}
+
+func (c *Configuration) LockoutMaxDuration() time.Duration {
+
+	max := parseDurationOr(c.Auth.Lockout.MaxDuration, time.Hour)
+	if min := c.LockoutDuration(); max < min {
+		return min
+	}
+
+	return max
+}
+
+// SessionGrace - returns time terminal session waits for reattach
+func (c *Configuration) SessionGrace() time.Duration {
+	return parseDurationOr(c.SSH.Sessions.ReattachGrace, time.Minute)
+}
+
+// MaxUpload - returns size limit of file uploaded over SFTP
+func (c *Configuration) MaxUpload() int64 {
+
+	if c.SSH.Files.MaxUpload <= 0 {
+		return 1 << 30
+	}
+
+	return c.SSH.Files.MaxUpload
+}
+
+func (c *Configuration) RecordingsDir() string {
+
+	if c.SSH.Recording.Dir == "" {
+		return "recordings"
+	}
+
+	return c.SSH.Recording.Dir
+}
+
+// RecordingRetention - returns age of recordings pruned by retention policy
+func (c *Configuration) RecordingRetention() time.Duration {
+	return parseDurationOr(c.SSH.Recording.Retention, 30*24*time.Hour)
+}
+
+// HistoryRetention - returns retention periods of raw, 1 minute and 1 hour agent metrics
+func (c *Configuration) HistoryRetention() (raw, minute, hour time.Duration) {
+	return parseDurationOr(c.Agent.History.Raw, 24*time.Hour),
+		parseDurationOr(c.Agent.History.Minute, 7*24*time.Hour),
+		parseDurationOr(c.Agent.History.Hour, 365*24*time.Hour)
+}
+
+// AgentPresence - returns agent reporting period and time after which offline agent is forgotten.
+// Zero forget period keeps offline agents forever
+func (c *Configuration) AgentPresence() (heartbeat, forget time.Duration) {
+	return parseDurationOr(c.Agent.Presence.Heartbeat, 5*time.Second),
+		parseDurationOrZero(c.Agent.Presence.Forget, 24*time.Hour)
+}
+
+// AlertsInterval - returns period of desky host metrics evaluation
+func (c *Configuration) AlertsInterval() time.Duration {
+	return parseDurationOr(c.Alerts.Interval, 15*time.Second)
+}
+
+// AlertsStaleAfter - returns period without metric values after which alert is resolved
+func (c *Configuration) AlertsStaleAfter() time.Duration {
+	return parseDurationOr(c.Alerts.StaleAfter, 5*time.Minute)
+}
+
+func parseDurationOr(value string, def time.Duration) time.Duration {
+
+	tm, err := time.ParseDuration(value)
+	if err != nil || tm <= 0 {
+		return def
+	}
+
+	return tm
+}
+
+// parseDurationOrZero - same as parseDurationOr, but zero value is kept, e.g. to disable feature
+func parseDurationOrZero(value string, def time.Duration) time.Duration {
+
+	tm, err := time.ParseDuration(value)
+	if err != nil || tm < 0 {
+		return def
+	}
+
+	return tm
+}
+
+func (c *Configuration) KeyFile() string {
+
+	if c.DB.KeyFile == "" {
+		return "desky.key"
+	}
+
+	return c.DB.KeyFile
+}
+
+// MasterKey - returns master key of secrets encryption.
+// Key is resolved from environment variable, configuration value or key file in this order
+func (c *Configuration) MasterKey() ([]byte, error) {
+
+	if value := os.Getenv(MasterKeyEnv); value != "" {
+		return envelope.ParseKey(value)
+	}
+
+	if c.DB.MasterKey != "" {
+		return envelope.ParseKey(c.DB.MasterKey)
+	}
+
+	key, err := envelope.ReadKeyFile(c.KeyFile())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, envelope.ErrNoMasterKey
+	}
+
+	return key, err
+}
+
+// SSHConnectTimeout - returns timeout of ssh connection to each hop
+func (c *Configuration) SSHConnectTimeout() time.Duration {
+	return parseDurationOr(c.SSH.ConnectTimeout, 15*time.Second)
+}
+
+// ExecTimeout - returns timeout of non-interactive command
+func (c *Configuration) ExecTimeout() time.Duration {
+	return parseDurationOr(c.SSH.Exec.Timeout, 30*time.Second)
+}
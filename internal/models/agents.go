@@ -3,9 +3,57 @@ package models
 var ExporterList = []string{"host", "cpu", "ram", "load", "temperature", "ports", "partitions"}
 
 type SessionCredentials struct {
-	Hostname string `json:"hostname"`
-	ID       string `json:"id"`
-	URL      string `json:"url"`
+	Hostname string        `json:"hostname"`
+	ID       string        `json:"id"`
+	URL      string        `json:"url"`
+	State    PresenceState `json:"state,omitempty"`
+	LastSeen int64         `json:"last-seen,omitempty"`
+}
+
+// PresenceState - agent state by time since it's last message
+type PresenceState string
+
+const (
+	PresenceOnline  PresenceState = "online"
+	PresenceStale   PresenceState = "stale"
+	PresenceOffline PresenceState = "offline"
+	// PresenceRemoved - state of event sent when agent is forgotten
+	PresenceRemoved PresenceState = "removed"
+)
+
+const PresenceEventType = "presence"
+
+// PresenceEvent - agent presence state change, sent to agents monitor WebSocket
+type PresenceEvent struct {
+	Type     string        `json:"type"`
+	HostID   string        `json:"host-id"`
+	Hostname string        `json:"hostname"`
+	State    PresenceState `json:"state"`
+	Previous PresenceState `json:"previous,omitempty"`
+	LastSeen int64         `json:"last-seen"`
+	Time     int64         `json:"time"`
+}
+
+// AgentObject - registered agent. Signed agent payloads are verified with it's secret
+type AgentObject struct {
+	ID        uint     `json:"id"`
+	HostID    string   `json:"host-id"`
+	Name      string   `json:"name"`
+	Tags      []string `json:"tags"`
+	FirstSeen int64    `json:"first-seen"`
+	Approved  bool     `json:"approved"`
+	Signed    bool     `json:"signed"`
+}
+
+type RequestFormAgent struct {
+	Name string   `json:"name" validate:"required,max=64"`
+	Tags []string `json:"tags" validate:"max=32,dive,required,max=32,excludesall=0x2C"`
+}
+
+// ResponseAgentSecret - generated agent secret. It's shown only once
+type ResponseAgentSecret struct {
+	HostID string `json:"host-id"`
+	Secret string `json:"secret"`
 }
 
 type FetchedResponse struct {
@@ -49,6 +97,7 @@ type Load struct {
 
 type Partition struct {
 	Device      string  `json:"device"`
+	Mountpoint  string  `json:"mountpoint"`
 	FS          string  `json:"fs"`
 	Total       int     `json:"total"`
 	Free        int     `json:"free"`
@@ -56,6 +105,14 @@ type Partition struct {
 	UsedPercent float64 `json:"used-percent"`
 }
 
+// Name - returns mount point of partition or device when mount point is unknown
+func (p Partition) Name() string {
+	if p.Mountpoint != "" {
+		return p.Mountpoint
+	}
+	return p.Device
+}
+
 type Port struct {
 	MAC  string `json:"mac"`
 	MTU  int    `json:"mtu"`
@@ -84,3 +141,84 @@ type AgentStatsObject struct {
 	RAM         *RAM           `json:"ram"`
 	Temperature *[]Temperature `json:"temperature"`
 }
+
+// Metrics - returns numeric values of stats by metric names like 'cpu.load' or 'disk./data.use'.
+// Partitions are named by mount point, device is used when agent doesn't report mount point
+func (s *AgentStatsObject) Metrics() map[string]float64 {
+
+	metrics := make(map[string]float64)
+
+	if s.CPU != nil {
+		metrics["cpu.load"] = s.CPU.Load
+	}
+
+	if s.Host != nil {
+		metrics["host.processes"] = float64(s.Host.Processes)
+		metrics["host.uptime"] = float64(s.Host.Uptime)
+	}
+
+	if s.Load != nil {
+		metrics["load.1"] = s.Load.Load1
+		metrics["load.5"] = s.Load.Load5
+		metrics["load.15"] = s.Load.Load15
+	}
+
+	if s.RAM != nil {
+		metrics["ram.use"] = s.RAM.Use
+		metrics["ram.used"] = float64(s.RAM.Used)
+		metrics["ram.available"] = float64(s.RAM.Available)
+	}
+
+	if s.Partitions != nil {
+		for _, p := range *s.Partitions {
+			metrics["disk."+p.Name()+".use"] = p.UsedPercent
+		}
+	}
+
+	if s.Temperature != nil {
+		for _, t := range *s.Temperature {
+			metrics["temperature."+t.Key] = t.Current
+		}
+	}
+
+	return metrics
+}
+
+// MetricTier - resolution of stored metric values
+type MetricTier string
+
+const (
+	TierRaw    MetricTier = "raw"
+	TierMinute MetricTier = "1m"
+	TierHour   MetricTier = "1h"
+)
+
+// Step - bucket length of tier in seconds. Raw values have no buckets
+func (t MetricTier) Step() int64 {
+	switch t {
+	case TierMinute:
+		return 60
+	case TierHour:
+		return 3600
+	}
+	return 0
+}
+
+// MetricPoint - aggregated metric values of bucket starting at Time
+type MetricPoint struct {
+	Time  int64   `json:"time"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Avg   float64 `json:"avg"`
+	Count int64   `json:"count"`
+}
+
+type ResponseMetricHistory struct {
+	HostID string        `json:"host-id"`
+	Metric string        `json:"metric"`
+	From   int64         `json:"from"`
+	To     int64         `json:"to"`
+	Step   int64         `json:"step"`
+	Tier   MetricTier    `json:"tier"`
+	Points []MetricPoint `json:"points"`
+}
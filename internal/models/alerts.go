@@ -0,0 +1,72 @@
+package models
+
+// AlertLocalHost - host name of desky host in alert rules and events
+const AlertLocalHost = "local"
+
+// AlertEventType - type field of alert events in monitor stream
+const AlertEventType = "alert"
+
+type AlertOperator string
+
+const (
+	AlertAbove        AlertOperator = ">"
+	AlertAboveOrEqual AlertOperator = ">="
+	AlertBelow        AlertOperator = "<"
+	AlertBelowOrEqual AlertOperator = "<="
+)
+
+type AlertState string
+
+const (
+	// AlertPending - condition is met, but not for rule duration yet
+	AlertPending AlertState = "pending"
+	AlertFiring  AlertState = "firing"
+	// AlertResolved - condition of pending or firing alert isn't met anymore
+	AlertResolved AlertState = "resolved"
+)
+
+type RequestFormAlertRule struct {
+	Name string `json:"name" validate:"required,max=64"`
+	// Host - agent host id, 'local' for desky host or empty for every host
+	Host      string        `json:"host" validate:"max=128"`
+	Metric    string        `json:"metric" validate:"required,max=128"`
+	Operator  AlertOperator `json:"operator" validate:"required,oneof=> >= < <="`
+	Threshold float64       `json:"threshold"`
+	// Hysteresis - distance from threshold value must pass to resolve firing alert
+	Hysteresis float64 `json:"hysteresis" validate:"gte=0"`
+	// For - duration like '5m' condition must be met before alert is fired
+	For       string   `json:"for"`
+	Notifiers []string `json:"notifiers" validate:"dive,required"`
+	Enabled   *bool    `json:"enabled"`
+}
+
+type AlertRuleObject struct {
+	ID         uint          `json:"id"`
+	Name       string        `json:"name"`
+	Host       string        `json:"host"`
+	Metric     string        `json:"metric"`
+	Operator   AlertOperator `json:"operator"`
+	Threshold  float64       `json:"threshold"`
+	Hysteresis float64       `json:"hysteresis"`
+	For        string        `json:"for"`
+	Notifiers  []string      `json:"notifiers"`
+	Enabled    bool          `json:"enabled"`
+	CreatedAt  int64         `json:"created-at"`
+}
+
+// AlertEvent - state of alert raised by rule on host
+type AlertEvent struct {
+	Type      string        `json:"type"`
+	RuleID    uint          `json:"rule-id"`
+	Rule      string        `json:"rule"`
+	Host      string        `json:"host"`
+	Metric    string        `json:"metric"`
+	Operator  AlertOperator `json:"operator"`
+	Threshold float64       `json:"threshold"`
+	Value     float64       `json:"value"`
+	State     AlertState    `json:"state"`
+	// Since - unix time of state change
+	Since int64 `json:"since"`
+	// Time - unix time of the last evaluated value
+	Time int64 `json:"time"`
+}
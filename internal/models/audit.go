@@ -0,0 +1,90 @@
+package models
+
+type AuditAction string
+
+const (
+	AuditLogin         AuditAction = "auth.login"
+	AuditLoginSecond   AuditAction = "auth.login.2fa"
+	AuditLoginSSO      AuditAction = "auth.login.oidc"
+	AuditLogout        AuditAction = "auth.logout"
+	AuditPassword      AuditAction = "auth.password"
+	AuditTwoFactor     AuditAction = "auth.2fa"
+	AuditTokenMint     AuditAction = "token.mint"
+	AuditTokenRevoke   AuditAction = "token.revoke"
+	AuditUserCreate    AuditAction = "user.create"
+	AuditUserEdit      AuditAction = "user.edit"
+	AuditUserDelete    AuditAction = "user.delete"
+	AuditUserUnlock    AuditAction = "user.unlock"
+	AuditAppCreate     AuditAction = "app.create"
+	AuditAppEdit       AuditAction = "app.edit"
+	AuditAppDelete     AuditAction = "app.delete"
+	AuditHostCreate    AuditAction = "ssh.host.create"
+	AuditHostEdit      AuditAction = "ssh.host.edit"
+	AuditHostDelete    AuditAction = "ssh.host.delete"
+	AuditHostImport    AuditAction = "ssh.host.import"
+	AuditHostKeyAccept AuditAction = "ssh.hostkey.accept"
+	AuditHostKeyReset  AuditAction = "ssh.hostkey.reset"
+	AuditHostKeyImport AuditAction = "ssh.hostkey.import"
+	AuditKeyCreate     AuditAction = "ssh.key.create"
+	AuditKeyUpdate     AuditAction = "ssh.key.update"
+	AuditKeyDelete     AuditAction = "ssh.key.delete"
+	AuditRecordDelete  AuditAction = "ssh.recording.delete"
+	AuditSSHOpen       AuditAction = "ssh.open"
+	AuditSSHClose      AuditAction = "ssh.close"
+	AuditSSHCommand    AuditAction = "ssh.command"
+	AuditSSHAttach     AuditAction = "ssh.attach"
+	AuditSSHTerminate  AuditAction = "ssh.terminate"
+	AuditSSHExec       AuditAction = "ssh.exec"
+	AuditTunnelCreate  AuditAction = "ssh.tunnel.create"
+	AuditTunnelUpdate  AuditAction = "ssh.tunnel.update"
+	AuditTunnelDelete  AuditAction = "ssh.tunnel.delete"
+	AuditTunnelStart   AuditAction = "ssh.tunnel.start"
+	AuditTunnelStop    AuditAction = "ssh.tunnel.stop"
+	AuditFileDownload  AuditAction = "ssh.file.download"
+	AuditFileUpload    AuditAction = "ssh.file.upload"
+	AuditFileRename    AuditAction = "ssh.file.rename"
+	AuditFileMkdir     AuditAction = "ssh.file.mkdir"
+	AuditFileChmod     AuditAction = "ssh.file.chmod"
+	AuditFileDelete    AuditAction = "ssh.file.delete"
+	AuditSystemdUnit   AuditAction = "systemd.command"
+	AuditAgentCommand  AuditAction = "agent.command"
+	AuditAgentApprove  AuditAction = "agent.approve"
+	AuditAgentEdit     AuditAction = "agent.edit"
+	AuditAgentRemove   AuditAction = "agent.remove"
+	AuditAgentSecret   AuditAction = "agent.secret"
+	AuditAlertCreate   AuditAction = "alert.rule.create"
+	AuditAlertUpdate   AuditAction = "alert.rule.update"
+	AuditAlertDelete   AuditAction = "alert.rule.delete"
+)
+
+type AuditResult string
+
+const (
+	AuditSuccess AuditResult = "success"
+	AuditFailure AuditResult = "failure"
+)
+
+type AuditEvent struct {
+	ID        uint        `json:"id"`
+	Timestamp int64       `json:"timestamp"`
+	Actor     string      `json:"actor"`
+	UserID    uint        `json:"user-id,omitempty"`
+	IP        string      `json:"ip"`
+	Action    AuditAction `json:"action"`
+	Target    string      `json:"target"`
+	Result    AuditResult `json:"result"`
+	Details   string      `json:"details,omitempty"`
+}
+
+// AuditFilter - audit log query. Zero fields are not filtered
+type AuditFilter struct {
+	Actor  string
+	Action string
+	Target string
+	Result string
+	From   int64
+	To     int64
+
+	Offset int
+	Limit  int
+}
@@ -1,9 +1,51 @@
 package models
 
+type UserRole string
+
+const (
+	RoleViewer   UserRole = "viewer"
+	RoleOperator UserRole = "operator"
+	RoleAdmin    UserRole = "admin"
+)
+
+// StringUserRole - parses role value. Unknown values are resolved as viewer
+func StringUserRole(value string) UserRole {
+
+	switch UserRole(value) {
+	case RoleAdmin:
+		return RoleAdmin
+	case RoleOperator:
+		return RoleOperator
+	default:
+		return RoleViewer
+	}
+}
+
+func (r UserRole) level() int {
+
+	switch r {
+	case RoleAdmin:
+		return 3
+	case RoleOperator:
+		return 2
+	case RoleViewer:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Allows - reports that role includes permissions of required role
+func (r UserRole) Allows(required UserRole) bool {
+	return r.level() >= required.level() && r.level() > 0
+}
+
 type DeskyUser struct {
-	ID       uint   `json:"id,omitempty"`
-	Login    string `json:"login"`
-	Password string `json:"password,omitempty"`
+	ID       uint     `json:"id,omitempty"`
+	Login    string   `json:"login"`
+	Password string   `json:"password,omitempty"`
+	Role     UserRole `json:"role,omitempty"`
+	Provider string   `json:"provider,omitempty"`
 }
 
 func NewDeskyUser(id uint, login, pwd string) *DeskyUser {
@@ -13,3 +55,158 @@ func NewDeskyUser(id uint, login, pwd string) *DeskyUser {
 		Password: pwd,
 	}
 }
+
+// ExternalIdentity - user identity confirmed by single sign-on provider
+type ExternalIdentity struct {
+	Provider string
+	Subject  string
+	Login    string
+	Role     UserRole
+}
+
+type LoginForm struct {
+	Login    string `json:"login" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type TokenKind string
+
+const (
+	SessionToken   TokenKind = ""
+	ChallengeToken TokenKind = "2fa"
+	APIToken       TokenKind = "api"
+)
+
+type TokenScope string
+
+const (
+	ScopeAppsRead    TokenScope = "apps:read"
+	ScopeAppsWrite   TokenScope = "apps:write"
+	ScopeSystemRead  TokenScope = "system:read"
+	ScopeSystemWrite TokenScope = "system:write"
+	ScopeAgentRead   TokenScope = "agent:read"
+	ScopeAgentWrite  TokenScope = "agent:write"
+	ScopeSSHRead     TokenScope = "ssh:read"
+	ScopeSSHWrite    TokenScope = "ssh:write"
+	ScopeSSHConnect  TokenScope = "ssh:connect"
+	ScopeSSHFiles    TokenScope = "ssh:files"
+	ScopeSSHExec     TokenScope = "ssh:exec"
+	ScopeAuditRead   TokenScope = "audit:read"
+)
+
+type SessionClaims struct {
+	Kind      TokenKind `json:"typ,omitempty"`
+	SessionID string    `json:"sid"`
+	UserID    uint      `json:"uid"`
+	Login     string    `json:"login"`
+	IssuedAt  int64     `json:"iat"`
+	ExpiresAt int64     `json:"exp"`
+
+	Role           UserRole     `json:"-"`
+	PasswordChange bool         `json:"-"`
+	Scopes         []TokenScope `json:"-"`
+}
+
+// HasScope - reports that API token claims contain any of scopes.
+// Session tokens are not limited by scopes
+func (c *SessionClaims) HasScope(scopes ...TokenScope) bool {
+
+	if c.Kind != APIToken {
+		return true
+	}
+
+	for _, required := range scopes {
+		for _, scope := range c.Scopes {
+			if scope == required {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+type TokenResponse struct {
+	Token          string `json:"token,omitempty"`
+	Challenge      string `json:"challenge,omitempty"`
+	TwoFactor      bool   `json:"two-factor"`
+	ExpiresAt      int64  `json:"expires"`
+	PasswordChange bool   `json:"password-change"`
+}
+
+type CurrentUserResponse struct {
+	ID             uint     `json:"id"`
+	Login          string   `json:"login"`
+	Role           UserRole `json:"role"`
+	TwoFactor      bool     `json:"two-factor"`
+	ExpiresAt      int64    `json:"expires"`
+	PasswordChange bool     `json:"password-change"`
+}
+
+type UserCreateForm struct {
+	Login    string `json:"login" validate:"required,min=3,max=32,alphanum"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+	Role     string `json:"role" validate:"omitempty,oneof=viewer operator admin"`
+}
+
+type UserRoleForm struct {
+	Role string `json:"role" validate:"required,oneof=viewer operator admin"`
+}
+
+type UserRenameForm struct {
+	Login string `json:"login" validate:"required,min=3,max=32,alphanum"`
+}
+
+type UserPasswordForm struct {
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+type OwnPasswordForm struct {
+	Current  string `json:"current" validate:"required"`
+	Password string `json:"password" validate:"required,min=8,max=72,nefield=Current"`
+}
+
+type SecondFactorForm struct {
+	Challenge string `json:"challenge" validate:"required"`
+	Code      string `json:"code" validate:"required_without=Recovery"`
+	Recovery  string `json:"recovery" validate:"required_without=Code"`
+}
+
+type TOTPCodeForm struct {
+	Code string `json:"code" validate:"required,numeric"`
+}
+
+type TOTPDisableForm struct {
+	Password string `json:"password" validate:"required"`
+}
+
+type TOTPSetupResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type RecoveryCodesResponse struct {
+	Codes []string `json:"recovery-codes"`
+}
+
+type APITokenForm struct {
+	Name    string   `json:"name" validate:"required,max=64"`
+	Scopes  []string `json:"scopes" validate:"required,min=1,dive,oneof=apps:read apps:write system:read system:write agent:read agent:write ssh:read ssh:write ssh:connect ssh:files ssh:exec audit:read"`
+	Expires int64    `json:"expires" validate:"omitempty,gt=0"`
+}
+
+type APITokenInfo struct {
+	ID       uint         `json:"id"`
+	UserID   uint         `json:"user-id"`
+	Name     string       `json:"name"`
+	Prefix   string       `json:"prefix"`
+	Scopes   []TokenScope `json:"scopes"`
+	Created  int64        `json:"created"`
+	Expires  int64        `json:"expires,omitempty"`
+	LastUsed int64        `json:"last-used,omitempty"`
+}
+
+type APITokenResponse struct {
+	APITokenInfo
+	Token string `json:"token"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import "encoding/json"
+
+// AgentCommand - command sent to agent over MQTT
+type AgentCommand string
+
+const (
+	CommandRefresh     AgentCommand = "refresh"
+	CommandInterval    AgentCommand = "set-interval"
+	CommandProcesses   AgentCommand = "processes"
+	CommandUnitRestart AgentCommand = "unit-restart"
+)
+
+type RequestFormAgentCommand struct {
+	Command  AgentCommand `json:"command" validate:"required,oneof=refresh set-interval processes unit-restart"`
+	Interval string       `json:"interval" validate:"required_if=Command set-interval"`
+	Unit     string       `json:"unit" validate:"required_if=Command unit-restart,omitempty,max=256,excludesall=/ "`
+	Timeout  string       `json:"timeout"`
+}
+
+type AgentCommandArgs struct {
+	Interval string `json:"interval,omitempty"`
+	Unit     string `json:"unit,omitempty"`
+}
+
+// AgentCommandRequest - message published to agent request topic.
+// Agent must answer to response topic with the same correlation id before deadline
+type AgentCommandRequest struct {
+	ID            string           `json:"id"`
+	Command       AgentCommand     `json:"command"`
+	Args          AgentCommandArgs `json:"args"`
+	ResponseTopic string           `json:"response-topic"`
+	Deadline      int64            `json:"deadline"`
+}
+
+// AgentCommandResponse - agent answer to command. Agent with secret signs it like stats payload
+type AgentCommandResponse struct {
+	ID        string          `json:"id"`
+	HostID    string          `json:"host-id"`
+	OK        bool            `json:"ok"`
+	Error     string          `json:"error,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	Timestamp int64           `json:"timestamp,omitempty"`
+	Signature string          `json:"signature,omitempty"`
+}
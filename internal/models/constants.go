@@ -4,5 +4,8 @@ type ConstantValue string
 
 const (
 	MESSAGE_BROKER_CONTEXT_KEY ConstantValue = "BROKER"
+	MESSAGE_SENDER_CONTEXT_KEY ConstantValue = "BROKER_SENDER"
 	DATABASE_CONTEXT_KEY       ConstantValue = "SQL_DATABASE"
+	SESSION_CONTEXT_KEY        ConstantValue = "USER_SESSION"
+	SECRETS_CONTEXT_KEY        ConstantValue = "SECRETS_KEEPER"
 )
@@ -3,8 +3,43 @@ package models
 import (
 	"encoding/json"
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// Tables - returns all repository tables for migration
+func Tables() []any {
+	return []any{
+		new(AppsTopicT),
+		new(AppsInstancesT),
+
+		new(DeskyUserT),
+		new(DeskySessionT),
+		new(DeskyRecoveryCodeT),
+		new(DeskyAPITokenT),
+		new(DeskyLoginLockT),
+
+		new(AuditEventT),
+
+		new(ExporterInfoT),
+
+		new(AgentT),
+		new(AgentMetricT),
+		new(AlertRuleT),
+
+		new(SSHSystemTypesT),
+		new(SSHKeyT),
+		new(SSHSecureT),
+		new(SSHCredentialsT),
+		new(SSHHostTagT),
+		new(SSHHostKeyT),
+		new(SSHRecordingT),
+		new(SSHTunnelT),
+	}
+}
+
 // Apps service repository tables ===========================
 
 type AppsTopicT struct {
@@ -26,15 +61,173 @@ type AppsInstancesT struct {
 // User service repository tables ===========================
 
 type DeskyUserT struct {
-	ID       uint   `gorm:"primaryKey"`
-	Login    string `gorm:"uniqueIndex"`
-	Password string
+	ID                 uint   `gorm:"primaryKey"`
+	Login              string `gorm:"uniqueIndex"`
+	Password           string
+	Role               UserRole `gorm:"default:viewer"`
+	MustChangePassword bool
+
+	TOTPSecret      string
+	TOTPEnabled     bool
+	TOTPLastCounter uint64
+
+	// Provider and Subject - identity of single sign-on users. Empty for local users
+	Provider string `gorm:"index:idx_user_external,priority:1"`
+	Subject  string `gorm:"index:idx_user_external,priority:2"`
 }
 
-func NewDeskyUserT(login, password string) *DeskyUserT {
+func NewDeskyUserT(login, password string, role UserRole) *DeskyUserT {
 	return &DeskyUserT{
 		Login:    login,
 		Password: password,
+		Role:     role,
+	}
+}
+
+// NewExternalDeskyUserT - creates single sign-on user without password
+func NewExternalDeskyUserT(identity *ExternalIdentity) *DeskyUserT {
+	return &DeskyUserT{
+		Login:    identity.Login,
+		Role:     identity.Role,
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+	}
+}
+
+type DeskyRecoveryCodeT struct {
+	ID     uint       `gorm:"primaryKey"`
+	UserID uint       `gorm:"index"`
+	User   DeskyUserT `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Hash   string
+}
+
+type DeskySessionT struct {
+	ID         string     `gorm:"primaryKey"`
+	UserID     uint       `gorm:"index"`
+	User       DeskyUserT `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	RemoteAddr string
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+}
+
+func NewDeskySessionT(id string, userID uint, remote string, expires time.Time) *DeskySessionT {
+	return &DeskySessionT{
+		ID:         id,
+		UserID:     userID,
+		RemoteAddr: remote,
+		ExpiresAt:  expires,
+	}
+}
+
+type DeskyAPITokenT struct {
+	ID         uint       `gorm:"primaryKey"`
+	UserID     uint       `gorm:"index"`
+	User       DeskyUserT `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Name       string
+	Prefix     string
+	Hash       string `gorm:"uniqueIndex"`
+	Scopes     string
+	CreatedAt  time.Time
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+}
+
+func NewDeskyAPITokenT(userID uint, name, prefix, hash string, scopes []TokenScope, expires *time.Time) *DeskyAPITokenT {
+
+	values := make([]string, len(scopes))
+	for i, scope := range scopes {
+		values[i] = string(scope)
+	}
+
+	return &DeskyAPITokenT{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		Hash:      hash,
+		Scopes:    strings.Join(values, ","),
+		ExpiresAt: expires,
+	}
+}
+
+func (t *DeskyAPITokenT) ScopesList() []TokenScope {
+
+	if t.Scopes == "" {
+		return []TokenScope{}
+	}
+
+	values := strings.Split(t.Scopes, ",")
+	scopes := make([]TokenScope, len(values))
+	for i, value := range values {
+		scopes[i] = TokenScope(value)
+	}
+
+	return scopes
+}
+
+func (t *DeskyAPITokenT) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && now.After(*t.ExpiresAt)
+}
+
+func (t *DeskyAPITokenT) Info() APITokenInfo {
+
+	info := APITokenInfo{
+		ID:      t.ID,
+		UserID:  t.UserID,
+		Name:    t.Name,
+		Prefix:  t.Prefix,
+		Scopes:  t.ScopesList(),
+		Created: t.CreatedAt.Unix(),
+	}
+
+	if t.ExpiresAt != nil {
+		info.Expires = t.ExpiresAt.Unix()
+	}
+
+	if t.LastUsedAt != nil {
+		info.LastUsed = t.LastUsedAt.Unix()
+	}
+
+	return info
+}
+
+type DeskyLoginLockT struct {
+	ID          uint   `gorm:"primaryKey"`
+	Key         string `gorm:"uniqueIndex"`
+	Level       int
+	LockedUntil time.Time
+}
+
+func NewDeskyLoginLockT(key string) *DeskyLoginLockT {
+	return &DeskyLoginLockT{
+		Key: key,
+	}
+}
+
+// Audit repository tables ===========================
+
+type AuditEventT struct {
+	ID         uint      `gorm:"primaryKey"`
+	CreatedAt  time.Time `gorm:"index"`
+	Actor      string    `gorm:"index"`
+	UserID     uint
+	RemoteAddr string
+	Action     AuditAction `gorm:"index"`
+	Target     string
+	Result     AuditResult
+	Details    string
+}
+
+func (t *AuditEventT) Event() AuditEvent {
+	return AuditEvent{
+		ID:        t.ID,
+		Timestamp: t.CreatedAt.Unix(),
+		Actor:     t.Actor,
+		UserID:    t.UserID,
+		IP:        t.RemoteAddr,
+		Action:    t.Action,
+		Target:    t.Target,
+		Result:    t.Result,
+		Details:   t.Details,
 	}
 }
 
@@ -57,6 +250,138 @@ func (t *ExporterInfoT) ResolveExtra() map[ExporterExtraField]any {
 	return extra
 }
 
+// Agent metrics repository tables ===========================
+
+// AgentMetricT - aggregated values of agent metric in time bucket.
+// Raw samples are stored as buckets of single value
+type AgentMetricT struct {
+	ID     uint       `gorm:"primaryKey"`
+	HostID string     `gorm:"uniqueIndex:idx_agent_metric_point"`
+	Metric string     `gorm:"uniqueIndex:idx_agent_metric_point"`
+	Tier   MetricTier `gorm:"uniqueIndex:idx_agent_metric_point"`
+	// Time - unix time of bucket start
+	Time int64 `gorm:"uniqueIndex:idx_agent_metric_point"`
+
+	Min   float64
+	Max   float64
+	Avg   float64
+	Count int64
+}
+
+func (t *AgentMetricT) Point() MetricPoint {
+	return MetricPoint{
+		Time:  t.Time,
+		Min:   t.Min,
+		Max:   t.Max,
+		Avg:   t.Avg,
+		Count: t.Count,
+	}
+}
+
+// AlertRuleT - threshold rule evaluated against agents and desky host metrics
+type AlertRuleT struct {
+	ID   uint   `gorm:"primaryKey"`
+	Name string `gorm:"uniqueIndex"`
+	// Host - agent host id, 'local' for desky host or empty for every host
+	Host       string
+	Metric     string
+	Operator   AlertOperator
+	Threshold  float64
+	Hysteresis float64
+	// For - seconds condition must be met before alert is fired
+	For int64
+	// Notifiers - comma separated names of notifiers, empty for all notifiers
+	Notifiers string
+	Enabled   bool
+	CreatedAt time.Time
+}
+
+func (t *AlertRuleT) NotifierList() []string {
+	if t.Notifiers == "" {
+		return []string{}
+	}
+	return strings.Split(t.Notifiers, ",")
+}
+
+// Matches - reports that rule is evaluated for host
+func (t *AlertRuleT) Matches(host string) bool {
+	return t.Enabled && (t.Host == "" || t.Host == host)
+}
+
+// Breached - reports that value meets rule condition
+func (t *AlertRuleT) Breached(value float64) bool {
+	switch t.Operator {
+	case AlertAbove:
+		return value > t.Threshold
+	case AlertAboveOrEqual:
+		return value >= t.Threshold
+	case AlertBelow:
+		return value < t.Threshold
+	case AlertBelowOrEqual:
+		return value <= t.Threshold
+	}
+	return false
+}
+
+// Cleared - reports that value left threshold by hysteresis distance
+func (t *AlertRuleT) Cleared(value float64) bool {
+	switch t.Operator {
+	case AlertAbove, AlertAboveOrEqual:
+		return value < t.Threshold-t.Hysteresis || (t.Hysteresis == 0 && !t.Breached(value))
+	case AlertBelow, AlertBelowOrEqual:
+		return value > t.Threshold+t.Hysteresis || (t.Hysteresis == 0 && !t.Breached(value))
+	}
+	return true
+}
+
+func (t *AlertRuleT) Object() AlertRuleObject {
+	return AlertRuleObject{
+		ID:         t.ID,
+		Name:       t.Name,
+		Host:       t.Host,
+		Metric:     t.Metric,
+		Operator:   t.Operator,
+		Threshold:  t.Threshold,
+		Hysteresis: t.Hysteresis,
+		For:        (time.Duration(t.For) * time.Second).String(),
+		Notifiers:  t.NotifierList(),
+		Enabled:    t.Enabled,
+		CreatedAt:  t.CreatedAt.Unix(),
+	}
+}
+
+// AgentT - registered agent. Stats of agents which aren't approved are dropped
+type AgentT struct {
+	ID     uint   `gorm:"primaryKey"`
+	HostID string `gorm:"uniqueIndex"`
+	Name   string
+	// Tags - comma separated tags
+	Tags      string
+	FirstSeen time.Time
+	Approved  bool `gorm:"index"`
+	// Secret - encrypted key of payload signatures. Empty secret allows unsigned payloads
+	Secret string
+}
+
+func (t *AgentT) TagList() []string {
+	if t.Tags == "" {
+		return []string{}
+	}
+	return strings.Split(t.Tags, ",")
+}
+
+func (t *AgentT) Object() AgentObject {
+	return AgentObject{
+		ID:        t.ID,
+		HostID:    t.HostID,
+		Name:      t.Name,
+		Tags:      t.TagList(),
+		FirstSeen: t.FirstSeen.Unix(),
+		Approved:  t.Approved,
+		Signed:    t.Secret != "",
+	}
+}
+
 // SSHLander service repository tables ===========================
 
 type SSHCredentialsT struct {
@@ -71,6 +396,43 @@ type SSHCredentialsT struct {
 
 	SecurityID uint
 	Security   SSHSecureT `gorm:"foreignKey:SecurityID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	// JumpHostID - stored host which connection goes through. Nil means direct connection
+	JumpHostID *uint `gorm:"index"`
+
+	Name        string
+	Description string
+	// Group - folder path of host, nested folders are separated by '/'
+	Group string        `gorm:"column:host_group;index"`
+	Tags  []SSHHostTagT `gorm:"foreignKey:CredentialsID"`
+}
+
+// TagList - returns host tags as strings
+func (c *SSHCredentialsT) TagList() []string {
+
+	tags := make([]string, len(c.Tags))
+	for i := range c.Tags {
+		tags[i] = c.Tags[i].Tag
+	}
+
+	return tags
+}
+
+// SSHHostTagT - free-form tag of stored host
+type SSHHostTagT struct {
+	ID            uint   `gorm:"primaryKey"`
+	CredentialsID uint   `gorm:"index"`
+	Tag           string `gorm:"index"`
+}
+
+func MakeSSHHostTags(tags []string) []SSHHostTagT {
+
+	result := make([]SSHHostTagT, len(tags))
+	for i, tag := range tags {
+		result[i] = SSHHostTagT{Tag: tag}
+	}
+
+	return result
 }
 
 func (c *SSHCredentialsT) ValueUser() string {
@@ -78,7 +440,7 @@ func (c *SSHCredentialsT) ValueUser() string {
 }
 
 func (c *SSHCredentialsT) UsePrivateKey() bool {
-	return c.Security.PrivateKeyUse
+	return c.Security.PrivateKeyUse || c.Security.Key != nil
 }
 
 func (c *SSHCredentialsT) Password() string {
@@ -86,9 +448,26 @@ func (c *SSHCredentialsT) Password() string {
 }
 
 func (c *SSHCredentialsT) PrivateKey() []byte {
+	if c.Security.Key != nil {
+		return []byte(c.Security.Key.PrivateKey)
+	}
 	return []byte(c.Security.PrivateKey)
 }
 
+func (c *SSHCredentialsT) Passphrase() []byte {
+	if c.Security.Key != nil {
+		return []byte(c.Security.Key.Passphrase)
+	}
+	return []byte(c.Security.Passphrase)
+}
+
+func (c *SSHCredentialsT) Certificate() []byte {
+	if c.Security.Key != nil {
+		return []byte(c.Security.Key.Certificate)
+	}
+	return []byte(c.Security.Certificate)
+}
+
 func (c *SSHCredentialsT) Socket() string {
 	return fmt.Sprintf("%s:%v", c.Host, c.Port)
 }
@@ -109,6 +488,14 @@ type SSHSecureT struct {
 	PrivateKeyUse bool
 	Password      string
 	PrivateKey    string
+	Passphrase    string
+
+	// Certificate - OpenSSH user certificate of private key in authorized_keys format
+	Certificate string
+
+	// KeyID - shared key used instead of own private key
+	KeyID *uint    `gorm:"index"`
+	Key   *SSHKeyT `gorm:"foreignKey:KeyID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;"`
 }
 
 func MakeSSHSecureT(
@@ -122,3 +509,134 @@ func MakeSSHSecureT(
 		PrivateKey:    key,
 	}
 }
+
+// SSHKeyT - named private key stored once and used by many hosts
+type SSHKeyT struct {
+	ID          uint   `gorm:"primaryKey"`
+	Name        string `gorm:"uniqueIndex"`
+	KeyType     string
+	Fingerprint string
+	PrivateKey  string
+	Passphrase  string
+	Certificate string
+	CreatedAt   time.Time
+}
+
+// SSHHostKeyT - trusted host key of stored SSH host.
+// Pending key is offered key that doesn't match trusted one and waits for review
+type SSHHostKeyT struct {
+	ID            uint            `gorm:"primaryKey"`
+	CredentialsID uint            `gorm:"uniqueIndex"`
+	Credentials   SSHCredentialsT `gorm:"foreignKey:CredentialsID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	KeyType     string
+	Key         string
+	Fingerprint string
+	Source      HostKeySource
+	TrustedAt   time.Time
+
+	PendingType        string
+	PendingKey         string
+	PendingFingerprint string
+	PendingSeenAt      *time.Time
+}
+
+func (k *SSHHostKeyT) HasPending() bool {
+	return k.PendingKey != ""
+}
+
+// Accept - replaces trusted key with pending one
+func (k *SSHHostKeyT) Accept() {
+	k.KeyType, k.Key, k.Fingerprint = k.PendingType, k.PendingKey, k.PendingFingerprint
+	k.Source = HostKeyAccepted
+	k.TrustedAt = time.Now()
+
+	k.PendingType, k.PendingKey, k.PendingFingerprint = "", "", ""
+	k.PendingSeenAt = nil
+}
+
+func (k *SSHHostKeyT) Object() SSHHostKeyObject {
+
+	obj := SSHHostKeyObject{
+		ID:          k.CredentialsID,
+		Host:        fmt.Sprintf("%s@%s", k.Credentials.Username, k.Credentials.Socket()),
+		KeyType:     k.KeyType,
+		Fingerprint: k.Fingerprint,
+		Source:      k.Source,
+		TrustedAt:   k.TrustedAt.Unix(),
+	}
+
+	if k.HasPending() {
+		obj.Pending = &SSHPendingKeyObject{
+			KeyType:     k.PendingType,
+			Fingerprint: k.PendingFingerprint,
+		}
+		if k.PendingSeenAt != nil {
+			obj.Pending.SeenAt = k.PendingSeenAt.Unix()
+		}
+	}
+
+	return obj
+}
+
+// SSHRecordingT - index of terminal session recording file.
+// Recordings are kept after host deletion, so host is stored as text
+type SSHRecordingT struct {
+	ID            uint   `gorm:"primaryKey"`
+	SessionID     string `gorm:"uniqueIndex"`
+	UserID        uint
+	Actor         string `gorm:"index"`
+	CredentialsID uint   `gorm:"index"`
+	Host          string
+	File          string
+
+	StartedAt time.Time `gorm:"index"`
+	EndedAt   *time.Time
+	Size      int64
+}
+
+func (r *SSHRecordingT) Object() SSHRecordingObject {
+
+	obj := SSHRecordingObject{
+		ID:        r.ID,
+		SessionID: r.SessionID,
+		Actor:     r.Actor,
+		HostID:    r.CredentialsID,
+		Host:      r.Host,
+		StartedAt: r.StartedAt.Unix(),
+		Size:      r.Size,
+	}
+
+	if r.EndedAt != nil {
+		obj.EndedAt = r.EndedAt.Unix()
+	}
+
+	return obj
+}
+
+// SSHTunnelT - port forwarding through stored host
+type SSHTunnelT struct {
+	ID            uint   `gorm:"primaryKey"`
+	Name          string `gorm:"uniqueIndex"`
+	CredentialsID uint   `gorm:"index"`
+	Type          SSHTunnelType
+
+	BindAddress string
+	BindPort    uint16
+	TargetHost  string
+	TargetPort  uint16
+
+	// Enabled - tunnel is started and restored after restart
+	Enabled   bool
+	CreatedAt time.Time
+}
+
+// Bind - address listened by tunnel. Local tunnels listen on desky host, remote tunnels on ssh host
+func (t *SSHTunnelT) Bind() string {
+	return net.JoinHostPort(t.BindAddress, strconv.Itoa(int(t.BindPort)))
+}
+
+// Target - address connections are forwarded to
+func (t *SSHTunnelT) Target() string {
+	return net.JoinHostPort(t.TargetHost, strconv.Itoa(int(t.TargetPort)))
+}
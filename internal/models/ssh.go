@@ -32,22 +32,128 @@ type RequestFormSSH struct {
 	Port   uint16 `json:"port" validate:"port"`
 	Host   string `json:"host" validate:"required"`
 
-	User          string `json:"user" validate:"required"`
-	PrivateKeyUse bool   `json:"private-key-use" validate:"boolean"`
+	User string `json:"user" validate:"required"`
 
-	Password   string `json:"password" validate:"required_if=PrivateKeyUse false"`
-	PrivateKey string `json:"private-key" validate:"required_if=PrivateKeyUse true"`
+	RequestFormSSHAuth
+	RequestFormInventory
+
+	JumpHost uint `json:"jump-host"`
+}
+
+// RequestFormSSHAuth - host authentication settings
+type RequestFormSSHAuth struct {
+	PrivateKeyUse bool `json:"private-key-use" validate:"boolean"`
+
+	Password   string `json:"password" validate:"required_if=PrivateKeyUse false KeyID 0"`
+	PrivateKey string `json:"private-key" validate:"required_if=PrivateKeyUse true KeyID 0"`
+	Passphrase string `json:"passphrase"`
+	// Certificate - OpenSSH user certificate of private key
+	Certificate string `json:"certificate"`
+	// KeyID - shared key used instead of private key
+	KeyID uint `json:"key-id"`
+}
+
+// RequestFormInventory - host description used to find and group hosts
+type RequestFormInventory struct {
+	Name        string `json:"name" validate:"max=64"`
+	Description string `json:"description" validate:"max=512"`
+	// Group - folder path, nested folders are separated by '/'
+	Group string   `json:"group" validate:"max=128"`
+	Tags  []string `json:"tags" validate:"max=32,dive,required,max=32"`
+}
+
+type RequestFormSSHEdit struct {
+	System string `json:"os" validate:"required"`
+	Port   uint16 `json:"port" validate:"port"`
+	Host   string `json:"host" validate:"required"`
+
+	User string `json:"user" validate:"required"`
+
+	RequestFormInventory
+
+	JumpHost uint `json:"jump-host"`
+
+	// Auth - new authentication settings. Stored ones are kept when it's omitted
+	Auth *RequestFormSSHAuth `json:"auth"`
+}
+
+type RequestFormSSHImport struct {
+	// Config - content of OpenSSH client config file
+	Config string `json:"config" validate:"required"`
+	System string `json:"os"`
+	// User - used for hosts without User option
+	User string `json:"user"`
+	// Password - used for hosts without resolved identity
+	Password string `json:"password"`
+	// Identities - shared key ids by IdentityFile values. Without mapping key with file base name is used
+	Identities map[string]uint `json:"identities"`
+
+	Group string   `json:"group" validate:"max=128"`
+	Tags  []string `json:"tags" validate:"max=32,dive,required,max=32"`
+}
+
+type ResponseSSHImport struct {
+	Imported []SSHImportedObject `json:"imported"`
+	Skipped  []SSHImportedObject `json:"skipped"`
+}
+
+type SSHImportedObject struct {
+	Alias  string `json:"alias"`
+	ID     uint   `json:"id,omitempty"`
+	Host   string `json:"host,omitempty"`
+	Reason string `json:"reason,omitempty"`
 }
 
 type ResponseCreateSSH struct {
+	ID            uint   `json:"id"`
 	PrivateKeyUse bool   `json:"private-key-use"`
 	Target        string `json:"target"`
 }
 
 type SSHInstanceObject struct {
-	ID            int    `json:"id"`
-	HostString    string `json:"host"`
-	PrivateKeyUse bool   `json:"private-key-use"`
+	ID            int       `json:"id"`
+	HostString    string    `json:"host"`
+	Name          string    `json:"name,omitempty"`
+	User          string    `json:"user"`
+	Address       string    `json:"address"`
+	Port          uint16    `json:"port"`
+	System        SSHtypeOS `json:"os"`
+	Description   string    `json:"description,omitempty"`
+	Group         string    `json:"group,omitempty"`
+	Tags          []string  `json:"tags"`
+	PrivateKeyUse bool      `json:"private-key-use"`
+	Certificate   bool      `json:"certificate"`
+	KeyID         uint      `json:"key-id,omitempty"`
+	JumpHost      uint      `json:"jump-host,omitempty"`
+}
+
+// ====================================================
+
+type RequestFormKey struct {
+	Name string `json:"name" validate:"required,max=64"`
+	// PrivateKey - can be omitted on update to keep stored key
+	PrivateKey  string `json:"private-key"`
+	Passphrase  string `json:"passphrase"`
+	Certificate string `json:"certificate"`
+}
+
+type SSHKeyObject struct {
+	ID          uint                  `json:"id"`
+	Name        string                `json:"name"`
+	KeyType     string                `json:"key-type"`
+	Fingerprint string                `json:"fingerprint"`
+	Certificate *SSHCertificateObject `json:"certificate,omitempty"`
+	Hosts       int64                 `json:"hosts"`
+	CreatedAt   int64                 `json:"created-at"`
+}
+
+type SSHCertificateObject struct {
+	KeyID       string   `json:"key-id"`
+	Serial      uint64   `json:"serial"`
+	Principals  []string `json:"principals"`
+	Authority   string   `json:"authority"`
+	ValidAfter  int64    `json:"valid-after,omitempty"`
+	ValidBefore int64    `json:"valid-before,omitempty"`
 }
 
 type SSHTestObject struct {
@@ -57,11 +163,179 @@ type SSHTestObject struct {
 
 // ====================================================
 
+type HostKeySource string
+
+const (
+	HostKeyFirstUse   HostKeySource = "first-use"
+	HostKeyKnownHosts HostKeySource = "known-hosts"
+	HostKeyAccepted   HostKeySource = "accepted"
+)
+
+type SSHHostKeyObject struct {
+	ID          uint                 `json:"id"`
+	Host        string               `json:"host"`
+	KeyType     string               `json:"key-type"`
+	Fingerprint string               `json:"fingerprint"`
+	Source      HostKeySource        `json:"source"`
+	TrustedAt   int64                `json:"trusted-at"`
+	Pending     *SSHPendingKeyObject `json:"pending,omitempty"`
+}
+
+type SSHPendingKeyObject struct {
+	KeyType     string `json:"key-type"`
+	Fingerprint string `json:"fingerprint"`
+	SeenAt      int64  `json:"seen-at"`
+}
+
+type RequestFormKnownHosts struct {
+	KnownHosts string `json:"known-hosts" validate:"required"`
+	Replace    bool   `json:"replace" validate:"boolean"`
+}
+
+type ResponseKnownHostsImport struct {
+	Imported []uint `json:"imported"`
+	Skipped  []uint `json:"skipped"`
+}
+
+type SSHRecordingObject struct {
+	ID        uint   `json:"id"`
+	SessionID string `json:"session-id"`
+	Actor     string `json:"actor"`
+	HostID    uint   `json:"host-id"`
+	Host      string `json:"host"`
+	StartedAt int64  `json:"started-at"`
+	EndedAt   int64  `json:"ended-at,omitempty"`
+	Size      int64  `json:"size"`
+}
+
+type SSHSessionObject struct {
+	ID        string `json:"id"`
+	HostID    uint   `json:"host-id"`
+	Host      string `json:"host"`
+	Owner     string `json:"owner"`
+	StartedAt int64  `json:"started-at"`
+	Clients   int    `json:"clients"`
+	Writers   int    `json:"writers"`
+}
+
+type SSHFileObject struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Mode     string `json:"mode"`
+	Perm     string `json:"perm"`
+	Dir      bool   `json:"dir"`
+	Link     bool   `json:"link"`
+	Modified int64  `json:"modified"`
+}
+
+type RequestFormRename struct {
+	From string `json:"from" validate:"required"`
+	To   string `json:"to" validate:"required"`
+}
+
+type RequestFormMkdir struct {
+	Path string `json:"path" validate:"required"`
+}
+
+type RequestFormChmod struct {
+	Path string `json:"path" validate:"required"`
+	// Mode - octal permission bits, e.g. '0644'
+	Mode string `json:"mode" validate:"required"`
+}
+
+type RequestFormExec struct {
+	Command string `json:"command" validate:"required,max=8192"`
+	// Timeout - command timeout in seconds, zero means default timeout
+	Timeout int `json:"timeout" validate:"gte=0"`
+}
+
+type RequestFormExecHosts struct {
+	Hosts   []int  `json:"hosts" validate:"required,min=1,dive,gt=0"`
+	Command string `json:"command" validate:"required,max=8192"`
+	Timeout int    `json:"timeout" validate:"gte=0"`
+}
+
+type SSHExecObject struct {
+	ID        int    `json:"id"`
+	Host      string `json:"host,omitempty"`
+	Stdout    string `json:"stdout"`
+	Stderr    string `json:"stderr"`
+	ExitCode  int    `json:"exit-code"`
+	Signal    string `json:"signal,omitempty"`
+	Duration  int64  `json:"duration-ms"`
+	TimedOut  bool   `json:"timed-out"`
+	Truncated bool   `json:"truncated"`
+	Error     string `json:"error,omitempty"`
+}
+
+type SSHTunnelType string
+
+const (
+	// TunnelLocal - listens on desky host and forwards to target from ssh host
+	TunnelLocal SSHTunnelType = "local"
+	// TunnelRemote - listens on ssh host and forwards to target from desky host
+	TunnelRemote SSHTunnelType = "remote"
+)
+
+type SSHTunnelState string
+
+const (
+	TunnelStopped      SSHTunnelState = "stopped"
+	TunnelConnecting   SSHTunnelState = "connecting"
+	TunnelRunning      SSHTunnelState = "running"
+	TunnelReconnecting SSHTunnelState = "reconnecting"
+)
+
+type RequestFormTunnel struct {
+	Name        string        `json:"name" validate:"required,max=64"`
+	HostID      uint          `json:"host-id" validate:"required,gt=0"`
+	Type        SSHTunnelType `json:"type" validate:"required,oneof=local remote"`
+	BindAddress string        `json:"bind-address" validate:"omitempty,ip"`
+	BindPort    uint16        `json:"bind-port" validate:"required,port"`
+	TargetHost  string        `json:"target-host" validate:"required,hostname_rfc1123|ip"`
+	TargetPort  uint16        `json:"target-port" validate:"required,port"`
+}
+
+type SSHTunnelObject struct {
+	ID          uint           `json:"id"`
+	Name        string         `json:"name"`
+	HostID      uint           `json:"host-id"`
+	Type        SSHTunnelType  `json:"type"`
+	Bind        string         `json:"bind"`
+	Target      string         `json:"target"`
+	Enabled     bool           `json:"enabled"`
+	State       SSHTunnelState `json:"state"`
+	Error       string         `json:"error,omitempty"`
+	Since       int64          `json:"since,omitempty"`
+	Reconnects  int64          `json:"reconnects"`
+	Connections int64          `json:"connections"`
+	Accepted    int64          `json:"accepted"`
+	BytesIn     int64          `json:"bytes-in"`
+	BytesOut    int64          `json:"bytes-out"`
+}
+
+type RecordingFilter struct {
+	Actor  string
+	HostID uint
+
+	Offset int
+	Limit  int
+}
+
+// ====================================================
+
 type SSHResponseWS struct {
 	Line string `json:"line,omitempty"`
 	Err  string `json:"err,omitempty"`
 }
 
+// SSHAttachWS - first text message of raw terminal protocol
+type SSHAttachWS struct {
+	Session string `json:"session"`
+	Access  string `json:"access"`
+}
+
 type SSHRequestWS struct {
 	Command string `json:"command"`
 }
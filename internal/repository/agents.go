@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/pkg/storage"
+)
+
+type AgentsRepository struct {
+	DefaultRepository
+	cipher SecretCipher
+}
+
+func NewAgentsRepository(db *storage.DB, c SecretCipher) *AgentsRepository {
+	return &AgentsRepository{
+		DefaultRepository: NewDefaultRepository(db),
+		cipher:            c,
+	}
+}
+
+func (r *AgentsRepository) CreateAgent(agent *models.AgentT) error {
+
+	data, err := r.encrypt(agent)
+	if err != nil {
+		return err
+	}
+
+	if err := r.db.Create(data).Error; err != nil {
+		return err
+	}
+
+	agent.ID = data.ID
+
+	return nil
+}
+
+func (r *AgentsRepository) UpdateAgent(agent *models.AgentT) error {
+
+	data, err := r.encrypt(agent)
+	if err != nil {
+		return err
+	}
+
+	return r.db.Save(data).Error
+}
+
+func (r *AgentsRepository) AgentById(id uint) (*models.AgentT, error) {
+
+	agent := new(models.AgentT)
+
+	err := r.db.First(agent, "ID = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if agent.Secret, err = r.cipher.Decrypt(agent.Secret); err != nil {
+		return nil, err
+	}
+
+	return agent, nil
+}
+
+func (r *AgentsRepository) Agents() ([]models.AgentT, error) {
+
+	list := []models.AgentT{}
+
+	if err := r.db.Order("host_id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range list {
+		var err error
+		if list[i].Secret, err = r.cipher.Decrypt(list[i].Secret); err != nil {
+			return nil, err
+		}
+	}
+
+	return list, nil
+}
+
+func (r *AgentsRepository) DeleteAgent(id uint) error {
+	return r.db.Unscoped().Delete(new(models.AgentT), "ID = ?", id).Error
+}
+
+func (r *AgentsRepository) encrypt(agent *models.AgentT) (*models.AgentT, error) {
+
+	secret, err := r.cipher.Encrypt(agent.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	data := *agent
+	data.Secret = secret
+
+	return &data, nil
+}
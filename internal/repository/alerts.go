@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/pkg/storage"
+)
+
+type AlertsRepository struct {
+	DefaultRepository
+}
+
+func NewAlertsRepository(db *storage.DB) *AlertsRepository {
+	return &AlertsRepository{
+		NewDefaultRepository(db),
+	}
+}
+
+func (r *AlertsRepository) CreateRule(rule *models.AlertRuleT) error {
+	return r.db.Create(rule).Error
+}
+
+func (r *AlertsRepository) UpdateRule(rule *models.AlertRuleT) error {
+	return r.db.Save(rule).Error
+}
+
+func (r *AlertsRepository) RuleById(id uint) (*models.AlertRuleT, error) {
+
+	rule := new(models.AlertRuleT)
+
+	if err := r.db.First(rule, "ID = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+// RuleByName - returns rule with name. Not found rule is nil without error
+func (r *AlertsRepository) RuleByName(name string) (*models.AlertRuleT, error) {
+
+	list := []models.AlertRuleT{}
+
+	if err := r.db.Where("name = ?", name).Limit(1).Find(&list).Error; err != nil {
+		return nil, err
+	}
+
+	if len(list) == 0 {
+		return nil, nil
+	}
+
+	return &list[0], nil
+}
+
+func (r *AlertsRepository) Rules() ([]models.AlertRuleT, error) {
+
+	list := []models.AlertRuleT{}
+
+	if err := r.db.Order("name").Find(&list).Error; err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func (r *AlertsRepository) DeleteRule(id uint) error {
+	return r.db.Unscoped().Delete(new(models.AlertRuleT), "ID = ?", id).Error
+}
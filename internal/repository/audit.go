@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/pkg/storage"
+	"gorm.io/gorm"
+)
+
+// AuditRepository - append only storage of audit events
+type AuditRepository struct {
+	DefaultRepository
+}
+
+func NewAuditRepository(db *storage.DB) *AuditRepository {
+	return &AuditRepository{
+		NewDefaultRepository(db),
+	}
+}
+
+func (r *AuditRepository) Append(event *models.AuditEventT) error {
+	return r.db.Create(event).Error
+}
+
+// Query - returns filtered events from newest to oldest and count of all filtered events
+func (r *AuditRepository) Query(f models.AuditFilter) ([]models.AuditEventT, int64, error) {
+
+	var (
+		list  = []models.AuditEventT{}
+		count int64
+	)
+
+	query := r.filter(r.db.Model(new(models.AuditEventT)), f)
+
+	if err := query.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if f.Limit > 0 {
+		query = query.Limit(f.Limit).Offset(f.Offset)
+	}
+
+	if err := query.Order("created_at DESC, ID DESC").Find(&list).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return list, count, nil
+}
+
+func (r *AuditRepository) filter(query *gorm.DB, f models.AuditFilter) *gorm.DB {
+
+	if f.Actor != "" {
+		query = query.Where("actor = ?", f.Actor)
+	}
+
+	if f.Action != "" {
+		query = query.Where("action = ? OR action LIKE ?", f.Action, f.Action+".%")
+	}
+
+	if f.Target != "" {
+		query = query.Where("target LIKE ?", "%"+f.Target+"%")
+	}
+
+	if f.Result != "" {
+		query = query.Where("result = ?", f.Result)
+	}
+
+	if f.From > 0 {
+		query = query.Where("created_at >= ?", time.Unix(f.From, 0))
+	}
+
+	if f.To > 0 {
+		query = query.Where("created_at <= ?", time.Unix(f.To, 0))
+	}
+
+	return query
+}
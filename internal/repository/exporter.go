@@ -7,11 +7,13 @@ import (
 
 type ExporterRepository struct {
 	DefaultRepository
+	cipher SecretCipher
 }
 
-func NewExporterRepository(db *storage.DB) *ExporterRepository {
+func NewExporterRepository(db *storage.DB, c SecretCipher) *ExporterRepository {
 	return &ExporterRepository{
-		NewDefaultRepository(db),
+		DefaultRepository: NewDefaultRepository(db),
+		cipher:            c,
 	}
 }
 
@@ -22,7 +24,7 @@ func (r *ExporterRepository) Get() ([]models.ExporterInfoT, error) {
 		return nil, err
 	}
 
-	return list, nil
+	return list, r.decryptList(list)
 }
 
 func (r *ExporterRepository) GetType(exporterType models.ExporterTypeString) ([]models.ExporterInfoT, error) {
@@ -33,28 +35,61 @@ func (r *ExporterRepository) GetType(exporterType models.ExporterTypeString) ([]
 		return nil, err
 	}
 
-	return list, nil
+	return list, r.decryptList(list)
 }
 
 func (r *ExporterRepository) Add(exporter *models.ExporterInfoT) error {
-	return r.db.Create(exporter).Error
+
+	extra, err := r.cipher.Encrypt(exporter.Extra)
+	if err != nil {
+		return err
+	}
+
+	data := *exporter
+	data.Extra = extra
+
+	if err := r.db.Create(&data).Error; err != nil {
+		return err
+	}
+
+	exporter.ID = data.ID
+
+	return nil
 }
 
 func (r *ExporterRepository) Edit(exporter *models.ExporterInfoT, id uint) error {
 
-	if err := r.db.First(exporter, "ID = ?", id).Error; err != nil {
+	if err := r.db.First(new(models.ExporterInfoT), "ID = ?", id).Error; err != nil {
 		return err
 	}
 
-	exporter.ID = id
+	extra, err := r.cipher.Encrypt(exporter.Extra)
+	if err != nil {
+		return err
+	}
+
+	data := *exporter
+	data.ID = id
+	data.Extra = extra
 
-	if err := r.db.Save(exporter).Error; err != nil {
+	if err := r.db.Save(&data).Error; err != nil {
 		return err
 	}
 
 	return nil
 }
 
+func (r *ExporterRepository) decryptList(list []models.ExporterInfoT) (err error) {
+
+	for i := range list {
+		if list[i].Extra, err = r.cipher.Decrypt(list[i].Extra); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (r *ExporterRepository) Delete(id uint) error {
 	return r.db.Unscoped().Delete(new(models.ExporterInfoT), "ID = ?", id).Error
 }
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/pkg/storage"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type HostKeysRepository struct {
+	DefaultRepository
+}
+
+func NewHostKeysRepository(db *storage.DB) *HostKeysRepository {
+	return &HostKeysRepository{
+		NewDefaultRepository(db),
+	}
+}
+
+func (r *HostKeysRepository) HostKey(credentialsID uint) (*models.SSHHostKeyT, error) {
+
+	key := new(models.SSHHostKeyT)
+
+	if err := r.db.Preload("Credentials").First(key, "credentials_id = ?", credentialsID).Error; err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+func (r *HostKeysRepository) HostKeys() ([]models.SSHHostKeyT, error) {
+
+	list := []models.SSHHostKeyT{}
+
+	if err := r.db.Preload("Credentials").Order("credentials_id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// TrustHostKey - stores first seen host key. Already trusted key of host is kept
+func (r *HostKeysRepository) TrustHostKey(key *models.SSHHostKeyT) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "credentials_id"}},
+		DoNothing: true,
+	}).Omit("Credentials").Create(key).Error
+}
+
+func (r *HostKeysRepository) SetPendingHostKey(credentialsID uint, keyType, key, fingerprint string) error {
+	return r.db.Model(new(models.SSHHostKeyT)).Where("credentials_id = ?", credentialsID).Updates(map[string]any{
+		"PendingType":        keyType,
+		"PendingKey":         key,
+		"PendingFingerprint": fingerprint,
+		"PendingSeenAt":      time.Now(),
+	}).Error
+}
+
+func (r *HostKeysRepository) UpdateHostKey(key *models.SSHHostKeyT) error {
+	return r.db.Omit("Credentials").Save(key).Error
+}
+
+func (r *HostKeysRepository) DeleteHostKey(credentialsID uint) error {
+
+	result := r.db.Unscoped().Delete(new(models.SSHHostKeyT), "credentials_id = ?", credentialsID)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// ImportHostKeys - stores imported keys in single transaction.
+// Existing keys are replaced only when 'replace' is set. Returns hosts with stored keys
+func (r *HostKeysRepository) ImportHostKeys(keys []models.SSHHostKeyT, replace bool) (imported []uint, err error) {
+
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+
+		for i := range keys {
+
+			if replace {
+				if err := tx.Unscoped().Delete(new(models.SSHHostKeyT), "credentials_id = ?", keys[i].CredentialsID).Error; err != nil {
+					return err
+				}
+			}
+
+			result := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "credentials_id"}},
+				DoNothing: true,
+			}).Omit("Credentials").Create(&keys[i])
+
+			if result.Error != nil {
+				return result.Error
+			}
+
+			if result.RowsAffected > 0 {
+				imported = append(imported, keys[i].CredentialsID)
+			}
+		}
+
+		return nil
+	})
+
+	return imported, err
+}
@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/pkg/storage"
+)
+
+type LocksRepository struct {
+	DefaultRepository
+}
+
+func NewLocksRepository(db *storage.DB) *LocksRepository {
+	return &LocksRepository{
+		NewDefaultRepository(db),
+	}
+}
+
+func (r *LocksRepository) Locks() ([]models.DeskyLoginLockT, error) {
+
+	list := []models.DeskyLoginLockT{}
+
+	if err := r.db.Find(&list).Error; err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func (r *LocksRepository) SaveLock(lock *models.DeskyLoginLockT) error {
+	return r.db.Save(lock).Error
+}
+
+func (r *LocksRepository) DeleteLock(key string) error {
+	return r.db.Unscoped().Delete(new(models.DeskyLoginLockT), "Key = ?", key).Error
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/pkg/storage"
+	"gorm.io/gorm/clause"
+)
+
+type MetricsRepository struct {
+	DefaultRepository
+}
+
+func NewMetricsRepository(db *storage.DB) *MetricsRepository {
+	return &MetricsRepository{
+		NewDefaultRepository(db),
+	}
+}
+
+// AddPoints - stores metric buckets. Already stored buckets are kept
+func (r *MetricsRepository) AddPoints(points []models.AgentMetricT) error {
+
+	if len(points) == 0 {
+		return nil
+	}
+
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(points, 500).Error
+}
+
+// Points - returns buckets of host metric with start time in [from, to) ordered by time
+func (r *MetricsRepository) Points(hostID, metric string, tier models.MetricTier, from, to int64) ([]models.AgentMetricT, error) {
+
+	list := []models.AgentMetricT{}
+
+	err := r.db.Where(
+		"host_id = ? AND metric = ? AND tier = ? AND time >= ? AND time < ?",
+		hostID, metric, tier, from, to,
+	).Order("time").Find(&list).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// TierPoints - returns buckets of all hosts and metrics with start time in [from, to)
+func (r *MetricsRepository) TierPoints(tier models.MetricTier, from, to int64) ([]models.AgentMetricT, error) {
+
+	list := []models.AgentMetricT{}
+
+	err := r.db.Where("tier = ? AND time >= ? AND time < ?", tier, from, to).Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// TierBounds - returns start time of the first and the last bucket of tier. Both are zero for empty tier
+func (r *MetricsRepository) TierBounds(tier models.MetricTier) (first, last int64, err error) {
+
+	var bounds struct {
+		First sql.NullInt64
+		Last  sql.NullInt64
+	}
+
+	err = r.db.Model(new(models.AgentMetricT)).
+		Select("MIN(time) AS first, MAX(time) AS last").
+		Where("tier = ?", tier).
+		Scan(&bounds).Error
+
+	return bounds.First.Int64, bounds.Last.Int64, err
+}
+
+// DeleteBefore - removes buckets of tier started before time
+func (r *MetricsRepository) DeleteBefore(tier models.MetricTier, before int64) (int64, error) {
+
+	result := r.db.Where("tier = ? AND time < ?", tier, before).Delete(new(models.AgentMetricT))
+
+	return result.RowsAffected, result.Error
+}
+
+// Metrics - returns names of stored host metrics
+func (r *MetricsRepository) Metrics(hostID string) ([]string, error) {
+
+	list := []string{}
+
+	err := r.db.Model(new(models.AgentMetricT)).
+		Where("host_id = ?", hostID).
+		Distinct("metric").
+		Order("metric").
+		Pluck("metric", &list).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
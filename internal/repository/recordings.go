@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/pkg/storage"
+)
+
+type RecordingsRepository struct {
+	DefaultRepository
+}
+
+func NewRecordingsRepository(db *storage.DB) *RecordingsRepository {
+	return &RecordingsRepository{
+		NewDefaultRepository(db),
+	}
+}
+
+func (r *RecordingsRepository) CreateRecording(rec *models.SSHRecordingT) error {
+	return r.db.Create(rec).Error
+}
+
+func (r *RecordingsRepository) FinishRecording(id uint, ended time.Time, size int64) error {
+	return r.db.Model(new(models.SSHRecordingT)).Where("ID = ?", id).Updates(map[string]any{
+		"EndedAt": ended,
+		"Size":    size,
+	}).Error
+}
+
+func (r *RecordingsRepository) RecordingById(id uint) (*models.SSHRecordingT, error) {
+
+	rec := new(models.SSHRecordingT)
+
+	if err := r.db.First(rec, "ID = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	return rec, nil
+}
+
+// Recordings - returns filtered recordings from newest to oldest and count of all filtered recordings
+func (r *RecordingsRepository) Recordings(f models.RecordingFilter) ([]models.SSHRecordingT, int64, error) {
+
+	var (
+		list  = []models.SSHRecordingT{}
+		count int64
+	)
+
+	query := r.db.Model(new(models.SSHRecordingT))
+
+	if f.Actor != "" {
+		query = query.Where("actor = ?", f.Actor)
+	}
+
+	if f.HostID > 0 {
+		query = query.Where("credentials_id = ?", f.HostID)
+	}
+
+	if err := query.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if f.Limit > 0 {
+		query = query.Limit(f.Limit).Offset(f.Offset)
+	}
+
+	if err := query.Order("started_at DESC, ID DESC").Find(&list).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return list, count, nil
+}
+
+// RecordingsBefore - returns recordings started before time
+func (r *RecordingsRepository) RecordingsBefore(t time.Time) ([]models.SSHRecordingT, error) {
+
+	list := []models.SSHRecordingT{}
+
+	if err := r.db.Where("started_at < ?", t).Find(&list).Error; err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func (r *RecordingsRepository) DeleteRecording(id uint) error {
+	return r.db.Unscoped().Delete(new(models.SSHRecordingT), "ID = ?", id).Error
+}
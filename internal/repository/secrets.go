@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/pkg/storage"
+	"gorm.io/gorm"
+)
+
+// SecretCipher - encrypts secret fields before they are stored
+type SecretCipher interface {
+	Encrypt(value string) (string, error)
+	Decrypt(value string) (string, error)
+}
+
+// SecretRewrapper - re-encrypts stored secret with current master key
+type SecretRewrapper interface {
+	Rewrap(value string) (string, bool, error)
+}
+
+type SecretsRepository struct {
+	DefaultRepository
+}
+
+func NewSecretsRepository(db *storage.DB) *SecretsRepository {
+	return &SecretsRepository{
+		NewDefaultRepository(db),
+	}
+}
+
+// Rewrap - encrypts plaintext secrets and re-encrypts secrets of previous master keys.
+// Works in single transaction and returns count of changed rows
+func (r *SecretsRepository) Rewrap(k SecretRewrapper) (count int, err error) {
+
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+
+		secures := []models.SSHSecureT{}
+		if err := tx.Find(&secures).Error; err != nil {
+			return err
+		}
+
+		for _, s := range secures {
+
+			password, pwdChanged, err := k.Rewrap(s.Password)
+			if err != nil {
+				return err
+			}
+
+			key, keyChanged, err := k.Rewrap(s.PrivateKey)
+			if err != nil {
+				return err
+			}
+
+			passphrase, phraseChanged, err := k.Rewrap(s.Passphrase)
+			if err != nil {
+				return err
+			}
+
+			if !pwdChanged && !keyChanged && !phraseChanged {
+				continue
+			}
+
+			if err := tx.Model(new(models.SSHSecureT)).Where("ID = ?", s.ID).Updates(map[string]any{
+				"Password":   password,
+				"PrivateKey": key,
+				"Passphrase": passphrase,
+			}).Error; err != nil {
+				return err
+			}
+
+			count++
+		}
+
+		keys := []models.SSHKeyT{}
+		if err := tx.Find(&keys).Error; err != nil {
+			return err
+		}
+
+		for _, sk := range keys {
+
+			key, keyChanged, err := k.Rewrap(sk.PrivateKey)
+			if err != nil {
+				return err
+			}
+
+			passphrase, phraseChanged, err := k.Rewrap(sk.Passphrase)
+			if err != nil {
+				return err
+			}
+
+			if !keyChanged && !phraseChanged {
+				continue
+			}
+
+			if err := tx.Model(new(models.SSHKeyT)).Where("ID = ?", sk.ID).Updates(map[string]any{
+				"PrivateKey": key,
+				"Passphrase": passphrase,
+			}).Error; err != nil {
+				return err
+			}
+
+			count++
+		}
+
+		agents := []models.AgentT{}
+		if err := tx.Find(&agents).Error; err != nil {
+			return err
+		}
+
+		for _, a := range agents {
+
+			if a.Secret == "" {
+				continue
+			}
+
+			secret, changed, err := k.Rewrap(a.Secret)
+			if err != nil {
+				return err
+			}
+
+			if !changed {
+				continue
+			}
+
+			if err := tx.Model(new(models.AgentT)).Where("ID = ?", a.ID).Update("Secret", secret).Error; err != nil {
+				return err
+			}
+
+			count++
+		}
+
+		exporters := []models.ExporterInfoT{}
+		if err := tx.Find(&exporters).Error; err != nil {
+			return err
+		}
+
+		for _, e := range exporters {
+
+			extra, changed, err := k.Rewrap(e.Extra)
+			if err != nil {
+				return err
+			}
+
+			if !changed {
+				continue
+			}
+
+			if err := tx.Model(new(models.ExporterInfoT)).Where("ID = ?", e.ID).Update("Extra", extra).Error; err != nil {
+				return err
+			}
+
+			count++
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
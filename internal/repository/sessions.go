@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/pkg/storage"
+)
+
+type SessionsRepository struct {
+	DefaultRepository
+}
+
+func NewSessionsRepository(db *storage.DB) *SessionsRepository {
+	return &SessionsRepository{
+		NewDefaultRepository(db),
+	}
+}
+
+func (r *SessionsRepository) CreateSession(session *models.DeskySessionT) error {
+	return r.db.Create(session).Error
+}
+
+func (r *SessionsRepository) SessionById(id string) (*models.DeskySessionT, error) {
+
+	s := new(models.DeskySessionT)
+
+	if err := r.db.Preload("User").First(s, "ID = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (r *SessionsRepository) DeleteSession(id string) error {
+	return r.db.Unscoped().Delete(new(models.DeskySessionT), "ID = ?", id).Error
+}
+
+func (r *SessionsRepository) DeleteUserSessions(userID uint) error {
+	return r.db.Unscoped().Delete(new(models.DeskySessionT), "user_id = ?", userID).Error
+}
+
+func (r *SessionsRepository) DeleteExpired() error {
+	return r.db.Unscoped().Delete(new(models.DeskySessionT), "expires_at < ?", time.Now()).Error
+}
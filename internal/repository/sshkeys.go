@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/pkg/storage"
+	"gorm.io/gorm"
+)
+
+type SSHKeysRepository struct {
+	DefaultRepository
+	cipher SecretCipher
+}
+
+func NewSSHKeysRepository(db *storage.DB, c SecretCipher) *SSHKeysRepository {
+	return &SSHKeysRepository{
+		DefaultRepository: NewDefaultRepository(db),
+		cipher:            c,
+	}
+}
+
+// Keys - returns shared keys without secret fields
+func (r *SSHKeysRepository) Keys() ([]models.SSHKeyT, error) {
+
+	keys := make([]models.SSHKeyT, 0)
+
+	if err := r.db.Omit("PrivateKey", "Passphrase").Order("name").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (r *SSHKeysRepository) KeyById(id uint) (*models.SSHKeyT, error) {
+
+	key := new(models.SSHKeyT)
+
+	if err := r.db.First(key, "ID = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	if err := decryptKey(r.cipher, key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// KeyByName - returns key without secret fields. Nil is returned when key doesn't exist
+func (r *SSHKeysRepository) KeyByName(name string) (*models.SSHKeyT, error) {
+
+	key := new(models.SSHKeyT)
+
+	err := r.db.Omit("PrivateKey", "Passphrase").First(key, "name = ?", name).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+func (r *SSHKeysRepository) CreateKey(key *models.SSHKeyT) error {
+
+	stored := *key
+	if err := encryptKey(r.cipher, &stored); err != nil {
+		return err
+	}
+
+	if err := r.db.Create(&stored).Error; err != nil {
+		return err
+	}
+
+	key.ID, key.CreatedAt = stored.ID, stored.CreatedAt
+	return nil
+}
+
+func (r *SSHKeysRepository) UpdateKey(key *models.SSHKeyT) error {
+
+	stored := *key
+	if err := encryptKey(r.cipher, &stored); err != nil {
+		return err
+	}
+
+	return r.db.Save(&stored).Error
+}
+
+func (r *SSHKeysRepository) DeleteKey(id uint) error {
+	return r.db.Delete(new(models.SSHKeyT), "ID = ?", id).Error
+}
+
+// KeyUsage - returns count of hosts using shared key
+func (r *SSHKeysRepository) KeyUsage(id uint) (int64, error) {
+
+	var count int64
+
+	if err := r.db.Model(new(models.SSHSecureT)).Where("key_id = ?", id).Count(&count).Error; err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func encryptKey(c SecretCipher, k *models.SSHKeyT) (err error) {
+
+	if k.PrivateKey, err = c.Encrypt(k.PrivateKey); err != nil {
+		return err
+	}
+
+	k.Passphrase, err = c.Encrypt(k.Passphrase)
+	return err
+}
+
+func decryptKey(c SecretCipher, k *models.SSHKeyT) (err error) {
+
+	if k.PrivateKey, err = c.Decrypt(k.PrivateKey); err != nil {
+		return err
+	}
+
+	k.Passphrase, err = c.Decrypt(k.Passphrase)
+	return err
+}
@@ -3,15 +3,18 @@ package repository
 import (
 	"github.com/eterline/desky-backend/internal/models"
 	"github.com/eterline/desky-backend/pkg/storage"
+	"gorm.io/gorm"
 )
 
 type SSHRepository struct {
 	DefaultRepository
+	cipher SecretCipher
 }
 
-func NewSSHLanderRepository(db *storage.DB) *SSHRepository {
+func NewSSHLanderRepository(db *storage.DB, c SecretCipher) *SSHRepository {
 	return &SSHRepository{
-		NewDefaultRepository(db),
+		DefaultRepository: NewDefaultRepository(db),
+		cipher:            c,
 	}
 }
 
@@ -19,52 +22,220 @@ func (r *SSHRepository) QueryAll() ([]models.SSHCredentialsT, error) {
 
 	credentialsList := make([]models.SSHCredentialsT, 0)
 
-	if err := r.db.Preload("OperationSystem").Preload("Security").Find(&credentialsList).Error; err != nil {
+	if err := r.db.Preload("OperationSystem").Preload("Security.Key").Preload("Tags").Find(&credentialsList).Error; err != nil {
 		return nil, err
 	}
+
+	for i := range credentialsList {
+		if err := r.decryptSecure(&credentialsList[i].Security); err != nil {
+			return nil, err
+		}
+	}
+
 	return credentialsList, nil
 }
 
-func (r *SSHRepository) AddHost(
-	username string,
-	host string,
-	port uint16,
+func (r *SSHRepository) AddHost(creds *models.SSHCredentialsT) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return r.createHost(tx, creds)
+	})
+}
 
-	osType string,
-	privateKeyUsage bool,
-	password, key string,
-) error {
+// UpdateHost - changes host settings and replaces it's tags. Authentication is changed only when secure isn't nil
+func (r *SSHRepository) UpdateHost(creds *models.SSHCredentialsT, secure *models.SSHSecureT) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+
+		stored := new(models.SSHCredentialsT)
+		if err := tx.First(stored, "ID = ?", creds.ID).Error; err != nil {
+			return err
+		}
+
+		systemID, err := systemTypeID(tx, creds.OperationSystem.SystemType)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(stored).Select(
+			"OperationSystemID", "Username", "Host", "Port", "JumpHostID", "Name", "Description", "Group",
+		).Updates(&models.SSHCredentialsT{
+			OperationSystemID: systemID,
+			Username:          creds.Username,
+			Host:              creds.Host,
+			Port:              creds.Port,
+			JumpHostID:        creds.JumpHostID,
+			Name:              creds.Name,
+			Description:       creds.Description,
+			Group:             creds.Group,
+		}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Delete(new(models.SSHHostTagT), "credentials_id = ?", creds.ID).Error; err != nil {
+			return err
+		}
+
+		for _, tag := range creds.Tags {
+			tag.ID, tag.CredentialsID = 0, creds.ID
+			if err := tx.Create(&tag).Error; err != nil {
+				return err
+			}
+		}
+
+		if secure == nil {
+			return nil
+		}
+
+		encrypted := *secure
+		if err := r.encryptSecure(&encrypted); err != nil {
+			return err
+		}
+
+		return tx.Model(new(models.SSHSecureT)).Where("ID = ?", stored.SecurityID).Select(
+			"PrivateKeyUse", "Password", "PrivateKey", "Passphrase", "Certificate", "KeyID",
+		).Updates(&encrypted).Error
+	})
+}
 
-	credentialsData := &models.SSHCredentialsT{
-		OperationSystem: models.MakeSSHSystemTypesT(osType),
+// ImportHosts - adds hosts in single transaction. Jumps holds index of host's jump host
+// in hosts list, it must be lower than host index. Negative index keeps JumpHostID of host
+func (r *SSHRepository) ImportHosts(hosts []models.SSHCredentialsT, jumps []int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
 
-		Username: username,
-		Host:     host,
-		Port:     port,
+		for idx := range hosts {
+
+			if jump := jumps[idx]; jump >= 0 {
+				hosts[idx].JumpHostID = &hosts[jump].ID
+			}
+
+			if err := r.createHost(tx, &hosts[idx]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
 
-		Security: models.MakeSSHSecureT(password, privateKeyUsage, key),
+func (r *SSHRepository) createHost(tx *gorm.DB, creds *models.SSHCredentialsT) error {
+
+	systemID, err := systemTypeID(tx, creds.OperationSystem.SystemType)
+	if err != nil {
+		return err
+	}
+
+	stored := *creds
+	stored.OperationSystemID = systemID
+	stored.OperationSystem = models.SSHSystemTypesT{}
+
+	if err := r.encryptSecure(&stored.Security); err != nil {
+		return err
 	}
 
-	if err := r.db.Create(credentialsData).Error; err != nil {
+	if err := tx.Create(&stored).Error; err != nil {
 		return err
 	}
+
+	creds.ID = stored.ID
 	return nil
 }
 
+// systemTypeID - returns id of OS type row, row is created on first use
+func systemTypeID(tx *gorm.DB, system models.SSHtypeOS) (uint, error) {
+
+	row := models.SSHSystemTypesT{SystemType: models.StringSSHtypeOS(string(system))}
+
+	if err := tx.Where(&row).FirstOrCreate(&row).Error; err != nil {
+		return 0, err
+	}
+
+	return row.ID, nil
+}
+
 func (r *SSHRepository) Delete(id int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
 
-	if err := r.db.Unscoped().Delete(new(models.SSHCredentialsT), "ID = ?", id).Error; err != nil {
-		return err
+		if err := tx.Unscoped().Delete(new(models.SSHHostKeyT), "credentials_id = ?", id).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Delete(new(models.SSHHostTagT), "credentials_id = ?", id).Error; err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Delete(new(models.SSHCredentialsT), "ID = ?", id).Error
+	})
+}
+
+// IsJumpHost - reports that other hosts connect through host
+func (r *SSHRepository) IsJumpHost(id int) (bool, error) {
+
+	var count int64
+
+	if err := r.db.Model(new(models.SSHCredentialsT)).Where("jump_host_id = ?", id).Count(&count).Error; err != nil {
+		return false, err
 	}
-	return nil
+
+	return count > 0, nil
+}
+
+// HasTunnels - reports that port forwarding tunnels are defined for host
+func (r *SSHRepository) HasTunnels(id int) (bool, error) {
+
+	var count int64
+
+	if err := r.db.Model(new(models.SSHTunnelT)).Where("credentials_id = ?", id).Count(&count).Error; err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
 }
 
 func (r *SSHRepository) QueryById(id int) (*models.SSHCredentialsT, error) {
 
 	sshCredentials := new(models.SSHCredentialsT)
 
-	if err := r.db.Preload("OperationSystem").Preload("Security").First(sshCredentials, "ID = ?", id).Error; err != nil {
+	if err := r.db.Preload("OperationSystem").Preload("Security.Key").Preload("Tags").First(sshCredentials, "ID = ?", id).Error; err != nil {
 		return nil, err
 	}
+
+	if err := r.decryptSecure(&sshCredentials.Security); err != nil {
+		return nil, err
+	}
+
 	return sshCredentials, nil
 }
+
+func (r *SSHRepository) encryptSecure(s *models.SSHSecureT) (err error) {
+
+	if s.Password, err = r.cipher.Encrypt(s.Password); err != nil {
+		return err
+	}
+
+	if s.PrivateKey, err = r.cipher.Encrypt(s.PrivateKey); err != nil {
+		return err
+	}
+
+	s.Passphrase, err = r.cipher.Encrypt(s.Passphrase)
+	return err
+}
+
+func (r *SSHRepository) decryptSecure(s *models.SSHSecureT) (err error) {
+
+	if s.Password, err = r.cipher.Decrypt(s.Password); err != nil {
+		return err
+	}
+
+	if s.PrivateKey, err = r.cipher.Decrypt(s.PrivateKey); err != nil {
+		return err
+	}
+
+	if s.Passphrase, err = r.cipher.Decrypt(s.Passphrase); err != nil {
+		return err
+	}
+
+	if s.Key != nil {
+		return decryptKey(r.cipher, s.Key)
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/pkg/storage"
+)
+
+type TokensRepository struct {
+	DefaultRepository
+}
+
+func NewTokensRepository(db *storage.DB) *TokensRepository {
+	return &TokensRepository{
+		NewDefaultRepository(db),
+	}
+}
+
+func (r *TokensRepository) CreateToken(token *models.DeskyAPITokenT) error {
+	return r.db.Create(token).Error
+}
+
+func (r *TokensRepository) TokenByHash(hash string) (*models.DeskyAPITokenT, error) {
+
+	t := new(models.DeskyAPITokenT)
+
+	if err := r.db.Preload("User").First(t, "hash = ?", hash).Error; err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (r *TokensRepository) TokenById(id uint) (*models.DeskyAPITokenT, error) {
+
+	t := new(models.DeskyAPITokenT)
+
+	if err := r.db.First(t, "ID = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (r *TokensRepository) UserTokens(userID uint) ([]models.DeskyAPITokenT, error) {
+
+	list := []models.DeskyAPITokenT{}
+
+	if err := r.db.Where("user_id = ?", userID).Order("ID").Find(&list).Error; err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func (r *TokensRepository) AllTokens() ([]models.DeskyAPITokenT, error) {
+
+	list := []models.DeskyAPITokenT{}
+
+	if err := r.db.Order("ID").Find(&list).Error; err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func (r *TokensRepository) TouchToken(id uint, at time.Time) error {
+	return r.db.Model(new(models.DeskyAPITokenT)).
+		Where("ID = ?", id).
+		Update("last_used_at", at).Error
+}
+
+func (r *TokensRepository) DeleteToken(id uint) error {
+	return r.db.Unscoped().Delete(new(models.DeskyAPITokenT), "ID = ?", id).Error
+}
+
+func (r *TokensRepository) DeleteUserTokens(userID uint) error {
+	return r.db.Unscoped().Delete(new(models.DeskyAPITokenT), "user_id = ?", userID).Error
+}
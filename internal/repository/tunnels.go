@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/pkg/storage"
+)
+
+type TunnelsRepository struct {
+	DefaultRepository
+}
+
+func NewTunnelsRepository(db *storage.DB) *TunnelsRepository {
+	return &TunnelsRepository{
+		NewDefaultRepository(db),
+	}
+}
+
+func (r *TunnelsRepository) CreateTunnel(t *models.SSHTunnelT) error {
+	return r.db.Create(t).Error
+}
+
+func (r *TunnelsRepository) UpdateTunnel(t *models.SSHTunnelT) error {
+	return r.db.Save(t).Error
+}
+
+func (r *TunnelsRepository) SetTunnelEnabled(id uint, enabled bool) error {
+	return r.db.Model(new(models.SSHTunnelT)).Where("ID = ?", id).Update("Enabled", enabled).Error
+}
+
+func (r *TunnelsRepository) TunnelById(id uint) (*models.SSHTunnelT, error) {
+
+	t := new(models.SSHTunnelT)
+
+	if err := r.db.First(t, "ID = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// TunnelByName - returns tunnel with name. Not found tunnel is nil without error
+func (r *TunnelsRepository) TunnelByName(name string) (*models.SSHTunnelT, error) {
+
+	list := []models.SSHTunnelT{}
+
+	if err := r.db.Where("name = ?", name).Limit(1).Find(&list).Error; err != nil {
+		return nil, err
+	}
+
+	if len(list) == 0 {
+		return nil, nil
+	}
+
+	return &list[0], nil
+}
+
+func (r *TunnelsRepository) Tunnels() ([]models.SSHTunnelT, error) {
+
+	list := []models.SSHTunnelT{}
+
+	if err := r.db.Order("name").Find(&list).Error; err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func (r *TunnelsRepository) DeleteTunnel(id uint) error {
+	return r.db.Unscoped().Delete(new(models.SSHTunnelT), "ID = ?", id).Error
+}
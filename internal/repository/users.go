@@ -45,6 +45,17 @@ func (r *UsersRepository) UserByLogin(login string) (*models.DeskyUserT, error)
 	return u, nil
 }
 
+func (r *UsersRepository) UserBySubject(provider, subject string) (*models.DeskyUserT, error) {
+
+	u := new(models.DeskyUserT)
+
+	if err := r.db.First(u, "Provider = ? AND Subject = ?", provider, subject).Error; err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
 func (r *UsersRepository) UserById(id int) (*models.DeskyUserT, error) {
 
 	u := new(models.DeskyUserT)
@@ -55,3 +66,105 @@ func (r *UsersRepository) UserById(id int) (*models.DeskyUserT, error) {
 
 	return u, nil
 }
+
+func (r *UsersRepository) UpdatePassword(id uint, password string) error {
+	return r.db.Model(new(models.DeskyUserT)).Where("ID = ?", id).Update("Password", password).Error
+}
+
+func (r *UsersRepository) SetPassword(id uint, password string, mustChange bool) error {
+	return r.db.Model(new(models.DeskyUserT)).Where("ID = ?", id).Updates(map[string]any{
+		"Password":           password,
+		"MustChangePassword": mustChange,
+	}).Error
+}
+
+func (r *UsersRepository) UpdateLogin(id uint, login string) error {
+	return r.db.Model(new(models.DeskyUserT)).Where("ID = ?", id).Update("Login", login).Error
+}
+
+func (r *UsersRepository) Count() (int64, error) {
+
+	var count int64
+
+	if err := r.db.Model(new(models.DeskyUserT)).Count(&count).Error; err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (r *UsersRepository) UpdateRole(id uint, role models.UserRole) error {
+	return r.db.Model(new(models.DeskyUserT)).Where("ID = ?", id).Update("Role", role).Error
+}
+
+func (r *UsersRepository) CountRole(role models.UserRole) (int64, error) {
+
+	var count int64
+
+	if err := r.db.Model(new(models.DeskyUserT)).Where("Role = ?", role).Count(&count).Error; err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (r *UsersRepository) FirstUser() (*models.DeskyUserT, error) {
+
+	u := new(models.DeskyUserT)
+
+	if err := r.db.Order("ID").First(u).Error; err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+func (r *UsersRepository) UpdateTOTP(id uint, secret string, enabled bool) error {
+	return r.db.Model(new(models.DeskyUserT)).Where("ID = ?", id).Updates(map[string]any{
+		"TOTPSecret":      secret,
+		"TOTPEnabled":     enabled,
+		"TOTPLastCounter": 0,
+	}).Error
+}
+
+func (r *UsersRepository) UpdateTOTPCounter(id uint, counter uint64) error {
+	return r.db.Model(new(models.DeskyUserT)).Where("ID = ?", id).Update("TOTPLastCounter", counter).Error
+}
+
+// UseTOTPCounter - stores counter of used TOTP code only if it's newer than stored one.
+// Reports false when code time step is already used, so concurrent replays are rejected
+func (r *UsersRepository) UseTOTPCounter(id uint, counter uint64) (bool, error) {
+
+	res := r.db.Model(new(models.DeskyUserT)).
+		Where("ID = ? AND totp_last_counter < ?", id, counter).
+		Update("TOTPLastCounter", counter)
+
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *UsersRepository) ReplaceRecoveryCodes(userID uint, hashes []string) error {
+
+	tx := r.db.Begin()
+
+	if err := tx.Unscoped().Delete(new(models.DeskyRecoveryCodeT), "user_id = ?", userID).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, h := range hashes {
+		if err := tx.Create(&models.DeskyRecoveryCodeT{UserID: userID, Hash: h}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// UseRecoveryCode - deletes recovery code of user by digest. Reports false when code doesn't exist
+func (r *UsersRepository) UseRecoveryCode(userID uint, digest string) (bool, error) {
+
+	res := r.db.Unscoped().Delete(new(models.DeskyRecoveryCodeT), "user_id = ? AND hash = ?", userID, digest)
+
+	return res.RowsAffected == 1, res.Error
+}
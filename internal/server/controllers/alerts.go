@@ -0,0 +1,232 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/internal/services/alerts"
+	"github.com/eterline/desky-backend/internal/services/handler"
+	"gorm.io/gorm"
+)
+
+type AlertsControllers struct {
+	engine *alerts.Engine
+}
+
+func InitAlerts(e *alerts.Engine) *AlertsControllers {
+	return &AlertsControllers{
+		engine: e,
+	}
+}
+
+// ListAlerts godoc
+//
+//	@Summary		ListAlerts
+//	@Description	Shows pending and firing alerts. Alert state changes are also sent to agents monitor WebSocket
+//	@Tags			alerts
+//
+//	@Produce		json
+//	@Success		200	{array}	models.AlertEvent
+//	@Success		204
+//	@Router			/alerts [get]
+func (ac *AlertsControllers) ListAlerts(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "alerts.list"
+
+	list := ac.engine.Alerts()
+
+	if handler.ListIsEmpty(w, list) {
+		return op, nil
+	}
+
+	return op, handler.WriteJSON(w, http.StatusOK, list)
+}
+
+// ListNotifiers godoc
+//
+//	@Summary		ListNotifiers
+//	@Description	Shows names of configured notifiers
+//	@Tags			alerts
+//
+//	@Produce		json
+//	@Success		200	{array}	string
+//	@Success		204
+//	@Router			/alerts/notifiers [get]
+func (ac *AlertsControllers) ListNotifiers(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "alerts.list-notifiers"
+
+	list := ac.engine.Notifiers()
+
+	if handler.ListIsEmpty(w, list) {
+		return op, nil
+	}
+
+	return op, handler.WriteJSON(w, http.StatusOK, list)
+}
+
+// ListRules godoc
+//
+//	@Summary		ListRules
+//	@Description	Shows alert rules
+//	@Tags			alerts
+//
+//	@Produce		json
+//	@Success		200	{array}	models.AlertRuleObject
+//	@Success		204
+//	@Router			/alerts/rules [get]
+func (ac *AlertsControllers) ListRules(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "alerts.list-rules"
+
+	list, err := ac.engine.Rules()
+	if err != nil {
+		return op, err
+	}
+
+	if handler.ListIsEmpty(w, list) {
+		return op, nil
+	}
+
+	return op, handler.WriteJSON(w, http.StatusOK, list)
+}
+
+// CreateRule godoc
+//
+//	@Summary		CreateRule
+//	@Description	Creates threshold rule, e.g. 'cpu.load > 90 for 5m'.
+//	@Description	Rule without host is evaluated for every agent and desky host ('local'),
+//	@Description	rule without notifiers uses all of them
+//	@Tags			alerts
+//
+//	@Param			request	body	models.RequestFormAlertRule	true	"rule settings"
+//	@Accept			json
+//	@Produce		json
+//	@Failure		400	{object}	handler.APIErrorResponse
+//	@Failure		409	{object}	handler.APIErrorResponse
+//	@Success		201	{object}	models.AlertRuleObject
+//	@Router			/alerts/rules [post]
+func (ac *AlertsControllers) CreateRule(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "alerts.create-rule"
+
+	rule, err := alertRuleForm(r)
+	if err != nil {
+		return op, err
+	}
+
+	if err := ac.engine.Create(rule); err != nil {
+		return op, alertsError(err)
+	}
+
+	return op, handler.WriteJSON(w, http.StatusCreated, rule.Object())
+}
+
+// UpdateRule godoc
+//
+//	@Summary		UpdateRule
+//	@Description	Changes alert rule. Raised alerts are dropped when rule condition is changed
+//	@Tags			alerts
+//
+//	@Param			id		path	int							true	"rule id"
+//	@Param			request	body	models.RequestFormAlertRule	true	"rule settings"
+//	@Accept			json
+//	@Produce		json
+//	@Failure		400	{object}	handler.APIErrorResponse
+//	@Failure		404	{object}	handler.APIErrorResponse
+//	@Failure		409	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	models.AlertRuleObject
+//	@Router			/alerts/rules/{id} [put]
+func (ac *AlertsControllers) UpdateRule(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "alerts.update-rule"
+
+	q, err := handler.ParseURLParameters(r, handler.NumOpts("id"))
+	if err != nil {
+		return op, err
+	}
+
+	rule, err := alertRuleForm(r)
+	if err != nil {
+		return op, err
+	}
+
+	rule.ID = uint(q.GetInt("id"))
+
+	if err := ac.engine.Update(rule); err != nil {
+		return op, alertsError(err)
+	}
+
+	return op, handler.WriteJSON(w, http.StatusOK, rule.Object())
+}
+
+// DeleteRule godoc
+//
+//	@Summary		DeleteRule
+//	@Description	Deletes alert rule with it's raised alerts
+//	@Tags			alerts
+//
+//	@Param			id	path	int	true	"rule id"
+//	@Produce		json
+//	@Failure		404	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	handler.APIResponse
+//	@Router			/alerts/rules/{id} [delete]
+func (ac *AlertsControllers) DeleteRule(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "alerts.delete-rule"
+
+	q, err := handler.ParseURLParameters(r, handler.NumOpts("id"))
+	if err != nil {
+		return op, err
+	}
+
+	if err := ac.engine.Delete(uint(q.GetInt("id"))); err != nil {
+		return op, alertsError(err)
+	}
+
+	return op, handler.StatusOK(w, "alert rule deleted")
+}
+
+func alertRuleForm(r *http.Request) (*models.AlertRuleT, error) {
+
+	form := new(models.RequestFormAlertRule)
+	if err := handler.DecodeRequest(r, form); err != nil {
+		return nil, err
+	}
+
+	if err := handler.Validate(form); err != nil {
+		return nil, err
+	}
+
+	var duration time.Duration
+
+	if form.For != "" {
+		var err error
+		if duration, err = time.ParseDuration(form.For); err != nil || duration < 0 {
+			return nil, handler.NewErrorResponse(http.StatusBadRequest, ErrAlertDuration)
+		}
+	}
+
+	rule := &models.AlertRuleT{
+		Name:       strings.TrimSpace(form.Name),
+		Host:       strings.TrimSpace(form.Host),
+		Metric:     strings.TrimSpace(form.Metric),
+		Operator:   form.Operator,
+		Threshold:  form.Threshold,
+		Hysteresis: form.Hysteresis,
+		For:        int64(duration / time.Second),
+		Notifiers:  strings.Join(cleanTags(form.Notifiers), ","),
+		Enabled:    form.Enabled == nil || *form.Enabled,
+	}
+
+	return rule, nil
+}
+
+func alertsError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return handler.NewErrorResponse(http.StatusNotFound, ErrAlertRuleNotFound)
+	case errors.Is(err, alerts.ErrUnknownNotifier):
+		return handler.NewErrorResponse(http.StatusBadRequest, err)
+	case alerts.IsAlertsServiceError(err):
+		return handler.NewErrorResponse(http.StatusConflict, err)
+	}
+	return err
+}
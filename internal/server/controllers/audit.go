@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/internal/services/handler"
+)
+
+const (
+	AuditPageSize    = 50
+	AuditMaxPageSize = 1000
+)
+
+type AuditLog interface {
+	Query(f models.AuditFilter) ([]models.AuditEvent, int64, error)
+}
+
+type AuditHandlerGroup struct {
+	log AuditLog
+}
+
+func InitAudit(log AuditLog) *AuditHandlerGroup {
+	return &AuditHandlerGroup{
+		log: log,
+	}
+}
+
+// Events godoc
+//
+//	@Summary		Events
+//	@Description	Showing audit log from newest to oldest. 'format=csv' or 'format=json' exports all filtered events as file
+//	@Tags			audit
+//
+//	@Param			page	query	int		false	"page number starting from 1"
+//	@Param			count	query	int		false	"events per page"
+//	@Param			actor	query	string	false	"user login"
+//	@Param			action	query	string	false	"action or action group, e.g. 'ssh' or 'ssh.command'"
+//	@Param			target	query	string	false	"target substring"
+//	@Param			result	query	string	false	"success or failure"
+//	@Param			from	query	int		false	"unix time from"
+//	@Param			to		query	int		false	"unix time to"
+//	@Param			format	query	string	false	"export format: csv or json"
+//	@Produce		json
+//	@Failure		400	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	[]models.AuditEvent
+//	@Router			/audit [get]
+func (ah *AuditHandlerGroup) Events(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "handler.audit.events"
+
+	q := r.URL.Query()
+
+	filter := models.AuditFilter{
+		Actor:  q.Get("actor"),
+		Action: q.Get("action"),
+		Target: q.Get("target"),
+		Result: q.Get("result"),
+	}
+
+	if filter.Result != "" &&
+		filter.Result != string(models.AuditSuccess) &&
+		filter.Result != string(models.AuditFailure) {
+		return op, handler.NewErrorResponse(http.StatusBadRequest, ErrAuditQuery)
+	}
+
+	if filter.From, err = queryInt64(q.Get("from")); err != nil {
+		return op, err
+	}
+
+	if filter.To, err = queryInt64(q.Get("to")); err != nil {
+		return op, err
+	}
+
+	format := q.Get("format")
+	export := format != ""
+
+	page, err := queryInt64(q.Get("page"))
+	if err != nil {
+		return op, err
+	}
+
+	count, err := queryInt64(q.Get("count"))
+	if err != nil {
+		return op, err
+	}
+
+	if !export || count > 0 {
+		filter.Limit = clampPageSize(int(count))
+		if page > 1 {
+			filter.Offset = int(page-1) * filter.Limit
+		}
+	}
+
+	list, all, err := ah.log.Query(filter)
+	if err != nil {
+		return op, err
+	}
+
+	w.Header().Add("All-Count", strconv.FormatInt(all, 10))
+
+	switch format {
+
+	case "":
+		if handler.ListIsEmpty(w, list) {
+			return op, nil
+		}
+		return op, handler.WriteJSON(w, http.StatusOK, list)
+
+	case "json":
+		attachment(w, "json")
+		return op, handler.WriteJSON(w, http.StatusOK, list)
+
+	case "csv":
+		attachment(w, "csv")
+		w.Header().Set("Content-Type", "text/csv")
+		return op, writeAuditCSV(w, list)
+
+	default:
+		return op, handler.NewErrorResponse(http.StatusBadRequest, ErrAuditQuery)
+	}
+}
+
+func writeAuditCSV(w http.ResponseWriter, list []models.AuditEvent) error {
+
+	wr := csv.NewWriter(w)
+
+	wr.Write([]string{"id", "time", "actor", "ip", "action", "target", "result", "details"})
+
+	for _, e := range list {
+		wr.Write([]string{
+			strconv.FormatUint(uint64(e.ID), 10),
+			time.Unix(e.Timestamp, 0).UTC().Format(time.RFC3339),
+			e.Actor, e.IP, string(e.Action), e.Target, string(e.Result), e.Details,
+		})
+	}
+
+	wr.Flush()
+	return wr.Error()
+}
+
+func attachment(w http.ResponseWriter, ext string) {
+	w.Header().Set(
+		"Content-Disposition",
+		fmt.Sprintf("attachment; filename=\"desky-audit-%s.%s\"", time.Now().Format("20060102-150405"), ext),
+	)
+}
+
+func clampPageSize(count int) int {
+
+	if count < 1 {
+		return AuditPageSize
+	}
+
+	if count > AuditMaxPageSize {
+		return AuditMaxPageSize
+	}
+
+	return count
+}
+
+func queryInt64(value string) (int64, error) {
+
+	if value == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, handler.NewErrorResponse(http.StatusBadRequest, ErrAuditQuery)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,406 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/internal/services/audit"
+	"github.com/eterline/desky-backend/internal/services/authorization"
+	"github.com/eterline/desky-backend/internal/services/handler"
+	"github.com/eterline/desky-backend/internal/services/limiter"
+	"github.com/eterline/desky-backend/pkg/logger"
+)
+
+type AuthProvider interface {
+	Login(login, password, remote string) (string, *models.SessionClaims, error)
+	LoginSecondFactor(challenge, code, recovery, remote string) (string, *models.SessionClaims, error)
+	Logout(sessionID string) error
+	ChangeOwnPassword(id int, current, password string) error
+
+	SetupTOTP(userID uint) (secret, uri string, err error)
+	EnableTOTP(userID uint, code string) ([]string, error)
+	DisableTOTP(userID uint, password string) error
+	RegenerateRecoveryCodes(userID uint, code string) ([]string, error)
+	TwoFactorEnabled(userID uint) bool
+}
+
+type AuditRecorder interface {
+	Record(event *models.AuditEventT, err error)
+}
+
+type AuthHandlerGroup struct {
+	auth         AuthProvider
+	audit        AuditRecorder
+	secureCookie bool
+}
+
+func InitAuth(auth AuthProvider, rec AuditRecorder, secureCookie bool) *AuthHandlerGroup {
+	return &AuthHandlerGroup{
+		auth:         auth,
+		audit:        rec,
+		secureCookie: secureCookie,
+	}
+}
+
+// Login godoc
+//
+//	@Summary		Login
+//	@Description	Opens user session and returns signed session token
+//	@Tags			auth
+//
+//	@Param			request	body	models.LoginForm	true	"user credentials"
+//	@Accept			json
+//	@Produce		json
+//	@Failure		401	{object}	handler.APIErrorResponse
+//	@Failure		429	{object}	handler.APIErrorResponse
+//	@Success		202	{object}	models.TokenResponse
+//	@Router			/auth/login [post]
+func (ah *AuthHandlerGroup) Login(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "handler.auth.login"
+
+	form := new(models.LoginForm)
+	if err := handler.DecodeRequest(r, form); err != nil {
+		return op, err
+	}
+
+	if err := handler.Validate(form); err != nil {
+		return op, err
+	}
+
+	token, claims, err := ah.auth.Login(form.Login, form.Password, r.RemoteAddr)
+
+	event := audit.Event(r, models.AuditLogin, form.Login)
+	event.Actor = form.Login
+	ah.audit.Record(event, err)
+
+	if err != nil {
+		return op, loginError(w, r, form.Login, err)
+	}
+
+	if claims.Kind == models.ChallengeToken {
+		return op, handler.WriteJSON(w, http.StatusAccepted, models.TokenResponse{
+			Challenge: token,
+			TwoFactor: true,
+			ExpiresAt: claims.ExpiresAt,
+		})
+	}
+
+	return op, ah.writeSession(w, token, claims)
+}
+
+// LoginSecondFactor godoc
+//
+//	@Summary		LoginSecondFactor
+//	@Description	Completes two-factor login challenge with TOTP or recovery code
+//	@Tags			auth
+//
+//	@Param			request	body	models.SecondFactorForm	true	"challenge and code"
+//	@Accept			json
+//	@Produce		json
+//	@Failure		401	{object}	handler.APIErrorResponse
+//	@Failure		429	{object}	handler.APIErrorResponse
+//	@Success		202	{object}	models.TokenResponse
+//	@Router			/auth/login/2fa [post]
+func (ah *AuthHandlerGroup) LoginSecondFactor(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "handler.auth.login-second-factor"
+
+	form := new(models.SecondFactorForm)
+	if err := handler.DecodeRequest(r, form); err != nil {
+		return op, err
+	}
+
+	if err := handler.Validate(form); err != nil {
+		return op, err
+	}
+
+	token, claims, err := ah.auth.LoginSecondFactor(form.Challenge, form.Code, form.Recovery, r.RemoteAddr)
+
+	event := audit.Event(r, models.AuditLoginSecond, "")
+	if claims != nil {
+		event.Actor, event.Target, event.UserID = claims.Login, claims.Login, claims.UserID
+	}
+	ah.audit.Record(event, err)
+
+	if err != nil {
+		return op, loginError(w, r, "", err)
+	}
+
+	return op, ah.writeSession(w, token, claims)
+}
+
+// loginError - reports failed login attempt to error log and converts error to response.
+// Locked clients receive 'Retry-After' header
+func loginError(w http.ResponseWriter, r *http.Request, login string, err error) error {
+
+	if login == "" {
+		login = "<two-factor>"
+	}
+
+	if locked, ok := limiter.IsLockedError(err); ok {
+
+		logger.ReturnEntry().Errorf(
+			"login attempt rejected: login '%s' from %s: %v",
+			login, limiter.RemoteIP(r.RemoteAddr), err,
+		)
+
+		w.Header().Set("Retry-After", strconv.Itoa(int(locked.RetryAfter().Seconds())))
+		return handler.NewErrorResponse(http.StatusTooManyRequests, ErrTooManyAttempts)
+	}
+
+	if authorization.IsAuthorizationServiceError(err) {
+
+		logger.ReturnEntry().Errorf(
+			"failed login attempt: login '%s' from %s",
+			login, limiter.RemoteIP(r.RemoteAddr),
+		)
+
+		return handler.NewErrorResponse(http.StatusUnauthorized, ErrUncorrectCredentials)
+	}
+
+	return err
+}
+
+func (ah *AuthHandlerGroup) writeSession(w http.ResponseWriter, token string, claims *models.SessionClaims) error {
+
+	handler.SetSessionCookie(w, token, time.Unix(claims.ExpiresAt, 0), ah.secureCookie)
+
+	return handler.WriteJSON(w, http.StatusAccepted, models.TokenResponse{
+		Token:          token,
+		ExpiresAt:      claims.ExpiresAt,
+		PasswordChange: claims.PasswordChange,
+	})
+}
+
+// Logout godoc
+//
+//	@Summary		Logout
+//	@Description	Closes current user session
+//	@Tags			auth
+//
+//	@Produce		json
+//	@Failure		401	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	handler.APIResponse
+//	@Router			/auth/logout [post]
+func (ah *AuthHandlerGroup) Logout(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "handler.auth.logout"
+
+	claims, ok := handler.SessionFromContext(r.Context())
+	if !ok {
+		return op, handler.UnauthorizedErrorResponse()
+	}
+
+	if err := ah.auth.Logout(claims.SessionID); err != nil {
+		return op, err
+	}
+
+	handler.ClearSessionCookie(w, ah.secureCookie)
+
+	return op, handler.StatusOK(w, "session closed")
+}
+
+// Me godoc
+//
+//	@Summary		Me
+//	@Description	Shows current session user
+//	@Tags			auth
+//
+//	@Produce		json
+//	@Failure		401	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	models.CurrentUserResponse
+//	@Router			/auth/me [get]
+func (ah *AuthHandlerGroup) Me(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "handler.auth.me"
+
+	claims, ok := handler.SessionFromContext(r.Context())
+	if !ok {
+		return op, handler.UnauthorizedErrorResponse()
+	}
+
+	return op, handler.WriteJSON(w, http.StatusOK, models.CurrentUserResponse{
+		ID:             claims.UserID,
+		Login:          claims.Login,
+		Role:           claims.Role,
+		TwoFactor:      ah.auth.TwoFactorEnabled(claims.UserID),
+		ExpiresAt:      claims.ExpiresAt,
+		PasswordChange: claims.PasswordChange,
+	})
+}
+
+// Password godoc
+//
+//	@Summary		Password
+//	@Description	Changes current user password. All user sessions will be closed
+//	@Tags			auth
+//
+//	@Param			request	body	models.OwnPasswordForm	true	"current and new password"
+//	@Accept			json
+//	@Produce		json
+//	@Failure		403	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	handler.APIResponse
+//	@Router			/auth/password [post]
+func (ah *AuthHandlerGroup) Password(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "handler.auth.password"
+
+	claims, ok := handler.SessionFromContext(r.Context())
+	if !ok {
+		return op, handler.UnauthorizedErrorResponse()
+	}
+
+	form := new(models.OwnPasswordForm)
+	if err := handler.DecodeRequest(r, form); err != nil {
+		return op, err
+	}
+
+	if err := handler.Validate(form); err != nil {
+		return op, err
+	}
+
+	if err := ah.auth.ChangeOwnPassword(int(claims.UserID), form.Current, form.Password); err != nil {
+		return op, usersError(err)
+	}
+
+	handler.ClearSessionCookie(w, ah.secureCookie)
+
+	return op, handler.StatusOK(w, "password changed, login again")
+}
+
+// SetupTOTP godoc
+//
+//	@Summary		SetupTOTP
+//	@Description	Generates TOTP secret and provisioning URI for authenticator apps
+//	@Tags			auth
+//
+//	@Produce		json
+//	@Failure		409	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	models.TOTPSetupResponse
+//	@Router			/auth/2fa/setup [post]
+func (ah *AuthHandlerGroup) SetupTOTP(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "handler.auth.setup-totp"
+
+	claims, ok := handler.SessionFromContext(r.Context())
+	if !ok {
+		return op, handler.UnauthorizedErrorResponse()
+	}
+
+	secret, uri, err := ah.auth.SetupTOTP(claims.UserID)
+	if err != nil {
+		return op, usersError(err)
+	}
+
+	return op, handler.WriteJSON(w, http.StatusOK, models.TOTPSetupResponse{
+		Secret: secret,
+		URI:    uri,
+	})
+}
+
+// EnableTOTP godoc
+//
+//	@Summary		EnableTOTP
+//	@Description	Confirms TOTP secret with code and returns one-time shown recovery codes
+//	@Tags			auth
+//
+//	@Param			request	body	models.TOTPCodeForm	true	"authenticator code"
+//	@Accept			json
+//	@Produce		json
+//	@Failure		403	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	models.RecoveryCodesResponse
+//	@Router			/auth/2fa/enable [post]
+func (ah *AuthHandlerGroup) EnableTOTP(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "handler.auth.enable-totp"
+
+	claims, ok := handler.SessionFromContext(r.Context())
+	if !ok {
+		return op, handler.UnauthorizedErrorResponse()
+	}
+
+	form := new(models.TOTPCodeForm)
+	if err := handler.DecodeRequest(r, form); err != nil {
+		return op, err
+	}
+
+	if err := handler.Validate(form); err != nil {
+		return op, err
+	}
+
+	codes, err := ah.auth.EnableTOTP(claims.UserID, form.Code)
+	if err != nil {
+		return op, usersError(err)
+	}
+
+	return op, handler.WriteJSON(w, http.StatusOK, models.RecoveryCodesResponse{Codes: codes})
+}
+
+// RecoveryCodes godoc
+//
+//	@Summary		RecoveryCodes
+//	@Description	Replaces two-factor recovery codes
+//	@Tags			auth
+//
+//	@Param			request	body	models.TOTPCodeForm	true	"authenticator code"
+//	@Accept			json
+//	@Produce		json
+//	@Failure		403	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	models.RecoveryCodesResponse
+//	@Router			/auth/2fa/recovery [post]
+func (ah *AuthHandlerGroup) RecoveryCodes(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "handler.auth.recovery-codes"
+
+	claims, ok := handler.SessionFromContext(r.Context())
+	if !ok {
+		return op, handler.UnauthorizedErrorResponse()
+	}
+
+	form := new(models.TOTPCodeForm)
+	if err := handler.DecodeRequest(r, form); err != nil {
+		return op, err
+	}
+
+	if err := handler.Validate(form); err != nil {
+		return op, err
+	}
+
+	codes, err := ah.auth.RegenerateRecoveryCodes(claims.UserID, form.Code)
+	if err != nil {
+		return op, usersError(err)
+	}
+
+	return op, handler.WriteJSON(w, http.StatusOK, models.RecoveryCodesResponse{Codes: codes})
+}
+
+// DisableTOTP godoc
+//
+//	@Summary		DisableTOTP
+//	@Description	Turns two-factor authentication off
+//	@Tags			auth
+//
+//	@Param			request	body	models.TOTPDisableForm	true	"current password"
+//	@Accept			json
+//	@Produce		json
+//	@Failure		403	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	handler.APIResponse
+//	@Router			/auth/2fa/disable [post]
+func (ah *AuthHandlerGroup) DisableTOTP(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "handler.auth.disable-totp"
+
+	claims, ok := handler.SessionFromContext(r.Context())
+	if !ok {
+		return op, handler.UnauthorizedErrorResponse()
+	}
+
+	form := new(models.TOTPDisableForm)
+	if err := handler.DecodeRequest(r, form); err != nil {
+		return op, err
+	}
+
+	if err := handler.Validate(form); err != nil {
+		return op, err
+	}
+
+	if err := ah.auth.DisableTOTP(claims.UserID, form.Password); err != nil {
+		return op, usersError(err)
+	}
+
+	return op, handler.StatusOK(w, "two-factor authentication disabled")
+}
@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+	agentrpc "github.com/eterline/desky-backend/internal/services/agent-rpc"
+	"github.com/eterline/desky-backend/internal/services/handler"
+)
+
+// minReportInterval - shortest agent reporting interval set by command
+const minReportInterval = time.Second
+
+type AgentCommander interface {
+	Call(ctx context.Context, hostID string, cmd models.AgentCommand, args models.AgentCommandArgs, timeout time.Duration) (models.AgentCommandResponse, error)
+}
+
+// SetCommands - sets agent commands client
+func (mc *MonitoringControllers) SetCommands(c AgentCommander) {
+	mc.commands = c
+}
+
+// AgentCommand godoc
+//
+//	@Summary		AgentCommand
+//	@Description	Sends command to agent over MQTT and waits it's response: refresh stats now, set reporting interval,
+//	@Description	list processes or restart systemd unit. Timeout is 10s by default and 1m at most
+//	@Tags			agent
+//
+//	@Param			id		path	string							true	"agent host id"
+//	@Param			request	body	models.RequestFormAgentCommand	true	"command"
+//	@Accept			json
+//	@Produce		json
+//	@Failure		400	{object}	handler.APIErrorResponse
+//	@Failure		404	{object}	handler.APIErrorResponse
+//	@Failure		409	{object}	handler.APIErrorResponse
+//	@Failure		502	{object}	handler.APIErrorResponse
+//	@Failure		504	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	models.AgentCommandResponse
+//	@Router			/agent/{id}/command [post]
+func (mc *MonitoringControllers) AgentCommand(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "agents.command"
+
+	p, err := handler.ParseURLParameters(r, handler.StrOpts("id"))
+	if err != nil {
+		return op, err
+	}
+
+	form := new(models.RequestFormAgentCommand)
+	if err := handler.DecodeRequest(r, form); err != nil {
+		return op, err
+	}
+
+	if err := handler.Validate(form); err != nil {
+		return op, err
+	}
+
+	if form.Command == models.CommandInterval {
+		interval, err := time.ParseDuration(form.Interval)
+		if err != nil || interval < minReportInterval {
+			return op, handler.NewErrorResponse(http.StatusBadRequest, ErrCommandInterval)
+		}
+	}
+
+	timeout := agentrpc.DefaultTimeout
+	if form.Timeout != "" {
+		if timeout, err = time.ParseDuration(form.Timeout); err != nil || timeout <= 0 || timeout > agentrpc.MaxTimeout {
+			return op, handler.NewErrorResponse(http.StatusBadRequest, ErrCommandTimeout)
+		}
+	}
+
+	id := p.GetStr("id")
+
+	agents := mc.monitor.List()
+	idx := slices.IndexFunc(agents, func(a models.SessionCredentials) bool { return a.ID == id })
+
+	switch {
+	case idx < 0:
+		return op, handler.NewErrorResponse(http.StatusNotFound, ErrAgentNotFound)
+	case agents[idx].State == models.PresenceOffline:
+		return op, handler.NewErrorResponse(http.StatusConflict, ErrAgentOffline)
+	}
+
+	resp, err := mc.commands.Call(r.Context(), id, form.Command, models.AgentCommandArgs{
+		Interval: form.Interval,
+		Unit:     form.Unit,
+	}, timeout)
+	if err != nil {
+		return op, commandError(err)
+	}
+
+	return op, handler.WriteJSON(w, http.StatusOK, resp)
+}
+
+func commandError(err error) error {
+	switch {
+	case errors.Is(err, agentrpc.ErrHostID):
+		return handler.NewErrorResponse(http.StatusBadRequest, err)
+	case errors.Is(err, agentrpc.ErrTimeout):
+		return handler.NewErrorResponse(http.StatusGatewayTimeout, err)
+	}
+	return handler.NewErrorResponse(http.StatusBadGateway, err)
+}
@@ -4,9 +4,44 @@ import "errors"
 
 var (
 	ErrUncorrectCredentials = errors.New("uncorrect login credentials")
+	ErrUserNotFound         = errors.New("user not found")
+	ErrTokenNotFound        = errors.New("token not found")
+	ErrTooManyAttempts      = errors.New("too many failed login attempts, try again later")
+	ErrAuditQuery           = errors.New("invalid audit query parameters")
+	ErrSSOLogin             = errors.New("single sign-on login failed")
 )
 
 var (
 	ErrWSNotOpened        = errors.New("websocket did not opened")
 	ErrUnknownUnitCommand = errors.New("unknown unit command")
+	ErrHistoryQuery       = errors.New("invalid history query parameters")
+	ErrAgentNotFound      = errors.New("agent not found")
+	ErrAgentOffline       = errors.New("agent is offline")
+	ErrCommandInterval    = errors.New("invalid agent reporting interval")
+	ErrCommandTimeout     = errors.New("invalid agent command timeout")
+	ErrHostKeyNotFound    = errors.New("host key not found")
+	ErrNoPendingHostKey   = errors.New("host has no pending key to accept")
+	ErrKnownHosts         = errors.New("invalid known_hosts content")
+	ErrRecordingNotFound  = errors.New("session recording not found")
+	ErrRecordingQuery     = errors.New("invalid recordings query parameters")
+	ErrSessionID          = errors.New("invalid terminal session id")
+	ErrAccessMode         = errors.New("unknown terminal access mode")
+	ErrHostNotFound       = errors.New("ssh host not found")
+	ErrJumpHostNotFound   = errors.New("jump host not found")
+	ErrJumpHostLoop       = errors.New("jump host chain has a loop")
+	ErrJumpHostInUse      = errors.New("host is used as jump host by other hosts")
+	ErrHostHasTunnels     = errors.New("host has port forwarding tunnels")
+	ErrTunnelNotFound     = errors.New("ssh tunnel not found")
+	ErrKeyNotFound        = errors.New("ssh key not found")
+	ErrKeyNameExists      = errors.New("ssh key with this name already exists")
+	ErrKeyInUse           = errors.New("ssh key is used by hosts")
+	ErrFileNotFound       = errors.New("file not found")
+	ErrFileMode           = errors.New("invalid file mode")
+	ErrFileName           = errors.New("invalid file name")
+	ErrFileTooLarge       = errors.New("uploaded file is too large")
+	ErrFileExists         = errors.New("file already exists")
+	ErrFileIsDir          = errors.New("path is a directory")
+	ErrFileRoot           = errors.New("operation is not allowed on files root")
+	ErrAlertRuleNotFound  = errors.New("alert rule not found")
+	ErrAlertDuration      = errors.New("invalid alert rule duration")
 )
@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/internal/services/audit"
+	"github.com/eterline/desky-backend/internal/services/handler"
+	sshlander "github.com/eterline/desky-backend/internal/services/ssh-lander"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// defaultExecParallel - hosts running command at the same time when it's not configured
+const defaultExecParallel = 8
+
+// SetExec - sets limits of non-interactive commands and count of hosts running command at the same time
+func (mc *SSHLanderControllers) SetExec(opts sshlander.ExecOptions, parallel int) {
+	mc.exec = opts
+	mc.execParallel = parallel
+}
+
+// ExecCommand godoc
+//
+//	@Summary		ExecCommand
+//	@Description	Runs command without PTY in new session. Non-zero exit code is returned in result
+//	@Tags			ssh-exec
+//
+//	@Param			id		path	int						true	"ssh host id"
+//	@Param			request	body	models.RequestFormExec	true	"command and timeout in seconds"
+//	@Accept			json
+//	@Produce		json
+//	@Failure		400	{object}	handler.APIErrorResponse
+//	@Failure		404	{object}	handler.APIErrorResponse
+//	@Failure		502	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	models.SSHExecObject
+//	@Router			/ssh/{id}/exec [post]
+func (mc *SSHLanderControllers) ExecCommand(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "sshlander.exec"
+
+	q, err := handler.ParseURLParameters(r, handler.NumOpts("id"))
+	if err != nil {
+		return op, err
+	}
+
+	form := new(models.RequestFormExec)
+	if err := handler.DecodeRequest(r, form); err != nil {
+		return op, err
+	}
+
+	if err := handler.Validate(form); err != nil {
+		return op, err
+	}
+
+	result, err := mc.execHost(r, q.GetInt("id"), form.Command, form.Timeout)
+	if err != nil {
+		return op, err
+	}
+
+	return op, handler.WriteJSON(w, http.StatusOK, result)
+}
+
+// ExecHosts godoc
+//
+//	@Summary		ExecHosts
+//	@Description	Runs command on several hosts in parallel. Host errors are returned in results
+//	@Tags			ssh-exec
+//
+//	@Param			request	body	models.RequestFormExecHosts	true	"host ids, command and timeout in seconds"
+//	@Accept			json
+//	@Produce		json
+//	@Failure		400	{object}	handler.APIErrorResponse
+//	@Success		200	{array}		models.SSHExecObject
+//	@Router			/ssh/exec [post]
+func (mc *SSHLanderControllers) ExecHosts(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "sshlander.exec-hosts"
+
+	form := new(models.RequestFormExecHosts)
+	if err := handler.DecodeRequest(r, form); err != nil {
+		return op, err
+	}
+
+	if err := handler.Validate(form); err != nil {
+		return op, err
+	}
+
+	hosts := slices.Compact(slices.Sorted(slices.Values(form.Hosts)))
+
+	parallel := mc.execParallel
+	if parallel <= 0 {
+		parallel = defaultExecParallel
+	}
+
+	var wg sync.WaitGroup
+	limit := make(chan struct{}, parallel)
+	results := make([]models.SSHExecObject, len(hosts))
+
+	for idx, id := range hosts {
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			limit <- struct{}{}
+			defer func() { <-limit }()
+
+			result, err := mc.execHost(r, id, form.Command, form.Timeout)
+			if err != nil {
+				result = &models.SSHExecObject{ID: id, ExitCode: -1, Error: errorMessage(err)}
+			}
+
+			results[idx] = *result
+		}()
+	}
+
+	wg.Wait()
+
+	return op, handler.WriteJSON(w, http.StatusOK, results)
+}
+
+// execHost - runs command on stored host and records it in audit log
+func (mc *SSHLanderControllers) execHost(r *http.Request, id int, command string, timeout int) (*models.SSHExecObject, error) {
+
+	creds, err := mc.repoSSH.QueryById(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, handler.NewErrorResponse(http.StatusNotFound, ErrHostNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	host := fmt.Sprintf("%s@%s", creds.Username, creds.Socket())
+
+	event := audit.Event(r, models.AuditSSHExec, fmt.Sprintf("%s (id: %d)", host, creds.ID))
+	event.Details = command
+
+	result, err := mc.runCommand(r.Context(), creds, command, timeout)
+	mc.audit.Record(event, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.SSHExecObject{
+		ID:        int(creds.ID),
+		Host:      host,
+		Stdout:    string(result.Stdout),
+		Stderr:    string(result.Stderr),
+		ExitCode:  result.ExitCode,
+		Signal:    result.Signal,
+		Duration:  result.Duration.Milliseconds(),
+		TimedOut:  result.TimedOut,
+		Truncated: result.Truncated,
+	}, nil
+}
+
+func (mc *SSHLanderControllers) runCommand(ctx context.Context, creds *models.SSHCredentialsT, command string, timeout int) (*sshlander.ExecResult, error) {
+
+	session, err := mc.connect(creds, uuid.New())
+	if err != nil {
+		return nil, gatewayError(err)
+	}
+	defer session.CloseDial()
+
+	opts := mc.exec
+	if requested := time.Duration(timeout) * time.Second; requested > 0 && (opts.Timeout <= 0 || requested < opts.Timeout) {
+		opts.Timeout = requested
+	}
+
+	result, err := session.Exec(ctx, command, opts)
+	if err != nil {
+		return nil, handler.NewErrorResponse(http.StatusBadGateway, err)
+	}
+
+	return result, nil
+}
+
+// errorMessage - returns error text shown to client
+func errorMessage(err error) string {
+	var apiErr *handler.APIErrorResponse
+	if errors.As(err, &apiErr) {
+		return fmt.Sprint(apiErr.Message)
+	}
+	return "internal server error"
+}
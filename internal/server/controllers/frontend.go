@@ -48,29 +48,6 @@ func (fh *FrontendHandlerGroup) WallpaperHandle(w http.ResponseWriter, r *http.R
 	return op, err
 }
 
-// func (fh *FrontendHandlerGroup) Login(w http.ResponseWriter, r *http.Request) (op string, err error) {
-// 	op = "handler.frontend.login"
-
-// 	form, err := authorization.DecodeCredentials(r)
-// 	if err != nil {
-// 		return op, err
-// 	}
-
-// 	token, err := fh.Auth.Token(authorization.NewPayload(form.GetUsername()))
-// 	if err != nil {
-// 		return op, err
-// 	}
-
-// 	if fh.Auth.IsValid(form) {
-// 		return op, handler.WriteJSON(w, http.StatusAccepted, NewTokenResponse(token))
-// 	}
-
-// 	return op, handler.NewErrorResponse(
-// 		http.StatusNotAcceptable,
-// 		ErrUncorrectCredentials,
-// 	)
-// }
-
 func AccessCheck(w http.ResponseWriter, r *http.Request) (op string, err error) {
 	op = "handler.frontend.access-check"
 
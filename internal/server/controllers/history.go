@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/internal/services/handler"
+	"github.com/eterline/desky-backend/internal/services/metrics"
+)
+
+// defaultHistoryRange - history range requested without 'from'
+const defaultHistoryRange = time.Hour
+
+type MetricsHistory interface {
+	History(hostID, metric string, from, to time.Time, step time.Duration) (*models.ResponseMetricHistory, error)
+	Metrics(hostID string) ([]string, error)
+}
+
+// SetHistory - sets storage of agent metrics history
+func (mc *MonitoringControllers) SetHistory(h MetricsHistory) {
+	mc.history = h
+}
+
+// AgentHistory godoc
+//
+//	@Summary		AgentHistory
+//	@Description	Shows agent metric values aggregated by step with min, max and average in every bucket.
+//	@Description	Range is the last hour by default, step splits range into 300 buckets by default
+//	@Tags			agent
+//
+//	@Param			id		path	string	true	"agent host id"
+//	@Param			metric	query	string	true	"metric name, e.g. cpu.load"
+//	@Param			from	query	int		false	"range start unix time"
+//	@Param			to		query	int		false	"range end unix time"
+//	@Param			step	query	string	false	"bucket length in seconds or duration like 5m"
+//	@Produce		json
+//	@Failure		400	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	models.ResponseMetricHistory
+//	@Router			/agent/{id}/history [get]
+func (mc *MonitoringControllers) AgentHistory(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "agents.history"
+
+	p, err := handler.ParseURLParameters(r, handler.StrOpts("id"))
+	if err != nil {
+		return op, err
+	}
+
+	q := r.URL.Query()
+
+	metric := q.Get("metric")
+	if metric == "" {
+		return op, handler.NewErrorResponse(http.StatusBadRequest, ErrHistoryQuery)
+	}
+
+	to := time.Now()
+	if q.Get("to") != "" {
+		if to, err = parseUnixTime(q.Get("to")); err != nil {
+			return op, handler.NewErrorResponse(http.StatusBadRequest, ErrHistoryQuery)
+		}
+	}
+
+	from := to.Add(-defaultHistoryRange)
+	if q.Get("from") != "" {
+		if from, err = parseUnixTime(q.Get("from")); err != nil {
+			return op, handler.NewErrorResponse(http.StatusBadRequest, ErrHistoryQuery)
+		}
+	}
+
+	var step time.Duration
+	if q.Get("step") != "" {
+		if step, err = parseStep(q.Get("step")); err != nil {
+			return op, handler.NewErrorResponse(http.StatusBadRequest, ErrHistoryQuery)
+		}
+	}
+
+	history, err := mc.history.History(p.GetStr("id"), metric, from, to, step)
+	if metrics.IsMetricsServiceError(err) {
+		return op, handler.NewErrorResponse(http.StatusBadRequest, err)
+	}
+	if err != nil {
+		return op, err
+	}
+
+	return op, handler.WriteJSON(w, http.StatusOK, history)
+}
+
+// AgentMetrics godoc
+//
+//	@Summary		AgentMetrics
+//	@Description	Shows names of agent metrics having history
+//	@Tags			agent
+//
+//	@Param			id	path	string	true	"agent host id"
+//	@Produce		json
+//	@Success		200	{array}		string
+//	@Success		204
+//	@Router			/agent/{id}/metrics [get]
+func (mc *MonitoringControllers) AgentMetrics(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "agents.metrics"
+
+	p, err := handler.ParseURLParameters(r, handler.StrOpts("id"))
+	if err != nil {
+		return op, err
+	}
+
+	list, err := mc.history.Metrics(p.GetStr("id"))
+	if err != nil {
+		return op, err
+	}
+
+	if handler.ListIsEmpty(w, list) {
+		return op, nil
+	}
+
+	return op, handler.WriteJSON(w, http.StatusOK, list)
+}
+
+func parseUnixTime(value string) (time.Time, error) {
+
+	sec, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(sec, 0), nil
+}
+
+// parseStep - parses step in seconds or duration format
+func parseStep(value string) (time.Duration, error) {
+
+	if sec, err := strconv.ParseUint(value, 10, 32); err == nil {
+		return time.Duration(sec) * time.Second, nil
+	}
+
+	return time.ParseDuration(value)
+}
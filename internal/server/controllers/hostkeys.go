@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/internal/services/handler"
+	sshlander "github.com/eterline/desky-backend/internal/services/ssh-lander"
+	"gorm.io/gorm"
+)
+
+// ListHostKeys godoc
+//
+//	@Summary		ListHostKeys
+//	@Description	Shows trusted host keys of SSH hosts and changed keys waiting for review
+//	@Tags			ssh
+//
+//	@Produce		json
+//	@Success		200	{array}	models.SSHHostKeyObject
+//	@Router			/ssh/hostkeys [get]
+func (mc *SSHLanderControllers) ListHostKeys(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "sshlander.list-host-keys"
+
+	keys, err := mc.hostKeys.HostKeys()
+	if err != nil {
+		return op, err
+	}
+
+	if handler.ListIsEmpty(w, keys) {
+		return op, nil
+	}
+
+	list := make([]models.SSHHostKeyObject, len(keys))
+	for i := range keys {
+		list[i] = keys[i].Object()
+	}
+
+	return op, handler.WriteJSON(w, http.StatusOK, list)
+}
+
+// AcceptHostKey godoc
+//
+//	@Summary		AcceptHostKey
+//	@Description	Trusts changed host key of SSH host instead of previous one
+//	@Tags			ssh
+//
+//	@Param			id	path	int	true	"ssh host id"
+//	@Produce		json
+//	@Failure		404	{object}	handler.APIErrorResponse
+//	@Failure		409	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	models.SSHHostKeyObject
+//	@Router			/ssh/hostkeys/{id}/accept [post]
+func (mc *SSHLanderControllers) AcceptHostKey(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "sshlander.accept-host-key"
+
+	q, err := handler.ParseURLParameters(r, handler.NumOpts("id"))
+	if err != nil {
+		return op, err
+	}
+
+	key, err := mc.hostKeys.HostKey(uint(q.GetInt("id")))
+	if err != nil {
+		return op, hostKeysError(err)
+	}
+
+	if !key.HasPending() {
+		return op, handler.NewErrorResponse(http.StatusConflict, ErrNoPendingHostKey)
+	}
+
+	key.Accept()
+
+	if err := mc.hostKeys.UpdateHostKey(key); err != nil {
+		return op, err
+	}
+
+	return op, handler.WriteJSON(w, http.StatusOK, key.Object())
+}
+
+// ResetHostKey godoc
+//
+//	@Summary		ResetHostKey
+//	@Description	Forgets host key of SSH host. Key of next connection will be trusted
+//	@Tags			ssh
+//
+//	@Param			id	path	int	true	"ssh host id"
+//	@Produce		json
+//	@Failure		404	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	handler.APIResponse
+//	@Router			/ssh/hostkeys/{id} [delete]
+func (mc *SSHLanderControllers) ResetHostKey(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "sshlander.reset-host-key"
+
+	q, err := handler.ParseURLParameters(r, handler.NumOpts("id"))
+	if err != nil {
+		return op, err
+	}
+
+	if err := mc.hostKeys.DeleteHostKey(uint(q.GetInt("id"))); err != nil {
+		return op, hostKeysError(err)
+	}
+
+	return op, handler.StatusOK(w, "host key reset")
+}
+
+// ImportKnownHosts godoc
+//
+//	@Summary		ImportKnownHosts
+//	@Description	Trusts keys of stored SSH hosts found in OpenSSH known_hosts content
+//	@Tags			ssh
+//
+//	@Param			request	body	models.RequestFormKnownHosts	true	"known_hosts content"
+//	@Accept			json
+//	@Produce		json
+//	@Failure		400	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	models.ResponseKnownHostsImport
+//	@Router			/ssh/hostkeys/import [post]
+func (mc *SSHLanderControllers) ImportKnownHosts(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "sshlander.import-known-hosts"
+
+	form := new(models.RequestFormKnownHosts)
+	if err := handler.DecodeRequest(r, form); err != nil {
+		return op, err
+	}
+
+	if err := handler.Validate(form); err != nil {
+		return op, err
+	}
+
+	hosts, err := mc.repoSSH.QueryAll()
+	if err != nil {
+		return op, err
+	}
+
+	addresses := make([]string, len(hosts))
+	for i, host := range hosts {
+		addresses[i] = host.Socket()
+	}
+
+	found, err := sshlander.KnownHostsKeys([]byte(form.KnownHosts), addresses)
+	if err != nil {
+		return op, handler.NewErrorResponse(http.StatusBadRequest, ErrKnownHosts)
+	}
+
+	keys := make([]models.SSHHostKeyT, 0, len(found))
+	for i, host := range hosts {
+		if key, ok := found[addresses[i]]; ok {
+			keys = append(keys, *sshlander.NewHostKey(host.ID, key, models.HostKeyKnownHosts))
+		}
+	}
+
+	imported, err := mc.hostKeys.ImportHostKeys(keys, form.Replace)
+	if err != nil {
+		return op, err
+	}
+
+	response := models.ResponseKnownHostsImport{
+		Imported: append(make([]uint, 0, len(imported)), imported...),
+		Skipped:  make([]uint, 0),
+	}
+
+	for _, key := range keys {
+		if !slices.Contains(imported, key.CredentialsID) {
+			response.Skipped = append(response.Skipped, key.CredentialsID)
+		}
+	}
+
+	return op, handler.WriteJSON(w, http.StatusOK, response)
+}
+
+func hostKeysError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return handler.NewErrorResponse(http.StatusNotFound, ErrHostKeyNotFound)
+	}
+	return err
+}
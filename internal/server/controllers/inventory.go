@@ -0,0 +1,237 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/internal/services/handler"
+	sshlander "github.com/eterline/desky-backend/internal/services/ssh-lander"
+	"github.com/eterline/desky-backend/pkg/sshconfig"
+	"gorm.io/gorm"
+)
+
+// EditHost godoc
+//
+//	@Summary		EditHost
+//	@Description	Changes host address, description, group and tags.
+//	@Description	Authentication settings are changed only when they're passed in 'auth'
+//	@Tags			ssh
+//
+//	@Param			id		path	int							true	"ssh host id"
+//	@Param			request	body	models.RequestFormSSHEdit	true	"host settings"
+//	@Accept			json
+//	@Produce		json
+//	@Failure		400	{object}	handler.APIErrorResponse
+//	@Failure		404	{object}	handler.APIErrorResponse
+//	@Failure		409	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	models.SSHInstanceObject
+//	@Router			/ssh/list/{id} [put]
+func (mc *SSHLanderControllers) EditHost(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "sshlander.edit-host"
+
+	q, err := handler.ParseURLParameters(r, handler.NumOpts("id"))
+	if err != nil {
+		return op, err
+	}
+
+	form := new(models.RequestFormSSHEdit)
+	if err := handler.DecodeRequest(r, form); err != nil {
+		return op, err
+	}
+
+	if err := handler.Validate(form); err != nil {
+		return op, err
+	}
+
+	creds, err := mc.repoSSH.QueryById(q.GetInt("id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return op, handler.NewErrorResponse(http.StatusNotFound, ErrHostNotFound)
+	}
+	if err != nil {
+		return op, err
+	}
+
+	if err := mc.checkJumpHost(creds.ID, form.JumpHost); err != nil {
+		return op, err
+	}
+
+	var secure *models.SSHSecureT
+
+	if form.Auth != nil {
+		auth, err := mc.hostSecure(form.Auth)
+		if err != nil {
+			return op, err
+		}
+		secure = &auth
+	}
+
+	creds.OperationSystem = models.MakeSSHSystemTypesT(form.System)
+	creds.Username, creds.Host, creds.Port = form.User, form.Host, form.Port
+
+	creds.JumpHostID = nil
+	if form.JumpHost != 0 {
+		creds.JumpHostID = &form.JumpHost
+	}
+
+	setInventory(creds, &form.RequestFormInventory)
+
+	if err := mc.repoSSH.UpdateHost(creds, secure); err != nil {
+		return op, err
+	}
+
+	updated, err := mc.repoSSH.QueryById(int(creds.ID))
+	if err != nil {
+		return op, err
+	}
+
+	return op, handler.WriteJSON(w, http.StatusOK, hostObject(updated))
+}
+
+// ImportHosts godoc
+//
+//	@Summary		ImportHosts
+//	@Description	Adds hosts of OpenSSH client config. IdentityFile is resolved to shared key by 'identities'
+//	@Description	or by key name equal to file name, hosts without key use passed password.
+//	@Description	ProxyJump is resolved to imported or stored hosts
+//	@Tags			ssh
+//
+//	@Param			request	body	models.RequestFormSSHImport	true	"ssh config and defaults of imported hosts"
+//	@Accept			json
+//	@Produce		json
+//	@Failure		400	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	models.ResponseSSHImport
+//	@Router			/ssh/import [post]
+func (mc *SSHLanderControllers) ImportHosts(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "sshlander.import-hosts"
+
+	form := new(models.RequestFormSSHImport)
+	if err := handler.DecodeRequest(r, form); err != nil {
+		return op, err
+	}
+
+	if err := handler.Validate(form); err != nil {
+		return op, err
+	}
+
+	cfg, err := sshconfig.Parse(strings.NewReader(form.Config))
+	if err != nil {
+		return op, handler.NewErrorResponse(http.StatusBadRequest, err)
+	}
+
+	keys, err := mc.keys.Keys()
+	if err != nil {
+		return op, err
+	}
+
+	for file, id := range form.Identities {
+		if !slices.ContainsFunc(keys, func(key models.SSHKeyT) bool { return key.ID == id }) {
+			return op, handler.NewErrorResponse(http.StatusBadRequest, fmt.Errorf("%w: %s", ErrKeyNotFound, file))
+		}
+	}
+
+	stored, err := mc.repoSSH.QueryAll()
+	if err != nil {
+		return op, err
+	}
+
+	system := form.System
+	if system == "" {
+		system = string(models.Linux)
+	}
+
+	plan := sshlander.PlanImport(cfg, stored, sshlander.ImportOptions{
+		System:     system,
+		User:       form.User,
+		Password:   form.Password,
+		Identities: form.Identities,
+		Keys:       keys,
+		Group:      cleanGroup(form.Group),
+		Tags:       cleanTags(form.Tags),
+	})
+
+	if err := mc.repoSSH.ImportHosts(plan.Hosts, plan.Jumps); err != nil {
+		return op, err
+	}
+
+	response := models.ResponseSSHImport{
+		Imported: make([]models.SSHImportedObject, len(plan.Hosts)),
+		Skipped:  append(make([]models.SSHImportedObject, 0, len(plan.Skipped)), plan.Skipped...),
+	}
+
+	for idx, host := range plan.Hosts {
+		response.Imported[idx] = models.SSHImportedObject{
+			Alias: plan.Aliases[idx],
+			ID:    host.ID,
+			Host:  fmt.Sprintf("%s@%s", host.Username, host.Socket()),
+		}
+	}
+
+	return op, handler.WriteJSON(w, http.StatusOK, response)
+}
+
+func hostObject(creds *models.SSHCredentialsT) models.SSHInstanceObject {
+
+	obj := models.SSHInstanceObject{
+		ID:            int(creds.ID),
+		HostString:    fmt.Sprintf("%s@%s", creds.Username, creds.Socket()),
+		Name:          creds.Name,
+		User:          creds.Username,
+		Address:       creds.Host,
+		Port:          creds.Port,
+		System:        models.StringSSHtypeOS(string(creds.OperationSystem.SystemType)),
+		Description:   creds.Description,
+		Group:         creds.Group,
+		Tags:          creds.TagList(),
+		PrivateKeyUse: creds.UsePrivateKey(),
+		Certificate:   len(creds.Certificate()) > 0,
+	}
+
+	if creds.Security.KeyID != nil {
+		obj.KeyID = *creds.Security.KeyID
+	}
+
+	if creds.JumpHostID != nil {
+		obj.JumpHost = *creds.JumpHostID
+	}
+
+	return obj
+}
+
+func setInventory(creds *models.SSHCredentialsT, form *models.RequestFormInventory) {
+	creds.Name = strings.TrimSpace(form.Name)
+	creds.Description = strings.TrimSpace(form.Description)
+	creds.Group = cleanGroup(form.Group)
+	creds.Tags = models.MakeSSHHostTags(cleanTags(form.Tags))
+}
+
+// cleanGroup - normalizes folder path, e.g. '/prod//db/' becomes 'prod/db'
+func cleanGroup(group string) string {
+	if strings.TrimSpace(group) == "" {
+		return ""
+	}
+	return strings.Trim(path.Clean("/"+strings.TrimSpace(group)), "/")
+}
+
+// inGroup - reports that group is folder or subfolder of filter. Empty filter matches all groups
+func inGroup(group, filter string) bool {
+	return filter == "" || group == filter || strings.HasPrefix(group, filter+"/")
+}
+
+// cleanTags - trims tags and removes duplicates keeping order
+func cleanTags(tags []string) []string {
+
+	result := make([]string, 0, len(tags))
+
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(tag); tag != "" && !slices.Contains(result, tag) {
+			result = append(result, tag)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/internal/services/handler"
+	sshlander "github.com/eterline/desky-backend/internal/services/ssh-lander"
+	"github.com/eterline/desky-backend/internal/services/tunnels"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SetDialTimeout - sets timeout of connection to host and each of it's jump hosts
+func (mc *SSHLanderControllers) SetDialTimeout(timeout time.Duration) {
+	mc.dialTimeout = timeout
+}
+
+// connect - opens session to host through it's jump hosts
+func (mc *SSHLanderControllers) connect(creds *models.SSHCredentialsT, id uuid.UUID) (*sshlander.SSHSession, error) {
+
+	hostKeys, hops, err := mc.route(creds)
+	if err != nil {
+		return nil, err
+	}
+
+	return sshlander.NewClientSession(creds, hostKeys, id, mc.dialTimeout, hops...)
+}
+
+// DialHost - connects to stored host through it's jump hosts for tunnels
+func (mc *SSHLanderControllers) DialHost(credentialsID uint) (tunnels.Conn, error) {
+
+	creds, err := mc.repoSSH.QueryById(int(credentialsID))
+	if err != nil {
+		return nil, err
+	}
+
+	hostKeys, hops, err := mc.route(creds)
+	if err != nil {
+		return nil, err
+	}
+
+	return sshlander.NewClient(creds, hostKeys, mc.dialTimeout, hops...)
+}
+
+// route - returns host key policy of host and it's jump hosts
+func (mc *SSHLanderControllers) route(creds *models.SSHCredentialsT) (sshlander.HostKeyPolicy, []sshlander.Hop, error) {
+
+	hostKeys, err := sshlander.TrustOnFirstUse(mc.hostKeys, creds.ID)
+	if err != nil {
+		return hostKeys, nil, err
+	}
+
+	hops, err := mc.jumpHops(creds)
+	return hostKeys, hops, err
+}
+
+// jumpHops - resolves jump hosts of host. Hops are ordered from the nearest to desky
+func (mc *SSHLanderControllers) jumpHops(creds *models.SSHCredentialsT) ([]sshlander.Hop, error) {
+
+	var hops []sshlander.Hop
+
+	visited := map[uint]bool{creds.ID: true}
+
+	for next := creds.JumpHostID; next != nil; {
+
+		if visited[*next] {
+			return nil, handler.NewErrorResponse(http.StatusConflict, ErrJumpHostLoop)
+		}
+
+		if len(hops) == sshlander.MaxJumpHops {
+			return nil, handler.NewErrorResponse(http.StatusConflict, sshlander.ErrJumpHops)
+		}
+
+		jump, err := mc.repoSSH.QueryById(int(*next))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, handler.NewErrorResponse(http.StatusConflict, ErrJumpHostNotFound)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		hostKeys, err := sshlander.TrustOnFirstUse(mc.hostKeys, jump.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		hops = append([]sshlander.Hop{{Credentials: jump, HostKeys: hostKeys}}, hops...)
+
+		visited[jump.ID] = true
+		next = jump.JumpHostID
+	}
+
+	return hops, nil
+}
+
+// checkJumpHost - validates jump host of host. Host id is zero for new host
+func (mc *SSHLanderControllers) checkJumpHost(hostID, id uint) error {
+
+	if id == 0 {
+		return nil
+	}
+
+	if id == hostID {
+		return handler.NewErrorResponse(http.StatusConflict, ErrJumpHostLoop)
+	}
+
+	jump, err := mc.repoSSH.QueryById(int(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return handler.NewErrorResponse(http.StatusBadRequest, ErrJumpHostNotFound)
+	}
+	if err != nil {
+		return err
+	}
+
+	hops, err := mc.jumpHops(jump)
+	if err != nil {
+		return err
+	}
+
+	for _, hop := range hops {
+		if hop.Credentials.(*models.SSHCredentialsT).ID == hostID {
+			return handler.NewErrorResponse(http.StatusConflict, ErrJumpHostLoop)
+		}
+	}
+
+	if len(hops) >= sshlander.MaxJumpHops {
+		return handler.NewErrorResponse(http.StatusBadRequest, sshlander.ErrJumpHops)
+	}
+
+	return nil
+}
+
+// gatewayError - connection errors of remote host are reported as bad gateway
+func gatewayError(err error) error {
+	var apiErr *handler.APIErrorResponse
+	if errors.As(err, &apiErr) {
+		return err
+	}
+	return handler.NewErrorResponse(http.StatusBadGateway, err)
+}
@@ -0,0 +1,321 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/internal/services/handler"
+	sshlander "github.com/eterline/desky-backend/internal/services/ssh-lander"
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+)
+
+// SetKeys - sets repository of named keys shared by hosts
+func (mc *SSHLanderControllers) SetKeys(keys SSHKeysRepository) {
+	mc.keys = keys
+}
+
+// ListKeys godoc
+//
+//	@Summary		ListKeys
+//	@Description	Lists shared keys with their certificates and count of hosts using them
+//	@Tags			ssh-keys
+//
+//	@Produce		json
+//	@Success		200	{array}	models.SSHKeyObject
+//	@Success		204
+//	@Router			/ssh/keys [get]
+func (mc *SSHLanderControllers) ListKeys(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "sshlander.list-keys"
+
+	keys, err := mc.keys.Keys()
+	if err != nil {
+		return op, err
+	}
+
+	if handler.ListIsEmpty(w, keys) {
+		return op, nil
+	}
+
+	list := make([]models.SSHKeyObject, len(keys))
+
+	for idx := range keys {
+		if list[idx], err = mc.keyObject(&keys[idx]); err != nil {
+			return op, err
+		}
+	}
+
+	return op, handler.WriteJSON(w, http.StatusOK, list)
+}
+
+// CreateKey godoc
+//
+//	@Summary		CreateKey
+//	@Description	Stores named private key which can be used by many hosts.
+//	@Description	Key can be protected by passphrase and have OpenSSH user certificate
+//	@Tags			ssh-keys
+//
+//	@Param			request	body	models.RequestFormKey	true	"key name, private key, passphrase and certificate"
+//	@Accept			json
+//	@Produce		json
+//	@Failure		400	{object}	handler.APIErrorResponse
+//	@Failure		409	{object}	handler.APIErrorResponse
+//	@Success		201	{object}	models.SSHKeyObject
+//	@Router			/ssh/keys [post]
+func (mc *SSHLanderControllers) CreateKey(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "sshlander.create-key"
+
+	form := new(models.RequestFormKey)
+	if err := handler.DecodeRequest(r, form); err != nil {
+		return op, err
+	}
+
+	if err := handler.Validate(form); err != nil {
+		return op, err
+	}
+
+	if err := mc.checkKeyName(form.Name, 0); err != nil {
+		return op, err
+	}
+
+	key := &models.SSHKeyT{
+		Name:        form.Name,
+		PrivateKey:  form.PrivateKey,
+		Passphrase:  form.Passphrase,
+		Certificate: form.Certificate,
+	}
+
+	if err := inspectKey(key); err != nil {
+		return op, err
+	}
+
+	if err := mc.keys.CreateKey(key); err != nil {
+		return op, err
+	}
+
+	obj, err := mc.keyObject(key)
+	if err != nil {
+		return op, err
+	}
+
+	return op, handler.WriteJSON(w, http.StatusCreated, obj)
+}
+
+// UpdateKey godoc
+//
+//	@Summary		UpdateKey
+//	@Description	Renames key, replaces it's certificate or private key. Without private key stored one is kept
+//	@Tags			ssh-keys
+//
+//	@Param			id		path	int						true	"key id"
+//	@Param			request	body	models.RequestFormKey	true	"key name, private key, passphrase and certificate"
+//	@Accept			json
+//	@Produce		json
+//	@Failure		400	{object}	handler.APIErrorResponse
+//	@Failure		404	{object}	handler.APIErrorResponse
+//	@Failure		409	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	models.SSHKeyObject
+//	@Router			/ssh/keys/{id} [put]
+func (mc *SSHLanderControllers) UpdateKey(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "sshlander.update-key"
+
+	q, err := handler.ParseURLParameters(r, handler.NumOpts("id"))
+	if err != nil {
+		return op, err
+	}
+
+	form := new(models.RequestFormKey)
+	if err := handler.DecodeRequest(r, form); err != nil {
+		return op, err
+	}
+
+	if err := handler.Validate(form); err != nil {
+		return op, err
+	}
+
+	key, err := mc.keys.KeyById(uint(q.GetInt("id")))
+	if err != nil {
+		return op, keysError(err)
+	}
+
+	if err := mc.checkKeyName(form.Name, key.ID); err != nil {
+		return op, err
+	}
+
+	key.Name, key.Certificate = form.Name, form.Certificate
+
+	if form.PrivateKey != "" {
+		key.PrivateKey, key.Passphrase = form.PrivateKey, form.Passphrase
+	}
+
+	if err := inspectKey(key); err != nil {
+		return op, err
+	}
+
+	if err := mc.keys.UpdateKey(key); err != nil {
+		return op, err
+	}
+
+	obj, err := mc.keyObject(key)
+	if err != nil {
+		return op, err
+	}
+
+	return op, handler.WriteJSON(w, http.StatusOK, obj)
+}
+
+// DeleteKey godoc
+//
+//	@Summary		DeleteKey
+//	@Description	Deletes shared key. Key used by hosts can't be deleted
+//	@Tags			ssh-keys
+//
+//	@Param			id	path	int	true	"key id"
+//	@Produce		json
+//	@Failure		404	{object}	handler.APIErrorResponse
+//	@Failure		409	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	handler.APIResponse
+//	@Router			/ssh/keys/{id} [delete]
+func (mc *SSHLanderControllers) DeleteKey(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "sshlander.delete-key"
+
+	q, err := handler.ParseURLParameters(r, handler.NumOpts("id"))
+	if err != nil {
+		return op, err
+	}
+
+	id := uint(q.GetInt("id"))
+
+	if _, err := mc.keys.KeyById(id); err != nil {
+		return op, keysError(err)
+	}
+
+	hosts, err := mc.keys.KeyUsage(id)
+	if err != nil {
+		return op, err
+	}
+
+	if hosts > 0 {
+		return op, handler.NewErrorResponse(http.StatusConflict, ErrKeyInUse)
+	}
+
+	if err := mc.keys.DeleteKey(id); err != nil {
+		return op, err
+	}
+
+	return op, handler.StatusOK(w, "key deleted")
+}
+
+// hostSecure - makes host authentication settings. Private key is checked before it's stored
+func (mc *SSHLanderControllers) hostSecure(form *models.RequestFormSSHAuth) (models.SSHSecureT, error) {
+
+	secure := models.MakeSSHSecureT(form.Password, form.PrivateKeyUse, form.PrivateKey)
+
+	if form.KeyID != 0 {
+
+		if _, err := mc.keys.KeyById(form.KeyID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return secure, handler.NewErrorResponse(http.StatusBadRequest, ErrKeyNotFound)
+			}
+			return secure, err
+		}
+
+		secure.PrivateKeyUse, secure.PrivateKey = true, ""
+		secure.KeyID = &form.KeyID
+
+		return secure, nil
+	}
+
+	if !form.PrivateKeyUse {
+		return secure, nil
+	}
+
+	secure.Passphrase, secure.Certificate = form.Passphrase, form.Certificate
+
+	_, err := sshlander.ParseSigner([]byte(form.PrivateKey), []byte(form.Passphrase), []byte(form.Certificate))
+	if err != nil {
+		return secure, handler.NewErrorResponse(http.StatusBadRequest, err)
+	}
+
+	return secure, nil
+}
+
+func (mc *SSHLanderControllers) checkKeyName(name string, id uint) error {
+
+	same, err := mc.keys.KeyByName(name)
+	if err != nil {
+		return err
+	}
+
+	if same != nil && same.ID != id {
+		return handler.NewErrorResponse(http.StatusConflict, ErrKeyNameExists)
+	}
+
+	return nil
+}
+
+func (mc *SSHLanderControllers) keyObject(key *models.SSHKeyT) (models.SSHKeyObject, error) {
+
+	hosts, err := mc.keys.KeyUsage(key.ID)
+	if err != nil {
+		return models.SSHKeyObject{}, err
+	}
+
+	obj := models.SSHKeyObject{
+		ID:          key.ID,
+		Name:        key.Name,
+		KeyType:     key.KeyType,
+		Fingerprint: key.Fingerprint,
+		Hosts:       hosts,
+		CreatedAt:   key.CreatedAt.Unix(),
+	}
+
+	if key.Certificate == "" {
+		return obj, nil
+	}
+
+	cert, err := sshlander.ParseCertificate([]byte(key.Certificate))
+	if err != nil {
+		return obj, nil
+	}
+
+	obj.Certificate = &models.SSHCertificateObject{
+		KeyID:      cert.KeyId,
+		Serial:     cert.Serial,
+		Principals: cert.ValidPrincipals,
+		Authority:  ssh.FingerprintSHA256(cert.SignatureKey),
+		ValidAfter: int64(cert.ValidAfter),
+	}
+
+	if cert.ValidBefore != ssh.CertTimeInfinity {
+		obj.Certificate.ValidBefore = int64(cert.ValidBefore)
+	}
+
+	return obj, nil
+}
+
+// inspectKey - checks that key can be used for authentication and fills it's public info
+func inspectKey(key *models.SSHKeyT) error {
+
+	signer, err := sshlander.ParseSigner([]byte(key.PrivateKey), []byte(key.Passphrase), []byte(key.Certificate))
+	if err != nil {
+		return handler.NewErrorResponse(http.StatusBadRequest, err)
+	}
+
+	pub := signer.PublicKey()
+	if cert, ok := pub.(*ssh.Certificate); ok {
+		pub = cert.Key
+	}
+
+	key.KeyType, key.Fingerprint = pub.Type(), ssh.FingerprintSHA256(pub)
+
+	return nil
+}
+
+func keysError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return handler.NewErrorResponse(http.StatusNotFound, ErrKeyNotFound)
+	}
+	return err
+}
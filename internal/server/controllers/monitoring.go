@@ -16,8 +16,16 @@ type MonitorProvider interface {
 	Pool() (<-chan any, context.CancelFunc)
 }
 
+// AlertsWatcher - provides alert state changes for monitor WebSocket
+type AlertsWatcher interface {
+	Watch() (<-chan models.AlertEvent, context.CancelFunc)
+}
+
 type MonitoringControllers struct {
 	monitor   MonitorProvider
+	history   MetricsHistory
+	alerts    AlertsWatcher
+	commands  AgentCommander
 	wsHandler *handler.WebSocketHandler
 	ctx       context.Context
 }
@@ -39,6 +47,10 @@ func InitMonitoring(ctx context.Context, m MonitorProvider, compress bool) *Moni
 	}
 }
 
+func (mc *MonitoringControllers) SetAlerts(a AlertsWatcher) {
+	mc.alerts = a
+}
+
 func (mc *MonitoringControllers) Monitor(w http.ResponseWriter, r *http.Request) (op string, err error) {
 	op = "agents.monitor"
 
@@ -73,6 +85,13 @@ func (mc *MonitoringControllers) MonitorWS(w http.ResponseWriter, r *http.Reques
 	monitor, stop := mc.monitor.Pool()
 	defer stop()
 
+	var alerts <-chan models.AlertEvent
+	if mc.alerts != nil {
+		var stopAlerts context.CancelFunc
+		alerts, stopAlerts = mc.alerts.Watch()
+		defer stopAlerts()
+	}
+
 	for {
 		select {
 
@@ -81,6 +100,9 @@ func (mc *MonitoringControllers) MonitorWS(w http.ResponseWriter, r *http.Reques
 
 		case data := <-monitor:
 			json.NewEncoder(wr).Encode(data)
+
+		case event := <-alerts:
+			json.NewEncoder(wr).Encode(event)
 		}
 	}
 }
@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/internal/services/handler"
+	"github.com/eterline/desky-backend/internal/services/recording"
+	"gorm.io/gorm"
+)
+
+// ListRecordings godoc
+//
+//	@Summary		ListRecordings
+//	@Description	Shows recorded terminal sessions from newest to oldest
+//	@Tags			ssh
+//
+//	@Param			page	query	int		false	"page number starting from 1"
+//	@Param			count	query	int		false	"recordings per page"
+//	@Param			actor	query	string	false	"user login"
+//	@Param			host	query	int		false	"ssh host id"
+//	@Produce		json
+//	@Failure		400	{object}	handler.APIErrorResponse
+//	@Success		200	{array}		models.SSHRecordingObject
+//	@Router			/ssh/recordings [get]
+func (mc *SSHLanderControllers) ListRecordings(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "sshlander.list-recordings"
+
+	q := r.URL.Query()
+
+	var values [3]uint64
+	for i, key := range []string{"page", "count", "host"} {
+
+		if q.Get(key) == "" {
+			continue
+		}
+
+		values[i], err = strconv.ParseUint(q.Get(key), 10, 32)
+		if err != nil {
+			return op, handler.NewErrorResponse(http.StatusBadRequest, ErrRecordingQuery)
+		}
+	}
+
+	page, count, host := int(values[0]), int(values[1]), uint(values[2])
+
+	filter := models.RecordingFilter{
+		Actor:  q.Get("actor"),
+		HostID: host,
+		Limit:  clampPageSize(count),
+	}
+
+	if page > 1 {
+		filter.Offset = (page - 1) * filter.Limit
+	}
+
+	list, all, err := mc.recorder.Recordings(filter)
+	if err != nil {
+		return op, err
+	}
+
+	w.Header().Add("All-Count", strconv.FormatInt(all, 10))
+
+	if handler.ListIsEmpty(w, list) {
+		return op, nil
+	}
+
+	return op, handler.WriteJSON(w, http.StatusOK, list)
+}
+
+// DownloadRecording godoc
+//
+//	@Summary		DownloadRecording
+//	@Description	Downloads terminal session recording in asciicast v2 format
+//	@Tags			ssh
+//
+//	@Param			id	path	int	true	"recording id"
+//	@Produce		application/x-asciicast
+//	@Failure		404	{object}	handler.APIErrorResponse
+//	@Success		200
+//	@Router			/ssh/recordings/{id} [get]
+func (mc *SSHLanderControllers) DownloadRecording(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "sshlander.download-recording"
+
+	q, err := handler.ParseURLParameters(r, handler.NumOpts("id"))
+	if err != nil {
+		return op, err
+	}
+
+	row, file, err := mc.recorder.Open(uint(q.GetInt("id")))
+	if err != nil {
+		return op, recordingsError(err)
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "application/x-asciicast")
+	w.Header().Set(
+		"Content-Disposition",
+		fmt.Sprintf("attachment; filename=\"desky-session-%d.cast\"", row.ID),
+	)
+
+	http.ServeContent(w, r, "", row.StartedAt, file)
+	return op, nil
+}
+
+// DeleteRecording godoc
+//
+//	@Summary		DeleteRecording
+//	@Description	Deletes terminal session recording file and it's index
+//	@Tags			ssh
+//
+//	@Param			id	path	int	true	"recording id"
+//	@Produce		json
+//	@Failure		404	{object}	handler.APIErrorResponse
+//	@Failure		409	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	handler.APIResponse
+//	@Router			/ssh/recordings/{id} [delete]
+func (mc *SSHLanderControllers) DeleteRecording(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "sshlander.delete-recording"
+
+	q, err := handler.ParseURLParameters(r, handler.NumOpts("id"))
+	if err != nil {
+		return op, err
+	}
+
+	if err := mc.recorder.Delete(uint(q.GetInt("id"))); err != nil {
+		return op, recordingsError(err)
+	}
+
+	return op, handler.StatusOK(w, "recording deleted")
+}
+
+func recordingsError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, os.ErrNotExist):
+		return handler.NewErrorResponse(http.StatusNotFound, ErrRecordingNotFound)
+	case recording.IsRecordingServiceError(err):
+		return handler.NewErrorResponse(http.StatusConflict, err)
+	}
+	return err
+}
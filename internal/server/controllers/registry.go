@@ -0,0 +1,210 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/internal/services/handler"
+	"github.com/eterline/desky-backend/internal/services/registry"
+	"gorm.io/gorm"
+)
+
+// AgentForgetter - drops removed agent from monitoring
+type AgentForgetter interface {
+	Forget(hostID string)
+}
+
+type RegistryControllers struct {
+	registry *registry.Registry
+	monitor  AgentForgetter
+}
+
+func InitRegistry(reg *registry.Registry, m AgentForgetter) *RegistryControllers {
+	return &RegistryControllers{
+		registry: reg,
+		monitor:  m,
+	}
+}
+
+// ListAgents godoc
+//
+//	@Summary		ListAgents
+//	@Description	Shows registered agents. Unknown agents wait for approval, 'pending=true' shows only them
+//	@Tags			agent
+//
+//	@Param			pending	query	bool	false	"only agents waiting for approval"
+//	@Produce		json
+//	@Success		200	{array}	models.AgentObject
+//	@Success		204
+//	@Router			/agent/registry [get]
+func (rc *RegistryControllers) ListAgents(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "agents.registry.list"
+
+	list := rc.registry.Agents(r.URL.Query().Get("pending") == "true")
+
+	if handler.ListIsEmpty(w, list) {
+		return op, nil
+	}
+
+	return op, handler.WriteJSON(w, http.StatusOK, list)
+}
+
+// ApproveAgent godoc
+//
+//	@Summary		ApproveAgent
+//	@Description	Approves agent, it's stats are accepted from now on
+//	@Tags			agent
+//
+//	@Param			id	path	int	true	"registry id"
+//	@Produce		json
+//	@Failure		404	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	models.AgentObject
+//	@Router			/agent/registry/{id}/approve [post]
+func (rc *RegistryControllers) ApproveAgent(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "agents.registry.approve"
+
+	q, err := handler.ParseURLParameters(r, handler.NumOpts("id"))
+	if err != nil {
+		return op, err
+	}
+
+	obj, err := rc.registry.Approve(uint(q.GetInt("id")))
+	if err != nil {
+		return op, registryError(err)
+	}
+
+	return op, handler.WriteJSON(w, http.StatusOK, obj)
+}
+
+// EditAgent godoc
+//
+//	@Summary		EditAgent
+//	@Description	Renames and tags agent
+//	@Tags			agent
+//
+//	@Param			id		path	int						true	"registry id"
+//	@Param			request	body	models.RequestFormAgent	true	"agent name and tags"
+//	@Accept			json
+//	@Produce		json
+//	@Failure		400	{object}	handler.APIErrorResponse
+//	@Failure		404	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	models.AgentObject
+//	@Router			/agent/registry/{id} [patch]
+func (rc *RegistryControllers) EditAgent(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "agents.registry.edit"
+
+	q, err := handler.ParseURLParameters(r, handler.NumOpts("id"))
+	if err != nil {
+		return op, err
+	}
+
+	form := new(models.RequestFormAgent)
+	if err := handler.DecodeRequest(r, form); err != nil {
+		return op, err
+	}
+
+	if err := handler.Validate(form); err != nil {
+		return op, err
+	}
+
+	obj, err := rc.registry.Edit(uint(q.GetInt("id")), strings.TrimSpace(form.Name), cleanTags(form.Tags))
+	if err != nil {
+		return op, registryError(err)
+	}
+
+	return op, handler.WriteJSON(w, http.StatusOK, obj)
+}
+
+// RemoveAgent godoc
+//
+//	@Summary		RemoveAgent
+//	@Description	Removes agent from registry and monitoring. Agent is queued for approval again on it's next stats
+//	@Tags			agent
+//
+//	@Param			id	path	int	true	"registry id"
+//	@Produce		json
+//	@Failure		404	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	handler.APIResponse
+//	@Router			/agent/registry/{id} [delete]
+func (rc *RegistryControllers) RemoveAgent(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "agents.registry.remove"
+
+	q, err := handler.ParseURLParameters(r, handler.NumOpts("id"))
+	if err != nil {
+		return op, err
+	}
+
+	hostID, err := rc.registry.Remove(uint(q.GetInt("id")))
+	if err != nil {
+		return op, registryError(err)
+	}
+
+	rc.monitor.Forget(hostID)
+
+	return op, handler.StatusOK(w, "agent removed")
+}
+
+// CreateSecret godoc
+//
+//	@Summary		CreateSecret
+//	@Description	Generates agent secret. Agent payloads must be signed with it from now on:
+//	@Description	'signature' field is hex HMAC-SHA256 of 'host-id.timestamp.data', where data is raw JSON of 'data' field.
+//	@Description	Secret is shown only once
+//	@Tags			agent
+//
+//	@Param			id	path	int	true	"registry id"
+//	@Produce		json
+//	@Failure		404	{object}	handler.APIErrorResponse
+//	@Success		201	{object}	models.ResponseAgentSecret
+//	@Router			/agent/registry/{id}/secret [post]
+func (rc *RegistryControllers) CreateSecret(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "agents.registry.create-secret"
+
+	q, err := handler.ParseURLParameters(r, handler.NumOpts("id"))
+	if err != nil {
+		return op, err
+	}
+
+	secret, err := rc.registry.GenerateSecret(uint(q.GetInt("id")))
+	if err != nil {
+		return op, registryError(err)
+	}
+
+	return op, handler.WriteJSON(w, http.StatusCreated, secret)
+}
+
+// DeleteSecret godoc
+//
+//	@Summary		DeleteSecret
+//	@Description	Deletes agent secret, unsigned payloads are accepted unless signatures are required by configuration
+//	@Tags			agent
+//
+//	@Param			id	path	int	true	"registry id"
+//	@Produce		json
+//	@Failure		404	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	models.AgentObject
+//	@Router			/agent/registry/{id}/secret [delete]
+func (rc *RegistryControllers) DeleteSecret(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "agents.registry.delete-secret"
+
+	q, err := handler.ParseURLParameters(r, handler.NumOpts("id"))
+	if err != nil {
+		return op, err
+	}
+
+	obj, err := rc.registry.ClearSecret(uint(q.GetInt("id")))
+	if err != nil {
+		return op, registryError(err)
+	}
+
+	return op, handler.WriteJSON(w, http.StatusOK, obj)
+}
+
+func registryError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return handler.NewErrorResponse(http.StatusNotFound, ErrAgentNotFound)
+	}
+	return err
+}
@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/internal/services/audit"
+	"github.com/eterline/desky-backend/internal/services/handler"
+	sshlander "github.com/eterline/desky-backend/internal/services/ssh-lander"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// ListSessions godoc
+//
+//	@Summary		ListSessions
+//	@Description	Shows running terminal sessions with count of attached clients
+//	@Tags			ssh
+//
+//	@Produce		json
+//	@Success		200	{array}	models.SSHSessionObject
+//	@Router			/ssh/sessions [get]
+func (mc *SSHLanderControllers) ListSessions(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "sshlander.list-sessions"
+
+	sessions := mc.sessions.Sessions()
+
+	if handler.ListIsEmpty(w, sessions) {
+		return op, nil
+	}
+
+	list := make([]models.SSHSessionObject, len(sessions))
+	for i, s := range sessions {
+		list[i] = s.Object()
+	}
+
+	return op, handler.WriteJSON(w, http.StatusOK, list)
+}
+
+// AttachWS godoc
+//
+//	@Summary		AttachWS
+//	@Description	Attaches websocket client to running terminal session. Reattached clients get scrollback replay.
+//	@Description	'access=read' joins session read-only, 'access=write' allows input and is allowed for session owner and admins.
+//	@Description	Modes are same as /ssh/connect
+//	@Tags			ssh
+//
+//	@Param			uuid	path	string	true	"terminal session uuid"
+//	@Param			access	query	string	false	"read or write, default read"
+//	@Param			mode	query	string	false	"raw for binary terminal protocol"
+//	@Failure		400	{object}	handler.APIErrorResponse
+//	@Failure		403	{object}	handler.APIErrorResponse
+//	@Failure		404	{object}	handler.APIErrorResponse
+//	@Router			/ssh/sessions/{uuid}/attach [get]
+func (mc *SSHLanderControllers) AttachWS(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "sshlander.attach[WS]"
+
+	if !websocket.IsWebSocketUpgrade(r) {
+		return op, handler.StatusOK(w, "websocket connection only")
+	}
+
+	shared, err := mc.sharedFromURL(r)
+	if err != nil {
+		return op, err
+	}
+
+	access := sshlander.AccessMode(r.URL.Query().Get("access"))
+	switch access {
+	case "":
+		access = sshlander.AccessReadOnly
+	case sshlander.AccessReadWrite, sshlander.AccessReadOnly:
+	default:
+		return op, handler.NewErrorResponse(http.StatusBadRequest, ErrAccessMode)
+	}
+
+	// input into other user's terminal is allowed for admins only
+	if claims, ok := handler.SessionFromContext(r.Context()); ok && access == sshlander.AccessReadWrite {
+		if claims.UserID != shared.Meta().OwnerID && !claims.Role.Allows(models.RoleAdmin) {
+			return op, handler.ForbiddenRequestResponse()
+		}
+	}
+
+	raw := r.URL.Query().Get("mode") == TerminalModeRaw
+
+	meta := shared.Meta()
+	event := audit.Event(r, models.AuditSSHAttach, fmt.Sprintf("%s (id: %d)", meta.Host, meta.CredentialsID))
+	event.Details = fmt.Sprintf("session: %s access: %s", shared.ID(), access)
+
+	socket, err := mc.wsHandler.HandleConnect(w, r)
+	if err != nil {
+		return op, err
+	}
+	defer socket.Exit()
+
+	output := terminalOutput(socket, raw)
+	defer output.CloseWriting()
+
+	attached, err := mc.attachTerm(socket, output, shared, access, raw)
+	mc.audit.Record(event, err)
+	if err != nil {
+		if errors.Is(err, sshlander.ErrSessionClosed) {
+			socket.CloseWith(websocket.CloseNormalClosure, "terminal session end")
+		}
+		return op, err
+	}
+
+	return op, mc.serveTerm(socket, attached, raw, event)
+}
+
+// CloseSession godoc
+//
+//	@Summary		CloseSession
+//	@Description	Ends running terminal session and disconnects it's clients. Allowed for session owner and admins
+//	@Tags			ssh
+//
+//	@Param			uuid	path	string	true	"terminal session uuid"
+//	@Produce		json
+//	@Failure		403	{object}	handler.APIErrorResponse
+//	@Failure		404	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	handler.APIResponse
+//	@Router			/ssh/sessions/{uuid} [delete]
+func (mc *SSHLanderControllers) CloseSession(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "sshlander.close-session"
+
+	shared, err := mc.sharedFromURL(r)
+	if err != nil {
+		return op, err
+	}
+
+	if claims, ok := handler.SessionFromContext(r.Context()); ok {
+		if claims.UserID != shared.Meta().OwnerID && !claims.Role.Allows(models.RoleAdmin) {
+			return op, handler.ForbiddenRequestResponse()
+		}
+	}
+
+	if err := shared.Close(); err != nil {
+		mc.logging.Errorf("terminal: %s close error: %v", shared.ID(), err)
+	}
+
+	return op, handler.StatusOK(w, "terminal session closed")
+}
+
+func (mc *SSHLanderControllers) sharedFromURL(r *http.Request) (*sshlander.SharedTerminal, error) {
+
+	q, err := handler.ParseURLParameters(r, handler.StrOpts("uuid"))
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.Parse(q.GetStr("uuid"))
+	if err != nil {
+		return nil, handler.NewErrorResponse(http.StatusBadRequest, ErrSessionID)
+	}
+
+	shared, err := mc.sessions.Session(id)
+	if err != nil {
+		return nil, handler.NewErrorResponse(http.StatusNotFound, err)
+	}
+
+	return shared, nil
+}
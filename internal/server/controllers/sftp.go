@@ -0,0 +1,597 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/internal/services/audit"
+	"github.com/eterline/desky-backend/internal/services/handler"
+	sshlander "github.com/eterline/desky-backend/internal/services/ssh-lander"
+	"github.com/google/uuid"
+	"github.com/pkg/sftp"
+	"gorm.io/gorm"
+)
+
+// defaultMaxUpload - size limit of uploaded file when it's not configured
+const defaultMaxUpload = 1 << 30
+
+// SetFiles - sets remote directory available in file browser and upload size limit
+func (mc *SSHLanderControllers) SetFiles(root string, maxUpload int64) {
+	mc.filesRoot = root
+	mc.maxUpload = maxUpload
+}
+
+// ListFiles godoc
+//
+//	@Summary		ListFiles
+//	@Description	Lists remote directory over SFTP. Empty path shows home directory
+//	@Tags			ssh-files
+//
+//	@Param			id		path	int		true	"ssh host id"
+//	@Param			path	query	string	false	"directory path"
+//	@Produce		json
+//	@Failure		404	{object}	handler.APIErrorResponse
+//	@Success		200	{array}		models.SSHFileObject
+//	@Router			/ssh/{id}/files [get]
+func (mc *SSHLanderControllers) ListFiles(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "sshlander.list-files"
+
+	files, _, err := mc.openFiles(r)
+	if err != nil {
+		return op, err
+	}
+	defer files.Close()
+
+	dir, err := files.Resolve(r.URL.Query().Get("path"))
+	if err != nil {
+		return op, filesError(err)
+	}
+
+	list, err := files.ReadDir(dir)
+	if err != nil {
+		return op, filesError(err)
+	}
+
+	result := make([]models.SSHFileObject, len(list))
+	for i, info := range list {
+		result[i] = fileObject(path.Join(dir, info.Name()), info)
+	}
+
+	w.Header().Set("Path", dir)
+
+	return op, handler.WriteJSON(w, http.StatusOK, result)
+}
+
+// StatFile godoc
+//
+//	@Summary		StatFile
+//	@Description	Shows remote file info. Symbolic link itself is described
+//	@Tags			ssh-files
+//
+//	@Param			id		path	int		true	"ssh host id"
+//	@Param			path	query	string	true	"file path"
+//	@Produce		json
+//	@Failure		404	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	models.SSHFileObject
+//	@Router			/ssh/{id}/files/stat [get]
+func (mc *SSHLanderControllers) StatFile(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "sshlander.stat-file"
+
+	files, _, err := mc.openFiles(r)
+	if err != nil {
+		return op, err
+	}
+	defer files.Close()
+
+	name, err := files.Resolve(r.URL.Query().Get("path"))
+	if err != nil {
+		return op, filesError(err)
+	}
+
+	info, err := files.Lstat(name)
+	if err != nil {
+		return op, filesError(err)
+	}
+
+	return op, handler.WriteJSON(w, http.StatusOK, fileObject(name, info))
+}
+
+// DownloadFile godoc
+//
+//	@Summary		DownloadFile
+//	@Description	Streams remote file. Range requests are supported
+//	@Tags			ssh-files
+//
+//	@Param			id		path	int		true	"ssh host id"
+//	@Param			path	query	string	true	"file path"
+//	@Produce		octet-stream
+//	@Failure		400	{object}	handler.APIErrorResponse
+//	@Failure		404	{object}	handler.APIErrorResponse
+//	@Success		200
+//	@Success		206
+//	@Router			/ssh/{id}/files/download [get]
+func (mc *SSHLanderControllers) DownloadFile(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "sshlander.download-file"
+
+	files, creds, err := mc.openFiles(r)
+	if err != nil {
+		return op, err
+	}
+	defer files.Close()
+
+	name, err := files.Resolve(r.URL.Query().Get("path"))
+	if err != nil {
+		return op, filesError(err)
+	}
+
+	event := audit.Event(r, models.AuditFileDownload, fileTarget(creds, name))
+	defer func() { mc.audit.Record(event, err) }()
+
+	file, err := files.Open(name)
+	if err != nil {
+		return op, filesError(err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return op, filesError(err)
+	}
+
+	if info.IsDir() {
+		return op, handler.NewErrorResponse(http.StatusBadRequest, ErrFileIsDir)
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": info.Name(),
+	}))
+
+	http.ServeContent(w, r, "", info.ModTime(), file)
+	return op, nil
+}
+
+// UploadFiles godoc
+//
+//	@Summary		UploadFiles
+//	@Description	Uploads multipart form files into remote directory.
+//	@Description	Existing files are replaced only with 'overwrite=true'
+//	@Tags			ssh-files
+//
+//	@Param			id			path		int		true	"ssh host id"
+//	@Param			path		query		string	true	"target directory"
+//	@Param			overwrite	query		bool	false	"replace existing files"
+//	@Param			file		formData	file	true	"uploaded file, may be repeated"
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Failure		400	{object}	handler.APIErrorResponse
+//	@Failure		409	{object}	handler.APIErrorResponse
+//	@Failure		413	{object}	handler.APIErrorResponse
+//	@Success		201	{array}		models.SSHFileObject
+//	@Router			/ssh/{id}/files/upload [post]
+func (mc *SSHLanderControllers) UploadFiles(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "sshlander.upload-files"
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return op, handler.NewErrorResponse(http.StatusBadRequest, err)
+	}
+
+	files, creds, err := mc.openFiles(r)
+	if err != nil {
+		return op, err
+	}
+	defer files.Close()
+
+	dir, err := files.Resolve(r.URL.Query().Get("path"))
+	if err != nil {
+		return op, filesError(err)
+	}
+
+	overwrite, _ := strconv.ParseBool(r.URL.Query().Get("overwrite"))
+
+	uploaded := []models.SSHFileObject{}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return op, handler.NewErrorResponse(http.StatusBadRequest, err)
+		}
+
+		if part.FileName() == "" {
+			continue
+		}
+
+		if !sshlander.ValidFileName(part.FileName()) {
+			return op, handler.NewErrorResponse(http.StatusBadRequest, ErrFileName)
+		}
+
+		name := path.Join(dir, part.FileName())
+
+		event := audit.Event(r, models.AuditFileUpload, fileTarget(creds, name))
+		info, err := mc.upload(files, name, overwrite, part)
+		mc.audit.Record(event, err)
+		if err != nil {
+			return op, filesError(err)
+		}
+
+		uploaded = append(uploaded, fileObject(name, info))
+	}
+
+	if len(uploaded) == 0 {
+		return op, handler.NewErrorResponse(http.StatusBadRequest, ErrFileName)
+	}
+
+	return op, handler.WriteJSON(w, http.StatusCreated, uploaded)
+}
+
+// upload - writes uploaded file into temporary file of the same directory,
+// which replaces target after successful copy. Failed upload leaves target untouched
+func (mc *SSHLanderControllers) upload(files *sshlander.FileSession, name string, overwrite bool, src io.Reader) (os.FileInfo, error) {
+
+	limit := mc.maxUpload
+	if limit <= 0 {
+		limit = defaultMaxUpload
+	}
+
+	if !overwrite {
+		if _, err := files.Lstat(name); err == nil {
+			return nil, handler.NewErrorResponse(http.StatusConflict, ErrFileExists)
+		}
+	}
+
+	temp := path.Join(path.Dir(name), "."+path.Base(name)+".upload-"+uuid.NewString()[:8])
+
+	file, err := files.OpenFile(temp, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return nil, err
+	}
+
+	written, err := io.Copy(file, io.LimitReader(src, limit+1))
+	if err == nil && written > limit {
+		err = handler.NewErrorResponse(http.StatusRequestEntityTooLarge, ErrFileTooLarge)
+	}
+
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+
+	if err == nil {
+		if overwrite {
+			// replaced file keeps it's permissions
+			if info, serr := files.Stat(name); serr == nil {
+				files.Chmod(temp, info.Mode().Perm())
+			}
+			err = files.PosixRename(temp, name)
+		} else {
+			err = files.Rename(temp, name)
+		}
+	}
+
+	if err != nil {
+		files.Remove(temp)
+		return nil, err
+	}
+
+	return files.Stat(name)
+}
+
+// RenameFile godoc
+//
+//	@Summary		RenameFile
+//	@Description	Renames or moves remote file
+//	@Tags			ssh-files
+//
+//	@Param			id		path	int							true	"ssh host id"
+//	@Param			request	body	models.RequestFormRename	true	"source and target paths"
+//	@Accept			json
+//	@Produce		json
+//	@Failure		404	{object}	handler.APIErrorResponse
+//	@Failure		409	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	models.SSHFileObject
+//	@Router			/ssh/{id}/files/rename [post]
+func (mc *SSHLanderControllers) RenameFile(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "sshlander.rename-file"
+
+	form := new(models.RequestFormRename)
+	if err := handler.DecodeRequest(r, form); err != nil {
+		return op, err
+	}
+
+	if err := handler.Validate(form); err != nil {
+		return op, err
+	}
+
+	files, creds, err := mc.openFiles(r)
+	if err != nil {
+		return op, err
+	}
+	defer files.Close()
+
+	from, err := files.Resolve(form.From)
+	if err != nil {
+		return op, filesError(err)
+	}
+
+	to, err := files.Resolve(form.To)
+	if err != nil {
+		return op, filesError(err)
+	}
+
+	if files.IsRoot(from) || files.IsRoot(to) {
+		return op, handler.NewErrorResponse(http.StatusBadRequest, ErrFileRoot)
+	}
+
+	event := audit.Event(r, models.AuditFileRename, fileTarget(creds, from))
+	event.Details = "to: " + to
+
+	err = files.Rename(from, to)
+	mc.audit.Record(event, err)
+	if err != nil {
+		return op, filesError(err)
+	}
+
+	return op, mc.writeFile(w, files, to, http.StatusOK)
+}
+
+// MakeDir godoc
+//
+//	@Summary		MakeDir
+//	@Description	Creates remote directory
+//	@Tags			ssh-files
+//
+//	@Param			id		path	int						true	"ssh host id"
+//	@Param			request	body	models.RequestFormMkdir	true	"directory path"
+//	@Accept			json
+//	@Produce		json
+//	@Failure		409	{object}	handler.APIErrorResponse
+//	@Success		201	{object}	models.SSHFileObject
+//	@Router			/ssh/{id}/files/mkdir [post]
+func (mc *SSHLanderControllers) MakeDir(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "sshlander.make-dir"
+
+	form := new(models.RequestFormMkdir)
+	if err := handler.DecodeRequest(r, form); err != nil {
+		return op, err
+	}
+
+	if err := handler.Validate(form); err != nil {
+		return op, err
+	}
+
+	files, creds, err := mc.openFiles(r)
+	if err != nil {
+		return op, err
+	}
+	defer files.Close()
+
+	dir, err := files.Resolve(form.Path)
+	if err != nil {
+		return op, filesError(err)
+	}
+
+	event := audit.Event(r, models.AuditFileMkdir, fileTarget(creds, dir))
+
+	err = files.Mkdir(dir)
+	mc.audit.Record(event, err)
+	if err != nil {
+		return op, filesError(err)
+	}
+
+	return op, mc.writeFile(w, files, dir, http.StatusCreated)
+}
+
+// ChmodFile godoc
+//
+//	@Summary		ChmodFile
+//	@Description	Changes permission bits of remote file
+//	@Tags			ssh-files
+//
+//	@Param			id		path	int						true	"ssh host id"
+//	@Param			request	body	models.RequestFormChmod	true	"file path and octal mode"
+//	@Accept			json
+//	@Produce		json
+//	@Failure		400	{object}	handler.APIErrorResponse
+//	@Failure		404	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	models.SSHFileObject
+//	@Router			/ssh/{id}/files/chmod [post]
+func (mc *SSHLanderControllers) ChmodFile(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "sshlander.chmod-file"
+
+	form := new(models.RequestFormChmod)
+	if err := handler.DecodeRequest(r, form); err != nil {
+		return op, err
+	}
+
+	if err := handler.Validate(form); err != nil {
+		return op, err
+	}
+
+	mode, err := parseFileMode(form.Mode)
+	if err != nil {
+		return op, err
+	}
+
+	files, creds, err := mc.openFiles(r)
+	if err != nil {
+		return op, err
+	}
+	defer files.Close()
+
+	name, err := files.Resolve(form.Path)
+	if err != nil {
+		return op, filesError(err)
+	}
+
+	event := audit.Event(r, models.AuditFileChmod, fileTarget(creds, name))
+	event.Details = "mode: " + form.Mode
+
+	err = files.Chmod(name, mode)
+	mc.audit.Record(event, err)
+	if err != nil {
+		return op, filesError(err)
+	}
+
+	return op, mc.writeFile(w, files, name, http.StatusOK)
+}
+
+// DeleteFile godoc
+//
+//	@Summary		DeleteFile
+//	@Description	Deletes remote file or empty directory
+//	@Tags			ssh-files
+//
+//	@Param			id		path	int		true	"ssh host id"
+//	@Param			path	query	string	true	"file path"
+//	@Produce		json
+//	@Failure		404	{object}	handler.APIErrorResponse
+//	@Failure		409	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	handler.APIResponse
+//	@Router			/ssh/{id}/files [delete]
+func (mc *SSHLanderControllers) DeleteFile(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "sshlander.delete-file"
+
+	files, creds, err := mc.openFiles(r)
+	if err != nil {
+		return op, err
+	}
+	defer files.Close()
+
+	if r.URL.Query().Get("path") == "" {
+		return op, handler.NewErrorResponse(http.StatusBadRequest, ErrFileName)
+	}
+
+	name, err := files.Resolve(r.URL.Query().Get("path"))
+	if err != nil {
+		return op, filesError(err)
+	}
+
+	if files.IsRoot(name) {
+		return op, handler.NewErrorResponse(http.StatusBadRequest, ErrFileRoot)
+	}
+
+	event := audit.Event(r, models.AuditFileDelete, fileTarget(creds, name))
+
+	info, err := files.Lstat(name)
+	if err == nil {
+		if info.IsDir() {
+			err = files.RemoveDirectory(name)
+		} else {
+			err = files.Remove(name)
+		}
+	}
+
+	mc.audit.Record(event, err)
+	if err != nil {
+		return op, filesError(err)
+	}
+
+	return op, handler.StatusOK(w, "file deleted")
+}
+
+// openFiles - opens SFTP session to host from 'id' URL parameter
+func (mc *SSHLanderControllers) openFiles(r *http.Request) (*sshlander.FileSession, *models.SSHCredentialsT, error) {
+
+	q, err := handler.ParseURLParameters(r, handler.NumOpts("id"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	creds, err := mc.repoSSH.QueryById(q.GetInt("id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, handler.NewErrorResponse(http.StatusNotFound, ErrHostNotFound)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	session, err := mc.connect(creds, uuid.New())
+	if err != nil {
+		return nil, nil, gatewayError(err)
+	}
+
+	files, err := sshlander.ConnectFiles(session, mc.filesRoot)
+	if err != nil {
+		return nil, nil, handler.NewErrorResponse(http.StatusBadGateway, err)
+	}
+
+	return files, creds, nil
+}
+
+func (mc *SSHLanderControllers) writeFile(w http.ResponseWriter, files *sshlander.FileSession, name string, code int) error {
+
+	info, err := files.Lstat(name)
+	if err != nil {
+		return filesError(err)
+	}
+
+	return handler.WriteJSON(w, code, fileObject(name, info))
+}
+
+func fileObject(name string, info os.FileInfo) models.SSHFileObject {
+	return models.SSHFileObject{
+		Name:     info.Name(),
+		Path:     name,
+		Size:     info.Size(),
+		Mode:     info.Mode().String(),
+		Perm:     fmt.Sprintf("%04o", info.Mode().Perm()),
+		Dir:      info.IsDir(),
+		Link:     info.Mode()&os.ModeSymlink != 0,
+		Modified: info.ModTime().Unix(),
+	}
+}
+
+func fileTarget(creds *models.SSHCredentialsT, name string) string {
+	return fmt.Sprintf("%s@%s:%s (id: %d)", creds.Username, creds.Socket(), name, creds.ID)
+}
+
+// parseFileMode - parses octal permission bits with setuid, setgid and sticky bits
+func parseFileMode(value string) (os.FileMode, error) {
+
+	bits, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || bits > 07777 {
+		return 0, handler.NewErrorResponse(http.StatusBadRequest, ErrFileMode)
+	}
+
+	mode := os.FileMode(bits & 0777)
+
+	if bits&04000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if bits&02000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if bits&01000 != 0 {
+		mode |= os.ModeSticky
+	}
+
+	return mode, nil
+}
+
+func filesError(err error) error {
+
+	var status *sftp.StatusError
+
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return handler.NewErrorResponse(http.StatusNotFound, ErrFileNotFound)
+	case errors.Is(err, os.ErrPermission):
+		return handler.NewErrorResponse(http.StatusForbidden, err)
+	case errors.Is(err, sshlander.ErrPath), errors.Is(err, sshlander.ErrOutsideRoot):
+		return handler.NewErrorResponse(http.StatusBadRequest, err)
+	case errors.As(err, &status):
+		return handler.NewErrorResponse(http.StatusConflict, err)
+	}
+
+	return err
+}
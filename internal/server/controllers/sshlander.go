@@ -4,19 +4,23 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/internal/services/audit"
 	"github.com/eterline/desky-backend/internal/services/handler"
+	"github.com/eterline/desky-backend/internal/services/recording"
 	sshlander "github.com/eterline/desky-backend/internal/services/ssh-lander"
+	"github.com/eterline/desky-backend/internal/services/tunnels"
 	"github.com/eterline/desky-backend/pkg/logger"
 	"github.com/eterline/desky-backend/pkg/net-wait-go-forked/wait"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"github.com/sirupsen/logrus"
-	"golang.org/x/crypto/ssh"
 )
 
 const (
@@ -30,30 +34,76 @@ const (
 )
 
 type SSHRepository interface {
-	AddHost(username string, host string, port uint16, osType string, privateKeyUsage bool, password string, key string) error
+	AddHost(creds *models.SSHCredentialsT) error
+	UpdateHost(creds *models.SSHCredentialsT, secure *models.SSHSecureT) error
+	ImportHosts(hosts []models.SSHCredentialsT, jumps []int) error
 	Delete(id int) error
+	IsJumpHost(id int) (bool, error)
+	HasTunnels(id int) (bool, error)
 	QueryAll() ([]models.SSHCredentialsT, error)
 	QueryById(id int) (*models.SSHCredentialsT, error)
 }
 
+type HostKeyRepository interface {
+	sshlander.HostKeyRepository
+	HostKeys() ([]models.SSHHostKeyT, error)
+	UpdateHostKey(key *models.SSHHostKeyT) error
+	DeleteHostKey(credentialsID uint) error
+	ImportHostKeys(keys []models.SSHHostKeyT, replace bool) ([]uint, error)
+}
+
+type SSHKeysRepository interface {
+	Keys() ([]models.SSHKeyT, error)
+	KeyById(id uint) (*models.SSHKeyT, error)
+	KeyByName(name string) (*models.SSHKeyT, error)
+	CreateKey(key *models.SSHKeyT) error
+	UpdateKey(key *models.SSHKeyT) error
+	DeleteKey(id uint) error
+	KeyUsage(id uint) (int64, error)
+}
+
 type SSHLanderControllers struct {
 	ctx context.Context
 
 	term      sshlander.TerminalType
 	repoSSH   SSHRepository
+	hostKeys  HostKeyRepository
+	audit     AuditRecorder
+	recorder  *recording.Recorder
+	sessions  *sshlander.Registry
 	wsHandler *handler.WebSocketHandler
 
-	logging *logrus.Logger
+	dialTimeout time.Duration
+
+	filesRoot string
+	maxUpload int64
 
-	sshMu sync.Mutex
+	exec         sshlander.ExecOptions
+	execParallel int
+
+	tunnels *tunnels.Manager
+	keys    SSHKeysRepository
+
+	logging *logrus.Logger
 }
 
-func InitSSHlander(ctx context.Context, repo SSHRepository) *SSHLanderControllers {
+func InitSSHlander(
+	ctx context.Context,
+	repo SSHRepository,
+	keys HostKeyRepository,
+	rec AuditRecorder,
+	recorder *recording.Recorder,
+	sessions *sshlander.Registry,
+) *SSHLanderControllers {
 	return &SSHLanderControllers{
-		ctx:     ctx,
-		repoSSH: repo,
-		logging: logger.ReturnEntry().Logger,
-		term:    sshlander.XtermColored,
+		ctx:      ctx,
+		repoSSH:  repo,
+		hostKeys: keys,
+		audit:    rec,
+		recorder: recorder,
+		sessions: sessions,
+		logging:  logger.ReturnEntry().Logger,
+		term:     sshlander.XtermColored,
 
 		wsHandler: handler.NewWebSocketHandler(ctx, &websocket.Upgrader{
 			ReadBufferSize:    MiddleByteChunk,
@@ -73,28 +123,21 @@ func (mc *SSHLanderControllers) ListHosts(w http.ResponseWriter, r *http.Request
 		return op, err
 	}
 
-	if handler.ListIsEmpty(w, sshList) {
-		return op, nil
-	}
-
-	resultList := make([]models.SSHInstanceObject, len(sshList))
-
-	for idx, sshInst := range sshList {
+	q := r.URL.Query()
+	group, tag := cleanGroup(q.Get("group")), q.Get("tag")
 
-		hostString := fmt.Sprintf(
-			"%s@%s:%v",
-			sshInst.Username,
-			sshInst.Host,
-			sshInst.Port,
-		)
+	resultList := make([]models.SSHInstanceObject, 0, len(sshList))
 
-		resultList[idx] = models.SSHInstanceObject{
-			ID:            int(sshInst.ID),
-			HostString:    hostString,
-			PrivateKeyUse: sshInst.Security.PrivateKeyUse,
+	for _, sshInst := range sshList {
+		if inGroup(sshInst.Group, group) && (tag == "" || slices.Contains(sshInst.TagList(), tag)) {
+			resultList = append(resultList, hostObject(&sshInst))
 		}
 	}
 
+	if handler.ListIsEmpty(w, resultList) {
+		return op, nil
+	}
+
 	return op, handler.WriteJSON(w, http.StatusOK, resultList)
 }
 
@@ -110,17 +153,38 @@ func (mc *SSHLanderControllers) AppendHost(w http.ResponseWriter, r *http.Reques
 		return op, err
 	}
 
-	if err := mc.repoSSH.AddHost(
-		form.User, form.Host, form.Port,
-		form.System,
-		form.PrivateKeyUse,
-		form.Password, form.PrivateKey,
-	); err != nil {
+	if err := mc.checkJumpHost(0, form.JumpHost); err != nil {
+		return op, err
+	}
+
+	secure, err := mc.hostSecure(&form.RequestFormSSHAuth)
+	if err != nil {
+		return op, err
+	}
+
+	creds := &models.SSHCredentialsT{
+		OperationSystem: models.MakeSSHSystemTypesT(form.System),
+
+		Username: form.User,
+		Host:     form.Host,
+		Port:     form.Port,
+
+		Security: secure,
+	}
+
+	setInventory(creds, &form.RequestFormInventory)
+
+	if form.JumpHost != 0 {
+		creds.JumpHostID = &form.JumpHost
+	}
+
+	if err := mc.repoSSH.AddHost(creds); err != nil {
 		return op, err
 	}
 
 	response := models.ResponseCreateSSH{
-		PrivateKeyUse: form.PrivateKeyUse,
+		ID:            creds.ID,
+		PrivateKeyUse: secure.PrivateKeyUse,
 		Target:        fmt.Sprintf("%s@%s:%v", form.User, form.Host, form.Port),
 	}
 
@@ -135,6 +199,24 @@ func (mc *SSHLanderControllers) DeleteHost(w http.ResponseWriter, r *http.Reques
 		return op, err
 	}
 
+	isJump, err := mc.repoSSH.IsJumpHost(q.GetInt("id"))
+	if err != nil {
+		return op, err
+	}
+
+	if isJump {
+		return op, handler.NewErrorResponse(http.StatusConflict, ErrJumpHostInUse)
+	}
+
+	hasTunnels, err := mc.repoSSH.HasTunnels(q.GetInt("id"))
+	if err != nil {
+		return op, err
+	}
+
+	if hasTunnels {
+		return op, handler.NewErrorResponse(http.StatusConflict, ErrHostHasTunnels)
+	}
+
 	if err := mc.repoSSH.Delete(q.GetInt("id")); err != nil {
 		return op, err
 	}
@@ -156,12 +238,21 @@ func (mc *SSHLanderControllers) TestHosts(w http.ResponseWriter, r *http.Request
 		return op, nil
 	}
 
-	result := getPingedList(sshList)
+	jumps := make(map[uint][]sshlander.Hop)
+	for _, credential := range sshList {
+		if hops, err := mc.jumpHops(&credential); err == nil {
+			jumps[credential.ID] = hops
+		}
+	}
+
+	result := getPingedList(sshList, jumps)
 
 	return op, handler.WriteJSON(w, http.StatusOK, result)
 }
 
-func getPingedList(hostsData []models.SSHCredentialsT) []models.SSHTestObject {
+// getPingedList - checks hosts availability. Hosts behind jump hosts are dialed from last jump host,
+// hosts with broken jump chain are unavailable
+func getPingedList(hostsData []models.SSHCredentialsT, jumps map[uint][]sshlander.Hop) []models.SSHTestObject {
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 	testedList := make([]models.SSHTestObject, len(hostsData))
@@ -184,6 +275,13 @@ func getPingedList(hostsData []models.SSHCredentialsT) []models.SSHTestObject {
 				wg.Done()
 			}()
 
+			if credential.JumpHostID != nil {
+				if hops, ok := jumps[credential.ID]; ok {
+					attempt = sshlander.Reachable(credential.Socket(), hops, 5*time.Second)
+				}
+				return
+			}
+
 			attempt = wait.New(
 				wait.WithDeadline(5*time.Second),
 				wait.WithProto("tcp"),
@@ -202,9 +300,6 @@ func getPingedList(hostsData []models.SSHCredentialsT) []models.SSHTestObject {
 func (mc *SSHLanderControllers) ConnectionWS(w http.ResponseWriter, r *http.Request) (op string, err error) {
 	op = "sshlander.connection[WS]"
 
-	mc.sshMu.Lock()
-	defer mc.sshMu.Unlock()
-
 	if !websocket.IsWebSocketUpgrade(r) {
 		return op, handler.StatusOK(w, "websocket connection only")
 	}
@@ -223,38 +318,57 @@ func (mc *SSHLanderControllers) ConnectionWS(w http.ResponseWriter, r *http.Requ
 
 	// ================================================================
 
+	raw := r.URL.Query().Get("mode") == TerminalModeRaw
+	size := terminalSize(r)
+
 	socket, err := mc.wsHandler.HandleConnect(w, r)
 	if err != nil {
 		return op, err
 	}
 	defer socket.Exit()
 
-	wsBase64Writer := socket.InitWebSocketBase64Writing()
-	defer wsBase64Writer.CloseWriting()
+	output := terminalOutput(socket, raw)
+	defer output.CloseWriting()
+
+	event := audit.Event(r, models.AuditSSHOpen, fmt.Sprintf(
+		"%s@%s (id: %d)",
+		baseCredentials.Username, baseCredentials.Socket(), baseCredentials.ID,
+	))
 
-	term, err := mc.initTerm(socket.ID, baseCredentials, ssh.InsecureIgnoreHostKey())
+	term, err := mc.initTerm(socket.ID, baseCredentials, size)
+	mc.audit.Record(event, err)
 	if err != nil {
-		fmt.Fprintf(wsBase64Writer, "ssh connection error: %v", err)
+		fmt.Fprintf(output, "ssh connection error: %v", err)
+		if _, ok := sshlander.IsHostKeyError(err); ok {
+			socket.CloseWith(websocket.ClosePolicyViolation, "host key mismatch")
+		}
 		socket.Exit()
 		return op, err
 	}
-	defer term.CloseDial()
 
-	return op, mc.wsSSH(wsBase64Writer, socket, term)
+	shared := mc.shareTerm(socket.ID, term, baseCredentials, event, size)
+
+	attached, err := mc.attachTerm(socket, output, shared, sshlander.AccessReadWrite, raw)
+	if err != nil {
+		shared.Close()
+		return op, err
+	}
+
+	return op, mc.serveTerm(socket, attached, raw, event)
 }
 
 func (mc *SSHLanderControllers) initTerm(
 	uuid uuid.UUID,
-	creds sshlander.SessionCredentials,
-	callback ssh.HostKeyCallback,
+	creds *models.SSHCredentialsT,
+	size sshlander.TerminalSize,
 ) (*sshlander.TerminalSession, error) {
 
-	session, err := sshlander.NewClientSession(creds, callback, uuid)
+	session, err := mc.connect(creds, uuid)
 	if err != nil {
 		return nil, err
 	}
 
-	term, err := sshlander.ConnectTerminal(mc.ctx, session, mc.term)
+	term, err := sshlander.ConnectTerminal(mc.ctx, session, mc.term, size)
 	if err != nil {
 		session.CloseDial()
 		return nil, err
@@ -265,24 +379,112 @@ func (mc *SSHLanderControllers) initTerm(
 	return term, nil
 }
 
-func (mc *SSHLanderControllers) wsSSH(writer *handler.WebSocketBase64Writing, socket *handler.WebSocketSession, term *sshlander.TerminalSession) error {
+// shareTerm - registers terminal in session registry with it's recording.
+// Session close is audited when terminal ends, not when websocket client leaves
+func (mc *SSHLanderControllers) shareTerm(
+	id uuid.UUID,
+	term *sshlander.TerminalSession,
+	creds *models.SSHCredentialsT,
+	event *models.AuditEventT,
+	size sshlander.TerminalSize,
+) *sshlander.SharedTerminal {
+
+	host := fmt.Sprintf("%s@%s", creds.Username, creds.Socket())
+
+	rec, err := mc.recorder.Start(recording.Meta{
+		SessionID:     id.String(),
+		UserID:        event.UserID,
+		Actor:         event.Actor,
+		CredentialsID: creds.ID,
+		Host:          host,
+	}, int(size.Cols), int(size.Rows))
+	if err != nil {
+		mc.logging.Errorf("ws uuid: %s session recording error: %v", id, err)
+	}
 
-	defer mc.logging.Infof("ws uuid: %s ssh closed", term.UUID())
+	var tap sshlander.SessionTap
+	if rec != nil {
+		tap = rec
+	}
 
-	go func() {
+	shared := mc.sessions.Share(id, term, sshlander.SharedMeta{
+		OwnerID:       event.UserID,
+		Owner:         event.Actor,
+		CredentialsID: creds.ID,
+		Host:          host,
+	}, tap)
 
-		defer func() {
-			defer term.WriteExit()
-			mc.logging.Infof("ws uuid: %s terminal session end", term.UUID())
-		}()
+	opened := time.Now()
+
+	shared.OnClose(func() {
+		closed := *event
+		closed.Action = models.AuditSSHClose
+		closed.Details = fmt.Sprintf("session duration: %s", time.Since(opened).Round(time.Second))
+		mc.audit.Record(&closed, nil)
 
-		if err := term.FromTerminalBytes(writer, UsualByteChunk); err != nil {
-			mc.logging.Infof(
-				"ws uuid: %s terminal write error: %v",
-				term.UUID(), err,
-			)
+		if err := rec.Close(); err != nil {
+			mc.logging.Error(err)
 		}
-	}()
+
+		mc.logging.Infof("ssh terminal closed: %s uuid: %s", host, id)
+	})
+
+	return shared
+}
+
+// attachTerm - connects websocket client to shared terminal. Client socket is closed when terminal ends.
+// Raw mode client gets text message with session UUID and access mode before terminal output
+func (mc *SSHLanderControllers) attachTerm(
+	socket *handler.WebSocketSession,
+	output io.Writer,
+	shared *sshlander.SharedTerminal,
+	access sshlander.AccessMode,
+	raw bool,
+) (*sshlander.Attachment, error) {
+
+	if raw {
+		hello, _ := json.Marshal(models.SSHAttachWS{
+			Session: shared.ID().String(),
+			Access:  string(access),
+		})
+		if _, err := socket.InitWebSocketWriting(false).Write(hello); err != nil {
+			return nil, err
+		}
+	}
+
+	return shared.Attach(output, access, func() {
+		socket.CloseWith(websocket.CloseNormalClosure, "terminal session end")
+		socket.Exit()
+	})
+}
+
+// serveTerm - serves client input until client leaves or terminal ends. Terminal stays open after client leaves
+func (mc *SSHLanderControllers) serveTerm(
+	socket *handler.WebSocketSession,
+	attached *sshlander.Attachment,
+	raw bool,
+	event *models.AuditEventT,
+) error {
+
+	defer attached.Detach()
+
+	id := attached.Terminal().ID()
+
+	mc.logging.Infof("ws uuid: %s attached to terminal: %s access: %s", socket.ID, id, attached.Mode())
+	defer mc.logging.Infof("ws uuid: %s detached from terminal: %s", socket.ID, id)
+
+	if raw {
+		return mc.wsSSHRaw(socket, attached)
+	}
+
+	return mc.wsSSH(socket, attached, event)
+}
+
+func (mc *SSHLanderControllers) wsSSH(
+	socket *handler.WebSocketSession,
+	attached *sshlander.Attachment,
+	event *models.AuditEventT,
+) error {
 
 	data := new(models.SSHRequestWS)
 
@@ -298,8 +500,19 @@ func (mc *SSHLanderControllers) wsSSH(writer *handler.WebSocketBase64Writing, so
 			continue
 		}
 
+		// read-only clients can only watch
+		if attached.Mode() != sshlander.AccessReadWrite {
+			continue
+		}
+
+		command := *event
+		command.Action = models.AuditSSHCommand
+		command.Details = data.Command
+
 		//
-		if err := term.Send([]byte(data.Command)); err != nil {
+		_, err := attached.Write(append([]byte(data.Command), sshlander.LineBreak...))
+		mc.audit.Record(&command, err)
+		if err != nil {
 			mc.logging.Error(err)
 			return err
 		}
@@ -307,7 +520,7 @@ func (mc *SSHLanderControllers) wsSSH(writer *handler.WebSocketBase64Writing, so
 		mc.logging.Infof(
 			"ssh sent command: '%s' uuid: %s ",
 			data.Command,
-			term.SSHSession.UUID(),
+			attached.Terminal().ID(),
 		)
 	}
 
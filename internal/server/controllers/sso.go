@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/internal/services/audit"
+	"github.com/eterline/desky-backend/internal/services/authorization"
+	"github.com/eterline/desky-backend/internal/services/handler"
+	"github.com/eterline/desky-backend/internal/services/limiter"
+	"github.com/eterline/desky-backend/internal/services/sso"
+	"github.com/eterline/desky-backend/pkg/logger"
+	"github.com/eterline/desky-backend/pkg/oidc"
+)
+
+const ssoStateCookie = "desky_oidc_state"
+
+type SSOProvider interface {
+	Begin(ctx context.Context) (state, target string, err error)
+	Complete(ctx context.Context, state, code string) (*models.ExternalIdentity, error)
+}
+
+type ExternalAuthProvider interface {
+	LoginExternal(identity *models.ExternalIdentity, remote string) (string, *models.SessionClaims, error)
+}
+
+type SSOHandlerGroup struct {
+	sso          SSOProvider
+	auth         ExternalAuthProvider
+	audit        AuditRecorder
+	secureCookie bool
+}
+
+func InitSSO(s SSOProvider, auth ExternalAuthProvider, rec AuditRecorder, secureCookie bool) *SSOHandlerGroup {
+	return &SSOHandlerGroup{
+		sso:          s,
+		auth:         auth,
+		audit:        rec,
+		secureCookie: secureCookie,
+	}
+}
+
+// Login godoc
+//
+//	@Summary		OIDCLogin
+//	@Description	Redirects browser to OpenID Connect provider login page
+//	@Tags			auth
+//
+//	@Failure		502	{object}	handler.APIErrorResponse
+//	@Success		302
+//	@Router			/auth/oidc/login [get]
+func (sh *SSOHandlerGroup) Login(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "handler.sso.login"
+
+	state, target, err := sh.sso.Begin(r.Context())
+	if err != nil {
+		return op, handler.NewErrorResponse(http.StatusBadGateway, err)
+	}
+
+	// state is bound to browser, so callback can't be completed in another one
+	http.SetCookie(w, &http.Cookie{
+		Name:     ssoStateCookie,
+		Value:    state,
+		Path:     "/api/auth/oidc",
+		Expires:  time.Now().Add(sso.StateTTL),
+		HttpOnly: true,
+		Secure:   sh.secureCookie,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, target, http.StatusFound)
+	return op, nil
+}
+
+// Callback godoc
+//
+//	@Summary		OIDCCallback
+//	@Description	Completes OpenID Connect login, opens session and redirects to dashboard
+//	@Tags			auth
+//
+//	@Param			code	query	string	true	"authorization code"
+//	@Param			state	query	string	true	"login state"
+//	@Failure		401	{object}	handler.APIErrorResponse
+//	@Failure		409	{object}	handler.APIErrorResponse
+//	@Success		302
+//	@Router			/auth/oidc/callback [get]
+func (sh *SSOHandlerGroup) Callback(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "handler.sso.callback"
+
+	q := r.URL.Query()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     ssoStateCookie,
+		Path:     "/api/auth/oidc",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   sh.secureCookie,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	event := audit.Event(r, models.AuditLoginSSO, "")
+
+	token, claims, err := sh.complete(r, q.Get("state"), q.Get("code"), q.Get("error"))
+	if claims != nil {
+		event.Actor, event.Target, event.UserID = claims.Login, claims.Login, claims.UserID
+	}
+	sh.audit.Record(event, err)
+
+	if err != nil {
+		return op, ssoError(r, err)
+	}
+
+	handler.SetSessionCookie(w, token, time.Unix(claims.ExpiresAt, 0), sh.secureCookie)
+	http.Redirect(w, r, "/", http.StatusFound)
+
+	return op, nil
+}
+
+func (sh *SSOHandlerGroup) complete(r *http.Request, state, code, providerError string) (string, *models.SessionClaims, error) {
+
+	if providerError != "" {
+		return "", nil, &oidc.ProviderError{
+			Code:        providerError,
+			Description: r.URL.Query().Get("error_description"),
+		}
+	}
+
+	cookie, err := r.Cookie(ssoStateCookie)
+	if err != nil || state == "" || cookie.Value != state {
+		return "", nil, sso.ErrState
+	}
+
+	identity, err := sh.sso.Complete(r.Context(), state, code)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return sh.auth.LoginExternal(identity, r.RemoteAddr)
+}
+
+// ssoError - reports failed single sign-on to error log and converts error to response.
+// Details of provider and token errors are kept in log only
+func ssoError(r *http.Request, err error) error {
+
+	logger.ReturnEntry().Errorf(
+		"failed single sign-on attempt from %s: %v",
+		limiter.RemoteIP(r.RemoteAddr), err,
+	)
+
+	switch {
+
+	case err == authorization.ErrExternalLogin, err == authorization.ErrLastAdmin:
+		return handler.NewErrorResponse(http.StatusConflict, err)
+
+	case errors.Is(err, oidc.ErrDiscovery):
+		return handler.NewErrorResponse(http.StatusBadGateway, err)
+
+	default:
+		return handler.NewErrorResponse(http.StatusUnauthorized, ErrSSOLogin)
+	}
+}
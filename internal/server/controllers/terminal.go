@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/eterline/desky-backend/internal/services/handler"
+	sshlander "github.com/eterline/desky-backend/internal/services/ssh-lander"
+	"github.com/gorilla/websocket"
+)
+
+// TerminalModeRaw - 'mode' query value of raw binary terminal protocol.
+// Any other value opens JSON line mode
+const TerminalModeRaw = "raw"
+
+type terminalWriter interface {
+	io.Writer
+	CloseWriting() error
+}
+
+// terminalSize - parses initial terminal size from 'cols' and 'rows' query values
+func terminalSize(r *http.Request) sshlander.TerminalSize {
+
+	q := r.URL.Query()
+
+	cols, errCols := strconv.ParseUint(q.Get("cols"), 10, 16)
+	rows, errRows := strconv.ParseUint(q.Get("rows"), 10, 16)
+
+	size := sshlander.TerminalSize{Cols: uint16(cols), Rows: uint16(rows)}
+	if errCols != nil || errRows != nil || !size.Valid() {
+		return sshlander.DefaultTerminalSize
+	}
+
+	return size
+}
+
+// terminalOutput - raw mode writes plain terminal output, line mode keeps base64 text messages
+func terminalOutput(socket *handler.WebSocketSession, raw bool) terminalWriter {
+
+	if raw {
+		return socket.InitWebSocketWriting(true)
+	}
+
+	return socket.InitWebSocketBase64Writing()
+}
+
+// wsSSHRaw - serves raw terminal protocol. Output is sent as binary messages with plain bytes,
+// text messages are input bytes and binary messages are frames described in sshlander.FrameType.
+// Input, resize and signals of read-only clients are ignored.
+// Raw input isn't audited: keystrokes can't be told apart from no-echo input like passwords,
+// session is audited by open, attach and close events
+func (mc *SSHLanderControllers) wsSSHRaw(
+	socket *handler.WebSocketSession,
+	attached *sshlander.Attachment,
+) error {
+
+	id := attached.Terminal().ID()
+
+	for msg := range socket.AwaitMessage(
+		websocket.CloseNormalClosure,
+		websocket.CloseGoingAway,
+	) {
+
+		input := msg.Body
+
+		if msg.Type == websocket.BinaryMessage {
+
+			frame, err := sshlander.ParseFrame(msg.Body)
+			if err != nil {
+				mc.logging.Errorf("ws uuid: %s terminal frame error: %v", socket.ID, err)
+				continue
+			}
+
+			switch frame.Type {
+
+			case sshlander.FrameResize:
+				err = attached.Resize(frame.Size)
+
+			case sshlander.FrameSignal:
+				err = attached.Signal(frame.Signal)
+
+			case sshlander.FrameKeepAlive:
+				err = attached.KeepAlive()
+			}
+
+			if err != nil && err != sshlander.ErrReadOnly {
+				mc.logging.Error(err)
+			}
+
+			if frame.Type != sshlander.FrameInput {
+				continue
+			}
+
+			input = frame.Data
+		}
+
+		if attached.Mode() != sshlander.AccessReadWrite {
+			continue
+		}
+
+		if _, err := attached.Write(input); err != nil {
+			mc.logging.Errorf("ws uuid: %s terminal: %s input error: %v", socket.ID, id, err)
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/internal/services/authorization"
+	"github.com/eterline/desky-backend/internal/services/handler"
+	"gorm.io/gorm"
+)
+
+type TokensService interface {
+	MintToken(userID uint, name string, scopes []models.TokenScope, expires time.Time) (string, *models.APITokenInfo, error)
+	Tokens(userID uint) ([]models.APITokenInfo, error)
+	RevokeToken(claims *models.SessionClaims, id uint) error
+}
+
+type TokensHandlerGroup struct {
+	tokens TokensService
+}
+
+func InitTokens(tokens TokensService) *TokensHandlerGroup {
+	return &TokensHandlerGroup{
+		tokens: tokens,
+	}
+}
+
+// ListTokens godoc
+//
+//	@Summary		ListTokens
+//	@Description	Showing personal API tokens of current user. Admins can request tokens of all users with 'all=true'
+//	@Tags			tokens
+//
+//	@Param			all	query	bool	false	"tokens of all users"
+//	@Produce		json
+//	@Success		200	{object}	[]models.APITokenInfo
+//	@Router			/tokens [get]
+func (th *TokensHandlerGroup) ListTokens(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "handler.tokens.list-tokens"
+
+	claims, ok := handler.SessionFromContext(r.Context())
+	if !ok {
+		return op, handler.UnauthorizedErrorResponse()
+	}
+
+	userID := claims.UserID
+	if r.URL.Query().Get("all") == "true" {
+		if !claims.Role.Allows(models.RoleAdmin) {
+			return op, handler.ForbiddenRequestResponse()
+		}
+		userID = 0
+	}
+
+	list, err := th.tokens.Tokens(userID)
+	if err != nil {
+		return op, err
+	}
+
+	if handler.ListIsEmpty(w, list) {
+		return op, nil
+	}
+
+	return op, handler.WriteJSON(w, http.StatusOK, list)
+}
+
+// MintToken godoc
+//
+//	@Summary		MintToken
+//	@Description	Creating personal API token. Token value is shown only once
+//	@Tags			tokens
+//
+//	@Param			request	body	models.APITokenForm	true	"token name, scopes and expiration unix time"
+//	@Accept			json
+//	@Produce		json
+//	@Failure		400	{object}	handler.APIErrorResponse
+//	@Success		201	{object}	models.APITokenResponse
+//	@Router			/tokens [post]
+func (th *TokensHandlerGroup) MintToken(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "handler.tokens.mint-token"
+
+	claims, ok := handler.SessionFromContext(r.Context())
+	if !ok {
+		return op, handler.UnauthorizedErrorResponse()
+	}
+
+	form := new(models.APITokenForm)
+	if err := handler.DecodeRequest(r, form); err != nil {
+		return op, err
+	}
+
+	if err := handler.Validate(form); err != nil {
+		return op, err
+	}
+
+	scopes := make([]models.TokenScope, len(form.Scopes))
+	for i, scope := range form.Scopes {
+		scopes[i] = models.TokenScope(scope)
+	}
+
+	var expires time.Time
+	if form.Expires > 0 {
+		expires = time.Unix(form.Expires, 0)
+	}
+
+	token, info, err := th.tokens.MintToken(claims.UserID, form.Name, scopes, expires)
+	if err != nil {
+		return op, tokensError(err)
+	}
+
+	return op, handler.WriteJSON(w, http.StatusCreated, models.APITokenResponse{
+		APITokenInfo: *info,
+		Token:        token,
+	})
+}
+
+// RevokeToken godoc
+//
+//	@Summary		RevokeToken
+//	@Description	Deleting personal API token
+//	@Tags			tokens
+//
+//	@Produce		json
+//	@Failure		404	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	handler.APIResponse
+//	@Router			/tokens/{id} [delete]
+func (th *TokensHandlerGroup) RevokeToken(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "handler.tokens.revoke-token"
+
+	claims, ok := handler.SessionFromContext(r.Context())
+	if !ok {
+		return op, handler.UnauthorizedErrorResponse()
+	}
+
+	q, err := handler.ParseURLParameters(r, handler.NumOpts("id"))
+	if err != nil {
+		return op, err
+	}
+
+	if err := th.tokens.RevokeToken(claims, uint(q.GetInt("id"))); err != nil {
+		return op, tokensError(err)
+	}
+
+	return op, handler.StatusOK(w, "token revoked")
+}
+
+func tokensError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return handler.NewErrorResponse(http.StatusNotFound, ErrTokenNotFound)
+
+	case err == authorization.ErrTokenExpires:
+		return handler.NewErrorResponse(http.StatusBadRequest, err)
+
+	default:
+		return err
+	}
+}
@@ -0,0 +1,281 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/internal/services/handler"
+	"github.com/eterline/desky-backend/internal/services/tunnels"
+	"github.com/gorilla/websocket"
+	"gorm.io/gorm"
+)
+
+// defaultBindAddress - tunnels listen only loopback when bind address isn't set
+const defaultBindAddress = "127.0.0.1"
+
+// SetTunnels - sets port forwarding manager
+func (mc *SSHLanderControllers) SetTunnels(m *tunnels.Manager) {
+	mc.tunnels = m
+}
+
+// ListTunnels godoc
+//
+//	@Summary		ListTunnels
+//	@Description	Lists port forwarding tunnels with state and traffic.
+//	@Description	WebSocket connection receives tunnels list every second
+//	@Tags			ssh-tunnels
+//
+//	@Produce		json
+//	@Success		200	{array}	models.SSHTunnelObject
+//	@Success		204
+//	@Router			/ssh/tunnels [get]
+func (mc *SSHLanderControllers) ListTunnels(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "sshlander.list-tunnels"
+
+	if websocket.IsWebSocketUpgrade(r) {
+		return mc.tunnelsWS(w, r)
+	}
+
+	list, err := mc.tunnels.Tunnels()
+	if err != nil {
+		return op, err
+	}
+
+	if handler.ListIsEmpty(w, list) {
+		return op, nil
+	}
+
+	return op, handler.WriteJSON(w, http.StatusOK, list)
+}
+
+func (mc *SSHLanderControllers) tunnelsWS(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "sshlander.list-tunnels[WS]"
+
+	sock, err := mc.wsHandler.HandleConnect(w, r)
+	if err != nil {
+		return op, err
+	}
+	defer sock.Exit()
+
+	sock.AwaitClose(websocket.CloseNormalClosure, websocket.CloseGoingAway)
+
+	wr := sock.InitWebSocketWriting(false)
+	defer wr.CloseWriting()
+
+	status, stop := mc.tunnels.Watch()
+	defer stop()
+
+	for {
+		select {
+
+		case <-sock.SessionDone():
+			return op, nil
+
+		case list, ok := <-status:
+			if !ok {
+				return op, nil
+			}
+			json.NewEncoder(wr).Encode(list)
+		}
+	}
+}
+
+// CreateTunnel godoc
+//
+//	@Summary		CreateTunnel
+//	@Description	Creates stopped port forwarding tunnel through stored host
+//	@Tags			ssh-tunnels
+//
+//	@Param			request	body	models.RequestFormTunnel	true	"tunnel settings"
+//	@Accept			json
+//	@Produce		json
+//	@Failure		400	{object}	handler.APIErrorResponse
+//	@Failure		409	{object}	handler.APIErrorResponse
+//	@Success		201	{object}	models.SSHTunnelObject
+//	@Router			/ssh/tunnels [post]
+func (mc *SSHLanderControllers) CreateTunnel(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "sshlander.create-tunnel"
+
+	def, err := mc.tunnelForm(r)
+	if err != nil {
+		return op, err
+	}
+
+	if err := mc.tunnels.Create(def); err != nil {
+		return op, tunnelsError(err)
+	}
+
+	obj, err := mc.tunnels.Tunnel(def.ID)
+	if err != nil {
+		return op, tunnelsError(err)
+	}
+
+	return op, handler.WriteJSON(w, http.StatusCreated, obj)
+}
+
+// UpdateTunnel godoc
+//
+//	@Summary		UpdateTunnel
+//	@Description	Changes tunnel settings. Started tunnel is restarted
+//	@Tags			ssh-tunnels
+//
+//	@Param			id		path	int							true	"tunnel id"
+//	@Param			request	body	models.RequestFormTunnel	true	"tunnel settings"
+//	@Accept			json
+//	@Produce		json
+//	@Failure		400	{object}	handler.APIErrorResponse
+//	@Failure		404	{object}	handler.APIErrorResponse
+//	@Failure		409	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	models.SSHTunnelObject
+//	@Router			/ssh/tunnels/{id} [put]
+func (mc *SSHLanderControllers) UpdateTunnel(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "sshlander.update-tunnel"
+
+	q, err := handler.ParseURLParameters(r, handler.NumOpts("id"))
+	if err != nil {
+		return op, err
+	}
+
+	def, err := mc.tunnelForm(r)
+	if err != nil {
+		return op, err
+	}
+	def.ID = uint(q.GetInt("id"))
+
+	if err := mc.tunnels.Update(def); err != nil {
+		return op, tunnelsError(err)
+	}
+
+	obj, err := mc.tunnels.Tunnel(def.ID)
+	if err != nil {
+		return op, tunnelsError(err)
+	}
+
+	return op, handler.WriteJSON(w, http.StatusOK, obj)
+}
+
+// DeleteTunnel godoc
+//
+//	@Summary		DeleteTunnel
+//	@Description	Stops and deletes tunnel
+//	@Tags			ssh-tunnels
+//
+//	@Param			id	path	int	true	"tunnel id"
+//	@Produce		json
+//	@Failure		404	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	handler.APIResponse
+//	@Router			/ssh/tunnels/{id} [delete]
+func (mc *SSHLanderControllers) DeleteTunnel(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "sshlander.delete-tunnel"
+
+	q, err := handler.ParseURLParameters(r, handler.NumOpts("id"))
+	if err != nil {
+		return op, err
+	}
+
+	if err := mc.tunnels.Delete(uint(q.GetInt("id"))); err != nil {
+		return op, tunnelsError(err)
+	}
+
+	return op, handler.StatusOK(w, "tunnel deleted")
+}
+
+// StartTunnel godoc
+//
+//	@Summary		StartTunnel
+//	@Description	Starts tunnel. Started tunnel reconnects after failures and is restored after restart
+//	@Tags			ssh-tunnels
+//
+//	@Param			id	path	int	true	"tunnel id"
+//	@Produce		json
+//	@Failure		404	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	models.SSHTunnelObject
+//	@Router			/ssh/tunnels/{id}/start [post]
+func (mc *SSHLanderControllers) StartTunnel(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "sshlander.start-tunnel"
+	return op, mc.switchTunnel(w, r, mc.tunnels.Start)
+}
+
+// StopTunnel godoc
+//
+//	@Summary		StopTunnel
+//	@Description	Stops tunnel and closes it's connections
+//	@Tags			ssh-tunnels
+//
+//	@Param			id	path	int	true	"tunnel id"
+//	@Produce		json
+//	@Failure		404	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	models.SSHTunnelObject
+//	@Router			/ssh/tunnels/{id}/stop [post]
+func (mc *SSHLanderControllers) StopTunnel(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "sshlander.stop-tunnel"
+	return op, mc.switchTunnel(w, r, mc.tunnels.Stop)
+}
+
+func (mc *SSHLanderControllers) switchTunnel(w http.ResponseWriter, r *http.Request, action func(id uint) error) error {
+
+	q, err := handler.ParseURLParameters(r, handler.NumOpts("id"))
+	if err != nil {
+		return err
+	}
+
+	id := uint(q.GetInt("id"))
+
+	if err := action(id); err != nil {
+		return tunnelsError(err)
+	}
+
+	obj, err := mc.tunnels.Tunnel(id)
+	if err != nil {
+		return tunnelsError(err)
+	}
+
+	return handler.WriteJSON(w, http.StatusOK, obj)
+}
+
+// tunnelForm - decodes and validates tunnel settings
+func (mc *SSHLanderControllers) tunnelForm(r *http.Request) (*models.SSHTunnelT, error) {
+
+	form := new(models.RequestFormTunnel)
+	if err := handler.DecodeRequest(r, form); err != nil {
+		return nil, err
+	}
+
+	if err := handler.Validate(form); err != nil {
+		return nil, err
+	}
+
+	_, err := mc.repoSSH.QueryById(int(form.HostID))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, handler.NewErrorResponse(http.StatusBadRequest, ErrHostNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if form.BindAddress == "" {
+		form.BindAddress = defaultBindAddress
+	}
+
+	return &models.SSHTunnelT{
+		Name:          form.Name,
+		CredentialsID: form.HostID,
+		Type:          form.Type,
+		BindAddress:   form.BindAddress,
+		BindPort:      form.BindPort,
+		TargetHost:    form.TargetHost,
+		TargetPort:    form.TargetPort,
+	}, nil
+}
+
+func tunnelsError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return handler.NewErrorResponse(http.StatusNotFound, ErrTunnelNotFound)
+	case tunnels.IsTunnelServiceError(err):
+		return handler.NewErrorResponse(http.StatusConflict, err)
+	}
+	return err
+}
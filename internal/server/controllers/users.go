@@ -0,0 +1,303 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/internal/services/authorization"
+	"github.com/eterline/desky-backend/internal/services/handler"
+	"github.com/eterline/desky-backend/internal/services/limiter"
+	"gorm.io/gorm"
+)
+
+type UsersService interface {
+	All() ([]*models.DeskyUser, error)
+	Register(user *models.DeskyUser) error
+	Edit(user *models.DeskyUser, id int) error
+	Delete(id int) error
+	Unlock(id int) error
+}
+
+type LockoutList interface {
+	Locks() []limiter.LockInfo
+}
+
+type UsersHandlerGroup struct {
+	users UsersService
+	locks LockoutList
+}
+
+func InitUsers(users UsersService, locks LockoutList) *UsersHandlerGroup {
+	return &UsersHandlerGroup{
+		users: users,
+		locks: locks,
+	}
+}
+
+// ListUsers godoc
+//
+//	@Summary		ListUsers
+//	@Description	Showing desky users list
+//	@Tags			users
+//
+//	@Produce		json
+//	@Success		200	{object}	[]models.DeskyUser
+//	@Router			/users [get]
+func (uh *UsersHandlerGroup) ListUsers(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "handler.users.list-users"
+
+	list, err := uh.users.All()
+	if err != nil {
+		return op, err
+	}
+
+	if handler.ListIsEmpty(w, list) {
+		return op, nil
+	}
+
+	return op, handler.WriteJSON(w, http.StatusOK, list)
+}
+
+// CreateUser godoc
+//
+//	@Summary		CreateUser
+//	@Description	Adding desky user
+//	@Tags			users
+//
+//	@Param			request	body	models.UserCreateForm	true	"user params"
+//	@Accept			json
+//	@Produce		json
+//	@Failure		409	{object}	handler.APIErrorResponse
+//	@Success		201	{object}	handler.APIResponse
+//	@Router			/users [post]
+func (uh *UsersHandlerGroup) CreateUser(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "handler.users.create-user"
+
+	form := new(models.UserCreateForm)
+	if err := handler.DecodeRequest(r, form); err != nil {
+		return op, err
+	}
+
+	if err := handler.Validate(form); err != nil {
+		return op, err
+	}
+
+	user := models.NewDeskyUser(0, form.Login, form.Password)
+	user.Role = models.StringUserRole(form.Role)
+
+	if err := uh.users.Register(user); err != nil {
+		return op, usersError(err)
+	}
+
+	return op, handler.StatusCreated(w, "user created")
+}
+
+// DeleteUser godoc
+//
+//	@Summary		DeleteUser
+//	@Description	Deleting desky user with his sessions
+//	@Tags			users
+//
+//	@Produce		json
+//	@Failure		404	{object}	handler.APIErrorResponse
+//	@Failure		409	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	handler.APIResponse
+//	@Router			/users/{id} [delete]
+func (uh *UsersHandlerGroup) DeleteUser(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "handler.users.delete-user"
+
+	q, err := handler.ParseURLParameters(r, handler.NumOpts("id"))
+	if err != nil {
+		return op, err
+	}
+
+	if err := uh.users.Delete(q.GetInt("id")); err != nil {
+		return op, usersError(err)
+	}
+
+	return op, handler.StatusOK(w, "user deleted")
+}
+
+// ChangePassword godoc
+//
+//	@Summary		ChangePassword
+//	@Description	Resetting desky user password. All user sessions will be closed
+//	@Tags			users
+//
+//	@Param			request	body	models.UserPasswordForm	true	"new password"
+//	@Accept			json
+//	@Produce		json
+//	@Failure		404	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	handler.APIResponse
+//	@Router			/users/{id}/password [patch]
+func (uh *UsersHandlerGroup) ChangePassword(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "handler.users.change-password"
+
+	q, err := handler.ParseURLParameters(r, handler.NumOpts("id"))
+	if err != nil {
+		return op, err
+	}
+
+	form := new(models.UserPasswordForm)
+	if err := handler.DecodeRequest(r, form); err != nil {
+		return op, err
+	}
+
+	if err := handler.Validate(form); err != nil {
+		return op, err
+	}
+
+	if err := uh.users.Edit(models.NewDeskyUser(0, "", form.Password), q.GetInt("id")); err != nil {
+		return op, usersError(err)
+	}
+
+	return op, handler.StatusOK(w, "password changed")
+}
+
+// RenameUser godoc
+//
+//	@Summary		RenameUser
+//	@Description	Changing desky user login
+//	@Tags			users
+//
+//	@Param			request	body	models.UserRenameForm	true	"new login"
+//	@Accept			json
+//	@Produce		json
+//	@Failure		404	{object}	handler.APIErrorResponse
+//	@Failure		409	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	handler.APIResponse
+//	@Router			/users/{id}/login [patch]
+func (uh *UsersHandlerGroup) RenameUser(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "handler.users.rename-user"
+
+	q, err := handler.ParseURLParameters(r, handler.NumOpts("id"))
+	if err != nil {
+		return op, err
+	}
+
+	form := new(models.UserRenameForm)
+	if err := handler.DecodeRequest(r, form); err != nil {
+		return op, err
+	}
+
+	if err := handler.Validate(form); err != nil {
+		return op, err
+	}
+
+	if err := uh.users.Edit(models.NewDeskyUser(0, form.Login, ""), q.GetInt("id")); err != nil {
+		return op, usersError(err)
+	}
+
+	return op, handler.StatusOK(w, "user renamed")
+}
+
+// SetRole godoc
+//
+//	@Summary		SetRole
+//	@Description	Changing desky user role: viewer, operator or admin
+//	@Tags			users
+//
+//	@Param			request	body	models.UserRoleForm	true	"new role"
+//	@Accept			json
+//	@Produce		json
+//	@Failure		404	{object}	handler.APIErrorResponse
+//	@Failure		409	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	handler.APIResponse
+//	@Router			/users/{id}/role [patch]
+func (uh *UsersHandlerGroup) SetRole(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "handler.users.set-role"
+
+	q, err := handler.ParseURLParameters(r, handler.NumOpts("id"))
+	if err != nil {
+		return op, err
+	}
+
+	form := new(models.UserRoleForm)
+	if err := handler.DecodeRequest(r, form); err != nil {
+		return op, err
+	}
+
+	if err := handler.Validate(form); err != nil {
+		return op, err
+	}
+
+	user := models.NewDeskyUser(0, "", "")
+	user.Role = models.StringUserRole(form.Role)
+
+	if err := uh.users.Edit(user, q.GetInt("id")); err != nil {
+		return op, usersError(err)
+	}
+
+	return op, handler.StatusOK(w, "user role changed")
+}
+
+// UnlockUser godoc
+//
+//	@Summary		UnlockUser
+//	@Description	Removing login lockout of desky user
+//	@Tags			users
+//
+//	@Produce		json
+//	@Failure		404	{object}	handler.APIErrorResponse
+//	@Success		200	{object}	handler.APIResponse
+//	@Router			/users/{id}/unlock [post]
+func (uh *UsersHandlerGroup) UnlockUser(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "handler.users.unlock-user"
+
+	q, err := handler.ParseURLParameters(r, handler.NumOpts("id"))
+	if err != nil {
+		return op, err
+	}
+
+	if err := uh.users.Unlock(q.GetInt("id")); err != nil {
+		return op, usersError(err)
+	}
+
+	return op, handler.StatusOK(w, "user unlocked")
+}
+
+// ListLocks godoc
+//
+//	@Summary		ListLocks
+//	@Description	Showing login lockouts by login and client IP
+//	@Tags			users
+//
+//	@Produce		json
+//	@Success		200	{object}	[]limiter.LockInfo
+//	@Router			/users/locks [get]
+func (uh *UsersHandlerGroup) ListLocks(w http.ResponseWriter, r *http.Request) (op string, err error) {
+	op = "handler.users.list-locks"
+
+	list := uh.locks.Locks()
+
+	if handler.ListIsEmpty(w, list) {
+		return op, nil
+	}
+
+	return op, handler.WriteJSON(w, http.StatusOK, list)
+}
+
+func usersError(err error) error {
+
+	switch {
+
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return handler.NewErrorResponse(http.StatusNotFound, ErrUserNotFound)
+
+	case err == authorization.ErrUserExists,
+		err == authorization.ErrLastUser,
+		err == authorization.ErrLastAdmin,
+		err == authorization.ErrTOTPEnabled,
+		err == authorization.ErrTOTPNotConfigured,
+		err == authorization.ErrExternalUser:
+		return handler.NewErrorResponse(http.StatusConflict, err)
+
+	case err == authorization.ErrVerifyPassword,
+		err == authorization.ErrVerifyCode:
+		return handler.NewErrorResponse(http.StatusForbidden, ErrUncorrectCredentials)
+
+	default:
+		return err
+	}
+}
@@ -0,0 +1,29 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/internal/services/audit"
+)
+
+type AuditRecorder interface {
+	Record(event *models.AuditEventT, err error)
+}
+
+// Audit - writes request to audit log with action and request path as target.
+// Responses with error status are recorded as failures
+func Audit(rec AuditRecorder, action models.AuditAction) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			rw := NewResponseWriter(w)
+			next.ServeHTTP(rw, r)
+
+			rec.Record(
+				audit.Event(r, action, r.URL.Path),
+				audit.StatusError(rw.statusCode),
+			)
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/internal/services/handler"
+)
+
+type SessionVerifier interface {
+	Session(token string) (*models.SessionClaims, error)
+}
+
+// Authorization - rejects requests without valid session or personal API token.
+// Token is accepted from 'Authorization: Bearer' header or session cookie,
+// so browser websocket upgrades are checked too.
+func Authorization(v SessionVerifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			token := handler.RequestToken(r)
+			if token == "" {
+				e := handler.UnauthorizedErrorResponse()
+				handler.WriteJSON(w, e.StatusCode, e)
+				return
+			}
+
+			claims, err := v.Session(token)
+			if err != nil {
+				e := handler.UnauthorizedErrorResponse()
+				handler.WriteJSON(w, e.StatusCode, e)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(handler.WithSession(r.Context(), claims)))
+		})
+	}
+}
+
+// PasswordChanged - rejects sessions of users that must change their one-time password.
+// Must be used after Authorization middleware
+func PasswordChanged(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if claims, ok := handler.SessionFromContext(r.Context()); ok && claims.PasswordChange {
+			e := handler.NewErrorResponse(http.StatusForbidden, ErrPasswordChange)
+			handler.WriteJSON(w, e.StatusCode, e)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireRole - rejects sessions without required role.
+// Must be used after Authorization middleware
+func RequireRole(role models.UserRole) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			claims, ok := handler.SessionFromContext(r.Context())
+			if !ok {
+				e := handler.UnauthorizedErrorResponse()
+				handler.WriteJSON(w, e.StatusCode, e)
+				return
+			}
+
+			if !claims.Role.Allows(role) {
+				e := handler.ForbiddenRequestResponse()
+				handler.WriteJSON(w, e.StatusCode, e)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireScope - rejects personal API tokens without any of scopes.
+// Browser sessions are not limited by scopes. Must be used after Authorization middleware
+func RequireScope(scopes ...models.TokenScope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			claims, ok := handler.SessionFromContext(r.Context())
+			if !ok {
+				e := handler.UnauthorizedErrorResponse()
+				handler.WriteJSON(w, e.StatusCode, e)
+				return
+			}
+
+			if !claims.HasScope(scopes...) {
+				e := handler.ForbiddenRequestResponse()
+				handler.WriteJSON(w, e.StatusCode, e)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// SessionOnly - rejects personal API tokens. Used for account management routes.
+// Must be used after Authorization middleware
+func SessionOnly(next http.Handler) http.Handler {
+	return RequireScope()(next)
+}
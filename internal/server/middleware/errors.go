@@ -3,5 +3,6 @@ package middlewares
 import "errors"
 
 var (
-	ErrNotAuthorized = errors.New("not authorized")
+	ErrNotAuthorized  = errors.New("not authorized")
+	ErrPasswordChange = errors.New("password must be changed before using api")
 )
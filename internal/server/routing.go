@@ -2,6 +2,9 @@ package server
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"os"
 
 	"github.com/eterline/desky-backend/internal/configuration"
 	"github.com/eterline/desky-backend/internal/models"
@@ -9,11 +12,25 @@ import (
 	"github.com/eterline/desky-backend/internal/server/controllers"
 	middlewares "github.com/eterline/desky-backend/internal/server/middleware"
 	agentmon "github.com/eterline/desky-backend/internal/services/agent-mon"
+	agentrpc "github.com/eterline/desky-backend/internal/services/agent-rpc"
+	"github.com/eterline/desky-backend/internal/services/alerts"
 	"github.com/eterline/desky-backend/internal/services/apps/appsdb"
+	"github.com/eterline/desky-backend/internal/services/audit"
+	"github.com/eterline/desky-backend/internal/services/authorization"
 	"github.com/eterline/desky-backend/internal/services/handler"
+	"github.com/eterline/desky-backend/internal/services/hash"
+	"github.com/eterline/desky-backend/internal/services/limiter"
+	"github.com/eterline/desky-backend/internal/services/metrics"
+	"github.com/eterline/desky-backend/internal/services/recording"
+	"github.com/eterline/desky-backend/internal/services/registry"
+	sshlander "github.com/eterline/desky-backend/internal/services/ssh-lander"
+	"github.com/eterline/desky-backend/internal/services/sso"
 	"github.com/eterline/desky-backend/internal/services/system"
+	"github.com/eterline/desky-backend/internal/services/tunnels"
 	"github.com/eterline/desky-backend/pkg/broker"
+	"github.com/eterline/desky-backend/pkg/envelope"
 	"github.com/eterline/desky-backend/pkg/logger"
+	"github.com/eterline/desky-backend/pkg/oidc"
 	"github.com/eterline/desky-backend/pkg/storage"
 	"github.com/go-chi/chi"
 	"github.com/sirupsen/logrus"
@@ -42,62 +59,354 @@ func ConfigRoutes(ctx context.Context, c *configuration.Configuration) (r *chi.M
 		middlewares.CorsPolicy,
 		middlewares.FilterContentType,
 		middlewares.PreSetHeaders,
-	).Mount("/api", api(ctx))
+	).Mount("/api", api(ctx, c))
 
 	return
 }
 
 // api - setting up api routes
-func api(ctx context.Context) *chi.Mux {
+func api(ctx context.Context, c *configuration.Configuration) *chi.Mux {
 
 	rt := chi.NewRouter()
 
+	secret, configured := authorization.SessionSecret(c.Auth.Secret)
+	if !configured {
+		log.Warn("session secret is not configured, sessions will be reset after restart")
+	}
+
+	auth := authorization.New(
+		repository.NewUsersRepository(databaseInstance),
+		repository.NewSessionsRepository(databaseInstance),
+		repository.NewTokensRepository(databaseInstance),
+		hash.New(hash.ParseAlgorithm(c.Auth.PasswordHash)),
+		secret, c.SessionTTL(),
+	)
+	auth.SetIssuer(c.Auth.TOTPIssuer)
+
+	lim, err := limiter.New(
+		repository.NewLocksRepository(databaseInstance),
+		limiter.Policy{
+			Attempts:   c.Auth.Lockout.LoginAttempts,
+			Window:     c.LockoutWindow(),
+			Lockout:    c.LockoutDuration(),
+			MaxLockout: c.LockoutMaxDuration(),
+		},
+		limiter.Policy{
+			Attempts:   c.Auth.Lockout.IPAttempts,
+			Window:     c.LockoutWindow(),
+			Lockout:    c.LockoutDuration(),
+			MaxLockout: c.LockoutMaxDuration(),
+		},
+	)
+	if err != nil {
+		log.Fatalf("login limiter init error: %v", err)
+	}
+	auth.SetLimiter(lim)
+
+	bootstrapAdmin(auth, c.Auth.BootstrapLogin)
+
+	auditor := audit.New(repository.NewAuditRepository(databaseInstance))
+
+	rt.Route("/auth", func(r chi.Router) {
+
+		srv := controllers.InitAuth(auth, auditor, c.SSL().TLS)
+
+		r.Post("/login", handler.InitController(srv.Login))
+		r.Post("/login/2fa", handler.InitController(srv.LoginSecondFactor))
+
+		if c.Auth.OIDC.Enable {
+			srv := controllers.InitSSO(ssoService(c), auth, auditor, c.SSL().TLS)
+
+			r.Get("/oidc/login", handler.InitController(srv.Login))
+			r.Get("/oidc/callback", handler.InitController(srv.Callback))
+		}
+
+		r.Group(func(r chi.Router) {
+			r.Use(middlewares.Authorization(auth))
+
+			r.Get("/me", handler.InitController(srv.Me))
+
+			r.With(
+				middlewares.Audit(auditor, models.AuditLogout),
+				middlewares.SessionOnly,
+			).Post("/logout", handler.InitController(srv.Logout))
+
+			r.With(
+				middlewares.Audit(auditor, models.AuditPassword),
+				middlewares.SessionOnly,
+			).Post("/password", handler.InitController(srv.Password))
+
+			r.With(
+				middlewares.Audit(auditor, models.AuditTwoFactor),
+				middlewares.SessionOnly,
+				middlewares.PasswordChanged,
+			).Route("/2fa", func(r chi.Router) {
+				r.Post("/setup", handler.InitController(srv.SetupTOTP))
+				r.Post("/enable", handler.InitController(srv.EnableTOTP))
+				r.Post("/recovery", handler.InitController(srv.RecoveryCodes))
+				r.Post("/disable", handler.InitController(srv.DisableTOTP))
+			})
+		})
+	})
+
+	rt.Group(func(rt chi.Router) {
+
+		if c.Auth.Enable {
+			rt.Use(middlewares.Authorization(auth), middlewares.PasswordChanged)
+		} else {
+			log.Warn("authorization is disabled, api is opened for everyone")
+		}
+
+		protectedAPI(ctx, rt, auth, lim, auditor, roleGuard(c.Auth.Enable), c)
+	})
+
+	return rt
+}
+
+// protectedAPI - setting up api routes that requires authorization
+func protectedAPI(
+	ctx context.Context,
+	rt chi.Router,
+	auth *authorization.AuthorizationService,
+	lim *limiter.LoginLimiter,
+	auditor *audit.Auditor,
+	role roleGuard,
+	c *configuration.Configuration,
+) {
+
+	audited := func(action models.AuditAction) func(http.Handler) http.Handler {
+		return middlewares.Audit(auditor, action)
+	}
+
+	viewer := role.require(models.RoleViewer)
+	admin := role.require(models.RoleAdmin)
+
+	rt.With(admin).Route("/users", func(r chi.Router) {
+
+		srv := controllers.InitUsers(auth, lim)
+
+		r.Get("/", handler.InitController(srv.ListUsers))
+		r.Get("/locks", handler.InitController(srv.ListLocks))
+		r.With(audited(models.AuditUserCreate)).Post("/", handler.InitController(srv.CreateUser))
+		r.With(audited(models.AuditUserDelete)).Delete("/{id}", handler.InitController(srv.DeleteUser))
+		r.With(audited(models.AuditUserEdit)).Patch("/{id}/password", handler.InitController(srv.ChangePassword))
+		r.With(audited(models.AuditUserEdit)).Patch("/{id}/login", handler.InitController(srv.RenameUser))
+		r.With(audited(models.AuditUserEdit)).Patch("/{id}/role", handler.InitController(srv.SetRole))
+		r.With(audited(models.AuditUserUnlock)).Post("/{id}/unlock", handler.InitController(srv.UnlockUser))
+	})
+
+	rt.With(viewer).Route("/tokens", func(r chi.Router) {
+
+		srv := controllers.InitTokens(auth)
+
+		r.Get("/", handler.InitController(srv.ListTokens))
+		r.With(audited(models.AuditTokenMint)).Post("/", handler.InitController(srv.MintToken))
+		r.With(audited(models.AuditTokenRevoke)).Delete("/{id}", handler.InitController(srv.RevokeToken))
+	})
+
 	rt.Route("/apps", func(r chi.Router) {
 
 		appRepo := repository.NewAppsRepository(databaseInstance)
 		srv := controllers.InitApplications(appsdb.New(appRepo), appRepo)
 
-		r.Get("/table", handler.InitController(srv.ShowTable))
-		r.Post("/table/{topic}", handler.InitController(srv.CreateApp))
-		r.Delete("/table/{id}", handler.InitController(srv.DeleteAppById))
-		r.Patch("/table/{id}", handler.InitController(srv.EditApp))
+		r.With(role.require(models.RoleViewer, models.ScopeAppsRead)).Get("/table", handler.InitController(srv.ShowTable))
+		r.With(audited(models.AuditAppCreate), role.require(models.RoleAdmin, models.ScopeAppsWrite)).Post("/table/{topic}", handler.InitController(srv.CreateApp))
+		r.With(audited(models.AuditAppDelete), role.require(models.RoleAdmin, models.ScopeAppsWrite)).Delete("/table/{id}", handler.InitController(srv.DeleteAppById))
+		r.With(audited(models.AuditAppEdit), role.require(models.RoleAdmin, models.ScopeAppsWrite)).Patch("/table/{id}", handler.InitController(srv.EditApp))
 	})
 
 	rt.Route("/system", func(r chi.Router) {
 
 		srv := controllers.InitSystem(ctx, system.New())
 
-		r.Get("/stats", handler.InitController(srv.Stats))
-		r.Get("/systemd", handler.InitController(srv.SystemdUnits))
+		r.With(role.require(models.RoleViewer, models.ScopeSystemRead)).Get("/stats", handler.InitController(srv.Stats))
+		r.With(role.require(models.RoleOperator, models.ScopeSystemRead)).Get("/systemd", handler.InitController(srv.SystemdUnits))
+		r.With(audited(models.AuditSystemdUnit), role.require(models.RoleOperator, models.ScopeSystemWrite)).Post("/systemd/{unit}/{command}", handler.InitController(srv.UnitCommand))
+	})
+
+	alertEngine := alerts.New(ctx, repository.NewAlertsRepository(databaseInstance), alertNotifiers(ctx, c)...)
+	if err := alertEngine.Load(); err != nil {
+		log.Errorf("alert rules loading error: %v", err)
+	}
+	alertEngine.RunLocal(alerts.SystemSource{Service: system.New()}, c.AlertsInterval())
+	alertEngine.RunExpiry(c.AlertsStaleAfter())
+
+	rt.Route("/alerts", func(r chi.Router) {
+
+		srv := controllers.InitAlerts(alertEngine)
+
+		r.With(role.require(models.RoleViewer, models.ScopeAgentRead)).Get("/", handler.InitController(srv.ListAlerts))
+		r.With(role.require(models.RoleViewer, models.ScopeAgentRead)).Get("/notifiers", handler.InitController(srv.ListNotifiers))
+		r.With(role.require(models.RoleViewer, models.ScopeAgentRead)).Get("/rules", handler.InitController(srv.ListRules))
+		r.With(audited(models.AuditAlertCreate), role.require(models.RoleOperator, models.ScopeAgentWrite)).Post("/rules", handler.InitController(srv.CreateRule))
+		r.With(audited(models.AuditAlertUpdate), role.require(models.RoleOperator, models.ScopeAgentWrite)).Put("/rules/{id}", handler.InitController(srv.UpdateRule))
+		r.With(audited(models.AuditAlertDelete), role.require(models.RoleOperator, models.ScopeAgentWrite)).Delete("/rules/{id}", handler.InitController(srv.DeleteRule))
 	})
 
 	rt.Route("/agent", func(r chi.Router) {
 
-		broker := ctx.Value(models.MESSAGE_BROKER_CONTEXT_KEY).(*broker.ListenerMQTT)
-		agent := agentmon.NewAgentMonitorServiceWithBroker(ctx, broker)
+		mqttBroker := ctx.Value(models.MESSAGE_BROKER_CONTEXT_KEY).(*broker.ListenerMQTT)
+		agent := agentmon.NewAgentMonitorServiceWithBroker(ctx, mqttBroker)
+
+		raw, minute, hour := c.HistoryRetention()
+		history := metrics.New(repository.NewMetricsRepository(databaseInstance), metrics.Retention{
+			Raw:    raw,
+			Minute: minute,
+			Hour:   hour,
+		})
+		history.RunMaintenance(ctx, metrics.MaintenanceInterval)
+		agent.AddRecorder(history)
+		agent.AddRecorder(alertEngine)
+
+		keeper := ctx.Value(models.SECRETS_CONTEXT_KEY).(*envelope.Keeper)
+		agents := registry.New(repository.NewAgentsRepository(databaseInstance, keeper), registry.Options{
+			AutoApprove:      c.Agent.Registry.AutoApprove,
+			RequireSignature: c.Agent.Registry.RequireSignature,
+		})
+		if err := agents.Load(); err != nil {
+			log.Errorf("agent registry loading error: %v", err)
+		}
+		agent.SetGate(agents)
+
+		heartbeat, forget := c.AgentPresence()
+		agent.SetPresence(agentmon.Presence{Heartbeat: heartbeat, Forget: forget})
+
 		if err := agent.RunDataUpdater("/agent/stats"); err != nil {
 			log.Error(err)
 			return
 		}
+
+		if err := agent.RunPresence("/agent/presence"); err != nil {
+			log.Error(err)
+		}
+
 		mon := controllers.InitMonitoring(ctx, agent, true)
+		mon.SetHistory(history)
+		mon.SetAlerts(alertEngine)
+
+		r.With(role.require(models.RoleViewer, models.ScopeAgentRead)).Get("/monitor", handler.InitController(mon.Monitor))
+		r.With(role.require(models.RoleViewer, models.ScopeAgentRead)).Get("/{id}/metrics", handler.InitController(mon.AgentMetrics))
+		r.With(role.require(models.RoleViewer, models.ScopeAgentRead)).Get("/{id}/history", handler.InitController(mon.AgentHistory))
+
+		reg := controllers.InitRegistry(agents, agent)
+
+		r.With(role.require(models.RoleAdmin, models.ScopeAgentRead)).Get("/registry", handler.InitController(reg.ListAgents))
+		r.With(audited(models.AuditAgentApprove), role.require(models.RoleAdmin, models.ScopeAgentWrite)).Post("/registry/{id}/approve", handler.InitController(reg.ApproveAgent))
+		r.With(audited(models.AuditAgentEdit), role.require(models.RoleAdmin, models.ScopeAgentWrite)).Patch("/registry/{id}", handler.InitController(reg.EditAgent))
+		r.With(audited(models.AuditAgentRemove), role.require(models.RoleAdmin, models.ScopeAgentWrite)).Delete("/registry/{id}", handler.InitController(reg.RemoveAgent))
+		r.With(audited(models.AuditAgentSecret), role.require(models.RoleAdmin, models.ScopeAgentWrite)).Post("/registry/{id}/secret", handler.InitController(reg.CreateSecret))
+		r.With(audited(models.AuditAgentSecret), role.require(models.RoleAdmin, models.ScopeAgentWrite)).Delete("/registry/{id}/secret", handler.InitController(reg.DeleteSecret))
+
+		sender, ok := ctx.Value(models.MESSAGE_SENDER_CONTEXT_KEY).(*broker.SenderMQTT)
+		if !ok {
+			log.Warn("agent commands are disabled: mqtt sender isn't available")
+			return
+		}
 
-		r.Get("/monitor", handler.InitController(mon.Monitor))
+		commands := agentrpc.New(sender)
+		commands.SetVerifier(agents)
+		if err := commands.Run(mqttBroker); err != nil {
+			log.Error(err)
+			return
+		}
+		mon.SetCommands(commands)
+
+		r.With(audited(models.AuditAgentCommand), role.require(models.RoleOperator, models.ScopeAgentWrite)).Post("/{id}/command", handler.InitController(mon.AgentCommand))
 	})
 
 	rt.Route("/ssh", func(r chi.Router) {
 
-		sshRepository := repository.NewSSHLanderRepository(databaseInstance)
-		srv := controllers.InitSSHlander(ctx, sshRepository)
+		keeper := ctx.Value(models.SECRETS_CONTEXT_KEY).(*envelope.Keeper)
+		sshRepository := repository.NewSSHLanderRepository(databaseInstance, keeper)
+
+		recorder := recording.New(repository.NewRecordingsRepository(databaseInstance), recording.Options{
+			Enable:    c.SSH.Recording.Enable,
+			Dir:       c.RecordingsDir(),
+			Input:     c.SSH.Recording.Input,
+			Retention: c.RecordingRetention(),
+		})
+		recorder.RunPruning(ctx, recording.PruneInterval)
+
+		sessions := sshlander.NewRegistry(c.SessionGrace(), c.SSH.Sessions.Scrollback)
+		go func() {
+			<-ctx.Done()
+			sessions.CloseAll()
+		}()
+
+		srv := controllers.InitSSHlander(
+			ctx, sshRepository, repository.NewHostKeysRepository(databaseInstance),
+			auditor, recorder, sessions,
+		)
+		srv.SetDialTimeout(c.SSHConnectTimeout())
+		srv.SetFiles(c.SSH.Files.Root, c.MaxUpload())
+		srv.SetExec(sshlander.ExecOptions{
+			Timeout:   c.ExecTimeout(),
+			MaxOutput: c.SSH.Exec.MaxOutput,
+		}, c.SSH.Exec.Parallel)
+
+		srv.SetKeys(repository.NewSSHKeysRepository(databaseInstance, keeper))
+
+		tunnelManager := tunnels.New(ctx, repository.NewTunnelsRepository(databaseInstance), srv.DialHost)
+		tunnelManager.RunRestore()
+		srv.SetTunnels(tunnelManager)
+
+		r.With(role.require(models.RoleOperator, models.ScopeSSHRead)).Get("/list", handler.InitController(srv.ListHosts))
+		r.With(audited(models.AuditHostCreate), role.require(models.RoleAdmin, models.ScopeSSHWrite)).Post("/list", handler.InitController(srv.AppendHost))
+		r.With(audited(models.AuditHostEdit), role.require(models.RoleAdmin, models.ScopeSSHWrite)).Put("/list/{id}", handler.InitController(srv.EditHost))
+		r.With(audited(models.AuditHostDelete), role.require(models.RoleAdmin, models.ScopeSSHWrite)).Delete("/list/{id}", handler.InitController(srv.DeleteHost))
+		r.With(audited(models.AuditHostImport), role.require(models.RoleAdmin, models.ScopeSSHWrite)).Post("/import", handler.InitController(srv.ImportHosts))
+
+		r.With(role.require(models.RoleOperator, models.ScopeSSHRead)).Get("/keys", handler.InitController(srv.ListKeys))
+		r.With(audited(models.AuditKeyCreate), role.require(models.RoleAdmin, models.ScopeSSHWrite)).Post("/keys", handler.InitController(srv.CreateKey))
+		r.With(audited(models.AuditKeyUpdate), role.require(models.RoleAdmin, models.ScopeSSHWrite)).Put("/keys/{id}", handler.InitController(srv.UpdateKey))
+		r.With(audited(models.AuditKeyDelete), role.require(models.RoleAdmin, models.ScopeSSHWrite)).Delete("/keys/{id}", handler.InitController(srv.DeleteKey))
+
+		r.With(role.require(models.RoleOperator, models.ScopeSSHRead)).Get("/hostkeys", handler.InitController(srv.ListHostKeys))
+		r.With(audited(models.AuditHostKeyAccept), role.require(models.RoleAdmin, models.ScopeSSHWrite)).Post("/hostkeys/{id}/accept", handler.InitController(srv.AcceptHostKey))
+		r.With(audited(models.AuditHostKeyReset), role.require(models.RoleAdmin, models.ScopeSSHWrite)).Delete("/hostkeys/{id}", handler.InitController(srv.ResetHostKey))
+		r.With(audited(models.AuditHostKeyImport), role.require(models.RoleAdmin, models.ScopeSSHWrite)).Post("/hostkeys/import", handler.InitController(srv.ImportKnownHosts))
 
-		r.Get("/list", handler.InitController(srv.ListHosts))
-		r.Post("/list", handler.InitController(srv.AppendHost))
-		r.Delete("/list/{id}", handler.InitController(srv.DeleteHost))
+		r.With(role.require(models.RoleAdmin, models.ScopeSSHRead)).Get("/recordings", handler.InitController(srv.ListRecordings))
+		r.With(role.require(models.RoleAdmin, models.ScopeSSHRead)).Get("/recordings/{id}", handler.InitController(srv.DownloadRecording))
+		r.With(audited(models.AuditRecordDelete), role.require(models.RoleAdmin, models.ScopeSSHWrite)).Delete("/recordings/{id}", handler.InitController(srv.DeleteRecording))
 
-		r.Get("/ping", handler.InitController(srv.TestHosts))
-		r.Get("/connect/{id}", handler.InitController(srv.ConnectionWS))
+		r.With(role.require(models.RoleOperator, models.ScopeSSHRead)).Get("/ping", handler.InitController(srv.TestHosts))
+		r.With(role.require(models.RoleOperator, models.ScopeSSHConnect)).Get("/connect/{id}", handler.InitController(srv.ConnectionWS))
+
+		r.With(role.require(models.RoleOperator, models.ScopeSSHRead)).Get("/sessions", handler.InitController(srv.ListSessions))
+		r.With(role.require(models.RoleOperator, models.ScopeSSHConnect)).Get("/sessions/{uuid}/attach", handler.InitController(srv.AttachWS))
+		r.With(audited(models.AuditSSHTerminate), role.require(models.RoleOperator, models.ScopeSSHConnect)).Delete("/sessions/{uuid}", handler.InitController(srv.CloseSession))
+
+		r.With(role.require(models.RoleOperator, models.ScopeSSHRead)).Get("/tunnels", handler.InitController(srv.ListTunnels))
+		r.With(audited(models.AuditTunnelCreate), role.require(models.RoleAdmin, models.ScopeSSHWrite)).Post("/tunnels", handler.InitController(srv.CreateTunnel))
+		r.With(audited(models.AuditTunnelUpdate), role.require(models.RoleAdmin, models.ScopeSSHWrite)).Put("/tunnels/{id}", handler.InitController(srv.UpdateTunnel))
+		r.With(audited(models.AuditTunnelDelete), role.require(models.RoleAdmin, models.ScopeSSHWrite)).Delete("/tunnels/{id}", handler.InitController(srv.DeleteTunnel))
+		r.With(audited(models.AuditTunnelStart), role.require(models.RoleAdmin, models.ScopeSSHWrite)).Post("/tunnels/{id}/start", handler.InitController(srv.StartTunnel))
+		r.With(audited(models.AuditTunnelStop), role.require(models.RoleAdmin, models.ScopeSSHWrite)).Post("/tunnels/{id}/stop", handler.InitController(srv.StopTunnel))
+
+		r.With(role.require(models.RoleOperator, models.ScopeSSHExec)).Post("/exec", handler.InitController(srv.ExecHosts))
+		r.With(role.require(models.RoleOperator, models.ScopeSSHExec)).Post("/{id}/exec", handler.InitController(srv.ExecCommand))
+
+		r.With(role.require(models.RoleOperator, models.ScopeSSHFiles)).Route("/{id}/files", func(r chi.Router) {
+			r.Get("/", handler.InitController(srv.ListFiles))
+			r.Get("/stat", handler.InitController(srv.StatFile))
+			r.Get("/download", handler.InitController(srv.DownloadFile))
+			r.Post("/upload", handler.InitController(srv.UploadFiles))
+			r.Post("/rename", handler.InitController(srv.RenameFile))
+			r.Post("/mkdir", handler.InitController(srv.MakeDir))
+			r.Post("/chmod", handler.InitController(srv.ChmodFile))
+			r.Delete("/", handler.InitController(srv.DeleteFile))
+		})
 	})
 
-	rt.Route("/parameters", func(r chi.Router) {
+	rt.With(role.require(models.RoleAdmin, models.ScopeAuditRead)).Route("/audit", func(r chi.Router) {
+
+		srv := controllers.InitAudit(auditor)
+
+		r.Get("/", handler.InitController(srv.Events))
+	})
+
+	rt.With(admin).Route("/parameters", func(r chi.Router) {
 		coll := logger.NewLoggerCollector()
 		logger.HookLevelWriter(coll, logrus.ErrorLevel)
 		srv := controllers.InitParameters(coll)
@@ -105,6 +414,128 @@ func api(ctx context.Context) *chi.Mux {
 		r.Get("/logs", handler.InitController(srv.GetLogs))
 		r.Get("/errors", handler.InitController(srv.Errors))
 	})
+}
 
-	return rt
+// roleGuard - builds role middlewares. Disabled authorization opens every route.
+// Personal API tokens are allowed only with any of listed scopes
+type roleGuard bool
+
+func (g roleGuard) require(role models.UserRole, scopes ...models.TokenScope) func(http.Handler) http.Handler {
+
+	if !g {
+		return func(next http.Handler) http.Handler {
+			return next
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return middlewares.RequireRole(role)(middlewares.RequireScope(scopes...)(next))
+	}
+}
+
+// ssoService - creates OpenID Connect login service from 'Auth.oidc' configuration section
+func ssoService(c *configuration.Configuration) *sso.SSOService {
+
+	opts := c.Auth.OIDC
+
+	roles := make(map[string]models.UserRole, len(opts.RoleMapping))
+	for value, role := range opts.RoleMapping {
+		roles[value] = models.StringUserRole(role)
+	}
+
+	var defaultRole models.UserRole
+	if opts.DefaultRole != "" {
+		defaultRole = models.StringUserRole(opts.DefaultRole)
+	}
+
+	return sso.New(
+		oidc.Config{
+			Issuer:       opts.Issuer,
+			ClientID:     opts.ClientID,
+			ClientSecret: opts.ClientSecret,
+			RedirectURL:  opts.RedirectURL,
+			Scopes:       opts.Scopes,
+		},
+		sso.Mapping{
+			LoginClaim:  opts.LoginClaim,
+			RoleClaim:   opts.RoleClaim,
+			Roles:       roles,
+			DefaultRole: defaultRole,
+		},
+		nil,
+	)
+}
+
+// alertNotifiers - creates alert notifiers from 'Alerts.notifiers' configuration section.
+// MQTT notifiers publish with message sender from context
+func alertNotifiers(ctx context.Context, c *configuration.Configuration) []alerts.Notifier {
+
+	sender, _ := ctx.Value(models.MESSAGE_SENDER_CONTEXT_KEY).(*broker.SenderMQTT)
+
+	list := make([]alerts.Notifier, 0, len(c.Alerts.Notifiers))
+
+	for _, opts := range c.Alerts.Notifiers {
+		switch opts.Type {
+
+		case "webhook":
+			list = append(list, alerts.NewWebhook(opts.Name, opts.URL, opts.Headers))
+
+		case "smtp":
+			port := opts.Port
+			if port == 0 {
+				port = 25
+			}
+			list = append(list, alerts.NewSMTP(opts.Name, alerts.SMTPOptions{
+				Host:     opts.Host,
+				Port:     port,
+				Username: opts.Username,
+				Password: opts.Password,
+				From:     opts.From,
+				To:       opts.To,
+			}))
+
+		case "mqtt":
+			if sender == nil {
+				log.Warnf("alert notifier %s skipped: mqtt sender isn't available", opts.Name)
+				continue
+			}
+			list = append(list, alerts.NewMQTT(opts.Name, opts.Topic, sender))
+		}
+	}
+
+	return list
+}
+
+// bootstrapAdmin - creates first admin when users table is empty.
+// One-time password is printed only to stdout and never stored in log files
+func bootstrapAdmin(auth *authorization.AuthorizationService, login string) {
+
+	if login == "" {
+		login = "admin"
+	}
+
+	password, created, err := auth.Bootstrap(login)
+	if err != nil {
+		log.Errorf("bootstrap admin creation error: %v", err)
+		return
+	}
+
+	if !created {
+
+		promoted, ok, err := auth.EnsureAdmin()
+		if err != nil {
+			log.Errorf("admin role check error: %v", err)
+		}
+		if ok {
+			log.Warnf("no admins found, user '%s' promoted to admin", promoted)
+		}
+
+		return
+	}
+
+	log.Warnf("users table is empty, bootstrap user '%s' created. one-time password printed to stdout", login)
+	fmt.Fprintf(os.Stdout,
+		"\n\tdesky bootstrap user: %s\n\tone-time password: %s\n\tchange it after first login: POST /api/auth/password\n\n",
+		login, password,
+	)
 }
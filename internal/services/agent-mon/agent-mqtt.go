@@ -3,11 +3,14 @@ package agentmon
 import (
 	"context"
 	"encoding/json"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/eterline/desky-backend/internal/models"
 	"github.com/eterline/desky-backend/pkg/broker"
+	"github.com/eterline/desky-backend/pkg/logger"
 )
 
 type AgentDataMessage struct {
@@ -16,12 +19,26 @@ type AgentDataMessage struct {
 	Timestamp int64                   `json:"timestamp"`
 }
 
+// AgentPresenceMessage - agent birth message or MQTT Last Will, e.g. {"host-id": "...", "state": "offline"}.
+// Agent with secret signs the state like stats payload
+type AgentPresenceMessage struct {
+	ID        string               `json:"host-id"`
+	State     models.PresenceState `json:"state"`
+	Timestamp int64                `json:"timestamp,omitempty"`
+	Signature string               `json:"signature,omitempty"`
+}
+
 type AgentMonitorServiceWithBroker struct {
 	broker BrokerListener
 
 	agentStats map[string]AgentDataMessage
 	agentStack map[string]models.SessionCredentials
 
+	recorders []StatsRecorder
+	gate      Gate
+	presence  Presence
+	watchers  map[chan models.PresenceEvent]struct{}
+
 	ctx context.Context
 	mu  sync.RWMutex
 }
@@ -36,35 +53,248 @@ func NewAgentMonitorServiceWithBroker(
 
 		agentStats: make(map[string]AgentDataMessage),
 		agentStack: make(map[string]models.SessionCredentials),
+
+		presence: Presence{Heartbeat: DefaultHeartbeat, Forget: DefaultForget},
+		watchers: make(map[chan models.PresenceEvent]struct{}),
 	}
 }
 
+// AddRecorder - adds consumer of received stats, e.g. history storage. Must be called before RunDataUpdater
+func (ab *AgentMonitorServiceWithBroker) AddRecorder(r StatsRecorder) {
+	ab.recorders = append(ab.recorders, r)
+}
+
+// SetGate - sets check of agent payloads. Payloads which aren't admitted are dropped.
+// Must be called before RunDataUpdater
+func (ab *AgentMonitorServiceWithBroker) SetGate(g Gate) {
+	ab.gate = g
+}
+
+// SetPresence - sets agent reporting period and time after which offline agent is forgotten.
+// Must be called before RunPresence
+func (ab *AgentMonitorServiceWithBroker) SetPresence(p Presence) {
+	ab.presence = p
+}
+
 func (ab *AgentMonitorServiceWithBroker) RunDataUpdater(topicListen string) error {
 	return ab.broker.ListenTopic(topicListen, func(m broker.Message) {
 		defer m.Ack()
 
 		data := new(AgentDataMessage)
 
-		if err := json.Unmarshal(m.Payload(), data); err != nil {
+		if err := json.Unmarshal(m.Payload(), data); err != nil || data.ID == "" {
 			return
 		}
 
+		if ab.gate != nil && ab.gate.Admit(data.ID, m.Payload()) != nil {
+			return
+		}
+
+		received := time.Now()
+
+		var hostname string
+		if data.Data.Host != nil {
+			hostname = data.Data.Host.Hostname
+		}
+
 		ab.mu.Lock()
-		defer ab.mu.Unlock()
 
-		if _, ok := ab.agentStack[data.ID]; !ok {
-			ab.agentStack[data.ID] = models.SessionCredentials{
-				Hostname: data.Data.Host.Hostname,
-				ID:       data.ID,
-				URL:      "-",
+		ab.seen(data.ID, hostname, received)
+
+		data.Timestamp = received.Unix()
+		ab.agentStats[data.ID] = *data
+
+		ab.mu.Unlock()
+
+		for _, r := range ab.recorders {
+			if err := r.Record(data.ID, received, data.Data); err != nil {
+				logger.ReturnEntry().Logger.Errorf("agent stats recording error: %v", err)
 			}
 		}
+	})
+}
 
-		data.Timestamp = time.Now().Unix()
-		ab.agentStats[data.ID] = *data
+// RunPresence - listens agent presence messages and refreshes presence states every heartbeat
+// until context is done. Offline message, e.g. MQTT Last Will, marks agent offline instantly
+func (ab *AgentMonitorServiceWithBroker) RunPresence(topicListen string) error {
+
+	err := ab.broker.ListenTopic(topicListen, func(m broker.Message) {
+		defer m.Ack()
+
+		msg := new(AgentPresenceMessage)
+
+		if err := json.Unmarshal(m.Payload(), msg); err != nil || msg.ID == "" {
+			return
+		}
+
+		if ab.gate != nil && ab.gate.VerifyPresence(msg.ID, msg.Timestamp, msg.State, msg.Signature) != nil {
+			return
+		}
+
+		ab.mu.Lock()
+		defer ab.mu.Unlock()
+
+		switch msg.State {
+		case models.PresenceOffline:
+			ab.offline(msg.ID, time.Now())
+		case models.PresenceOnline:
+			ab.seen(msg.ID, "", time.Now())
+		}
 	})
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		tick := time.NewTicker(ab.presence.Heartbeat)
+		defer tick.Stop()
+
+		for {
+			select {
+			case <-ab.ctx.Done():
+				return
+			case now := <-tick.C:
+				ab.refresh(now)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// seen - marks agent online. Must be called with lock held
+func (ab *AgentMonitorServiceWithBroker) seen(id, hostname string, ts time.Time) {
+
+	agent, ok := ab.agentStack[id]
+	if !ok {
+		agent = models.SessionCredentials{
+			ID:  id,
+			URL: "-",
+		}
+	}
+
+	if hostname != "" {
+		agent.Hostname = hostname
+	}
+
+	previous := agent.State
+	agent.State, agent.LastSeen = models.PresenceOnline, ts.Unix()
+	ab.agentStack[id] = agent
+
+	if previous != models.PresenceOnline {
+		ab.notify(agent, previous, ts)
+	}
+}
+
+// offline - marks known agent offline. Must be called with lock held
+func (ab *AgentMonitorServiceWithBroker) offline(id string, ts time.Time) {
+
+	agent, ok := ab.agentStack[id]
+	if !ok || agent.State == models.PresenceOffline {
+		return
+	}
+
+	previous := agent.State
+	agent.State = models.PresenceOffline
+	ab.agentStack[id] = agent
+
+	ab.notify(agent, previous, ts)
+}
+
+// refresh - changes presence states by time since last agent message and forgets long offline agents.
+// Agent marked offline stays offline until it's next message
+func (ab *AgentMonitorServiceWithBroker) refresh(now time.Time) {
+
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+
+	for id, agent := range ab.agentStack {
+
+		since := now.Sub(time.Unix(agent.LastSeen, 0))
+
+		if ab.presence.Forget > 0 && since > ab.presence.Forget {
+			ab.remove(id, now)
+			continue
+		}
+
+		state := ab.presence.State(since)
+		if state == agent.State || agent.State == models.PresenceOffline {
+			continue
+		}
+
+		previous := agent.State
+		agent.State = state
+		ab.agentStack[id] = agent
+
+		ab.notify(agent, previous, now)
+	}
+}
+
+// Forget - removes agent with it's stats, e.g. when it's removed from registry
+func (ab *AgentMonitorServiceWithBroker) Forget(hostID string) {
+
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+
+	ab.remove(hostID, time.Now())
+}
+
+// remove - removes known agent with it's stats and sends removal event. Must be called with lock held
+func (ab *AgentMonitorServiceWithBroker) remove(id string, ts time.Time) {
+
+	agent, ok := ab.agentStack[id]
+	if !ok {
+		return
+	}
+
+	delete(ab.agentStack, id)
+	delete(ab.agentStats, id)
+
+	previous := agent.State
+	agent.State = models.PresenceRemoved
+
+	ab.notify(agent, previous, ts)
 }
 
+// notify - sends presence event to watchers. Events are dropped for slow watcher
+func (ab *AgentMonitorServiceWithBroker) notify(agent models.SessionCredentials, previous models.PresenceState, ts time.Time) {
+
+	event := models.PresenceEvent{
+		Type:     models.PresenceEventType,
+		HostID:   agent.ID,
+		Hostname: agent.Hostname,
+		State:    agent.State,
+		Previous: previous,
+		LastSeen: agent.LastSeen,
+		Time:     ts.Unix(),
+	}
+
+	for ch := range ab.watchers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+func (ab *AgentMonitorServiceWithBroker) watch() chan models.PresenceEvent {
+
+	ch := make(chan models.PresenceEvent, eventsBuffer)
+
+	ab.mu.Lock()
+	ab.watchers[ch] = struct{}{}
+	ab.mu.Unlock()
+
+	return ch
+}
+
+func (ab *AgentMonitorServiceWithBroker) unwatch(ch chan models.PresenceEvent) {
+	ab.mu.Lock()
+	delete(ab.watchers, ch)
+	ab.mu.Unlock()
+}
+
+// List - returns known agents with their presence states, offline agents included
 func (ab *AgentMonitorServiceWithBroker) List() []models.SessionCredentials {
 
 	list := make([]models.SessionCredentials, 0)
@@ -76,20 +306,28 @@ func (ab *AgentMonitorServiceWithBroker) List() []models.SessionCredentials {
 		list = append(list, data)
 	}
 
+	slices.SortFunc(list, func(a, b models.SessionCredentials) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+
 	return list
 }
 
+// Pool - sends stats of agents which aren't offline every 5 seconds and presence events until it's canceled
 func (ab *AgentMonitorServiceWithBroker) Pool() (<-chan any, context.CancelFunc) {
 
 	ch := make(chan any)
 	ctx, cancel := context.WithCancel(ab.ctx)
 
-	go ab.collectStatsTo(ctx, ch) // first send after start
+	events := ab.watch()
 
 	go func() {
 		tick := time.NewTicker(5 * time.Second)
 		defer tick.Stop()
 		defer close(ch)
+		defer ab.unwatch(events)
+
+		ab.collectStatsTo(ctx, ch) // first send after start
 
 		for {
 			select {
@@ -98,6 +336,13 @@ func (ab *AgentMonitorServiceWithBroker) Pool() (<-chan any, context.CancelFunc)
 
 			case <-tick.C:
 				ab.collectStatsTo(ctx, ch)
+
+			case event := <-events:
+				select {
+				case ch <- event:
+				case <-ctx.Done():
+					return
+				}
 			}
 		}
 	}()
@@ -106,26 +351,31 @@ func (ab *AgentMonitorServiceWithBroker) Pool() (<-chan any, context.CancelFunc)
 }
 
 func (ab *AgentMonitorServiceWithBroker) collectStatsTo(ctx context.Context, channel chan any) {
+
 	ab.mu.RLock()
-	defer ab.mu.RUnlock()
 
-	if len(ab.agentStats) == 0 && ctx.Err() == nil {
-		channel <- struct{}{}
+	stats := make([]AgentDataMessage, 0, len(ab.agentStats))
+	for id, data := range ab.agentStats {
+		if ab.agentStack[id].State != models.PresenceOffline {
+			stats = append(stats, data)
+		}
 	}
 
-	for key, data := range ab.agentStats {
-		if ctx.Err() == nil {
-			channel <- data
-
-			if time.Unix(
-				data.Timestamp, 0,
-			).Add(
-				10 * time.Second,
-			).After(
-				time.Unix(data.Timestamp, 0),
-			) {
-				delete(ab.agentStats, key)
-			}
+	ab.mu.RUnlock()
+
+	if len(stats) == 0 {
+		select {
+		case channel <- struct{}{}:
+		case <-ctx.Done():
+		}
+		return
+	}
+
+	for _, data := range stats {
+		select {
+		case channel <- data:
+		case <-ctx.Done():
+			return
 		}
 	}
 }
@@ -0,0 +1,170 @@
+package agentmon
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/pkg/broker"
+)
+
+type memoryMessage struct {
+	topic   string
+	payload []byte
+}
+
+func (m memoryMessage) Duplicate() bool   { return false }
+func (m memoryMessage) Qos() byte         { return 1 }
+func (m memoryMessage) Retained() bool    { return false }
+func (m memoryMessage) Topic() string     { return m.topic }
+func (m memoryMessage) MessageID() uint16 { return 0 }
+func (m memoryMessage) Payload() []byte   { return m.payload }
+func (m memoryMessage) Ack()              {}
+
+type memoryBroker map[string]func(broker.Message)
+
+func (b memoryBroker) ListenTopic(topic string, msgHandle func(broker.Message)) error {
+	b[topic] = msgHandle
+	return nil
+}
+
+func (b memoryBroker) publish(topic, payload string) {
+	b[topic](memoryMessage{topic, []byte(payload)})
+}
+
+func TestPresence(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mq := memoryBroker{}
+
+	agent := NewAgentMonitorServiceWithBroker(ctx, mq)
+	agent.SetPresence(Presence{Heartbeat: time.Hour, Forget: 24 * time.Hour})
+
+	if err := agent.RunDataUpdater("/agent/stats"); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.RunPresence("/agent/presence"); err != nil {
+		t.Fatal(err)
+	}
+
+	pool, stop := agent.Pool()
+	defer stop()
+
+	// nothing is received yet
+	if data := <-pool; data != struct{}{} {
+		t.Fatalf("first pool data = %+v", data)
+	}
+
+	expect := func(state, previous models.PresenceState) {
+		t.Helper()
+		for {
+			select {
+			case data := <-pool:
+				event, ok := data.(models.PresenceEvent)
+				if !ok {
+					continue
+				}
+				if event.HostID != "host-1" || event.State != state || event.Previous != previous {
+					t.Fatalf("event = %+v, want %s after %s", event, state, previous)
+				}
+				return
+			case <-time.After(time.Second):
+				t.Fatalf("no %s event", state)
+			}
+		}
+	}
+
+	state := func() models.PresenceState {
+		t.Helper()
+		list := agent.List()
+		if len(list) != 1 {
+			t.Fatalf("agents = %+v", list)
+		}
+		return list[0].State
+	}
+
+	mq.publish("/agent/stats", `{"host-id":"host-1","data":{"host":{"hostname":"node"}}}`)
+	expect(models.PresenceOnline, "")
+
+	if list := agent.List(); list[0].Hostname != "node" || list[0].LastSeen == 0 {
+		t.Fatalf("agents = %+v", list)
+	}
+
+	now := time.Now()
+
+	agent.refresh(now.Add(StaleBeats*time.Hour + time.Minute))
+	expect(models.PresenceStale, models.PresenceOnline)
+
+	agent.refresh(now.Add(OfflineBeats*time.Hour + time.Minute))
+	expect(models.PresenceOffline, models.PresenceStale)
+
+	// offline agent is still listed, but it's stats aren't sent
+	if state() != models.PresenceOffline {
+		t.Fatalf("state = %s", state())
+	}
+
+	mq.publish("/agent/presence", `{"host-id":"host-1","state":"online"}`)
+	expect(models.PresenceOnline, models.PresenceOffline)
+
+	// last will marks agent offline until it's next message
+	mq.publish("/agent/presence", `{"host-id":"host-1","state":"offline"}`)
+	expect(models.PresenceOffline, models.PresenceOnline)
+
+	agent.refresh(time.Now())
+	if state() != models.PresenceOffline {
+		t.Fatalf("state after refresh = %s", state())
+	}
+
+	// unknown agent is ignored by last will
+	mq.publish("/agent/presence", `{"host-id":"host-2","state":"offline"}`)
+
+	agent.refresh(now.Add(25 * time.Hour))
+	expect(models.PresenceRemoved, models.PresenceOffline)
+
+	if list := agent.List(); len(list) != 0 {
+		t.Fatalf("forgotten agents = %+v", list)
+	}
+}
+
+// memoryGate - admits approved hosts only
+type memoryGate map[string]bool
+
+func (g memoryGate) Admit(hostID string, payload []byte) error {
+	if !g[hostID] {
+		return errors.New("agent isn't approved")
+	}
+	return nil
+}
+
+func (g memoryGate) VerifyPresence(hostID string, timestamp int64, state models.PresenceState, signature string) error {
+	return g.Admit(hostID, nil)
+}
+
+func TestGate(t *testing.T) {
+
+	mq := memoryBroker{}
+
+	agent := NewAgentMonitorServiceWithBroker(context.Background(), mq)
+	agent.SetGate(memoryGate{"host-1": true})
+
+	agent.RunDataUpdater("/agent/stats")
+	agent.RunPresence("/agent/presence")
+
+	mq.publish("/agent/stats", `{"host-id":"host-1","data":{}}`)
+	mq.publish("/agent/stats", `{"host-id":"spoofed","data":{}}`)
+	mq.publish("/agent/presence", `{"host-id":"spoofed","state":"online"}`)
+
+	if list := agent.List(); len(list) != 1 || list[0].ID != "host-1" {
+		t.Fatalf("agents = %+v", list)
+	}
+
+	agent.Forget("host-1")
+
+	if list := agent.List(); len(list) != 0 {
+		t.Fatalf("agents after forget = %+v", list)
+	}
+}
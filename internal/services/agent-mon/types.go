@@ -3,10 +3,43 @@ package agentmon
 import (
 	"context"
 	"sync"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
 
 	"github.com/eterline/desky-backend/pkg/broker"
 )
 
+const (
+	DefaultHeartbeat = 5 * time.Second
+	DefaultForget    = 24 * time.Hour
+
+	// StaleBeats - missed heartbeats after which agent is stale
+	StaleBeats = 2
+	// OfflineBeats - missed heartbeats after which agent is offline
+	OfflineBeats = 6
+
+	eventsBuffer = 64
+)
+
+// Presence - agent reporting period and time after which offline agent is forgotten.
+// Zero Forget keeps offline agents forever
+type Presence struct {
+	Heartbeat time.Duration
+	Forget    time.Duration
+}
+
+// State - returns presence state of agent by time since it's last message
+func (p Presence) State(since time.Duration) models.PresenceState {
+	switch {
+	case since > OfflineBeats*p.Heartbeat:
+		return models.PresenceOffline
+	case since > StaleBeats*p.Heartbeat:
+		return models.PresenceStale
+	}
+	return models.PresenceOnline
+}
+
 type CacheStorage interface {
 	GetValue(key any) any
 	PushValue(key any, value any)
@@ -17,6 +50,17 @@ type BrokerListener interface {
 	ListenTopic(topic string, msgHandle func(broker.Message)) error
 }
 
+// StatsRecorder - consumes received agent stats
+type StatsRecorder interface {
+	Record(hostID string, ts time.Time, stats models.AgentStatsObject) error
+}
+
+// Gate - admits agent payloads, e.g. registry of approved agents
+type Gate interface {
+	Admit(hostID string, payload []byte) error
+	VerifyPresence(hostID string, timestamp int64, state models.PresenceState, signature string) error
+}
+
 type Provider interface {
 	Parameter(string) (any, error)
 }
@@ -0,0 +1,31 @@
+package agentrpc
+
+import (
+	"errors"
+	"fmt"
+)
+
+type AgentRPCError struct {
+	err error
+}
+
+func (e *AgentRPCError) Error() string {
+	return fmt.Sprintf("agent command error: %s", e.err.Error())
+}
+
+func IsAgentRPCError(e error) bool {
+	var rerr *AgentRPCError
+	return errors.As(e, &rerr)
+}
+
+var (
+	ErrHostID = &AgentRPCError{
+		err: errors.New("host id can't be used in topic name"),
+	}
+	ErrTimeout = &AgentRPCError{
+		err: errors.New("agent response timeout"),
+	}
+	ErrFailed = &AgentRPCError{
+		err: errors.New("agent failed to execute command"),
+	}
+)
@@ -0,0 +1,148 @@
+// Package agentrpc sends commands to agents over MQTT and awaits their responses.
+// Requests are published to per host topic and matched with responses by correlation id
+package agentrpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/pkg/broker"
+	"github.com/google/uuid"
+)
+
+type call struct {
+	host     string
+	response chan models.AgentCommandResponse
+}
+
+// Client - keeps commands waiting for agent responses
+type Client struct {
+	publisher Publisher
+	verifier  Verifier
+
+	mu      sync.Mutex
+	pending map[string]call
+}
+
+func New(p Publisher) *Client {
+	return &Client{
+		publisher: p,
+		pending:   make(map[string]call),
+	}
+}
+
+// SetVerifier - sets check of agent responses. Responses which aren't verified are dropped.
+// Must be called before Run
+func (c *Client) SetVerifier(v Verifier) {
+	c.verifier = v
+}
+
+// Run - subscribes agent responses. Must be called before Call
+func (c *Client) Run(l Listener) error {
+	return l.ListenTopic(ResponseFilter, func(m broker.Message) {
+		defer m.Ack()
+
+		resp := models.AgentCommandResponse{}
+		if err := json.Unmarshal(m.Payload(), &resp); err != nil {
+			return
+		}
+
+		host := responseHost(m.Topic())
+
+		if c.verifier != nil && c.verifier.VerifyResponse(host, resp) != nil {
+			return
+		}
+
+		c.mu.Lock()
+		pending, ok := c.pending[resp.ID]
+		if ok && pending.host == host {
+			delete(c.pending, resp.ID)
+		}
+		c.mu.Unlock()
+
+		// response from other host can't answer the command
+		if !ok || pending.host != host {
+			return
+		}
+
+		resp.HostID = host
+		pending.response <- resp
+	})
+}
+
+// Call - publishes command to agent and waits it's response until timeout.
+// Response of failed command is returned with ErrFailed
+func (c *Client) Call(
+	ctx context.Context,
+	hostID string,
+	cmd models.AgentCommand,
+	args models.AgentCommandArgs,
+	timeout time.Duration,
+) (models.AgentCommandResponse, error) {
+
+	if !validHostID(hostID) {
+		return models.AgentCommandResponse{}, ErrHostID
+	}
+
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	req := models.AgentCommandRequest{
+		ID:            uuid.NewString(),
+		Command:       cmd,
+		Args:          args,
+		ResponseTopic: ResponseTopic(hostID),
+		Deadline:      time.Now().Add(timeout).Unix(),
+	}
+
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return models.AgentCommandResponse{}, err
+	}
+
+	response := make(chan models.AgentCommandResponse, 1)
+
+	c.mu.Lock()
+	c.pending[req.ID] = call{host: hostID, response: response}
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, req.ID)
+		c.mu.Unlock()
+	}()
+
+	if err := c.publisher.Publish(RequestTopic(hostID), payload); err != nil {
+		return models.AgentCommandResponse{}, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+
+	case resp := <-response:
+		if !resp.OK {
+			return resp, fmt.Errorf("%w: %s", ErrFailed, resp.Error)
+		}
+		return resp, nil
+
+	case <-timer.C:
+		return models.AgentCommandResponse{}, ErrTimeout
+
+	case <-ctx.Done():
+		return models.AgentCommandResponse{}, ctx.Err()
+	}
+}
+
+// Pending - returns count of commands waiting for response
+func (c *Client) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.pending)
+}
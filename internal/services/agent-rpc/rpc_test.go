@@ -0,0 +1,159 @@
+package agentrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/pkg/broker"
+)
+
+// standInAgent - answers commands of host like desky agent. Unit restart fails for unknown units
+func standInAgent(t *testing.T, lb *broker.Loopback, hostID string) <-chan models.AgentCommandRequest {
+
+	received := make(chan models.AgentCommandRequest, 8)
+
+	err := lb.ListenTopic(RequestTopic(hostID), func(m broker.Message) {
+
+		var req models.AgentCommandRequest
+		if err := json.Unmarshal(m.Payload(), &req); err != nil {
+			t.Error(err)
+			return
+		}
+		received <- req
+
+		resp := models.AgentCommandResponse{ID: req.ID, OK: true}
+
+		switch req.Command {
+		case models.CommandProcesses:
+			resp.Data = json.RawMessage(`[{"pid":1,"name":"init"}]`)
+		case models.CommandUnitRestart:
+			if req.Args.Unit != "nginx.service" {
+				resp.OK, resp.Error = false, "unit not found"
+			}
+		case models.CommandRefresh:
+			// agent which doesn't answer
+			return
+		}
+
+		payload, _ := json.Marshal(resp)
+		lb.Publish(req.ResponseTopic, payload)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return received
+}
+
+func TestCall(t *testing.T) {
+
+	lb := broker.NewLoopback()
+	client := New(lb)
+
+	if err := client.Run(lb); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := standInAgent(t, lb, "host-1")
+	ctx := context.Background()
+
+	resp, err := client.Call(ctx, "host-1", models.CommandProcesses, models.AgentCommandArgs{}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.HostID != "host-1" || string(resp.Data) != `[{"pid":1,"name":"init"}]` {
+		t.Fatalf("response = %+v", resp)
+	}
+
+	if req := <-requests; req.ResponseTopic != "/agent/host-1/response" || req.ID != resp.ID || req.Deadline == 0 {
+		t.Fatalf("request = %+v", req)
+	}
+
+	_, err = client.Call(ctx, "host-1", models.CommandInterval, models.AgentCommandArgs{Interval: "10s"}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if req := <-requests; req.Args.Interval != "10s" {
+		t.Fatalf("request = %+v", req)
+	}
+
+	resp, err = client.Call(ctx, "host-1", models.CommandUnitRestart, models.AgentCommandArgs{Unit: "none.service"}, time.Second)
+	if !errors.Is(err, ErrFailed) || resp.Error != "unit not found" {
+		t.Fatalf("response = %+v, error = %v", resp, err)
+	}
+
+	if _, err := client.Call(ctx, "host-1", models.CommandRefresh, models.AgentCommandArgs{}, 50*time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("error = %v, want %v", err, ErrTimeout)
+	}
+
+	if _, err := client.Call(ctx, "host/#", models.CommandRefresh, models.AgentCommandArgs{}, time.Second); !errors.Is(err, ErrHostID) {
+		t.Fatalf("error = %v, want %v", err, ErrHostID)
+	}
+
+	if client.Pending() != 0 {
+		t.Fatalf("pending = %d", client.Pending())
+	}
+}
+
+func TestSpoofedResponse(t *testing.T) {
+
+	lb := broker.NewLoopback()
+	client := New(lb)
+
+	if err := client.Run(lb); err != nil {
+		t.Fatal(err)
+	}
+
+	// other host answers command of host-1 with it's correlation id
+	lb.ListenTopic(RequestTopic("host-1"), func(m broker.Message) {
+		var req models.AgentCommandRequest
+		json.Unmarshal(m.Payload(), &req)
+
+		payload, _ := json.Marshal(models.AgentCommandResponse{ID: req.ID, OK: true})
+		lb.Publish(ResponseTopic("host-2"), payload)
+	})
+
+	_, err := client.Call(context.Background(), "host-1", models.CommandRefresh, models.AgentCommandArgs{}, 50*time.Millisecond)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("error = %v, want %v", err, ErrTimeout)
+	}
+}
+
+// rejectingVerifier - verifies responses of listed hosts only
+type rejectingVerifier map[string]bool
+
+func (v rejectingVerifier) VerifyResponse(hostID string, resp models.AgentCommandResponse) error {
+	if !v[hostID] {
+		return errors.New("unsigned response")
+	}
+	return nil
+}
+
+func TestUnverifiedResponse(t *testing.T) {
+
+	lb := broker.NewLoopback()
+	client := New(lb)
+	client.SetVerifier(rejectingVerifier{"host-2": true})
+
+	if err := client.Run(lb); err != nil {
+		t.Fatal(err)
+	}
+
+	standInAgent(t, lb, "host-1")
+	standInAgent(t, lb, "host-2")
+
+	_, err := client.Call(context.Background(), "host-1", models.CommandProcesses, models.AgentCommandArgs{}, 50*time.Millisecond)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("error = %v, want %v", err, ErrTimeout)
+	}
+
+	if _, err := client.Call(context.Background(), "host-2", models.CommandProcesses, models.AgentCommandArgs{}, time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,57 @@
+package agentrpc
+
+import (
+	"strings"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/pkg/broker"
+)
+
+const (
+	// DefaultTimeout - time to wait agent response when timeout isn't set
+	DefaultTimeout = 10 * time.Second
+	// MaxTimeout - longest allowed time to wait agent response
+	MaxTimeout = time.Minute
+
+	// ResponseFilter - topic filter of all agent responses
+	ResponseFilter = "/agent/+/response"
+)
+
+// Publisher - publishes message to MQTT topic, e.g. broker.SenderMQTT
+type Publisher interface {
+	Publish(topic string, payload []byte) error
+}
+
+// Listener - subscribes MQTT topic filter, e.g. broker.ListenerMQTT
+type Listener interface {
+	ListenTopic(topic string, msgHandle func(broker.Message)) error
+}
+
+// Verifier - checks that response is sent by approved agent, e.g. agent registry
+type Verifier interface {
+	VerifyResponse(hostID string, resp models.AgentCommandResponse) error
+}
+
+// RequestTopic - returns topic that agent listens commands on
+func RequestTopic(hostID string) string {
+	return "/agent/" + hostID + "/command"
+}
+
+// ResponseTopic - returns topic that agent answers commands to
+func ResponseTopic(hostID string) string {
+	return "/agent/" + hostID + "/response"
+}
+
+// responseHost - returns host id from response topic
+func responseHost(topic string) string {
+	levels := strings.Split(topic, "/")
+	if len(levels) != 4 {
+		return ""
+	}
+	return levels[2]
+}
+
+func validHostID(id string) bool {
+	return id != "" && !strings.ContainsAny(id, "/+#")
+}
@@ -0,0 +1,439 @@
+// Package alerts evaluates threshold rules against metrics of agents and desky host.
+// Alert is pending while condition is met for less than rule duration, then it's fired.
+// Firing alert is resolved only when value leaves threshold by hysteresis distance,
+// when it's rule is changed or when it's metric isn't reported for stale period
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/pkg/logger"
+)
+
+type alertKey struct {
+	rule uint
+	host string
+}
+
+// Engine - keeps rules and states of raised alerts
+type Engine struct {
+	ctx        context.Context
+	repository Repository
+	notifiers  []Notifier
+
+	mu       sync.Mutex
+	rules    []models.AlertRuleT
+	alerts   map[alertKey]*models.AlertEvent
+	watchers map[chan models.AlertEvent]struct{}
+}
+
+func New(ctx context.Context, r Repository, notifiers ...Notifier) *Engine {
+	return &Engine{
+		ctx:        ctx,
+		repository: r,
+		notifiers:  notifiers,
+		alerts:     make(map[alertKey]*models.AlertEvent),
+		watchers:   make(map[chan models.AlertEvent]struct{}),
+	}
+}
+
+// Load - reads rules from repository. Alerts of changed and deleted rules are resolved
+func (e *Engine) Load() error {
+
+	rules, err := e.repository.Rules()
+	if err != nil {
+		return err
+	}
+
+	var notices []notice
+	now := time.Now()
+
+	e.mu.Lock()
+
+	for key, alert := range e.alerts {
+		idx := slices.IndexFunc(rules, func(r models.AlertRuleT) bool { return r.ID == key.rule })
+		if idx < 0 || !sameRule(&rules[idx], alert) {
+			notices = append(notices, e.drop(key, now))
+		}
+	}
+
+	e.rules = rules
+
+	e.mu.Unlock()
+
+	for _, n := range notices {
+		e.publish(n)
+	}
+
+	return nil
+}
+
+// Expire - resolves alerts which metric isn't reported for stale period, e.g. host is offline
+func (e *Engine) Expire(now time.Time, stale time.Duration) {
+
+	var notices []notice
+
+	e.mu.Lock()
+
+	for key, alert := range e.alerts {
+		if now.Sub(time.Unix(alert.Time, 0)) >= stale {
+			notices = append(notices, e.drop(key, now))
+		}
+	}
+
+	e.mu.Unlock()
+
+	for _, n := range notices {
+		e.publish(n)
+	}
+}
+
+// drop - resolves alert which isn't evaluated anymore. Fired alert is resolved with notification
+// by notifiers of current rule
+func (e *Engine) drop(key alertKey, ts time.Time) notice {
+
+	alert := e.alerts[key]
+	delete(e.alerts, key)
+
+	n := notice{
+		event:  resolved(*alert, ts),
+		notify: alert.State == models.AlertFiring,
+	}
+
+	if idx := slices.IndexFunc(e.rules, func(r models.AlertRuleT) bool { return r.ID == key.rule }); idx >= 0 {
+		n.notifiers = e.rules[idx].NotifierList()
+	}
+
+	return n
+}
+
+func sameRule(rule *models.AlertRuleT, alert *models.AlertEvent) bool {
+	return rule.Enabled && rule.Name == alert.Rule && rule.Metric == alert.Metric &&
+		rule.Operator == alert.Operator && rule.Threshold == alert.Threshold && rule.Matches(alert.Host)
+}
+
+// Rules - returns all rules
+func (e *Engine) Rules() ([]models.AlertRuleObject, error) {
+
+	list, err := e.repository.Rules()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.AlertRuleObject, len(list))
+	for i := range list {
+		result[i] = list[i].Object()
+	}
+
+	return result, nil
+}
+
+func (e *Engine) Rule(id uint) (models.AlertRuleObject, error) {
+
+	rule, err := e.repository.RuleById(id)
+	if err != nil {
+		return models.AlertRuleObject{}, err
+	}
+
+	return rule.Object(), nil
+}
+
+func (e *Engine) Create(rule *models.AlertRuleT) error {
+
+	if err := e.check(rule); err != nil {
+		return err
+	}
+
+	if err := e.repository.CreateRule(rule); err != nil {
+		return err
+	}
+
+	return e.Load()
+}
+
+// Update - changes rule. Raised alerts are dropped when rule condition is changed
+func (e *Engine) Update(rule *models.AlertRuleT) error {
+
+	stored, err := e.repository.RuleById(rule.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := e.check(rule); err != nil {
+		return err
+	}
+
+	rule.CreatedAt = stored.CreatedAt
+
+	if err := e.repository.UpdateRule(rule); err != nil {
+		return err
+	}
+
+	return e.Load()
+}
+
+func (e *Engine) Delete(id uint) error {
+
+	if _, err := e.repository.RuleById(id); err != nil {
+		return err
+	}
+
+	if err := e.repository.DeleteRule(id); err != nil {
+		return err
+	}
+
+	return e.Load()
+}
+
+// Notifiers - returns names of notifiers
+func (e *Engine) Notifiers() []string {
+
+	names := make([]string, len(e.notifiers))
+	for i, n := range e.notifiers {
+		names[i] = n.Name()
+	}
+
+	return names
+}
+
+func (e *Engine) check(rule *models.AlertRuleT) error {
+
+	same, err := e.repository.RuleByName(rule.Name)
+	if err != nil {
+		return err
+	}
+
+	if same != nil && same.ID != rule.ID {
+		return ErrNameExists
+	}
+
+	names := e.Notifiers()
+	for _, name := range rule.NotifierList() {
+		if !slices.Contains(names, name) {
+			return fmt.Errorf("%w: %s", ErrUnknownNotifier, name)
+		}
+	}
+
+	return nil
+}
+
+// Alerts - returns pending and firing alerts
+func (e *Engine) Alerts() []models.AlertEvent {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	list := make([]models.AlertEvent, 0, len(e.alerts))
+	for _, alert := range e.alerts {
+		list = append(list, *alert)
+	}
+
+	slices.SortFunc(list, func(a, b models.AlertEvent) int {
+		if c := strings.Compare(a.Rule, b.Rule); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Host, b.Host)
+	})
+
+	return list
+}
+
+// Record - evaluates rules against agent stats
+func (e *Engine) Record(hostID string, ts time.Time, stats models.AgentStatsObject) error {
+	e.Evaluate(hostID, ts, stats.Metrics())
+	return nil
+}
+
+// notice - alert state change and notifiers it's delivered to
+type notice struct {
+	event     models.AlertEvent
+	notify    bool
+	notifiers []string
+}
+
+// Evaluate - changes states of host alerts by metric values
+func (e *Engine) Evaluate(host string, ts time.Time, values map[string]float64) {
+
+	var notices []notice
+
+	e.mu.Lock()
+
+	for i := range e.rules {
+
+		rule := &e.rules[i]
+		if !rule.Matches(host) {
+			continue
+		}
+
+		value, ok := values[rule.Metric]
+		if !ok {
+			continue
+		}
+
+		if n, changed := e.step(rule, host, ts, value); changed {
+			n.notifiers = rule.NotifierList()
+			notices = append(notices, n)
+		}
+	}
+
+	e.mu.Unlock()
+
+	for _, n := range notices {
+		e.publish(n)
+	}
+}
+
+// step - moves alert of rule on host to the next state and reports state change.
+// Notifiers are used for fired alerts and for resolving of fired alerts
+func (e *Engine) step(rule *models.AlertRuleT, host string, ts time.Time, value float64) (notice, bool) {
+
+	key := alertKey{rule.ID, host}
+	alert, raised := e.alerts[key]
+
+	if !raised {
+
+		if !rule.Breached(value) {
+			return notice{}, false
+		}
+
+		alert = &models.AlertEvent{
+			Type:      models.AlertEventType,
+			RuleID:    rule.ID,
+			Rule:      rule.Name,
+			Host:      host,
+			Metric:    rule.Metric,
+			Operator:  rule.Operator,
+			Threshold: rule.Threshold,
+			State:     models.AlertPending,
+			Since:     ts.Unix(),
+		}
+		e.alerts[key] = alert
+	}
+
+	alert.Value, alert.Time = value, ts.Unix()
+
+	switch alert.State {
+
+	case models.AlertPending:
+		if !rule.Breached(value) {
+			delete(e.alerts, key)
+			return notice{event: resolved(*alert, ts)}, true
+		}
+
+		if ts.Unix()-alert.Since >= rule.For {
+			alert.State, alert.Since = models.AlertFiring, ts.Unix()
+			return notice{event: *alert, notify: true}, true
+		}
+
+		return notice{event: *alert}, !raised
+
+	case models.AlertFiring:
+		if rule.Cleared(value) {
+			delete(e.alerts, key)
+			return notice{event: resolved(*alert, ts), notify: true}, true
+		}
+	}
+
+	return notice{}, false
+}
+
+func resolved(alert models.AlertEvent, ts time.Time) models.AlertEvent {
+	alert.State, alert.Since = models.AlertResolved, ts.Unix()
+	return alert
+}
+
+// publish - sends event to watchers and notifiers of rule. Rule without notifiers uses all of them
+func (e *Engine) publish(n notice) {
+
+	e.mu.Lock()
+	for ch := range e.watchers {
+		select {
+		case ch <- n.event:
+		default:
+		}
+	}
+	e.mu.Unlock()
+
+	if !n.notify {
+		return
+	}
+
+	for _, notifier := range e.notifiers {
+		if len(n.notifiers) == 0 || slices.Contains(n.notifiers, notifier.Name()) {
+			go e.notify(notifier, n.event)
+		}
+	}
+}
+
+func (e *Engine) notify(n Notifier, event models.AlertEvent) {
+
+	ctx, cancel := context.WithTimeout(e.ctx, NotifyTimeout)
+	defer cancel()
+
+	if err := n.Notify(ctx, event); err != nil {
+		logger.ReturnEntry().Logger.Errorf("alert notifier %s error: %v", n.Name(), err)
+	}
+}
+
+// Watch - sends alert state changes until it's canceled. Events are dropped for slow watcher
+func (e *Engine) Watch() (<-chan models.AlertEvent, context.CancelFunc) {
+
+	ch := make(chan models.AlertEvent, watchBuffer)
+	ctx, cancel := context.WithCancel(e.ctx)
+
+	e.mu.Lock()
+	e.watchers[ch] = struct{}{}
+	e.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		e.mu.Lock()
+		delete(e.watchers, ch)
+		e.mu.Unlock()
+	}()
+
+	return ch, cancel
+}
+
+// RunExpiry - resolves stale alerts until context is done
+func (e *Engine) RunExpiry(stale time.Duration) {
+	go func() {
+
+		ticker := time.NewTicker(min(stale, time.Minute))
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-e.ctx.Done():
+				return
+			case now := <-ticker.C:
+				e.Expire(now, stale)
+			}
+		}
+	}()
+}
+
+// RunLocal - evaluates rules against desky host stats every interval until context is done
+func (e *Engine) RunLocal(src LocalSource, interval time.Duration) {
+	go func() {
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-e.ctx.Done():
+				return
+			case <-ticker.C:
+				stats := src.Snapshot()
+				e.Evaluate(models.AlertLocalHost, time.Now(), stats.Metrics())
+			}
+		}
+	}()
+}
@@ -0,0 +1,261 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+type memoryRules map[uint]*models.AlertRuleT
+
+func (m memoryRules) CreateRule(rule *models.AlertRuleT) error {
+	rule.ID = uint(len(m) + 1)
+	m[rule.ID] = rule
+	return nil
+}
+
+func (m memoryRules) UpdateRule(rule *models.AlertRuleT) error {
+	m[rule.ID] = rule
+	return nil
+}
+
+func (m memoryRules) RuleById(id uint) (*models.AlertRuleT, error) {
+	rule, ok := m[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return rule, nil
+}
+
+func (m memoryRules) RuleByName(name string) (*models.AlertRuleT, error) {
+	for _, rule := range m {
+		if rule.Name == name {
+			return rule, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m memoryRules) Rules() ([]models.AlertRuleT, error) {
+	list := []models.AlertRuleT{}
+	for _, rule := range m {
+		list = append(list, *rule)
+	}
+	return list, nil
+}
+
+func (m memoryRules) DeleteRule(id uint) error {
+	delete(m, id)
+	return nil
+}
+
+type memoryNotifier struct {
+	name   string
+	mu     sync.Mutex
+	events []models.AlertEvent
+	sent   chan struct{}
+}
+
+func newMemoryNotifier(name string) *memoryNotifier {
+	return &memoryNotifier{name: name, sent: make(chan struct{}, 16)}
+}
+
+func (n *memoryNotifier) Name() string {
+	return n.name
+}
+
+func (n *memoryNotifier) Notify(ctx context.Context, event models.AlertEvent) error {
+	n.mu.Lock()
+	n.events = append(n.events, event)
+	n.mu.Unlock()
+	n.sent <- struct{}{}
+	return nil
+}
+
+func (n *memoryNotifier) await(t *testing.T) models.AlertEvent {
+	t.Helper()
+	select {
+	case <-n.sent:
+	case <-time.After(time.Second):
+		t.Fatal("notification isn't sent")
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.events[len(n.events)-1]
+}
+
+func TestAlertLifecycle(t *testing.T) {
+
+	mail, hook := newMemoryNotifier("mail"), newMemoryNotifier("hook")
+	engine := New(context.Background(), memoryRules{}, mail, hook)
+
+	err := engine.Create(&models.AlertRuleT{
+		Name:       "cpu",
+		Metric:     "cpu.load",
+		Operator:   models.AlertAbove,
+		Threshold:  90,
+		Hysteresis: 5,
+		For:        300,
+		Notifiers:  "mail",
+		Enabled:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, stop := engine.Watch()
+	defer stop()
+
+	base := time.Unix(1700000000, 0)
+	cpu := func(offset time.Duration, load float64) {
+		engine.Evaluate("host-1", base.Add(offset), map[string]float64{"cpu.load": load})
+	}
+
+	expect := func(state models.AlertState) {
+		t.Helper()
+		select {
+		case e := <-events:
+			if e.State != state {
+				t.Fatalf("event state = %s, want %s", e.State, state)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no %s event", state)
+		}
+	}
+
+	cpu(0, 95)
+	expect(models.AlertPending)
+
+	// pending alert which isn't fired is resolved without notification
+	cpu(time.Minute, 50)
+	expect(models.AlertResolved)
+
+	cpu(2*time.Minute, 95)
+	expect(models.AlertPending)
+
+	cpu(5*time.Minute, 96)
+	if alerts := engine.Alerts(); len(alerts) != 1 || alerts[0].State != models.AlertPending || alerts[0].Value != 96 {
+		t.Fatalf("alerts = %+v", alerts)
+	}
+
+	cpu(7*time.Minute, 97)
+	expect(models.AlertFiring)
+
+	if e := mail.await(t); e.State != models.AlertFiring || e.Host != "host-1" || e.Value != 97 {
+		t.Fatalf("notification = %+v", e)
+	}
+
+	// value below threshold but within hysteresis keeps alert firing
+	cpu(8*time.Minute, 87)
+	if alerts := engine.Alerts(); len(alerts) != 1 || alerts[0].State != models.AlertFiring {
+		t.Fatalf("alerts = %+v", alerts)
+	}
+
+	cpu(9*time.Minute, 80)
+	expect(models.AlertResolved)
+
+	if e := mail.await(t); e.State != models.AlertResolved {
+		t.Fatalf("notification = %+v", e)
+	}
+
+	if len(engine.Alerts()) != 0 {
+		t.Fatalf("alerts = %+v", engine.Alerts())
+	}
+
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	if len(hook.events) != 0 {
+		t.Fatalf("not selected notifier got %+v", hook.events)
+	}
+}
+
+func TestRuleScope(t *testing.T) {
+
+	all := newMemoryNotifier("all")
+	engine := New(context.Background(), memoryRules{}, all)
+
+	rules := []models.AlertRuleT{
+		{Name: "disk", Metric: "disk./data.use", Operator: models.AlertAboveOrEqual, Threshold: 85, Enabled: true},
+		{Name: "local-ram", Host: models.AlertLocalHost, Metric: "ram.use", Operator: models.AlertAbove, Threshold: 90, Enabled: true},
+		{Name: "disabled", Metric: "ram.use", Operator: models.AlertAbove, Threshold: 10},
+	}
+
+	for i := range rules {
+		if err := engine.Create(&rules[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Now()
+
+	engine.Evaluate("host-1", now, map[string]float64{"disk./data.use": 85, "ram.use": 95})
+	engine.Evaluate(models.AlertLocalHost, now, map[string]float64{"ram.use": 95})
+
+	alerts := engine.Alerts()
+	if len(alerts) != 2 || alerts[0].Rule != "disk" || alerts[1].Host != models.AlertLocalHost {
+		t.Fatalf("alerts = %+v", alerts)
+	}
+
+	all.await(t)
+	all.await(t)
+
+	// changed condition drops raised alert
+	rules[0].Threshold = 99
+	if err := engine.Update(&rules[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	if alerts := engine.Alerts(); len(alerts) != 1 {
+		t.Fatalf("alerts after update = %+v", alerts)
+	}
+
+	if e := all.await(t); e.State != models.AlertResolved || e.Rule != "disk" {
+		t.Fatalf("dropped alert notification = %+v", e)
+	}
+
+	if err := engine.Create(&models.AlertRuleT{Name: "disk"}); !errors.Is(err, ErrNameExists) {
+		t.Fatalf("error = %v, want %v", err, ErrNameExists)
+	}
+
+	if err := engine.Create(&models.AlertRuleT{Name: "other", Notifiers: "pager"}); !errors.Is(err, ErrUnknownNotifier) {
+		t.Fatalf("error = %v, want %v", err, ErrUnknownNotifier)
+	}
+}
+
+func TestStaleAlerts(t *testing.T) {
+
+	all := newMemoryNotifier("all")
+	engine := New(context.Background(), memoryRules{}, all)
+
+	err := engine.Create(&models.AlertRuleT{
+		Name: "ram", Metric: "ram.use", Operator: models.AlertAbove, Threshold: 90, Enabled: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(1700000000, 0)
+
+	engine.Evaluate("host-1", now, map[string]float64{"ram.use": 95})
+	engine.Evaluate("host-2", now.Add(4*time.Minute), map[string]float64{"ram.use": 95})
+
+	all.await(t)
+	all.await(t)
+
+	// host-1 stopped reporting
+	engine.Expire(now.Add(5*time.Minute), 5*time.Minute)
+
+	alerts := engine.Alerts()
+	if len(alerts) != 1 || alerts[0].Host != "host-2" {
+		t.Fatalf("alerts = %+v", alerts)
+	}
+
+	if e := all.await(t); e.State != models.AlertResolved || e.Host != "host-1" {
+		t.Fatalf("stale alert notification = %+v", e)
+	}
+}
@@ -0,0 +1,28 @@
+package alerts
+
+import (
+	"errors"
+	"fmt"
+)
+
+type AlertsServiceError struct {
+	err error
+}
+
+func (e *AlertsServiceError) Error() string {
+	return fmt.Sprintf("alerts error: %s", e.err.Error())
+}
+
+func IsAlertsServiceError(e error) bool {
+	var aerr *AlertsServiceError
+	return errors.As(e, &aerr)
+}
+
+var (
+	ErrNameExists = &AlertsServiceError{
+		err: errors.New("alert rule with this name already exists"),
+	}
+	ErrUnknownNotifier = &AlertsServiceError{
+		err: errors.New("unknown notifier"),
+	}
+)
@@ -0,0 +1,73 @@
+package alerts
+
+import (
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/internal/services/system"
+)
+
+// SystemSource - provides desky host stats in agent stats format
+type SystemSource struct {
+	Service *system.SystemService
+}
+
+func (s SystemSource) Snapshot() models.AgentStatsObject {
+
+	cpu := s.Service.CPUInfo()
+	host := s.Service.HostInfo()
+	avg := s.Service.Load()
+	ram := s.Service.RAMInfo()
+
+	stats := models.AgentStatsObject{
+		CPU: &models.CPU{
+			Name:        cpu.Name,
+			Model:       cpu.Model,
+			CoreCount:   int(cpu.CoreCount),
+			ThreadCount: int(cpu.ThreadCount),
+			Load:        cpu.Load,
+		},
+		Host: &models.Host{
+			Hostname:   host.Name,
+			Hypervisor: host.VirtSystem,
+			OS:         host.OS,
+			Processes:  int(host.ProcessCount),
+			Uptime:     int(host.Uptime),
+		},
+		Load: &models.Load{
+			Load1:  avg.Load1,
+			Load5:  avg.Load5,
+			Load15: avg.Load15,
+		},
+		RAM: &models.RAM{
+			Total:     int(ram.Total),
+			Available: int(ram.Avail),
+			Used:      int(ram.Used),
+			Use:       ram.UsePercent,
+		},
+	}
+
+	partitions := make([]models.Partition, 0)
+	for _, p := range s.Service.Partitions() {
+		partitions = append(partitions, models.Partition{
+			Device:      p.Device,
+			Mountpoint:  p.Mountpoint,
+			FS:          p.FS,
+			Total:       int(p.Total),
+			Free:        int(p.Free),
+			Used:        int(p.Used),
+			UsedPercent: p.UsedPercent,
+		})
+	}
+	stats.Partitions = &partitions
+
+	temperatures := make([]models.Temperature, 0)
+	for _, sensor := range s.Service.Temperatures() {
+		temperatures = append(temperatures, models.Temperature{
+			Key:     sensor.Key,
+			Current: sensor.Current,
+			Max:     sensor.Max,
+		})
+	}
+	stats.Temperature = &temperatures
+
+	return stats
+}
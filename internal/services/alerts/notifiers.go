@@ -0,0 +1,185 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/eterline/desky-backend/internal/models"
+)
+
+// WebhookNotifier - posts alert events as JSON
+type WebhookNotifier struct {
+	name    string
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func NewWebhook(name, url string, headers map[string]string) *WebhookNotifier {
+	return &WebhookNotifier{
+		name:    name,
+		url:     url,
+		headers: headers,
+		client:  &http.Client{},
+	}
+}
+
+func (n *WebhookNotifier) Name() string {
+	return n.name
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, event models.AlertEvent) error {
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range n.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %s", resp.Status)
+	}
+
+	return nil
+}
+
+type SMTPOptions struct {
+	Host     string
+	Port     uint16
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// SMTPNotifier - sends alert events by mail. Authentication is used only when username is set
+type SMTPNotifier struct {
+	name string
+	opts SMTPOptions
+}
+
+func NewSMTP(name string, opts SMTPOptions) *SMTPNotifier {
+	return &SMTPNotifier{
+		name: name,
+		opts: opts,
+	}
+}
+
+func (n *SMTPNotifier) Name() string {
+	return n.name
+}
+
+func (n *SMTPNotifier) Notify(ctx context.Context, event models.AlertEvent) error {
+
+	var auth smtp.Auth
+	if n.opts.Username != "" {
+		auth = smtp.PlainAuth("", n.opts.Username, n.opts.Password, n.opts.Host)
+	}
+
+	addr := net.JoinHostPort(n.opts.Host, strconv.Itoa(int(n.opts.Port)))
+	done := make(chan error, 1)
+
+	go func() {
+		done <- smtp.SendMail(addr, auth, n.opts.From, n.opts.To, mailMessage(n.opts.From, n.opts.To, event))
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func mailMessage(from string, to []string, event models.AlertEvent) []byte {
+
+	// rule and host names come from users and agents, line breaks would inject headers
+	subject := mime.QEncoding.Encode("utf-8", oneLine(
+		fmt.Sprintf("[desky] %s: %s on %s", strings.ToUpper(string(event.State)), event.Rule, event.Host),
+	))
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Unix(event.Time, 0).Format(time.RFC1123Z))
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+
+	fmt.Fprintf(&b, "Rule: %s\r\n", oneLine(event.Rule))
+	fmt.Fprintf(&b, "Host: %s\r\n", oneLine(event.Host))
+	fmt.Fprintf(&b, "Condition: %s %s %g\r\n", oneLine(event.Metric), event.Operator, event.Threshold)
+	fmt.Fprintf(&b, "Value: %g\r\n", event.Value)
+	fmt.Fprintf(&b, "State: %s since %s\r\n", event.State, time.Unix(event.Since, 0).UTC().Format(time.RFC3339))
+
+	return []byte(b.String())
+}
+
+// oneLine - replaces control characters with spaces
+func oneLine(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+// Publisher - publishes message to MQTT topic, e.g. broker.SenderMQTT
+type Publisher interface {
+	Publish(topic string, payload []byte) error
+}
+
+// MQTTNotifier - publishes alert events as JSON
+type MQTTNotifier struct {
+	name      string
+	topic     string
+	publisher Publisher
+}
+
+func NewMQTT(name, topic string, p Publisher) *MQTTNotifier {
+	return &MQTTNotifier{
+		name:      name,
+		topic:     topic,
+		publisher: p,
+	}
+}
+
+func (n *MQTTNotifier) Name() string {
+	return n.name
+}
+
+func (n *MQTTNotifier) Notify(ctx context.Context, event models.AlertEvent) error {
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return n.publisher.Publish(n.topic, payload)
+}
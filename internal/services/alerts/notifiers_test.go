@@ -0,0 +1,205 @@
+package alerts
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+)
+
+var testEvent = models.AlertEvent{
+	Type:      models.AlertEventType,
+	RuleID:    1,
+	Rule:      "cpu",
+	Host:      "host-1",
+	Metric:    "cpu.load",
+	Operator:  models.AlertAbove,
+	Threshold: 90,
+	Value:     97,
+	State:     models.AlertFiring,
+	Since:     1700000000,
+	Time:      1700000000,
+}
+
+func TestWebhookNotifier(t *testing.T) {
+
+	received := make(chan models.AlertEvent, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var event models.AlertEvent
+		json.NewDecoder(r.Body).Decode(&event)
+		received <- event
+	}))
+	defer srv.Close()
+
+	err := NewWebhook("hook", srv.URL, map[string]string{"Authorization": "Bearer secret"}).Notify(context.Background(), testEvent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if event := <-received; event != testEvent {
+		t.Fatalf("event = %+v", event)
+	}
+
+	if err := NewWebhook("hook", srv.URL, nil).Notify(context.Background(), testEvent); err == nil {
+		t.Fatal("error status isn't reported")
+	}
+}
+
+// smtpStandIn - accepts single mail and returns it's envelope and data
+func smtpStandIn(t *testing.T) (string, <-chan []string) {
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	lines := make(chan []string, 1)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var got []string
+		rd := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+
+		reply("220 localhost ESMTP")
+
+		for data := false; ; {
+			line, err := rd.ReadString('\n')
+			if err != nil {
+				break
+			}
+			line = strings.TrimRight(line, "\r\n")
+			got = append(got, line)
+
+			switch {
+			case data:
+				if line == "." {
+					data = false
+					reply("250 queued")
+				}
+			case strings.HasPrefix(line, "EHLO"):
+				reply("250-localhost")
+				reply("250 AUTH PLAIN")
+			case strings.HasPrefix(line, "AUTH PLAIN"):
+				reply("235 accepted")
+			case line == "DATA":
+				data = true
+				reply("354 go ahead")
+			case line == "QUIT":
+				reply("221 bye")
+				lines <- got
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+
+		lines <- got
+	}()
+
+	return ln.Addr().String(), lines
+}
+
+func TestSMTPNotifier(t *testing.T) {
+
+	addr, lines := smtpStandIn(t)
+	host, port, _ := net.SplitHostPort(addr)
+
+	portNum, _ := strconv.ParseUint(port, 10, 16)
+
+	n := NewSMTP("mail", SMTPOptions{
+		Host:     host,
+		Port:     uint16(portNum),
+		Username: "desky",
+		Password: "pw",
+		From:     "desky@example.com",
+		To:       []string{"ops@example.com"},
+	})
+
+	if err := n.Notify(context.Background(), testEvent); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	select {
+	case got = <-lines:
+	case <-time.After(time.Second):
+		t.Fatal("mail isn't received")
+	}
+
+	session := strings.Join(got, "\n")
+
+	auth := base64.StdEncoding.EncodeToString([]byte("\x00desky\x00pw"))
+
+	for _, want := range []string{
+		"AUTH PLAIN " + auth,
+		"MAIL FROM:<desky@example.com>",
+		"RCPT TO:<ops@example.com>",
+		"Subject: [desky] FIRING: cpu on host-1",
+		"Condition: cpu.load > 90",
+		"Value: 97",
+	} {
+		if !strings.Contains(session, want) {
+			t.Errorf("smtp session has no %q:\n%s", want, session)
+		}
+	}
+}
+
+func TestMailHeaderInjection(t *testing.T) {
+
+	event := testEvent
+	event.Host = "host-1\r\nBcc: victim@example.com"
+
+	msg := string(mailMessage("desky@example.com", []string{"ops@example.com"}, event))
+
+	if strings.Contains(msg, "\r\nBcc:") {
+		t.Fatalf("host name injected header:\n%s", msg)
+	}
+
+	if !strings.Contains(msg, "Subject: [desky] FIRING: cpu on host-1  Bcc: victim@example.com\r\n") {
+		t.Fatalf("subject isn't kept in one line:\n%s", msg)
+	}
+}
+
+type memoryPublisher map[string][]byte
+
+func (p memoryPublisher) Publish(topic string, payload []byte) error {
+	p[topic] = payload
+	return nil
+}
+
+func TestMQTTNotifier(t *testing.T) {
+
+	pub := memoryPublisher{}
+
+	if err := NewMQTT("mqtt", "/desky/alerts", pub).Notify(context.Background(), testEvent); err != nil {
+		t.Fatal(err)
+	}
+
+	var event models.AlertEvent
+	if err := json.Unmarshal(pub["/desky/alerts"], &event); err != nil || event != testEvent {
+		t.Fatalf("published = %s, %v", pub["/desky/alerts"], err)
+	}
+}
@@ -0,0 +1,37 @@
+package alerts
+
+import (
+	"context"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+)
+
+const (
+	// LocalInterval - period of desky host metrics evaluation
+	LocalInterval = 15 * time.Second
+	// NotifyTimeout - timeout of single notification delivery
+	NotifyTimeout = 10 * time.Second
+	// watchBuffer - events kept for slow watcher before they're dropped
+	watchBuffer = 64
+)
+
+type Repository interface {
+	CreateRule(rule *models.AlertRuleT) error
+	UpdateRule(rule *models.AlertRuleT) error
+	RuleById(id uint) (*models.AlertRuleT, error)
+	RuleByName(name string) (*models.AlertRuleT, error)
+	Rules() ([]models.AlertRuleT, error)
+	DeleteRule(id uint) error
+}
+
+// Notifier - delivers firing and resolved alerts
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, event models.AlertEvent) error
+}
+
+// LocalSource - provides stats of desky host
+type LocalSource interface {
+	Snapshot() models.AgentStatsObject
+}
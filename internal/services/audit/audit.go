@@ -0,0 +1,93 @@
+package audit
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/internal/services/handler"
+	"github.com/eterline/desky-backend/internal/services/limiter"
+	"github.com/eterline/desky-backend/pkg/logger"
+	"github.com/sirupsen/logrus"
+)
+
+type Repository interface {
+	Append(event *models.AuditEventT) error
+	Query(f models.AuditFilter) ([]models.AuditEventT, int64, error)
+}
+
+// Auditor - writes security relevant actions to append only audit log.
+// Storage errors never break audited actions and are written to error log
+type Auditor struct {
+	repository Repository
+	log        *logrus.Logger
+}
+
+func New(r Repository) *Auditor {
+	return &Auditor{
+		repository: r,
+		log:        logger.ReturnEntry().Logger,
+	}
+}
+
+// Event - returns new event with actor and address of request
+func Event(r *http.Request, action models.AuditAction, target string) *models.AuditEventT {
+
+	event := &models.AuditEventT{
+		RemoteAddr: limiter.RemoteIP(r.RemoteAddr),
+		Action:     action,
+		Target:     target,
+	}
+
+	if claims, ok := handler.SessionFromContext(r.Context()); ok {
+		event.Actor = claims.Login
+		event.UserID = claims.UserID
+	}
+
+	return event
+}
+
+// Record - stores event with result of action. Nil error means successful action
+func (a *Auditor) Record(event *models.AuditEventT, err error) {
+
+	e := *event
+	e.ID = 0
+	e.CreatedAt = time.Now()
+	e.Result = models.AuditSuccess
+
+	if err != nil {
+		e.Result = models.AuditFailure
+		if e.Details == "" {
+			e.Details = err.Error()
+		}
+	}
+
+	if err := a.repository.Append(&e); err != nil {
+		a.log.Errorf("audit event '%s' of '%s' write error: %v", e.Action, e.Actor, err)
+	}
+}
+
+// Query - returns filtered events and count of all filtered events
+func (a *Auditor) Query(f models.AuditFilter) ([]models.AuditEvent, int64, error) {
+
+	list, count, err := a.repository.Query(f)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	events := make([]models.AuditEvent, len(list))
+	for i := range list {
+		events[i] = list[i].Event()
+	}
+
+	return events, count, nil
+}
+
+// StatusError - returns error of failed http response status
+func StatusError(code int) error {
+	if code < http.StatusBadRequest {
+		return nil
+	}
+	return fmt.Errorf("response status %d", code)
+}
@@ -1,10 +1,13 @@
 package authorization
 
 import (
-	"errors"
+	"sync"
+	"time"
 
 	"github.com/eterline/desky-backend/internal/models"
 	"github.com/eterline/desky-backend/internal/services/hash"
+	"github.com/eterline/desky-backend/pkg/totp"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -13,18 +16,86 @@ type UserRepository interface {
 	CreateUser(user *models.DeskyUserT) error
 	DeleteUser(id int) error
 	UserByLogin(login string) (*models.DeskyUserT, error)
+	UserBySubject(provider, subject string) (*models.DeskyUserT, error)
 	UserById(id int) (*models.DeskyUserT, error)
+	UpdatePassword(id uint, password string) error
+	SetPassword(id uint, password string, mustChange bool) error
+	UpdateLogin(id uint, login string) error
+	UpdateRole(id uint, role models.UserRole) error
+	Count() (int64, error)
+	CountRole(role models.UserRole) (int64, error)
+	FirstUser() (*models.DeskyUserT, error)
+
+	UpdateTOTP(id uint, secret string, enabled bool) error
+	UpdateTOTPCounter(id uint, counter uint64) error
+	UseTOTPCounter(id uint, counter uint64) (bool, error)
+	ReplaceRecoveryCodes(userID uint, hashes []string) error
+	UseRecoveryCode(userID uint, digest string) (bool, error)
+}
+
+type SessionRepository interface {
+	CreateSession(session *models.DeskySessionT) error
+	SessionById(id string) (*models.DeskySessionT, error)
+	DeleteSession(id string) error
+	DeleteUserSessions(userID uint) error
+	DeleteExpired() error
+}
+
+// AttemptsLimiter - limits failed login attempts by client address and login
+type AttemptsLimiter interface {
+	Check(remote, login string) error
+	Failure(remote, login string) error
+	Success(remote, login string) error
+	Unlock(login string) error
 }
 
 type AuthorizationService struct {
 	repository UserRepository
+	sessions   SessionRepository
+	tokens     TokenRepository
+	limiter    AttemptsLimiter
 	hash       *hash.HashService
+	totp       *totp.TOTP
+
+	dummy     string
+	dummyOnce sync.Once
+
+	secret []byte
+	ttl    time.Duration
+	issuer string
+
+	now func() time.Time
 }
 
-func New(r UserRepository) *AuthorizationService {
+func New(
+	r UserRepository, s SessionRepository, t TokenRepository,
+	h *hash.HashService, secret []byte, ttl time.Duration,
+) *AuthorizationService {
 	return &AuthorizationService{
 		repository: r,
-		hash:       hash.New(hash.SHA512, []byte("random")),
+		sessions:   s,
+		tokens:     t,
+		limiter:    noLimit{},
+		hash:       h,
+		totp:       totp.New(),
+		secret:     secret,
+		ttl:        ttl,
+		issuer:     DefaultIssuer,
+		now:        time.Now,
+	}
+}
+
+// SetIssuer - sets issuer name that is shown in authenticator apps
+func (aus *AuthorizationService) SetIssuer(issuer string) {
+	if issuer != "" {
+		aus.issuer = issuer
+	}
+}
+
+// SetLimiter - sets failed login attempts limiter
+func (aus *AuthorizationService) SetLimiter(l AttemptsLimiter) {
+	if l != nil {
+		aus.limiter = l
 	}
 }
 
@@ -38,6 +109,8 @@ func (aus *AuthorizationService) All() ([]*models.DeskyUser, error) {
 
 	for i, usr := range users {
 		data[i] = models.NewDeskyUser(usr.ID, usr.Login, "")
+		data[i].Role = usr.Role
+		data[i].Provider = usr.Provider
 	}
 
 	return data, nil
@@ -51,23 +124,203 @@ func (aus *AuthorizationService) Register(user *models.DeskyUser) error {
 	}
 
 	if err != gorm.ErrRecordNotFound && tReq.Login == user.Login {
-		return errors.New("user already exists")
+		return ErrUserExists
 	}
 
-	hashedPassword := aus.hash.StringHash(user.Password)
-	userT := models.NewDeskyUserT(user.Login, hashedPassword.String())
+	hashedPassword, err := aus.hash.Hash(user.Password)
+	if err != nil {
+		return err
+	}
+
+	userT := models.NewDeskyUserT(user.Login, hashedPassword, models.StringUserRole(string(user.Role)))
 
 	return aus.repository.CreateUser(userT)
 }
 
+// Edit - renames user, changes his role and/or resets his password. Empty fields are not changed.
+// All changes are checked before any of them is stored. Password reset closes all user sessions
+// and revokes his API tokens
 func (aus *AuthorizationService) Edit(user *models.DeskyUser, id int) error {
-	panic("")
+
+	current, err := aus.repository.UserById(id)
+	if err != nil {
+		return err
+	}
+
+	rename := user.Login != "" && user.Login != current.Login
+	if rename {
+
+		_, err := aus.repository.UserByLogin(user.Login)
+		if err == nil {
+			return ErrUserExists
+		}
+		if err != gorm.ErrRecordNotFound {
+			return err
+		}
+	}
+
+	changeRole := user.Role != "" && user.Role != current.Role
+	if changeRole {
+		if err := aus.keepAdmin(current); err != nil {
+			return err
+		}
+	}
+
+	if rename {
+		if err := aus.repository.UpdateLogin(current.ID, user.Login); err != nil {
+			return err
+		}
+	}
+
+	if changeRole {
+		if err := aus.repository.UpdateRole(current.ID, models.StringUserRole(string(user.Role))); err != nil {
+			return err
+		}
+	}
+
+	if user.Password != "" {
+		if err := aus.setPassword(current.ID, user.Password); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// keepAdmin - protects the last admin from demotion or deletion
+func (aus *AuthorizationService) keepAdmin(user *models.DeskyUserT) error {
+
+	if user.Role != models.RoleAdmin {
+		return nil
+	}
+
+	count, err := aus.repository.CountRole(models.RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	if count <= 1 {
+		return ErrLastAdmin
+	}
+
+	return nil
+}
+
+// ChangeOwnPassword - changes password after current password verification
+func (aus *AuthorizationService) ChangeOwnPassword(id int, current, password string) error {
+
+	if _, err := aus.VerifyWithID(id, "", current); err != nil {
+		return err
+	}
+
+	return aus.setPassword(uint(id), password)
 }
 
+// setPassword - stores new password hash, closes user sessions and revokes his API tokens,
+// so password reset locks out whoever had access before
+func (aus *AuthorizationService) setPassword(id uint, password string) error {
+
+	hashedPassword, err := aus.hash.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	if err := aus.repository.SetPassword(id, hashedPassword, false); err != nil {
+		return err
+	}
+
+	if err := aus.sessions.DeleteUserSessions(id); err != nil {
+		return err
+	}
+
+	return aus.tokens.DeleteUserTokens(id)
+}
+
+// Delete - removes user with his sessions. The last user can't be deleted
 func (aus *AuthorizationService) Delete(id int) error {
+
+	user, err := aus.repository.UserById(id)
+	if err != nil {
+		return err
+	}
+
+	count, err := aus.repository.Count()
+	if err != nil {
+		return err
+	}
+
+	if count <= 1 {
+		return ErrLastUser
+	}
+
+	if err := aus.keepAdmin(user); err != nil {
+		return err
+	}
+
+	if err := aus.sessions.DeleteUserSessions(user.ID); err != nil {
+		return err
+	}
+
+	if err := aus.tokens.DeleteUserTokens(user.ID); err != nil {
+		return err
+	}
+
 	return aus.repository.DeleteUser(id)
 }
 
+// Bootstrap - creates first admin user with one-time password when users table is empty.
+// Created user must change password after first login
+func (aus *AuthorizationService) Bootstrap(login string) (password string, created bool, err error) {
+
+	count, err := aus.repository.Count()
+	if err != nil || count > 0 {
+		return "", false, err
+	}
+
+	password, err = OneTimePassword()
+	if err != nil {
+		return "", false, err
+	}
+
+	hashedPassword, err := aus.hash.Hash(password)
+	if err != nil {
+		return "", false, err
+	}
+
+	userT := models.NewDeskyUserT(login, hashedPassword, models.RoleAdmin)
+	userT.MustChangePassword = true
+
+	if err := aus.repository.CreateUser(userT); err != nil {
+		return "", false, err
+	}
+
+	return password, true, nil
+}
+
+// EnsureAdmin - promotes the first user to admin when no admins exist.
+// Used for databases that were created before roles were introduced
+func (aus *AuthorizationService) EnsureAdmin() (login string, promoted bool, err error) {
+
+	count, err := aus.repository.CountRole(models.RoleAdmin)
+	if err != nil || count > 0 {
+		return "", false, err
+	}
+
+	user, err := aus.repository.FirstUser()
+	if err == gorm.ErrRecordNotFound {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	if err := aus.repository.UpdateRole(user.ID, models.RoleAdmin); err != nil {
+		return "", false, err
+	}
+
+	return user.Login, true, nil
+}
+
 func (aus *AuthorizationService) VerifyWithID(id int, login, password string) (*models.DeskyUser, error) {
 
 	user, err := aus.repository.UserById(id)
@@ -75,10 +328,14 @@ func (aus *AuthorizationService) VerifyWithID(id int, login, password string) (*
 		return nil, err
 	}
 
-	if !(aus.hash.EqStrings(user.Password, password) && int(user.ID) == id) {
+	if int(user.ID) != id {
 		return nil, ErrVerifyPassword
 	}
 
+	if err := aus.checkPassword(user, password); err != nil {
+		return nil, err
+	}
+
 	return models.NewDeskyUser(user.ID, user.Login, user.Password), nil
 }
 
@@ -89,10 +346,189 @@ func (aus *AuthorizationService) Verify(login, password string) (*models.DeskyUs
 		return nil, err
 	}
 
-	ok := aus.hash.EqStrings(user.Password, password)
-	if !ok {
-		return nil, ErrVerifyPassword
+	if err := aus.checkPassword(user, password); err != nil {
+		return nil, err
 	}
 
 	return models.NewDeskyUser(user.ID, user.Login, user.Password), nil
 }
+
+// checkPassword - compares password with stored hash.
+// Legacy or outdated hashes are transparently replaced after successful check
+func (aus *AuthorizationService) checkPassword(user *models.DeskyUserT, password string) error {
+
+	// single sign-on users have no local password
+	if user.Password == "" {
+		aus.compareDummy(password)
+		return ErrVerifyPassword
+	}
+
+	ok, err := aus.hash.Compare(user.Password, password)
+	if err != nil || !ok {
+		return ErrVerifyPassword
+	}
+
+	if !aus.hash.NeedsRehash(user.Password) {
+		return nil
+	}
+
+	// rehash errors must not block login, old hash stays valid until next attempt
+	rehashed, err := aus.hash.Hash(password)
+	if err != nil {
+		return nil
+	}
+
+	if err := aus.repository.UpdatePassword(user.ID, rehashed); err == nil {
+		user.Password = rehashed
+	}
+
+	return nil
+}
+
+// compareDummy - spends the same time as password check of existing user,
+// so response timing doesn't reveal valid logins
+func (aus *AuthorizationService) compareDummy(password string) {
+
+	aus.dummyOnce.Do(func() {
+		aus.dummy, _ = aus.hash.Hash(uuid.NewString())
+	})
+
+	aus.hash.Compare(aus.dummy, password)
+}
+
+// Login - verifies user credentials and opens new signed session.
+// Users with enabled two-factor authentication receive challenge token instead of session
+func (aus *AuthorizationService) Login(login, password, remote string) (string, *models.SessionClaims, error) {
+
+	if err := aus.limiter.Check(remote, login); err != nil {
+		return "", nil, err
+	}
+
+	user, err := aus.repository.UserByLogin(login)
+	if err == gorm.ErrRecordNotFound {
+		aus.compareDummy(password)
+		return "", nil, aus.failure(remote, login, ErrVerifyPassword)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := aus.checkPassword(user, password); err != nil {
+		return "", nil, aus.failure(remote, login, err)
+	}
+
+	// attempt of two-factor user stays reserved until the second factor is accepted,
+	// so valid password can't reset failures of code guessing
+	if user.TOTPEnabled {
+		return aus.challenge(user)
+	}
+
+	if err := aus.limiter.Success(remote, login); err != nil {
+		return "", nil, err
+	}
+
+	return aus.openSession(user, remote)
+}
+
+// failure - counts failed attempt. Returns limiter error when attempt caused lockout
+func (aus *AuthorizationService) failure(remote, login string, cause error) error {
+
+	if err := aus.limiter.Failure(remote, login); err != nil {
+		return err
+	}
+
+	return cause
+}
+
+// Unlock - removes login lockout of user
+func (aus *AuthorizationService) Unlock(id int) error {
+
+	user, err := aus.repository.UserById(id)
+	if err != nil {
+		return err
+	}
+
+	return aus.limiter.Unlock(user.Login)
+}
+
+// openSession - stores new session and signs it's token
+func (aus *AuthorizationService) openSession(user *models.DeskyUserT, remote string) (string, *models.SessionClaims, error) {
+
+	now := aus.now()
+
+	claims := &models.SessionClaims{
+		Kind:      models.SessionToken,
+		SessionID: uuid.NewString(),
+		UserID:    user.ID,
+		Login:     user.Login,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(aus.ttl).Unix(),
+
+		Role:           user.Role,
+		PasswordChange: user.MustChangePassword,
+	}
+
+	token, err := signToken(aus.secret, claims)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := aus.sessions.DeleteExpired(); err != nil {
+		return "", nil, err
+	}
+
+	session := models.NewDeskySessionT(
+		claims.SessionID, claims.UserID,
+		remote, time.Unix(claims.ExpiresAt, 0),
+	)
+
+	if err := aus.sessions.CreateSession(session); err != nil {
+		return "", nil, err
+	}
+
+	return token, claims, nil
+}
+
+// Session - validates session or personal API token and returns it's claims
+func (aus *AuthorizationService) Session(token string) (*models.SessionClaims, error) {
+
+	if IsAPIToken(token) {
+		return aus.apiToken(token)
+	}
+
+	claims, err := parseToken(aus.secret, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Kind != models.SessionToken {
+		return nil, ErrInvalidToken
+	}
+
+	if aus.now().Unix() > claims.ExpiresAt {
+		return nil, ErrSessionExpired
+	}
+
+	session, err := aus.sessions.SessionById(claims.SessionID)
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrSessionExpired
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if session.UserID != claims.UserID || aus.now().After(session.ExpiresAt) {
+		return nil, ErrSessionExpired
+	}
+
+	claims.Login = session.User.Login
+	claims.Role = session.User.Role
+	claims.PasswordChange = session.User.MustChangePassword
+
+	return claims, nil
+}
+
+// Logout - closes session by it's ID
+func (aus *AuthorizationService) Logout(sessionID string) error {
+	return aus.sessions.DeleteSession(sessionID)
+}
@@ -0,0 +1,755 @@
+package authorization
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/internal/services/hash"
+	"github.com/eterline/desky-backend/internal/services/limiter"
+	"github.com/eterline/desky-backend/pkg/totp"
+	"gorm.io/gorm"
+)
+
+type memoryUsers struct {
+	users    map[uint]*models.DeskyUserT
+	recovery map[uint][]string
+}
+
+func (m *memoryUsers) All() ([]models.DeskyUserT, error) {
+	list := []models.DeskyUserT{}
+	for _, user := range m.users {
+		list = append(list, *user)
+	}
+	return list, nil
+}
+
+func (m *memoryUsers) CreateUser(user *models.DeskyUserT) error {
+	user.ID = uint(len(m.users) + 1)
+	copied := *user
+	m.users[user.ID] = &copied
+	return nil
+}
+
+func (m *memoryUsers) DeleteUser(id int) error {
+	delete(m.users, uint(id))
+	return nil
+}
+
+func (m *memoryUsers) find(match func(user *models.DeskyUserT) bool) (*models.DeskyUserT, error) {
+	for _, user := range m.users {
+		if match(user) {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *memoryUsers) UserByLogin(login string) (*models.DeskyUserT, error) {
+	return m.find(func(user *models.DeskyUserT) bool { return user.Login == login })
+}
+
+func (m *memoryUsers) UserBySubject(provider, subject string) (*models.DeskyUserT, error) {
+	return m.find(func(user *models.DeskyUserT) bool {
+		return user.Provider == provider && user.Subject == subject
+	})
+}
+
+func (m *memoryUsers) UserById(id int) (*models.DeskyUserT, error) {
+	return m.find(func(user *models.DeskyUserT) bool { return user.ID == uint(id) })
+}
+
+func (m *memoryUsers) UpdatePassword(id uint, password string) error {
+	m.users[id].Password = password
+	return nil
+}
+
+func (m *memoryUsers) SetPassword(id uint, password string, mustChange bool) error {
+	m.users[id].Password, m.users[id].MustChangePassword = password, mustChange
+	return nil
+}
+
+func (m *memoryUsers) UpdateLogin(id uint, login string) error {
+	m.users[id].Login = login
+	return nil
+}
+
+func (m *memoryUsers) UpdateRole(id uint, role models.UserRole) error {
+	m.users[id].Role = role
+	return nil
+}
+
+func (m *memoryUsers) Count() (int64, error) {
+	return int64(len(m.users)), nil
+}
+
+func (m *memoryUsers) CountRole(role models.UserRole) (count int64, err error) {
+	for _, user := range m.users {
+		if user.Role == role {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *memoryUsers) FirstUser() (*models.DeskyUserT, error) {
+	return m.UserById(1)
+}
+
+func (m *memoryUsers) UpdateTOTP(id uint, secret string, enabled bool) error {
+	m.users[id].TOTPSecret, m.users[id].TOTPEnabled = secret, enabled
+	return nil
+}
+
+func (m *memoryUsers) UpdateTOTPCounter(id uint, counter uint64) error {
+	m.users[id].TOTPLastCounter = counter
+	return nil
+}
+
+func (m *memoryUsers) UseTOTPCounter(id uint, counter uint64) (bool, error) {
+	if m.users[id].TOTPLastCounter >= counter {
+		return false, nil
+	}
+	m.users[id].TOTPLastCounter = counter
+	return true, nil
+}
+
+func (m *memoryUsers) ReplaceRecoveryCodes(userID uint, hashes []string) error {
+	m.recovery[userID] = hashes
+	return nil
+}
+
+func (m *memoryUsers) UseRecoveryCode(userID uint, digest string) (bool, error) {
+	for i, h := range m.recovery[userID] {
+		if h == digest {
+			m.recovery[userID] = append(m.recovery[userID][:i], m.recovery[userID][i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type memorySessions struct {
+	users    *memoryUsers
+	sessions map[string]models.DeskySessionT
+}
+
+func (m *memorySessions) CreateSession(session *models.DeskySessionT) error {
+	m.sessions[session.ID] = *session
+	return nil
+}
+
+func (m *memorySessions) SessionById(id string) (*models.DeskySessionT, error) {
+	session, ok := m.sessions[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	session.User = *m.users.users[session.UserID]
+	return &session, nil
+}
+
+func (m *memorySessions) DeleteSession(id string) error {
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *memorySessions) DeleteUserSessions(userID uint) error {
+	for id, session := range m.sessions {
+		if session.UserID == userID {
+			delete(m.sessions, id)
+		}
+	}
+	return nil
+}
+
+func (m *memorySessions) DeleteExpired() error {
+	return nil
+}
+
+type memoryTokens struct {
+	users  *memoryUsers
+	tokens map[uint]models.DeskyAPITokenT
+}
+
+func (m *memoryTokens) CreateToken(token *models.DeskyAPITokenT) error {
+	token.ID = uint(len(m.tokens) + 1)
+	m.tokens[token.ID] = *token
+	return nil
+}
+
+func (m *memoryTokens) TokenByHash(hash string) (*models.DeskyAPITokenT, error) {
+	for _, token := range m.tokens {
+		if token.Hash == hash {
+			token.User = *m.users.users[token.UserID]
+			return &token, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *memoryTokens) TokenById(id uint) (*models.DeskyAPITokenT, error) {
+	token, ok := m.tokens[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &token, nil
+}
+
+func (m *memoryTokens) UserTokens(userID uint) ([]models.DeskyAPITokenT, error) {
+	list := []models.DeskyAPITokenT{}
+	for _, token := range m.tokens {
+		if token.UserID == userID {
+			list = append(list, token)
+		}
+	}
+	return list, nil
+}
+
+func (m *memoryTokens) AllTokens() ([]models.DeskyAPITokenT, error) {
+	list := []models.DeskyAPITokenT{}
+	for _, token := range m.tokens {
+		list = append(list, token)
+	}
+	return list, nil
+}
+
+func (m *memoryTokens) TouchToken(id uint, at time.Time) error {
+	token := m.tokens[id]
+	token.LastUsedAt = &at
+	m.tokens[id] = token
+	return nil
+}
+
+func (m *memoryTokens) DeleteToken(id uint) error {
+	delete(m.tokens, id)
+	return nil
+}
+
+func (m *memoryTokens) DeleteUserTokens(userID uint) error {
+	for id, token := range m.tokens {
+		if token.UserID == userID {
+			delete(m.tokens, id)
+		}
+	}
+	return nil
+}
+
+type memoryLocks map[string]models.DeskyLoginLockT
+
+func (m memoryLocks) Locks() ([]models.DeskyLoginLockT, error) {
+	return nil, nil
+}
+
+func (m memoryLocks) SaveLock(lock *models.DeskyLoginLockT) error {
+	m[lock.Key] = *lock
+	return nil
+}
+
+func (m memoryLocks) DeleteLock(key string) error {
+	delete(m, key)
+	return nil
+}
+
+type testService struct {
+	*AuthorizationService
+
+	users    *memoryUsers
+	sessions *memorySessions
+	tokens   *memoryTokens
+	now      time.Time
+}
+
+// newTestService - creates service over memory repositories with clock fixed at now
+func newTestService(t *testing.T) *testService {
+
+	users := &memoryUsers{users: map[uint]*models.DeskyUserT{}, recovery: map[uint][]string{}}
+
+	ts := &testService{
+		users:    users,
+		sessions: &memorySessions{users: users, sessions: map[string]models.DeskySessionT{}},
+		tokens:   &memoryTokens{users: users, tokens: map[uint]models.DeskyAPITokenT{}},
+		now:      time.Unix(1700000000, 0),
+	}
+
+	ts.AuthorizationService = New(ts.users, ts.sessions, ts.tokens, hash.New(hash.Bcrypt), []byte("test-secret"), time.Hour)
+	ts.AuthorizationService.now = func() time.Time { return ts.now }
+	ts.totp = totp.New(totp.WithClock(func() time.Time { return ts.now }))
+
+	return ts
+}
+
+// user - creates local user with password 'password'
+func (ts *testService) user(t *testing.T, login string, role models.UserRole) *models.DeskyUserT {
+
+	hashed, err := ts.hash.Hash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := models.NewDeskyUserT(login, hashed, role)
+	if err := ts.users.CreateUser(user); err != nil {
+		t.Fatal(err)
+	}
+
+	return user
+}
+
+// twoFactor - enables TOTP of user and returns it's secret and recovery codes
+func (ts *testService) twoFactor(t *testing.T, user *models.DeskyUserT) (string, []string) {
+
+	secret, _, err := ts.SetupTOTP(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	codes, err := ts.EnableTOTP(user.ID, ts.code(t, secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return secret, codes
+}
+
+// code - returns TOTP code of current time step
+func (ts *testService) code(t *testing.T, secret string) string {
+
+	code, err := ts.totp.Code(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return code
+}
+
+func TestEditRename(t *testing.T) {
+
+	ts := newTestService(t)
+	user := ts.user(t, "bob", models.RoleOperator)
+	ts.user(t, "alice", models.RoleOperator)
+
+	if err := ts.Edit(&models.DeskyUser{Login: "alice", Password: "new password"}, int(user.ID)); !errors.Is(err, ErrUserExists) {
+		t.Fatalf("error = %v, want %v", err, ErrUserExists)
+	}
+
+	if _, _, err := ts.Login("bob", "password", "10.0.0.1:5000"); err != nil {
+		t.Fatalf("failed edit changed user: %v", err)
+	}
+
+	if err := ts.Edit(&models.DeskyUser{Login: "robert"}, int(user.ID)); err != nil {
+		t.Fatal(err)
+	}
+
+	if stored := ts.users.users[user.ID]; stored.Login != "robert" {
+		t.Fatalf("edited user = %+v", stored)
+	}
+}
+
+func TestDeleteLastUser(t *testing.T) {
+
+	ts := newTestService(t)
+	first := ts.user(t, "bob", models.RoleOperator)
+	second := ts.user(t, "alice", models.RoleOperator)
+
+	if err := ts.Delete(int(second.ID)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ts.Delete(int(first.ID)); !errors.Is(err, ErrLastUser) {
+		t.Fatalf("error = %v, want %v", err, ErrLastUser)
+	}
+}
+
+func TestEditLastAdmin(t *testing.T) {
+
+	ts := newTestService(t)
+	admin := ts.user(t, "admin", models.RoleAdmin)
+	ts.user(t, "viewer", models.RoleViewer)
+
+	cases := []struct {
+		name string
+		edit models.DeskyUser
+		err  error
+	}{
+		{"demote", models.DeskyUser{Role: models.RoleViewer}, ErrLastAdmin},
+		{"rename and demote", models.DeskyUser{Login: "root", Role: models.RoleOperator}, ErrLastAdmin},
+		{"rename to taken login", models.DeskyUser{Login: "viewer"}, ErrUserExists},
+	}
+
+	for _, c := range cases {
+		if err := ts.Edit(&c.edit, int(admin.ID)); !errors.Is(err, c.err) {
+			t.Errorf("%s: error = %v, want %v", c.name, err, c.err)
+		}
+	}
+
+	stored := ts.users.users[admin.ID]
+	if stored.Login != "admin" || stored.Role != models.RoleAdmin {
+		t.Fatalf("failed edit changed user: %+v", stored)
+	}
+
+	if err := ts.Delete(int(admin.ID)); !errors.Is(err, ErrLastAdmin) {
+		t.Fatalf("delete: error = %v, want %v", err, ErrLastAdmin)
+	}
+
+	second := ts.user(t, "second", models.RoleAdmin)
+
+	if err := ts.Edit(&models.DeskyUser{Login: "root", Role: models.RoleViewer}, int(admin.ID)); err != nil {
+		t.Fatal(err)
+	}
+
+	if stored := ts.users.users[admin.ID]; stored.Login != "root" || stored.Role != models.RoleViewer {
+		t.Fatalf("edited user = %+v", stored)
+	}
+
+	if err := ts.Edit(&models.DeskyUser{Role: models.RoleViewer}, int(second.ID)); !errors.Is(err, ErrLastAdmin) {
+		t.Fatalf("error = %v, want %v", err, ErrLastAdmin)
+	}
+}
+
+func TestEditPasswordReset(t *testing.T) {
+
+	ts := newTestService(t)
+	user := ts.user(t, "bob", models.RoleOperator)
+
+	token, _, err := ts.Login("bob", "password", "10.0.0.1:5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ts.Edit(&models.DeskyUser{Password: "new password"}, int(user.ID)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ts.Session(token); !errors.Is(err, ErrSessionExpired) {
+		t.Fatalf("session after reset: error = %v, want %v", err, ErrSessionExpired)
+	}
+
+	if _, _, err := ts.Login("bob", "password", "10.0.0.1:5000"); !errors.Is(err, ErrVerifyPassword) {
+		t.Fatalf("old password: error = %v, want %v", err, ErrVerifyPassword)
+	}
+
+	if _, _, err := ts.Login("bob", "new password", "10.0.0.1:5000"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+
+	ts := newTestService(t)
+	user := ts.user(t, "bob", models.RoleOperator)
+	secret, _ := ts.twoFactor(t, user)
+
+	challenge, claims, err := ts.Login("bob", "password", "10.0.0.1:5000")
+	if err != nil || claims.Kind != models.ChallengeToken {
+		t.Fatalf("login = %+v, %v", claims, err)
+	}
+
+	if _, err := ts.Session(challenge); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("challenge used as session: error = %v, want %v", err, ErrInvalidToken)
+	}
+
+	// code of time step used to enable TOTP can't be used again
+	if _, _, err := ts.LoginSecondFactor(challenge, ts.code(t, secret), "", "10.0.0.1:5000"); !errors.Is(err, ErrVerifyCode) {
+		t.Fatalf("used code: error = %v, want %v", err, ErrVerifyCode)
+	}
+
+	ts.now = ts.now.Add(30 * time.Second)
+	code := ts.code(t, secret)
+
+	token, claims, err := ts.LoginSecondFactor(challenge, code, "", "10.0.0.1:5000")
+	if err != nil || claims.Kind != models.SessionToken {
+		t.Fatalf("second factor = %+v, %v", claims, err)
+	}
+
+	if _, err := ts.Session(token); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := ts.LoginSecondFactor(challenge, code, "", "10.0.0.1:5000"); !errors.Is(err, ErrVerifyCode) {
+		t.Fatalf("replayed code: error = %v, want %v", err, ErrVerifyCode)
+	}
+
+	ts.now = ts.now.Add(ChallengeTTL + time.Second)
+
+	if _, _, err := ts.LoginSecondFactor(challenge, ts.code(t, secret), "", "10.0.0.1:5000"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expired challenge: error = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+
+	ts := newTestService(t)
+	user := ts.user(t, "bob", models.RoleOperator)
+	_, codes := ts.twoFactor(t, user)
+
+	if len(codes) != RecoveryCodesCount {
+		t.Fatalf("recovery codes = %d", len(codes))
+	}
+
+	for _, stored := range ts.users.recovery[user.ID] {
+		for _, code := range codes {
+			if stored == code || stored == NormalizeRecoveryCode(code) {
+				t.Fatal("recovery code is stored in plain text")
+			}
+		}
+	}
+
+	challenge, _, err := ts.Login("bob", "password", "10.0.0.1:5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name string
+		code string
+		err  error
+	}{
+		{"unknown", "aaaaaaaa-bbbbbbbb", ErrVerifyCode},
+		{"valid", strings.ToUpper(codes[0]), nil},
+		{"reused", codes[0], ErrVerifyCode},
+		{"other", codes[1], nil},
+	}
+
+	for _, c := range cases {
+		if _, _, err := ts.LoginSecondFactor(challenge, "", c.code, "10.0.0.1:5000"); !errors.Is(err, c.err) {
+			t.Errorf("%s: error = %v, want %v", c.name, err, c.err)
+		}
+	}
+
+	if left := len(ts.users.recovery[user.ID]); left != RecoveryCodesCount-2 {
+		t.Fatalf("recovery codes left = %d", left)
+	}
+}
+
+func TestAPITokens(t *testing.T) {
+
+	ts := newTestService(t)
+	admin := ts.user(t, "admin", models.RoleAdmin)
+	bob := ts.user(t, "bob", models.RoleOperator)
+	alice := ts.user(t, "alice", models.RoleOperator)
+
+	scopes := []models.TokenScope{models.ScopeSSHRead, models.ScopeAgentRead}
+
+	token, info, err := ts.MintToken(bob.ID, "ci", scopes, ts.now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := ts.MintToken(bob.ID, "old", scopes, ts.now.Add(-time.Second)); !errors.Is(err, ErrTokenExpires) {
+		t.Fatalf("expired mint: error = %v, want %v", err, ErrTokenExpires)
+	}
+
+	claims, err := ts.Session(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if claims.Kind != models.APIToken || claims.UserID != bob.ID || claims.Role != models.RoleOperator || len(claims.Scopes) != 2 {
+		t.Fatalf("claims = %+v", claims)
+	}
+
+	// token role follows it's owner
+	ts.users.UpdateRole(bob.ID, models.RoleViewer)
+	if claims, err := ts.Session(token); err != nil || claims.Role != models.RoleViewer {
+		t.Fatalf("demoted owner claims = %+v, %v", claims, err)
+	}
+
+	cases := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"unknown", APITokenPrefix + "unknown", ErrInvalidToken},
+		{"changed", token[:len(token)-1] + "x", ErrInvalidToken},
+	}
+
+	for _, c := range cases {
+		if _, err := ts.Session(c.token); !errors.Is(err, c.err) {
+			t.Errorf("%s: error = %v, want %v", c.name, err, c.err)
+		}
+	}
+
+	// other users can't revoke token, admin can
+	if err := ts.RevokeToken(&models.SessionClaims{UserID: alice.ID, Role: models.RoleOperator}, info.ID); err != gorm.ErrRecordNotFound {
+		t.Fatalf("foreign revoke: error = %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+
+	if _, err := ts.Session(token); err != nil {
+		t.Fatalf("token revoked by other user: %v", err)
+	}
+
+	ts.now = ts.now.Add(2 * time.Hour)
+
+	if _, err := ts.Session(token); !errors.Is(err, ErrSessionExpired) {
+		t.Fatalf("expired: error = %v, want %v", err, ErrSessionExpired)
+	}
+
+	if err := ts.RevokeToken(&models.SessionClaims{UserID: admin.ID, Role: models.RoleAdmin}, info.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ts.Session(token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("revoked: error = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestPasswordResetRevokesTokens(t *testing.T) {
+
+	ts := newTestService(t)
+	user := ts.user(t, "bob", models.RoleOperator)
+
+	token, _, err := ts.MintToken(user.ID, "ci", []models.TokenScope{models.ScopeSSHRead}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ts.Edit(&models.DeskyUser{Password: "new password"}, int(user.ID)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ts.Session(token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("token after reset: error = %v, want %v", err, ErrInvalidToken)
+	}
+
+	token, _, _ = ts.MintToken(user.ID, "ci", []models.TokenScope{models.ScopeSSHRead}, time.Time{})
+
+	if err := ts.ChangeOwnPassword(int(user.ID), "new password", "newer password"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ts.Session(token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("token after change: error = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+
+	ts := newTestService(t)
+	user := ts.user(t, "bob", models.RoleOperator)
+	secret, _ := ts.twoFactor(t, user)
+
+	// login attempts only, guesses come from different addresses
+	l, err := limiter.New(memoryLocks{}, limiter.Policy{
+		Attempts:   5,
+		Window:     time.Hour,
+		Lockout:    time.Hour,
+		MaxLockout: time.Hour,
+	}, limiter.Policy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.SetLimiter(l)
+
+	var locked error
+
+	for i := 0; i < 10 && locked == nil; i++ {
+
+		remote := fmt.Sprintf("10.0.0.%d:5000", i+1)
+
+		challenge, _, err := ts.Login("bob", "password", remote)
+		if err != nil {
+			locked = err
+			break
+		}
+
+		_, _, err = ts.LoginSecondFactor(challenge, "000000", "", remote)
+		if !errors.Is(err, ErrVerifyCode) {
+			locked = err
+		}
+	}
+
+	if _, ok := limiter.IsLockedError(locked); !ok {
+		t.Fatalf("code guessing with valid password isn't locked: %v", locked)
+	}
+
+	if _, _, err := ts.Login("bob", "password", "10.0.1.1:5000"); err == nil {
+		t.Fatal("locked login accepted valid password")
+	}
+
+	if err := ts.Unlock(int(user.ID)); err != nil {
+		t.Fatal(err)
+	}
+
+	// accepted second factor releases login
+	for i := 0; i < 3; i++ {
+
+		challenge, _, err := ts.Login("bob", "password", "10.0.2.1:5000")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ts.now = ts.now.Add(30 * time.Second)
+
+		if _, _, err := ts.LoginSecondFactor(challenge, ts.code(t, secret), "", "10.0.2.1:5000"); err != nil {
+			t.Fatalf("login %d: %v", i, err)
+		}
+	}
+
+	// failed password attempts lock users without second factor
+	ts.user(t, "alice", models.RoleOperator)
+
+	for i := 0; i < 4; i++ {
+		if _, _, err := ts.Login("alice", "wrong", "10.0.3.1:5000"); !errors.Is(err, ErrVerifyPassword) {
+			t.Fatalf("failure %d: error = %v, want %v", i, err, ErrVerifyPassword)
+		}
+	}
+
+	if _, _, err := ts.Login("alice", "wrong", "10.0.3.1:5000"); err == nil || errors.Is(err, ErrVerifyPassword) {
+		t.Fatalf("fifth failure: error = %v, want lockout", err)
+	}
+}
+
+func TestLoginExternal(t *testing.T) {
+
+	ts := newTestService(t)
+	ts.user(t, "bob", models.RoleAdmin)
+
+	identity := &models.ExternalIdentity{
+		Provider: "https://idp.example",
+		Subject:  "42",
+		Login:    "alice",
+		Role:     models.RoleAdmin,
+	}
+
+	_, claims, err := ts.LoginExternal(identity, "10.0.0.1:5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alice := ts.users.users[claims.UserID]
+	if alice.Login != "alice" || alice.Password != "" || alice.Role != models.RoleAdmin {
+		t.Fatalf("provisioned user = %+v", alice)
+	}
+
+	if _, _, err := ts.Login("alice", "", "10.0.0.1:5000"); !errors.Is(err, ErrVerifyPassword) {
+		t.Fatalf("password login of external user: error = %v, want %v", err, ErrVerifyPassword)
+	}
+
+	// local user isn't linked by login
+	local := &models.ExternalIdentity{Provider: "https://idp.example", Subject: "7", Login: "bob", Role: models.RoleAdmin}
+	if _, _, err := ts.LoginExternal(local, "10.0.0.1:5000"); !errors.Is(err, ErrExternalLogin) {
+		t.Fatalf("local login: error = %v, want %v", err, ErrExternalLogin)
+	}
+
+	// role follows claims while other admin exists
+	identity.Role = models.RoleOperator
+	if _, claims, err := ts.LoginExternal(identity, "10.0.0.1:5000"); err != nil || claims.Role != models.RoleOperator {
+		t.Fatalf("demoted claims = %+v, %v", claims, err)
+	}
+
+	// the last admin can't be demoted by claims
+	ts.users.UpdateRole(alice.ID, models.RoleAdmin)
+	ts.users.UpdateRole(1, models.RoleViewer)
+
+	identity.Role = models.RoleViewer
+	if _, _, err := ts.LoginExternal(identity, "10.0.0.1:5000"); !errors.Is(err, ErrLastAdmin) {
+		t.Fatalf("last admin: error = %v, want %v", err, ErrLastAdmin)
+	}
+
+	if role := ts.users.users[alice.ID].Role; role != models.RoleAdmin {
+		t.Fatalf("last admin role = %s", role)
+	}
+}
@@ -13,8 +13,62 @@ func (ausr *AuthorizationServiceError) Error() string {
 	return fmt.Sprintf("authorization service error: %s", ausr.err.Error())
 }
 
+func IsAuthorizationServiceError(e error) bool {
+
+	if e == nil {
+		return false
+	}
+
+	_, ok := e.(*AuthorizationServiceError)
+	return ok
+}
+
 var (
 	ErrVerifyPassword = &AuthorizationServiceError{
 		err: errors.New("verification password error"),
 	}
+
+	ErrInvalidToken = &AuthorizationServiceError{
+		err: errors.New("invalid session token"),
+	}
+
+	ErrSessionExpired = &AuthorizationServiceError{
+		err: errors.New("session expired"),
+	}
+
+	ErrUserExists = &AuthorizationServiceError{
+		err: errors.New("user already exists"),
+	}
+
+	ErrLastUser = &AuthorizationServiceError{
+		err: errors.New("the last user can't be deleted"),
+	}
+
+	ErrVerifyCode = &AuthorizationServiceError{
+		err: errors.New("verification code error"),
+	}
+
+	ErrTOTPEnabled = &AuthorizationServiceError{
+		err: errors.New("two-factor authentication already enabled"),
+	}
+
+	ErrTOTPNotConfigured = &AuthorizationServiceError{
+		err: errors.New("two-factor authentication is not configured"),
+	}
+
+	ErrTokenExpires = &AuthorizationServiceError{
+		err: errors.New("token expiration must be in the future"),
+	}
+
+	ErrLastAdmin = &AuthorizationServiceError{
+		err: errors.New("the last admin can't be deleted or demoted"),
+	}
+
+	ErrExternalLogin = &AuthorizationServiceError{
+		err: errors.New("login is already used by another user"),
+	}
+
+	ErrExternalUser = &AuthorizationServiceError{
+		err: errors.New("user is managed by single sign-on provider"),
+	}
 )
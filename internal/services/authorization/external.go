@@ -0,0 +1,47 @@
+package authorization
+
+import (
+	"github.com/eterline/desky-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// LoginExternal - opens session for identity confirmed by single sign-on provider.
+// Unknown identities are provisioned as new users, role of known users follows provider claims.
+// Local users are never linked with external identities by login.
+// Claims can't demote the last admin, such login fails with ErrLastAdmin
+func (aus *AuthorizationService) LoginExternal(identity *models.ExternalIdentity, remote string) (string, *models.SessionClaims, error) {
+
+	user, err := aus.repository.UserBySubject(identity.Provider, identity.Subject)
+
+	switch err {
+
+	case nil:
+		if user.Role != identity.Role {
+			if err := aus.keepAdmin(user); err != nil {
+				return "", nil, err
+			}
+			if err := aus.repository.UpdateRole(user.ID, identity.Role); err != nil {
+				return "", nil, err
+			}
+			user.Role = identity.Role
+		}
+
+	case gorm.ErrRecordNotFound:
+		if _, err := aus.repository.UserByLogin(identity.Login); err != gorm.ErrRecordNotFound {
+			if err != nil {
+				return "", nil, err
+			}
+			return "", nil, ErrExternalLogin
+		}
+
+		user = models.NewExternalDeskyUserT(identity)
+		if err := aus.repository.CreateUser(user); err != nil {
+			return "", nil, err
+		}
+
+	default:
+		return "", nil, err
+	}
+
+	return aus.openSession(user, remote)
+}
@@ -0,0 +1,62 @@
+package authorization
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/eterline/desky-backend/internal/models"
+)
+
+// signToken - encodes claims and signs them with HMAC-SHA256.
+// Token format: base64url(claims).base64url(signature)
+func signToken(secret []byte, claims *models.SessionClaims) (string, error) {
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	body := base64.RawURLEncoding.EncodeToString(payload)
+	sign := base64.RawURLEncoding.EncodeToString(tokenMAC(secret, body))
+
+	return body + "." + sign, nil
+}
+
+// parseToken - checks token signature and decodes claims
+func parseToken(secret []byte, token string) (*models.SessionClaims, error) {
+
+	body, sign, ok := strings.Cut(token, ".")
+	if !ok || body == "" || sign == "" {
+		return nil, ErrInvalidToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(sign)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if !hmac.Equal(signature, tokenMAC(secret, body)) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	claims := new(models.SessionClaims)
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+func tokenMAC(secret []byte, body string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(body))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,152 @@
+package authorization
+
+import (
+	"strings"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	APITokenPrefix = "dsk_"
+	// APITokenTouchInterval - minimal interval between last-used timestamp updates
+	APITokenTouchInterval = time.Minute
+)
+
+type TokenRepository interface {
+	CreateToken(token *models.DeskyAPITokenT) error
+	TokenByHash(hash string) (*models.DeskyAPITokenT, error)
+	TokenById(id uint) (*models.DeskyAPITokenT, error)
+	UserTokens(userID uint) ([]models.DeskyAPITokenT, error)
+	AllTokens() ([]models.DeskyAPITokenT, error)
+	TouchToken(id uint, at time.Time) error
+	DeleteToken(id uint) error
+	DeleteUserTokens(userID uint) error
+}
+
+// IsAPIToken - reports that token value has personal API token format
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, APITokenPrefix)
+}
+
+// MintToken - creates personal API token. Plain token value is returned only once
+func (aus *AuthorizationService) MintToken(
+	userID uint, name string,
+	scopes []models.TokenScope, expires time.Time,
+) (string, *models.APITokenInfo, error) {
+
+	if _, err := aus.repository.UserById(int(userID)); err != nil {
+		return "", nil, err
+	}
+
+	token, err := APITokenValue()
+	if err != nil {
+		return "", nil, err
+	}
+
+	var expiresAt *time.Time
+	if !expires.IsZero() {
+		if !expires.After(aus.now()) {
+			return "", nil, ErrTokenExpires
+		}
+		expiresAt = &expires
+	}
+
+	tokenT := models.NewDeskyAPITokenT(
+		userID, name,
+		token[:len(APITokenPrefix)+6], TokenDigest(token),
+		scopes, expiresAt,
+	)
+
+	if err := aus.tokens.CreateToken(tokenT); err != nil {
+		return "", nil, err
+	}
+
+	info := tokenT.Info()
+
+	return token, &info, nil
+}
+
+// Tokens - returns token list of user. Zero user ID returns tokens of all users
+func (aus *AuthorizationService) Tokens(userID uint) ([]models.APITokenInfo, error) {
+
+	var (
+		list []models.DeskyAPITokenT
+		err  error
+	)
+
+	if userID == 0 {
+		list, err = aus.tokens.AllTokens()
+	} else {
+		list, err = aus.tokens.UserTokens(userID)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]models.APITokenInfo, len(list))
+	for i := range list {
+		data[i] = list[i].Info()
+	}
+
+	return data, nil
+}
+
+// RevokeToken - deletes token. Users can revoke only own tokens, admins - any token
+func (aus *AuthorizationService) RevokeToken(claims *models.SessionClaims, id uint) error {
+
+	token, err := aus.tokens.TokenById(id)
+	if err != nil {
+		return err
+	}
+
+	if token.UserID != claims.UserID && !claims.Role.Allows(models.RoleAdmin) {
+		return gorm.ErrRecordNotFound
+	}
+
+	return aus.tokens.DeleteToken(id)
+}
+
+// apiToken - validates personal API token and returns it's claims.
+// Role of token is resolved from token owner on every request
+func (aus *AuthorizationService) apiToken(value string) (*models.SessionClaims, error) {
+
+	token, err := aus.tokens.TokenByHash(TokenDigest(value))
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := aus.now()
+
+	if token.Expired(now) {
+		return nil, ErrSessionExpired
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > APITokenTouchInterval {
+		if err := aus.tokens.TouchToken(token.ID, now); err != nil {
+			return nil, err
+		}
+	}
+
+	claims := &models.SessionClaims{
+		Kind:     models.APIToken,
+		UserID:   token.UserID,
+		Login:    token.User.Login,
+		IssuedAt: token.CreatedAt.Unix(),
+
+		Role:           token.User.Role,
+		PasswordChange: token.User.MustChangePassword,
+		Scopes:         token.ScopesList(),
+	}
+
+	if token.ExpiresAt != nil {
+		claims.ExpiresAt = token.ExpiresAt.Unix()
+	}
+
+	return claims, nil
+}
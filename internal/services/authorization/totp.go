@@ -0,0 +1,244 @@
+package authorization
+
+import (
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/pkg/totp"
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultIssuer      = "desky"
+	TOTPSecretSize     = 20
+	RecoveryCodesCount = 10
+	ChallengeTTL       = 5 * time.Minute
+)
+
+// challenge - signs short living token of the first login step
+func (aus *AuthorizationService) challenge(user *models.DeskyUserT) (string, *models.SessionClaims, error) {
+
+	now := aus.now()
+
+	claims := &models.SessionClaims{
+		Kind:      models.ChallengeToken,
+		SessionID: uuid.NewString(),
+		UserID:    user.ID,
+		Login:     user.Login,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ChallengeTTL).Unix(),
+	}
+
+	token, err := signToken(aus.secret, claims)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, claims, nil
+}
+
+// LoginSecondFactor - completes login challenge with TOTP or recovery code
+func (aus *AuthorizationService) LoginSecondFactor(challenge, code, recovery, remote string) (string, *models.SessionClaims, error) {
+
+	claims, err := parseToken(aus.secret, challenge)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if claims.Kind != models.ChallengeToken || aus.now().Unix() > claims.ExpiresAt {
+		return "", nil, ErrInvalidToken
+	}
+
+	user, err := aus.repository.UserById(int(claims.UserID))
+	if err != nil {
+		return "", nil, ErrInvalidToken
+	}
+
+	if !user.TOTPEnabled {
+		return "", nil, ErrInvalidToken
+	}
+
+	if err := aus.limiter.Check(remote, user.Login); err != nil {
+		return "", nil, err
+	}
+
+	if code != "" {
+		err = aus.checkTOTP(user, code)
+	} else {
+		err = aus.useRecoveryCode(user, recovery)
+	}
+	if err != nil {
+		return "", nil, aus.failure(remote, user.Login, err)
+	}
+
+	if err := aus.limiter.Success(remote, user.Login); err != nil {
+		return "", nil, err
+	}
+
+	return aus.openSession(user, remote)
+}
+
+// SetupTOTP - generates new pending secret. Two-factor stays disabled until EnableTOTP
+func (aus *AuthorizationService) SetupTOTP(userID uint) (secret, uri string, err error) {
+
+	user, err := aus.repository.UserById(int(userID))
+	if err != nil {
+		return "", "", err
+	}
+
+	if user.TOTPEnabled {
+		return "", "", ErrTOTPEnabled
+	}
+
+	// second factor of single sign-on users is managed by provider
+	if user.Provider != "" {
+		return "", "", ErrExternalUser
+	}
+
+	secret, err = totp.GenerateSecret(TOTPSecretSize)
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := aus.repository.UpdateTOTP(user.ID, secret, false); err != nil {
+		return "", "", err
+	}
+
+	return secret, aus.totp.URI(aus.issuer, user.Login, secret), nil
+}
+
+// EnableTOTP - confirms pending secret with code and returns recovery codes.
+// Recovery codes are stored as digests and can't be shown again
+func (aus *AuthorizationService) EnableTOTP(userID uint, code string) ([]string, error) {
+
+	user, err := aus.repository.UserById(int(userID))
+	if err != nil {
+		return nil, err
+	}
+
+	if user.TOTPEnabled {
+		return nil, ErrTOTPEnabled
+	}
+
+	if user.TOTPSecret == "" {
+		return nil, ErrTOTPNotConfigured
+	}
+
+	if err := aus.checkTOTP(user, code); err != nil {
+		return nil, err
+	}
+
+	if err := aus.repository.UpdateTOTP(user.ID, user.TOTPSecret, true); err != nil {
+		return nil, err
+	}
+
+	if err := aus.repository.UpdateTOTPCounter(user.ID, user.TOTPLastCounter); err != nil {
+		return nil, err
+	}
+
+	return aus.newRecoveryCodes(user.ID)
+}
+
+// RegenerateRecoveryCodes - replaces all recovery codes after TOTP code check
+func (aus *AuthorizationService) RegenerateRecoveryCodes(userID uint, code string) ([]string, error) {
+
+	user, err := aus.repository.UserById(int(userID))
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.TOTPEnabled {
+		return nil, ErrTOTPNotConfigured
+	}
+
+	if err := aus.checkTOTP(user, code); err != nil {
+		return nil, err
+	}
+
+	return aus.newRecoveryCodes(user.ID)
+}
+
+// DisableTOTP - turns two-factor off after password check
+func (aus *AuthorizationService) DisableTOTP(userID uint, password string) error {
+
+	if _, err := aus.VerifyWithID(int(userID), "", password); err != nil {
+		return err
+	}
+
+	if err := aus.repository.UpdateTOTP(userID, "", false); err != nil {
+		return err
+	}
+
+	return aus.repository.ReplaceRecoveryCodes(userID, nil)
+}
+
+// TwoFactorEnabled - reports user two-factor state
+func (aus *AuthorizationService) TwoFactorEnabled(userID uint) bool {
+
+	user, err := aus.repository.UserById(int(userID))
+	if err != nil {
+		return false
+	}
+
+	return user.TOTPEnabled
+}
+
+// checkTOTP - validates code and rejects replays of already used time steps.
+// Counter is moved forward by conditional update, so the same code can't pass twice concurrently
+func (aus *AuthorizationService) checkTOTP(user *models.DeskyUserT, code string) error {
+
+	counter, ok := aus.totp.Validate(user.TOTPSecret, code)
+	if !ok || counter <= user.TOTPLastCounter {
+		return ErrVerifyCode
+	}
+
+	used, err := aus.repository.UseTOTPCounter(user.ID, counter)
+	if err != nil {
+		return err
+	}
+
+	if !used {
+		return ErrVerifyCode
+	}
+
+	user.TOTPLastCounter = counter
+
+	return nil
+}
+
+// useRecoveryCode - deletes matching recovery code. Codes are random, so they're looked up by digest
+func (aus *AuthorizationService) useRecoveryCode(user *models.DeskyUserT, value string) error {
+
+	used, err := aus.repository.UseRecoveryCode(user.ID, TokenDigest(NormalizeRecoveryCode(value)))
+	if err != nil {
+		return err
+	}
+
+	if !used {
+		return ErrVerifyCode
+	}
+
+	return nil
+}
+
+func (aus *AuthorizationService) newRecoveryCodes(userID uint) ([]string, error) {
+
+	codes := make([]string, RecoveryCodesCount)
+	hashes := make([]string, RecoveryCodesCount)
+
+	for i := range codes {
+
+		code, err := RecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+
+		codes[i], hashes[i] = code, TokenDigest(NormalizeRecoveryCode(code))
+	}
+
+	if err := aus.repository.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
@@ -1 +1,89 @@
 package authorization
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+const (
+	SessionSecretSize   = 32
+	OneTimePasswordSize = 18
+	APITokenSize        = 32
+)
+
+// SessionSecret - returns configured secret bytes or random generated secret when value is empty.
+// Random secret invalidates all issued tokens after application restart.
+func SessionSecret(value string) ([]byte, bool) {
+
+	if value != "" {
+		return []byte(value), true
+	}
+
+	secret := make([]byte, SessionSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+
+	return secret, false
+}
+
+// OneTimePassword - generates random url safe password
+func OneTimePassword() (string, error) {
+
+	buf := make([]byte, OneTimePasswordSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// RecoveryCode - generates random two-factor recovery code. Example: 'k3f9qx2m-7dwa4hnb'
+func RecoveryCode() (string, error) {
+
+	buf := make([]byte, 10)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	code := strings.ToLower(base32.StdEncoding.EncodeToString(buf))
+
+	return code[:8] + "-" + code[8:], nil
+}
+
+// NormalizeRecoveryCode - removes separators and case differences of user input
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ReplaceAll(code, "-", "")
+	code = strings.ReplaceAll(code, " ", "")
+	return strings.ToLower(code)
+}
+
+// APITokenValue - generates random personal API token. Example: 'dsk_Xq3v...'
+func APITokenValue() (string, error) {
+
+	buf := make([]byte, APITokenSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return APITokenPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// TokenDigest - returns SHA256 hex digest of API token or recovery code.
+// Tokens are random enough, so digest doesn't need salt and can be used for lookups
+func TokenDigest(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// noLimit - attempts limiter that never locks
+type noLimit struct{}
+
+func (noLimit) Check(remote, login string) error   { return nil }
+func (noLimit) Failure(remote, login string) error { return nil }
+func (noLimit) Success(remote, login string) error { return nil }
+func (noLimit) Unlock(login string) error          { return nil }
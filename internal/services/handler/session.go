@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+)
+
+const SessionCookieName = "desky_session"
+
+// RequestToken - returns session token from 'Authorization: Bearer' header or session cookie
+func RequestToken(r *http.Request) string {
+
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, ok := strings.Cut(header, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+
+	if cookie, err := r.Cookie(SessionCookieName); err == nil {
+		return cookie.Value
+	}
+
+	return ""
+}
+
+// WithSession - appends session claims to request context
+func WithSession(ctx context.Context, claims *models.SessionClaims) context.Context {
+	return context.WithValue(ctx, models.SESSION_CONTEXT_KEY, claims)
+}
+
+// SessionFromContext - returns session claims from request context
+func SessionFromContext(ctx context.Context) (*models.SessionClaims, bool) {
+	claims, ok := ctx.Value(models.SESSION_CONTEXT_KEY).(*models.SessionClaims)
+	return claims, ok && claims != nil
+}
+
+// SetSessionCookie - writes http only session cookie
+func SetSessionCookie(w http.ResponseWriter, token string, expires time.Time, secure bool) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// ClearSessionCookie - removes session cookie from client
+func ClearSessionCookie(w http.ResponseWriter, secure bool) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"net"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
@@ -27,8 +28,12 @@ type WebSocketConnector interface {
 
 	ReadMessage() (messageType int, p []byte, err error)
 	WriteMessage(messageType int, data []byte) error
+	WriteControl(messageType int, data []byte, deadline time.Time) error
 }
 
+// CloseFrameTimeout - write timeout of close frame
+const CloseFrameTimeout = time.Second
+
 type WebSocketHandler struct {
 	upgrade *websocket.Upgrader
 	logger  WebSocketLogger
@@ -190,6 +195,16 @@ func (h *WebSocketSession) WriteBase64(msg []byte) error {
 	return h.conn.WriteMessage(websocket.TextMessage, buf)
 }
 
+// CloseWith - sends close frame with status code and reason to client.
+// Safe to call concurrently with message writers
+func (h *WebSocketSession) CloseWith(code int, reason string) error {
+	return h.conn.WriteControl(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(code, reason),
+		time.Now().Add(CloseFrameTimeout),
+	)
+}
+
 func (h *WebSocketSession) Exit() error {
 	h.cancel()
 	return h.conn.Close()
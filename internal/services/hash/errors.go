@@ -0,0 +1,7 @@
+package hash
+
+import "errors"
+
+var (
+	ErrUnknownFormat = errors.New("unknown password hash format")
+)
@@ -3,7 +3,13 @@ package hash
 import (
 	"crypto/rand"
 	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
 
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -11,50 +17,204 @@ const (
 	_ Algorithm = iota
 	SHA512
 	Bcrypt
+	Argon2id
 )
 
-func New(algo Algorithm, salt []byte) *HashService {
-	return &HashService{
-		algo: algo,
-		salt: salt,
+// LegacySalt - static salt of old SHA512 password hashes.
+// SHA512 is verify-only: it's used for rows that are not rehashed yet and never for new hashes.
+var LegacySalt = []byte("random")
+
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+const argon2idPrefix = "$argon2id$"
+
+// ParseAlgorithm - returns algorithm of new hashes by it's name. Argon2id is used by default
+func ParseAlgorithm(name string) Algorithm {
+	switch strings.ToLower(name) {
+	case "bcrypt":
+		return Bcrypt
+	default:
+		return Argon2id
 	}
 }
 
-func (h *HashService) StringHash(value string) HashStream {
+// New - creates hash service. Legacy SHA512 can't be used for new hashes, Argon2id is used instead
+func New(algo Algorithm) *HashService {
+
+	if algo != Bcrypt {
+		algo = Argon2id
+	}
+
+	return &HashService{
+		algo:       algo,
+		bcryptCost: bcrypt.DefaultCost,
+		argon:      DefaultArgon2Params,
+	}
+}
 
-	v := []byte(value)
+// Hash - hashes value with random salt. Result stores algorithm, cost and salt with the hash:
+//
+//	$argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+//	$2a$10$<salt and hash>
+func (h *HashService) Hash(value string) (string, error) {
 
 	switch h.algo {
 
 	case Bcrypt:
-		return hBcrypt(v, h.salt)
+		return hBcrypt([]byte(value), h.bcryptCost)
+
+	default:
+		return hArgon2id([]byte(value), h.argon)
+	}
+}
+
+// Compare - checks value with self-described hash in constant time
+func (h *HashService) Compare(encoded, value string) (bool, error) {
+
+	switch Detect(encoded) {
+
+	case Argon2id:
+		params, salt, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return false, err
+		}
+
+		other := argon2.IDKey([]byte(value), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+		return subtle.ConstantTimeCompare(key, other) == 1, nil
+
+	case Bcrypt:
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(value))
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return false, nil
+		}
+		return err == nil, err
 
 	case SHA512:
-		return hSHA512(v, h.salt)
+		stored, err := hex.DecodeString(encoded)
+		if err != nil {
+			return false, ErrUnknownFormat
+		}
+		return subtle.ConstantTimeCompare(stored, hSHA512([]byte(value), LegacySalt)) == 1, nil
+
+	default:
+		return false, ErrUnknownFormat
+	}
+}
+
+// NeedsRehash - reports that hash was made with other algorithm or weaker parameters.
+// Legacy SHA512 hashes always need rehash
+func (h *HashService) NeedsRehash(encoded string) bool {
+
+	algo := Detect(encoded)
+	if algo == SHA512 || algo != h.algo {
+		return true
+	}
+
+	switch algo {
+
+	case Argon2id:
+		params, _, _, err := decodeArgon2id(encoded)
+		return err != nil || params != h.argon
+
+	case Bcrypt:
+		cost, err := bcrypt.Cost([]byte(encoded))
+		return err != nil || cost < h.bcryptCost
 
 	default:
-		return hSHA512(v, h.salt)
+		return false
 	}
 }
 
-func (h *HashService) EqStrings(source, stringFromRequest string) bool {
-	return source == h.StringHash(stringFromRequest).String()
+// Detect - resolves hash algorithm from encoded value
+func Detect(encoded string) Algorithm {
+
+	switch {
+
+	case strings.HasPrefix(encoded, argon2idPrefix):
+		return Argon2id
+
+	case strings.HasPrefix(encoded, "$2a$"),
+		strings.HasPrefix(encoded, "$2b$"),
+		strings.HasPrefix(encoded, "$2y$"):
+		return Bcrypt
+
+	case len(encoded) == hex.EncodedLen(sha512.Size):
+		return SHA512
+
+	default:
+		return 0
+	}
 }
 
 // hash funcs =====================
 
-func hBcrypt(stream, salt []byte) []byte {
-	stream = append(stream, salt...)
+func hBcrypt(stream []byte, cost int) (string, error) {
+
+	hashed, err := bcrypt.GenerateFromPassword(stream, cost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hashed), nil
+}
+
+func hArgon2id(stream []byte, p Argon2Params) (string, error) {
+
+	salt, err := GenerateCryptoSalt(uint(p.SaltLength))
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey(stream, salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+
+	return fmt.Sprintf(
+		"%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix, argon2.Version,
+		p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func decodeArgon2id(encoded string) (p Argon2Params, salt, key []byte, err error) {
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, ErrUnknownFormat
+	}
 
-	stream, err := bcrypt.GenerateFromPassword(stream, bcrypt.DefaultCost)
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, ErrUnknownFormat
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, ErrUnknownFormat
+	}
+
+	salt, err = base64.RawStdEncoding.DecodeString(parts[4])
 	if err != nil {
-		panic(err)
+		return p, nil, nil, ErrUnknownFormat
 	}
 
-	return stream
+	key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return p, nil, nil, ErrUnknownFormat
+	}
+
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+
+	return p, salt, key, nil
 }
 
-func hSHA512(stream, salt []byte) []byte {
+func hSHA512(stream, salt []byte) HashStream {
 	var sha512Hasher = sha512.New()
 	stream = append(stream, salt...)
 	sha512Hasher.Write(stream)
@@ -0,0 +1,80 @@
+package hash
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHashCompare(t *testing.T) {
+
+	for _, algo := range []Algorithm{Argon2id, Bcrypt} {
+
+		h := New(algo)
+
+		encoded, err := h.Hash("secret")
+		if err != nil {
+			t.Fatalf("algo %d hash error: %v", algo, err)
+		}
+
+		if Detect(encoded) != algo {
+			t.Errorf("algo %d detected as %d: %s", algo, Detect(encoded), encoded)
+		}
+
+		if ok, err := h.Compare(encoded, "secret"); !ok || err != nil {
+			t.Errorf("algo %d: valid password rejected: %v", algo, err)
+		}
+
+		if ok, _ := h.Compare(encoded, "Secret"); ok {
+			t.Errorf("algo %d: invalid password accepted", algo)
+		}
+
+		if h.NeedsRehash(encoded) {
+			t.Errorf("algo %d: fresh hash needs rehash", algo)
+		}
+	}
+}
+
+func TestHashSalted(t *testing.T) {
+
+	h := New(Argon2id)
+
+	first, _ := h.Hash("secret")
+	second, _ := h.Hash("secret")
+
+	if first == second {
+		t.Error("same password produced equal hashes")
+	}
+
+	if !strings.HasPrefix(first, "$argon2id$v=19$m=65536,t=3,p=2$") {
+		t.Errorf("unexpected argon2id format: %s", first)
+	}
+}
+
+func TestLegacyRehash(t *testing.T) {
+
+	legacy := hSHA512([]byte("secret"), LegacySalt).String()
+	h := New(Argon2id)
+
+	if ok, err := h.Compare(legacy, "secret"); !ok || err != nil {
+		t.Fatalf("legacy hash rejected: %v", err)
+	}
+
+	if !h.NeedsRehash(legacy) {
+		t.Error("legacy hash must be rehashed")
+	}
+
+	// SHA512 is verify-only
+	sha := New(SHA512)
+
+	if encoded, _ := sha.Hash("secret"); Detect(encoded) != Argon2id {
+		t.Errorf("new hash isn't argon2id: %s", encoded)
+	}
+
+	if !sha.NeedsRehash(legacy) || ParseAlgorithm("sha512") != Argon2id {
+		t.Error("sha512 can be chosen for new hashes")
+	}
+
+	if _, err := h.Compare("plain", "plain"); err != ErrUnknownFormat {
+		t.Errorf("unknown format error expected, got: %v", err)
+	}
+}
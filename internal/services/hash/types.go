@@ -14,7 +14,17 @@ func (s HashStream) Bytes() []byte {
 	return []byte(s)
 }
 
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
 type HashService struct {
 	algo Algorithm
-	salt []byte
+
+	bcryptCost int
+	argon      Argon2Params
 }
@@ -0,0 +1,34 @@
+package limiter
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// LockedError - returned for locked keys
+type LockedError struct {
+	Key   string
+	Until time.Time
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("too many failed attempts: '%s' locked until %s", e.Key, e.Until.Format(time.RFC3339))
+}
+
+// RetryAfter - returns time left until unlock
+func (e *LockedError) RetryAfter() time.Duration {
+
+	d := time.Until(e.Until)
+	if d < time.Second {
+		return time.Second
+	}
+
+	return d.Round(time.Second)
+}
+
+func IsLockedError(e error) (*LockedError, bool) {
+	var locked *LockedError
+	ok := errors.As(e, &locked)
+	return locked, ok
+}
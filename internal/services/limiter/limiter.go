@@ -0,0 +1,277 @@
+package limiter
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+)
+
+const (
+	loginKeyPrefix = "login:"
+	ipKeyPrefix    = "ip:"
+)
+
+// LoginLimiter - sliding window limiter of failed logins by client IP and login.
+// Attempts are reserved by Check and released by Success, so parallel requests
+// can't pass before their failures are counted.
+// Failures are counted in memory, lockouts are persisted to survive restarts
+type LoginLimiter struct {
+	repository LockRepository
+
+	login Policy
+	ip    Policy
+
+	failures map[string][]time.Time
+	locks    map[string]*models.DeskyLoginLockT
+
+	now func() time.Time
+	mu  sync.Mutex
+}
+
+func New(r LockRepository, login, ip Policy) (*LoginLimiter, error) {
+
+	l := &LoginLimiter{
+		repository: r,
+		login:      login,
+		ip:         ip,
+		failures:   make(map[string][]time.Time),
+		locks:      make(map[string]*models.DeskyLoginLockT),
+		now:        time.Now,
+	}
+
+	locks, err := r.Locks()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range locks {
+		l.locks[locks[i].Key] = &locks[i]
+	}
+
+	return l, nil
+}
+
+// Check - returns LockedError when login or client IP is locked
+// or has no attempts left inside window. Otherwise reserves attempt,
+// that must be finished with Failure or Success
+func (l *LoginLimiter) Check(remote, login string) error {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.prune(now)
+
+	list := keys(remote, login)
+
+	for _, key := range list {
+
+		if lock, ok := l.locks[key]; ok && now.Before(lock.LockedUntil) {
+			return &LockedError{Key: key, Until: lock.LockedUntil}
+		}
+
+		policy := l.policy(key)
+		if policy.Attempts < 1 {
+			continue
+		}
+
+		// attempts in progress are counted as failures
+		if actual := l.actual(key, policy, now); len(actual) >= policy.Attempts {
+			return &LockedError{Key: key, Until: actual[0].Add(policy.Window)}
+		}
+	}
+
+	for _, key := range list {
+		if l.policy(key).Attempts > 0 {
+			l.failures[key] = append(l.failures[key], now)
+		}
+	}
+
+	return nil
+}
+
+// Failure - confirms attempt reserved by Check as failed.
+// Returns LockedError when attempt caused lockout
+func (l *LoginLimiter) Failure(remote, login string) error {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+
+	var locked error
+
+	for _, key := range keys(remote, login) {
+
+		policy := l.policy(key)
+		if policy.Attempts < 1 {
+			continue
+		}
+
+		if len(l.actual(key, policy, now)) < policy.Attempts {
+			continue
+		}
+
+		lock, err := l.lock(key, policy, now)
+		if err != nil {
+			return err
+		}
+
+		delete(l.failures, key)
+		locked = &LockedError{Key: key, Until: lock.LockedUntil}
+	}
+
+	return locked
+}
+
+// Success - releases attempt reserved by Check of client IP,
+// resets failures and lockout level of login
+func (l *LoginLimiter) Success(remote, login string) error {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if ip := RemoteIP(remote); ip != "" {
+		key := ipKeyPrefix + ip
+		if list := l.failures[key]; len(list) > 0 {
+			l.failures[key] = list[:len(list)-1]
+		}
+	}
+
+	key := loginKeyPrefix + login
+	delete(l.failures, key)
+
+	return l.unlock(key)
+}
+
+// Unlock - removes lockout of login
+func (l *LoginLimiter) Unlock(login string) error {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := loginKeyPrefix + login
+	delete(l.failures, key)
+
+	return l.unlock(key)
+}
+
+// Locks - returns stored lockouts
+func (l *LoginLimiter) Locks() []LockInfo {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	list := make([]LockInfo, 0, len(l.locks))
+
+	for _, lock := range l.locks {
+		list = append(list, LockInfo{
+			Key:    lock.Key,
+			Level:  lock.Level,
+			Until:  lock.LockedUntil.Unix(),
+			Active: now.Before(lock.LockedUntil),
+		})
+	}
+
+	return list
+}
+
+// lock - locks key with exponential duration.
+// Lockout level is reset when previous lockout expired long ago
+func (l *LoginLimiter) lock(key string, policy Policy, now time.Time) (*models.DeskyLoginLockT, error) {
+
+	lock, ok := l.locks[key]
+	if !ok {
+		lock = models.NewDeskyLoginLockT(key)
+	}
+
+	if ok && now.After(lock.LockedUntil.Add(policy.MaxLockout)) {
+		lock.Level = 0
+	}
+
+	lock.Level++
+	lock.LockedUntil = now.Add(policy.duration(lock.Level))
+
+	if err := l.repository.SaveLock(lock); err != nil {
+		return nil, err
+	}
+
+	l.locks[key] = lock
+
+	return lock, nil
+}
+
+func (l *LoginLimiter) unlock(key string) error {
+
+	if _, ok := l.locks[key]; !ok {
+		return nil
+	}
+
+	if err := l.repository.DeleteLock(key); err != nil {
+		return err
+	}
+
+	delete(l.locks, key)
+
+	return nil
+}
+
+// actual - returns failures of key inside sliding window
+func (l *LoginLimiter) actual(key string, policy Policy, now time.Time) []time.Time {
+
+	list := l.failures[key]
+	from := now.Add(-policy.Window)
+
+	i := 0
+	for i < len(list) && !list[i].After(from) {
+		i++
+	}
+
+	return list[i:]
+}
+
+// prune - forgets keys without failures inside their windows
+func (l *LoginLimiter) prune(now time.Time) {
+	for key := range l.failures {
+		if len(l.actual(key, l.policy(key), now)) == 0 {
+			delete(l.failures, key)
+		}
+	}
+}
+
+func (l *LoginLimiter) policy(key string) Policy {
+	if strings.HasPrefix(key, ipKeyPrefix) {
+		return l.ip
+	}
+	return l.login
+}
+
+func keys(remote, login string) []string {
+
+	list := make([]string, 0, 2)
+
+	if ip := RemoteIP(remote); ip != "" {
+		list = append(list, ipKeyPrefix+ip)
+	}
+
+	if login != "" {
+		list = append(list, loginKeyPrefix+login)
+	}
+
+	return list
+}
+
+// RemoteIP - returns IP part of request remote address
+func RemoteIP(remote string) string {
+
+	host, _, err := net.SplitHostPort(remote)
+	if err != nil {
+		return remote
+	}
+
+	return host
+}
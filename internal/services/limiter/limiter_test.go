@@ -0,0 +1,165 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+)
+
+type memoryLocks map[string]models.DeskyLoginLockT
+
+func (m memoryLocks) Locks() ([]models.DeskyLoginLockT, error) {
+	list := []models.DeskyLoginLockT{}
+	for _, lock := range m {
+		list = append(list, lock)
+	}
+	return list, nil
+}
+
+func (m memoryLocks) SaveLock(lock *models.DeskyLoginLockT) error {
+	m[lock.Key] = *lock
+	return nil
+}
+
+func (m memoryLocks) DeleteLock(key string) error {
+	delete(m, key)
+	return nil
+}
+
+var testPolicy = Policy{
+	Attempts:   3,
+	Window:     time.Minute,
+	Lockout:    time.Minute,
+	MaxLockout: 4 * time.Minute,
+}
+
+func newTestLimiter(t *testing.T, repo memoryLocks, now *time.Time) *LoginLimiter {
+
+	l, err := New(repo, testPolicy, Policy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l.now = func() time.Time { return *now }
+
+	return l
+}
+
+// fail - makes failed attempt the way login does
+func fail(l *LoginLimiter, remote, login string) error {
+	if err := l.Check(remote, login); err != nil {
+		return err
+	}
+	return l.Failure(remote, login)
+}
+
+func TestLimiterLockout(t *testing.T) {
+
+	now := time.Unix(1700000000, 0)
+	repo := memoryLocks{}
+	l := newTestLimiter(t, repo, &now)
+
+	for i := 0; i < 2; i++ {
+		if err := fail(l, "10.0.0.1:5000", "bob"); err != nil {
+			t.Fatalf("failure %d: unexpected lockout: %v", i, err)
+		}
+	}
+
+	if err := fail(l, "10.0.0.1:5000", "bob"); err == nil {
+		t.Fatal("third failure must lock login")
+	}
+
+	if _, ok := IsLockedError(l.Check("10.0.0.2:5000", "bob")); !ok {
+		t.Fatal("locked login accepted from other address")
+	}
+
+	if err := l.Check("10.0.0.1:5000", "alice"); err != nil {
+		t.Fatalf("ip policy is disabled, other login rejected: %v", err)
+	}
+
+	// lockout is persisted and survives restart
+	restarted := newTestLimiter(t, repo, &now)
+	if _, ok := IsLockedError(restarted.Check("", "bob")); !ok {
+		t.Fatal("lockout lost after restart")
+	}
+
+	now = now.Add(time.Minute + time.Second)
+	if err := restarted.Check("", "bob"); err != nil {
+		t.Fatalf("expired lockout rejects login: %v", err)
+	}
+}
+
+func TestLimiterBackoff(t *testing.T) {
+
+	now := time.Unix(1700000000, 0)
+	l := newTestLimiter(t, memoryLocks{}, &now)
+
+	expected := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 4 * time.Minute}
+
+	for level, d := range expected {
+
+		var err error
+		for i := 0; i < testPolicy.Attempts; i++ {
+			err = fail(l, "", "bob")
+		}
+
+		locked, ok := IsLockedError(err)
+		if !ok {
+			t.Fatalf("level %d: login is not locked", level+1)
+		}
+
+		if got := locked.Until.Sub(now); got != d {
+			t.Errorf("level %d: lockout %s, expected %s", level+1, got, d)
+		}
+
+		now = locked.Until
+	}
+
+	if err := l.Success("", "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(l.Locks()) != 0 {
+		t.Error("successful login must reset lockout")
+	}
+}
+
+func TestLimiterSlidingWindow(t *testing.T) {
+
+	now := time.Unix(1700000000, 0)
+	l := newTestLimiter(t, memoryLocks{}, &now)
+
+	// failures spread wider than window never lock
+	for i := 0; i < 10; i++ {
+		if err := fail(l, "", "bob"); err != nil {
+			t.Fatalf("failure %d: old failures must leave window: %v", i, err)
+		}
+		now = now.Add(31 * time.Second)
+	}
+}
+
+func TestLimiterBurst(t *testing.T) {
+
+	now := time.Unix(1700000000, 0)
+	l := newTestLimiter(t, memoryLocks{}, &now)
+
+	// parallel attempts are reserved before any failure is counted
+	for i := 0; i < testPolicy.Attempts; i++ {
+		if err := l.Check("", "bob"); err != nil {
+			t.Fatalf("attempt %d rejected: %v", i, err)
+		}
+	}
+
+	if _, ok := IsLockedError(l.Check("", "bob")); !ok {
+		t.Fatal("attempt over limit accepted while others are in progress")
+	}
+
+	if err := l.Success("", "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.Check("", "bob"); err != nil {
+		t.Fatalf("successful login must release attempts: %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package limiter
+
+import (
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+)
+
+type LockRepository interface {
+	Locks() ([]models.DeskyLoginLockT, error)
+	SaveLock(lock *models.DeskyLoginLockT) error
+	DeleteLock(key string) error
+}
+
+// Policy - limits failed attempts of single key
+type Policy struct {
+	// Attempts - failures count inside window that causes lockout
+	Attempts int
+	// Window - sliding window of counted failures
+	Window time.Duration
+	// Lockout - first lockout duration. Every next lockout is twice longer
+	Lockout time.Duration
+	// MaxLockout - lockout duration limit
+	MaxLockout time.Duration
+}
+
+// duration - returns exponential lockout duration for lockout level starting from 1
+func (p Policy) duration(level int) time.Duration {
+
+	d := p.Lockout
+	for i := 1; i < level && d < p.MaxLockout; i++ {
+		d *= 2
+	}
+
+	if d > p.MaxLockout {
+		return p.MaxLockout
+	}
+
+	return d
+}
+
+type LockInfo struct {
+	Key    string `json:"key"`
+	Level  int    `json:"level"`
+	Until  int64  `json:"until"`
+	Active bool   `json:"active"`
+}
@@ -0,0 +1,28 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+)
+
+type MetricsServiceError struct {
+	err error
+}
+
+func (e *MetricsServiceError) Error() string {
+	return fmt.Sprintf("agent metrics error: %s", e.err.Error())
+}
+
+func IsMetricsServiceError(e error) bool {
+	var merr *MetricsServiceError
+	return errors.As(e, &merr)
+}
+
+var (
+	ErrRange = &MetricsServiceError{
+		err: errors.New("history range start must be before it's end"),
+	}
+	ErrTooManyPoints = &MetricsServiceError{
+		err: fmt.Errorf("history step is too small, max %d points can be requested", MaxPoints),
+	}
+)
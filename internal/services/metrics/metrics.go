@@ -0,0 +1,386 @@
+// Package metrics keeps time-series of agent stats.
+// Raw samples are rolled up into 1 minute and 1 hour buckets with min, max and average values,
+// every tier has it's own retention period
+package metrics
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/pkg/logger"
+)
+
+// Store - records agent stats, rolls them up and serves history
+type Store struct {
+	repository Repository
+	retention  Retention
+
+	// rolled - end of the last rolled up bucket of tier
+	rolled map[models.MetricTier]int64
+	mu     sync.Mutex
+
+	now func() time.Time
+}
+
+func New(r Repository, retention Retention) *Store {
+	return &Store{
+		repository: r,
+		retention:  retention,
+		rolled:     make(map[models.MetricTier]int64),
+		now:        time.Now,
+	}
+}
+
+// Record - stores raw values of agent stats
+func (s *Store) Record(hostID string, ts time.Time, stats models.AgentStatsObject) error {
+
+	values := stats.Metrics()
+	points := make([]models.AgentMetricT, 0, len(values))
+
+	for metric, value := range values {
+		points = append(points, models.AgentMetricT{
+			HostID: hostID,
+			Metric: metric,
+			Tier:   models.TierRaw,
+			Time:   ts.Unix(),
+			Min:    value,
+			Max:    value,
+			Avg:    value,
+			Count:  1,
+		})
+	}
+
+	return s.repository.AddPoints(points)
+}
+
+// Rollup - aggregates closed buckets of every tier from the previous tier
+func (s *Store) Rollup() error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := 1; i < len(tiers); i++ {
+		if err := s.rollup(tiers[i-1], tiers[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) rollup(source, target models.MetricTier) error {
+
+	step := target.Step()
+	until := floor(s.now().Add(-RollupDelay).Unix(), step)
+
+	from, err := s.rolledUntil(source, target)
+	if err != nil || from == 0 {
+		return err
+	}
+
+	// source is read by windows to keep memory bounded after long downtime
+	window := step * 60
+
+	for ; from < until; from += window {
+
+		to := min(from+window, until)
+
+		points, err := s.repository.TierPoints(source, from, to)
+		if err != nil {
+			return err
+		}
+
+		if err := s.repository.AddPoints(aggregate(points, target)); err != nil {
+			return err
+		}
+
+		s.rolled[target] = to
+	}
+
+	return nil
+}
+
+// rolledUntil - returns end of the last rolled bucket of target tier.
+// For empty tier it's start of the first source bucket. Zero means that there is nothing to roll up
+func (s *Store) rolledUntil(source, target models.MetricTier) (int64, error) {
+
+	if until, ok := s.rolled[target]; ok {
+		return until, nil
+	}
+
+	_, last, err := s.repository.TierBounds(target)
+	if err != nil {
+		return 0, err
+	}
+
+	if last > 0 {
+		s.rolled[target] = last + target.Step()
+		return s.rolled[target], nil
+	}
+
+	first, _, err := s.repository.TierBounds(source)
+	if err != nil || first == 0 {
+		return 0, err
+	}
+
+	return floor(first, target.Step()), nil
+}
+
+// Prune - deletes buckets older than retention period of their tier
+func (s *Store) Prune() (int64, error) {
+
+	var pruned int64
+
+	for _, tier := range tiers {
+
+		retention := s.retention.of(tier)
+		if retention <= 0 {
+			continue
+		}
+
+		count, err := s.repository.DeleteBefore(tier, s.now().Add(-retention).Unix())
+		if err != nil {
+			return pruned, err
+		}
+		pruned += count
+	}
+
+	return pruned, nil
+}
+
+// RunMaintenance - rolls up and prunes buckets at start and then every interval until context is done
+func (s *Store) RunMaintenance(ctx context.Context, interval time.Duration) {
+
+	log := logger.ReturnEntry().Logger
+
+	go func() {
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := s.Rollup(); err != nil {
+				log.Errorf("agent metrics rollup error: %v", err)
+			}
+
+			if _, err := s.Prune(); err != nil {
+				log.Errorf("agent metrics pruning error: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// History - returns host metric values in [from, to] aggregated by step.
+// Zero step splits range into DefaultPoints buckets.
+// Data is read from the coarsest tier which is fine enough for step and still keeps range start,
+// range part which isn't rolled up yet is read from finer tiers
+func (s *Store) History(hostID, metric string, from, to time.Time, step time.Duration) (*models.ResponseMetricHistory, error) {
+
+	if !from.Before(to) {
+		return nil, ErrRange
+	}
+
+	start, end := from.Unix(), to.Unix()
+
+	stepSec := int64(step / time.Second)
+	if step <= 0 {
+		stepSec = (end - start + DefaultPoints - 1) / DefaultPoints
+	}
+	stepSec = max(stepSec, 1)
+
+	tier := s.tierFor(start, stepSec)
+	stepSec = max(stepSec, tier.Step())
+
+	if (end-start)/stepSec >= MaxPoints {
+		return nil, ErrTooManyPoints
+	}
+
+	s.mu.Lock()
+	rolled := make(map[models.MetricTier]int64, len(s.rolled))
+	for t, until := range s.rolled {
+		rolled[t] = until
+	}
+	s.mu.Unlock()
+
+	var (
+		points []models.AgentMetricT
+		cursor = start
+	)
+
+	for i := tierIndex(tier); i >= 0 && cursor <= end; i-- {
+
+		limit := end + 1
+		if tiers[i] != models.TierRaw {
+			limit = min(limit, rolled[tiers[i]])
+		}
+
+		if limit <= cursor {
+			continue
+		}
+
+		// bucket containing range start is included
+		query := cursor
+		if cursor == start {
+			query = floor(start, tiers[i].Step())
+		}
+
+		list, err := s.repository.Points(hostID, metric, tiers[i], query, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		points = append(points, list...)
+		cursor = limit
+	}
+
+	return &models.ResponseMetricHistory{
+		HostID: hostID,
+		Metric: metric,
+		From:   start,
+		To:     end,
+		Step:   stepSec,
+		Tier:   tier,
+		Points: bucketize(points, stepSec),
+	}, nil
+}
+
+// Metrics - returns names of stored host metrics
+func (s *Store) Metrics(hostID string) ([]string, error) {
+	return s.repository.Metrics(hostID)
+}
+
+// tierFor - returns the coarsest tier with step not greater than requested one which keeps buckets since start.
+// When finer tiers are already pruned at start, the finest tier keeping start is used
+func (s *Store) tierFor(start, step int64) models.MetricTier {
+
+	keeps := func(tier models.MetricTier) bool {
+		retention := s.retention.of(tier)
+		return retention <= 0 || start >= s.now().Add(-retention).Unix()
+	}
+
+	for i := len(tiers) - 1; i >= 0; i-- {
+		if tiers[i].Step() <= step && keeps(tiers[i]) {
+			return tiers[i]
+		}
+	}
+
+	for _, tier := range tiers {
+		if keeps(tier) {
+			return tier
+		}
+	}
+
+	return tiers[len(tiers)-1]
+}
+
+func tierIndex(tier models.MetricTier) int {
+	for i, t := range tiers {
+		if t == tier {
+			return i
+		}
+	}
+	return 0
+}
+
+type bucketKey struct {
+	hostID string
+	metric string
+	time   int64
+}
+
+// aggregate - merges source buckets into buckets of target tier
+func aggregate(points []models.AgentMetricT, target models.MetricTier) []models.AgentMetricT {
+
+	buckets := make(map[bucketKey]*models.MetricPoint)
+
+	for _, p := range points {
+
+		key := bucketKey{p.HostID, p.Metric, floor(p.Time, target.Step())}
+
+		if b, ok := buckets[key]; ok {
+			merge(b, p.Point())
+			continue
+		}
+
+		point := p.Point()
+		point.Time = key.time
+		buckets[key] = &point
+	}
+
+	result := make([]models.AgentMetricT, 0, len(buckets))
+
+	for key, b := range buckets {
+		result = append(result, models.AgentMetricT{
+			HostID: key.hostID,
+			Metric: key.metric,
+			Tier:   target,
+			Time:   b.Time,
+			Min:    b.Min,
+			Max:    b.Max,
+			Avg:    b.Avg,
+			Count:  b.Count,
+		})
+	}
+
+	return result
+}
+
+// bucketize - merges buckets of single metric into buckets of step ordered by time
+func bucketize(points []models.AgentMetricT, step int64) []models.MetricPoint {
+
+	buckets := make(map[int64]*models.MetricPoint)
+
+	for _, p := range points {
+
+		start := floor(p.Time, step)
+
+		if b, ok := buckets[start]; ok {
+			merge(b, p.Point())
+			continue
+		}
+
+		point := p.Point()
+		point.Time = start
+		buckets[start] = &point
+	}
+
+	result := make([]models.MetricPoint, 0, len(buckets))
+	for _, b := range buckets {
+		result = append(result, *b)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Time < result[j].Time
+	})
+
+	return result
+}
+
+// merge - adds values of point to bucket. Average is weighted by values count
+func merge(b *models.MetricPoint, p models.MetricPoint) {
+
+	count := b.Count + p.Count
+	if count > 0 {
+		b.Avg = (b.Avg*float64(b.Count) + p.Avg*float64(p.Count)) / float64(count)
+	}
+
+	b.Min = min(b.Min, p.Min)
+	b.Max = max(b.Max, p.Max)
+	b.Count = count
+}
+
+func floor(t, step int64) int64 {
+	if step <= 1 {
+		return t
+	}
+	return t - t%step
+}
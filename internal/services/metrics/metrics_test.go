@@ -0,0 +1,218 @@
+package metrics
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+)
+
+type memoryMetrics []models.AgentMetricT
+
+func (m *memoryMetrics) AddPoints(points []models.AgentMetricT) error {
+	for _, p := range points {
+		if !m.has(p) {
+			*m = append(*m, p)
+		}
+	}
+	return nil
+}
+
+func (m *memoryMetrics) has(p models.AgentMetricT) bool {
+	for _, stored := range *m {
+		if stored.HostID == p.HostID && stored.Metric == p.Metric && stored.Tier == p.Tier && stored.Time == p.Time {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *memoryMetrics) Points(hostID, metric string, tier models.MetricTier, from, to int64) ([]models.AgentMetricT, error) {
+	list := []models.AgentMetricT{}
+	for _, p := range *m {
+		if p.HostID == hostID && p.Metric == metric && p.Tier == tier && p.Time >= from && p.Time < to {
+			list = append(list, p)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Time < list[j].Time })
+	return list, nil
+}
+
+func (m *memoryMetrics) TierPoints(tier models.MetricTier, from, to int64) ([]models.AgentMetricT, error) {
+	list := []models.AgentMetricT{}
+	for _, p := range *m {
+		if p.Tier == tier && p.Time >= from && p.Time < to {
+			list = append(list, p)
+		}
+	}
+	return list, nil
+}
+
+func (m *memoryMetrics) TierBounds(tier models.MetricTier) (first, last int64, err error) {
+	for _, p := range *m {
+		if p.Tier != tier {
+			continue
+		}
+		if first == 0 || p.Time < first {
+			first = p.Time
+		}
+		last = max(last, p.Time)
+	}
+	return first, last, nil
+}
+
+func (m *memoryMetrics) DeleteBefore(tier models.MetricTier, before int64) (int64, error) {
+	kept := (*m)[:0]
+	for _, p := range *m {
+		if p.Tier != tier || p.Time >= before {
+			kept = append(kept, p)
+		}
+	}
+	deleted := int64(len(*m) - len(kept))
+	*m = kept
+	return deleted, nil
+}
+
+func (m *memoryMetrics) Metrics(hostID string) ([]string, error) {
+	return nil, nil
+}
+
+func (m *memoryMetrics) count(tier models.MetricTier) int {
+	n := 0
+	for _, p := range *m {
+		if p.Tier == tier {
+			n++
+		}
+	}
+	return n
+}
+
+func cpuStats(load float64) models.AgentStatsObject {
+	return models.AgentStatsObject{CPU: &models.CPU{Load: load}}
+}
+
+func TestRollupAndHistory(t *testing.T) {
+
+	repo := &memoryMetrics{}
+	store := New(repo, Retention{Raw: 3 * time.Hour, Minute: 48 * time.Hour})
+
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	// two hours of samples every 15 seconds, load is minute number within hour
+	for ts := base; ts.Before(base.Add(2 * time.Hour)); ts = ts.Add(15 * time.Second) {
+		if err := store.Record("host-1", ts, cpuStats(float64(ts.Minute()))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store.now = func() time.Time { return base.Add(2*time.Hour + 30*time.Second) }
+
+	if err := store.Rollup(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := repo.count(models.TierMinute); n != 120 {
+		t.Fatalf("minute buckets = %d, want 120", n)
+	}
+
+	if n := repo.count(models.TierHour); n != 2 {
+		t.Fatalf("hour buckets = %d, want 2", n)
+	}
+
+	// repeated rollup doesn't duplicate buckets
+	if err := store.Rollup(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := repo.count(models.TierMinute); n != 120 {
+		t.Fatalf("minute buckets after repeat = %d, want 120", n)
+	}
+
+	history, err := store.History("host-1", "cpu.load", base, base.Add(2*time.Hour-time.Second), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if history.Tier != models.TierHour || len(history.Points) != 2 {
+		t.Fatalf("history = %+v", history)
+	}
+
+	hour := history.Points[0]
+	if hour.Min != 0 || hour.Max != 59 || math.Abs(hour.Avg-29.5) > 1e-9 || hour.Count != 240 {
+		t.Fatalf("hour bucket = %+v", hour)
+	}
+
+	history, err = store.History("host-1", "cpu.load", base.Add(time.Hour), base.Add(time.Hour+5*time.Minute-time.Second), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if history.Tier != models.TierMinute || len(history.Points) != 5 || history.Points[4].Avg != 4 {
+		t.Fatalf("minute history = %+v", history)
+	}
+}
+
+func TestHistoryReadsNotRolledRange(t *testing.T) {
+
+	repo := &memoryMetrics{}
+	store := New(repo, Retention{})
+
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	for ts := base; ts.Before(base.Add(90 * time.Minute)); ts = ts.Add(time.Minute) {
+		store.Record("host-1", ts, cpuStats(1))
+	}
+
+	store.now = func() time.Time { return base.Add(90 * time.Minute) }
+
+	if err := store.Rollup(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the second hour isn't rolled up into hour tier yet and is read from minute tier
+	history, err := store.History("host-1", "cpu.load", base, base.Add(2*time.Hour), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(history.Points) != 2 || history.Points[0].Count != 60 || history.Points[1].Count != 30 {
+		t.Fatalf("history = %+v", history.Points)
+	}
+}
+
+func TestPruneAndLimits(t *testing.T) {
+
+	repo := &memoryMetrics{}
+	store := New(repo, Retention{Raw: time.Hour})
+
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	store.Record("host-1", base, cpuStats(1))
+	store.Record("host-1", base.Add(2*time.Hour), cpuStats(1))
+
+	store.now = func() time.Time { return base.Add(2 * time.Hour) }
+
+	pruned, err := store.Prune()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pruned != 1 || repo.count(models.TierRaw) != 1 {
+		t.Fatalf("pruned = %d, raw left = %d", pruned, repo.count(models.TierRaw))
+	}
+
+	if _, err := store.History("host-1", "cpu.load", base, base, 0); !errors.Is(err, ErrRange) {
+		t.Fatalf("error = %v, want %v", err, ErrRange)
+	}
+
+	if _, err := store.History("host-1", "cpu.load", base.Add(90*time.Minute), base.Add(2*time.Hour), time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := New(repo, Retention{}).History("host-1", "cpu.load", base, base.Add(24*time.Hour), time.Second); !errors.Is(err, ErrTooManyPoints) {
+		t.Fatalf("error = %v, want %v", err, ErrTooManyPoints)
+	}
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+)
+
+const (
+	// MaintenanceInterval - period of rollups and retention policy checks
+	MaintenanceInterval = time.Minute
+	// RollupDelay - time bucket stays open for late samples before rollup
+	RollupDelay = 10 * time.Second
+	// DefaultPoints - points count of history requested without step
+	DefaultPoints = 300
+	// MaxPoints - upper bound of history points count
+	MaxPoints = 10000
+)
+
+type Repository interface {
+	AddPoints(points []models.AgentMetricT) error
+	Points(hostID, metric string, tier models.MetricTier, from, to int64) ([]models.AgentMetricT, error)
+	TierPoints(tier models.MetricTier, from, to int64) ([]models.AgentMetricT, error)
+	TierBounds(tier models.MetricTier) (first, last int64, err error)
+	DeleteBefore(tier models.MetricTier, before int64) (int64, error)
+	Metrics(hostID string) ([]string, error)
+}
+
+// Retention - age of pruned buckets per tier. Zero keeps buckets forever
+type Retention struct {
+	Raw    time.Duration
+	Minute time.Duration
+	Hour   time.Duration
+}
+
+func (r Retention) of(tier models.MetricTier) time.Duration {
+	switch tier {
+	case models.TierMinute:
+		return r.Minute
+	case models.TierHour:
+		return r.Hour
+	}
+	return r.Raw
+}
+
+// tiers - stored tiers from the finest to the coarsest. Each tier is rolled up from previous one
+var tiers = []models.MetricTier{models.TierRaw, models.TierMinute, models.TierHour}
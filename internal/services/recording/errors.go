@@ -0,0 +1,25 @@
+package recording
+
+import (
+	"errors"
+	"fmt"
+)
+
+type RecordingServiceError struct {
+	err error
+}
+
+func (e *RecordingServiceError) Error() string {
+	return fmt.Sprintf("session recording error: %s", e.err.Error())
+}
+
+func IsRecordingServiceError(e error) bool {
+	var rerr *RecordingServiceError
+	return errors.As(e, &rerr)
+}
+
+var (
+	ErrActive = &RecordingServiceError{
+		err: errors.New("recording of active session can't be deleted"),
+	}
+)
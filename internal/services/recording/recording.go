@@ -0,0 +1,238 @@
+// Package recording writes terminal sessions into asciicast v2 files
+// and keeps their index in database
+package recording
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/pkg/asciicast"
+	"github.com/eterline/desky-backend/pkg/logger"
+)
+
+// Recorder - starts session recordings and applies retention policy
+type Recorder struct {
+	repository Repository
+	opts       Options
+
+	active map[uint]struct{}
+	mu     sync.Mutex
+
+	now func() time.Time
+}
+
+func New(r Repository, opts Options) *Recorder {
+	return &Recorder{
+		repository: r,
+		opts:       opts,
+		active:     make(map[uint]struct{}),
+		now:        time.Now,
+	}
+}
+
+func (rec *Recorder) Enabled() bool {
+	return rec != nil && rec.opts.Enable
+}
+
+// Start - creates recording file and it's index row.
+// Returns nil session when recording is disabled
+func (rec *Recorder) Start(meta Meta, cols, rows int) (*Session, error) {
+
+	if !rec.Enabled() {
+		return nil, nil
+	}
+
+	if err := os.MkdirAll(rec.opts.Dir, 0700); err != nil {
+		return nil, err
+	}
+
+	started := rec.now()
+	name := fmt.Sprintf("%s_%s.cast", started.UTC().Format("20060102-150405"), meta.SessionID)
+
+	file, err := os.OpenFile(rec.path(name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Session{
+		recorder: rec,
+		file:     file,
+		counter:  countWriter{w: file},
+		input:    rec.opts.Input,
+		row: &models.SSHRecordingT{
+			SessionID:     meta.SessionID,
+			UserID:        meta.UserID,
+			Actor:         meta.Actor,
+			CredentialsID: meta.CredentialsID,
+			Host:          meta.Host,
+			File:          name,
+			StartedAt:     started,
+		},
+	}
+
+	s.cast, err = asciicast.NewWriter(&s.counter, asciicast.Header{
+		Width:     cols,
+		Height:    rows,
+		Timestamp: started.Unix(),
+		Title:     meta.title(),
+		Env:       map[string]string{"TERM": "xterm-256color"},
+	})
+
+	if err == nil {
+		err = rec.repository.CreateRecording(s.row)
+	}
+
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+
+	rec.mu.Lock()
+	rec.active[s.row.ID] = struct{}{}
+	rec.mu.Unlock()
+
+	return s, nil
+}
+
+// Recordings - returns filtered recordings and count of all filtered recordings
+func (rec *Recorder) Recordings(f models.RecordingFilter) ([]models.SSHRecordingObject, int64, error) {
+
+	list, count, err := rec.repository.Recordings(f)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	objects := make([]models.SSHRecordingObject, len(list))
+	for i := range list {
+		objects[i] = list[i].Object()
+	}
+
+	return objects, count, nil
+}
+
+// Open - returns recording row with opened recording file
+func (rec *Recorder) Open(id uint) (*models.SSHRecordingT, *os.File, error) {
+
+	row, err := rec.repository.RecordingById(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.Open(rec.path(row.File))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return row, file, nil
+}
+
+// Delete - removes recording file and it's index row
+func (rec *Recorder) Delete(id uint) error {
+
+	row, err := rec.repository.RecordingById(id)
+	if err != nil {
+		return err
+	}
+
+	if rec.isActive(row.ID) {
+		return ErrActive
+	}
+
+	return rec.delete(row)
+}
+
+// Prune - deletes recordings older than retention period
+func (rec *Recorder) Prune() (int, error) {
+
+	if rec.opts.Retention <= 0 {
+		return 0, nil
+	}
+
+	list, err := rec.repository.RecordingsBefore(rec.now().Add(-rec.opts.Retention))
+	if err != nil {
+		return 0, err
+	}
+
+	pruned := 0
+
+	for i := range list {
+
+		if rec.isActive(list[i].ID) {
+			continue
+		}
+
+		if err := rec.delete(&list[i]); err != nil {
+			return pruned, err
+		}
+		pruned++
+	}
+
+	return pruned, nil
+}
+
+// RunPruning - applies retention policy at start and then every interval until context is done
+func (rec *Recorder) RunPruning(ctx context.Context, interval time.Duration) {
+
+	if rec.opts.Retention <= 0 {
+		return
+	}
+
+	log := logger.ReturnEntry().Logger
+
+	go func() {
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			pruned, err := rec.Prune()
+			if err != nil {
+				log.Errorf("session recordings pruning error: %v", err)
+			}
+			if pruned > 0 {
+				log.Infof("session recordings pruned: %d", pruned)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (rec *Recorder) delete(row *models.SSHRecordingT) error {
+
+	err := os.Remove(rec.path(row.File))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return rec.repository.DeleteRecording(row.ID)
+}
+
+// path - returns recording file path. Only base name of stored file is used
+func (rec *Recorder) path(name string) string {
+	return filepath.Join(rec.opts.Dir, filepath.Base(name))
+}
+
+func (rec *Recorder) isActive(id uint) bool {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	_, ok := rec.active[id]
+	return ok
+}
+
+func (rec *Recorder) finish(id uint) {
+	rec.mu.Lock()
+	delete(rec.active, id)
+	rec.mu.Unlock()
+}
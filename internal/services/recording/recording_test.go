@@ -0,0 +1,231 @@
+package recording
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+type memoryRecordings map[uint]*models.SSHRecordingT
+
+func (m memoryRecordings) CreateRecording(rec *models.SSHRecordingT) error {
+	rec.ID = uint(len(m) + 1)
+	m[rec.ID] = rec
+	return nil
+}
+
+func (m memoryRecordings) FinishRecording(id uint, ended time.Time, size int64) error {
+	m[id].EndedAt = &ended
+	m[id].Size = size
+	return nil
+}
+
+func (m memoryRecordings) RecordingById(id uint) (*models.SSHRecordingT, error) {
+	rec, ok := m[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return rec, nil
+}
+
+func (m memoryRecordings) Recordings(f models.RecordingFilter) ([]models.SSHRecordingT, int64, error) {
+	list := []models.SSHRecordingT{}
+	for _, rec := range m {
+		list = append(list, *rec)
+	}
+	return list, int64(len(list)), nil
+}
+
+func (m memoryRecordings) RecordingsBefore(t time.Time) ([]models.SSHRecordingT, error) {
+	list := []models.SSHRecordingT{}
+	for _, rec := range m {
+		if rec.StartedAt.Before(t) {
+			list = append(list, *rec)
+		}
+	}
+	return list, nil
+}
+
+func (m memoryRecordings) DeleteRecording(id uint) error {
+	delete(m, id)
+	return nil
+}
+
+var testMeta = Meta{
+	SessionID:     "6b1f4a1e-0000-4000-8000-000000000001",
+	Actor:         "admin",
+	CredentialsID: 1,
+	Host:          "root@10.0.0.1:22",
+}
+
+func TestSessionRecording(t *testing.T) {
+
+	repo := memoryRecordings{}
+	rec := New(repo, Options{Enable: true, Dir: t.TempDir(), Input: true})
+
+	s, err := rec.Start(testMeta, 120, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Output([]byte("$ "))
+	s.Input([]byte("ls\r"))
+	s.Resize(100, 30)
+	s.Output([]byte("file\r\n"))
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	row := repo[1]
+	if row.EndedAt == nil || row.Size == 0 {
+		t.Fatalf("recording is not finished: %+v", row)
+	}
+
+	path := filepath.Join(rec.opts.Dir, row.File)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Size() != row.Size {
+		t.Fatalf("stored size %d, file size %d", row.Size, info.Size())
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	types := []string{}
+
+	for scanner.Scan() {
+
+		if len(types) == 0 {
+			header := map[string]any{}
+			if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+				t.Fatal(err)
+			}
+			if header["version"] != 2.0 || header["width"] != 120.0 || header["height"] != 40.0 {
+				t.Fatalf("wrong header: %v", header)
+			}
+			types = append(types, "header")
+			continue
+		}
+
+		event := []any{}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatal(err)
+		}
+		types = append(types, event[1].(string))
+	}
+
+	want := []string{"header", "o", "i", "r", "o"}
+	if len(types) != len(want) {
+		t.Fatalf("events %v, want %v", types, want)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("events %v, want %v", types, want)
+		}
+	}
+}
+
+func TestInputDisabled(t *testing.T) {
+
+	repo := memoryRecordings{}
+	rec := New(repo, Options{Enable: true, Dir: t.TempDir()})
+
+	s, err := rec.Start(testMeta, 80, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Input([]byte("secret\r"))
+	s.Close()
+
+	data, err := os.ReadFile(filepath.Join(rec.opts.Dir, repo[1].File))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 1 {
+		t.Fatalf("input is recorded: %v", lines[1:])
+	}
+}
+
+func TestDisabledRecorder(t *testing.T) {
+
+	rec := New(memoryRecordings{}, Options{Dir: t.TempDir()})
+
+	s, err := rec.Start(testMeta, 80, 24)
+	if err != nil || s != nil {
+		t.Fatalf("disabled recorder started session: %v %v", s, err)
+	}
+
+	// nil session must be safe to use
+	s.Output([]byte("data"))
+	s.Input([]byte("data"))
+	s.Resize(10, 10)
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("nil session: %v", err)
+	}
+}
+
+func TestPrune(t *testing.T) {
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	repo := memoryRecordings{}
+	rec := New(repo, Options{Enable: true, Dir: t.TempDir(), Retention: 24 * time.Hour})
+	rec.now = func() time.Time { return now }
+
+	old, err := rec.Start(testMeta, 80, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(time.Hour)
+	meta := testMeta
+	meta.SessionID = "6b1f4a1e-0000-4000-8000-000000000002"
+
+	active, err := rec.Start(meta, 80, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old.Close()
+
+	now = now.Add(48 * time.Hour)
+
+	if err := rec.Delete(2); err != ErrActive {
+		t.Fatalf("active recording deleted: %v", err)
+	}
+
+	pruned, err := rec.Prune()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pruned != 1 || len(repo) != 1 || repo[2] == nil {
+		t.Fatalf("pruned %d, left %v", pruned, repo)
+	}
+
+	entries, _ := os.ReadDir(rec.opts.Dir)
+	if len(entries) != 1 {
+		t.Fatalf("recording files left: %d", len(entries))
+	}
+
+	active.Close()
+	if err := rec.Delete(2); err != nil {
+		t.Fatal(err)
+	}
+}
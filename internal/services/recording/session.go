@@ -0,0 +1,112 @@
+package recording
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/pkg/asciicast"
+)
+
+// Session - active terminal session recording.
+// Methods are safe for concurrent use and for nil session.
+// Recording errors never break terminal, recording is stopped and error is returned by Close
+type Session struct {
+	recorder *Recorder
+	row      *models.SSHRecordingT
+	file     *os.File
+	cast     *asciicast.Writer
+	counter  countWriter
+	input    bool
+
+	failed error
+	closed bool
+	mu     sync.Mutex
+}
+
+// Output - records terminal output
+func (s *Session) Output(data []byte) {
+	s.record(func() error {
+		return s.cast.Output(data)
+	})
+}
+
+// Input - records terminal input when input recording is enabled
+func (s *Session) Input(data []byte) {
+
+	if s == nil || !s.input {
+		return
+	}
+
+	s.record(func() error {
+		return s.cast.Input(data)
+	})
+}
+
+// Resize - records terminal size change
+func (s *Session) Resize(cols, rows int) {
+	s.record(func() error {
+		return s.cast.Resize(cols, rows)
+	})
+}
+
+// Close - closes recording file and saves session end and file size
+func (s *Session) Close() error {
+
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	defer s.recorder.finish(s.row.ID)
+
+	err := errors.Join(
+		s.failed,
+		s.file.Close(),
+		s.recorder.repository.FinishRecording(s.row.ID, s.recorder.now(), s.counter.n),
+	)
+
+	if err != nil {
+		return fmt.Errorf("session '%s' recording: %w", s.row.SessionID, err)
+	}
+
+	return nil
+}
+
+func (s *Session) record(event func() error) {
+
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failed != nil || s.closed {
+		return
+	}
+
+	s.failed = event()
+}
+
+// countWriter - counts size of written recording
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,44 @@
+package recording
+
+import (
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+)
+
+// PruneInterval - period of retention policy checks
+const PruneInterval = time.Hour
+
+type Repository interface {
+	CreateRecording(rec *models.SSHRecordingT) error
+	FinishRecording(id uint, ended time.Time, size int64) error
+	RecordingById(id uint) (*models.SSHRecordingT, error)
+	Recordings(f models.RecordingFilter) ([]models.SSHRecordingT, int64, error)
+	RecordingsBefore(t time.Time) ([]models.SSHRecordingT, error)
+	DeleteRecording(id uint) error
+}
+
+type Options struct {
+	Enable bool
+	Dir    string
+	// Input - records terminal input events
+	Input bool
+	// Retention - age of pruned recordings. Zero keeps recordings forever
+	Retention time.Duration
+}
+
+// Meta - recorded terminal session description
+type Meta struct {
+	SessionID     string
+	UserID        uint
+	Actor         string
+	CredentialsID uint
+	Host          string
+}
+
+func (m Meta) title() string {
+	if m.Actor == "" {
+		return m.Host
+	}
+	return m.Actor + " - " + m.Host
+}
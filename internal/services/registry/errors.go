@@ -0,0 +1,40 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+)
+
+type RegistryError struct {
+	err error
+}
+
+func (e *RegistryError) Error() string {
+	return fmt.Sprintf("agent registry error: %s", e.err.Error())
+}
+
+func IsRegistryError(e error) bool {
+	var rerr *RegistryError
+	return errors.As(e, &rerr)
+}
+
+var (
+	ErrPending = &RegistryError{
+		err: errors.New("agent isn't approved"),
+	}
+	ErrQueueFull = &RegistryError{
+		err: errors.New("approval queue is full"),
+	}
+	ErrUnsigned = &RegistryError{
+		err: errors.New("agent payload isn't signed"),
+	}
+	ErrSignature = &RegistryError{
+		err: errors.New("invalid agent payload signature"),
+	}
+	ErrReplay = &RegistryError{
+		err: errors.New("agent payload timestamp isn't newer than last accepted"),
+	}
+	ErrMessage = &RegistryError{
+		err: errors.New("invalid agent payload"),
+	}
+)
@@ -0,0 +1,320 @@
+// Package registry keeps agents known to desky. Unknown agents are queued for approval
+// and payloads of agents with secret must be signed with it
+package registry
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+)
+
+type Registry struct {
+	repository Repository
+	opts       Options
+
+	mu     sync.RWMutex
+	agents map[string]*models.AgentT
+	last   map[lastKey]int64
+}
+
+func New(r Repository, opts Options) *Registry {
+	return &Registry{
+		repository: r,
+		opts:       opts,
+		agents:     make(map[string]*models.AgentT),
+		last:       make(map[lastKey]int64),
+	}
+}
+
+// Load - reads registered agents from repository
+func (g *Registry) Load() error {
+
+	list, err := g.repository.Agents()
+	if err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.agents = make(map[string]*models.AgentT, len(list))
+	for i := range list {
+		g.agents[list[i].HostID] = &list[i]
+	}
+
+	return nil
+}
+
+// Sign - returns signature of agent payload: hex HMAC-SHA256 of 'host-id.timestamp.data'
+// where data is raw JSON of payload 'data' field
+func Sign(secret, hostID string, timestamp int64, data []byte) string {
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(hostID + "." + strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(data)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// PresenceData - returns signed data of agent presence message: the state itself
+func PresenceData(state models.PresenceState) []byte {
+	return []byte(state)
+}
+
+// ResponseData - returns signed data of agent command response: 'id.ok.error.data'
+// where error is Go quoted string and data is raw JSON of response 'data' field
+func ResponseData(resp models.AgentCommandResponse) []byte {
+	head := resp.ID + "." + strconv.FormatBool(resp.OK) + "." + strconv.Quote(resp.Error) + "."
+	return append([]byte(head), resp.Data...)
+}
+
+// Admit - checks that stats payload is sent by approved agent. Unknown agent is queued for approval.
+// Signed payload is accepted only within SignatureSkew of it's timestamp and only if
+// timestamp is newer than the one of last accepted payload
+func (g *Registry) Admit(hostID string, payload []byte) error {
+
+	msg := new(signedMessage)
+	if err := json.Unmarshal(payload, msg); err != nil || msg.ID != hostID || hostID == "" {
+		return ErrMessage
+	}
+
+	now := time.Now()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	agent, ok := g.agents[hostID]
+	if !ok {
+		var err error
+		if agent, err = g.register(msg, now); err != nil {
+			return err
+		}
+	}
+
+	if !agent.Approved {
+		return ErrPending
+	}
+
+	return g.verify(agent, kindStats, msg.Timestamp, msg.Data, msg.Signature, now)
+}
+
+// VerifyPresence - checks that presence message is sent by approved agent.
+// Unknown agents aren't queued for approval. Last Will of signed agent is signed at connect
+// and is rejected as stale, so such agent goes offline by missed heartbeats
+func (g *Registry) VerifyPresence(hostID string, timestamp int64, state models.PresenceState, signature string) error {
+	return g.verifyApproved(hostID, kindPresence, timestamp, PresenceData(state), signature)
+}
+
+// VerifyResponse - checks that command response received on host topic is sent by approved agent
+func (g *Registry) VerifyResponse(hostID string, resp models.AgentCommandResponse) error {
+	return g.verifyApproved(hostID, kindResponse, resp.Timestamp, ResponseData(resp), resp.Signature)
+}
+
+func (g *Registry) verifyApproved(hostID string, k kind, timestamp int64, data []byte, signature string) error {
+
+	now := time.Now()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	agent, ok := g.agents[hostID]
+	if !ok || !agent.Approved {
+		return ErrPending
+	}
+
+	return g.verify(agent, k, timestamp, data, signature, now)
+}
+
+// register - adds unknown agent. Must be called with lock held
+func (g *Registry) register(msg *signedMessage, now time.Time) (*models.AgentT, error) {
+
+	if !g.opts.AutoApprove && g.pending() >= MaxPending {
+		return nil, ErrQueueFull
+	}
+
+	name := msg.ID
+
+	data := hostData{}
+	if json.Unmarshal(msg.Data, &data) == nil && data.Host != nil && data.Host.Hostname != "" {
+		name = data.Host.Hostname
+	}
+
+	agent := &models.AgentT{
+		HostID:    msg.ID,
+		Name:      name,
+		FirstSeen: now,
+		Approved:  g.opts.AutoApprove,
+	}
+
+	if err := g.repository.CreateAgent(agent); err != nil {
+		return nil, err
+	}
+
+	g.agents[agent.HostID] = agent
+
+	return agent, nil
+}
+
+func (g *Registry) pending() (count int) {
+	for _, agent := range g.agents {
+		if !agent.Approved {
+			count++
+		}
+	}
+	return count
+}
+
+// verify - checks signature of agent message. Must be called with lock held
+func (g *Registry) verify(agent *models.AgentT, k kind, timestamp int64, data []byte, signature string, now time.Time) error {
+
+	if agent.Secret == "" {
+		if g.opts.RequireSignature {
+			return ErrUnsigned
+		}
+		return nil
+	}
+
+	if signature == "" {
+		return ErrUnsigned
+	}
+
+	if skew := now.Sub(time.Unix(timestamp, 0)); skew > SignatureSkew || skew < -SignatureSkew {
+		return ErrSignature
+	}
+
+	want := Sign(agent.Secret, agent.HostID, timestamp, data)
+	if !hmac.Equal([]byte(want), []byte(strings.ToLower(signature))) {
+		return ErrSignature
+	}
+
+	key := lastKey{hostID: agent.HostID, kind: k}
+	if timestamp <= g.last[key] {
+		return ErrReplay
+	}
+	g.last[key] = timestamp
+
+	return nil
+}
+
+// Approved - reports that agent is approved
+func (g *Registry) Approved(hostID string) bool {
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	agent, ok := g.agents[hostID]
+	return ok && agent.Approved
+}
+
+// Agents - returns registered agents, only pending ones if pending is set
+func (g *Registry) Agents(pending bool) []models.AgentObject {
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	list := make([]models.AgentObject, 0, len(g.agents))
+	for _, agent := range g.agents {
+		if !pending || !agent.Approved {
+			list = append(list, agent.Object())
+		}
+	}
+
+	slices.SortFunc(list, func(a, b models.AgentObject) int {
+		return strings.Compare(a.HostID, b.HostID)
+	})
+
+	return list
+}
+
+func (g *Registry) Approve(id uint) (models.AgentObject, error) {
+	return g.change(id, func(agent *models.AgentT) {
+		agent.Approved = true
+	})
+}
+
+// Edit - renames and tags agent
+func (g *Registry) Edit(id uint, name string, tags []string) (models.AgentObject, error) {
+	return g.change(id, func(agent *models.AgentT) {
+		agent.Name, agent.Tags = name, strings.Join(tags, ",")
+	})
+}
+
+// GenerateSecret - sets new random secret of agent. Agent payloads must be signed with it from now on
+func (g *Registry) GenerateSecret(id uint) (models.ResponseAgentSecret, error) {
+
+	key := make([]byte, secretSize)
+	if _, err := rand.Read(key); err != nil {
+		return models.ResponseAgentSecret{}, err
+	}
+
+	secret := hex.EncodeToString(key)
+
+	obj, err := g.change(id, func(agent *models.AgentT) {
+		agent.Secret = secret
+	})
+	if err != nil {
+		return models.ResponseAgentSecret{}, err
+	}
+
+	return models.ResponseAgentSecret{HostID: obj.HostID, Secret: secret}, nil
+}
+
+// ClearSecret - allows unsigned agent payloads
+func (g *Registry) ClearSecret(id uint) (models.AgentObject, error) {
+	return g.change(id, func(agent *models.AgentT) {
+		agent.Secret = ""
+	})
+}
+
+func (g *Registry) change(id uint, set func(agent *models.AgentT)) (models.AgentObject, error) {
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	agent, err := g.repository.AgentById(id)
+	if err != nil {
+		return models.AgentObject{}, err
+	}
+
+	set(agent)
+
+	if err := g.repository.UpdateAgent(agent); err != nil {
+		return models.AgentObject{}, err
+	}
+
+	g.agents[agent.HostID] = agent
+
+	return agent.Object(), nil
+}
+
+// Remove - deletes agent and returns it's host id. Removed agent is queued again on it's next payload
+func (g *Registry) Remove(id uint) (string, error) {
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	agent, err := g.repository.AgentById(id)
+	if err != nil {
+		return "", err
+	}
+
+	if err := g.repository.DeleteAgent(id); err != nil {
+		return "", err
+	}
+
+	delete(g.agents, agent.HostID)
+	for _, k := range []kind{kindStats, kindPresence, kindResponse} {
+		delete(g.last, lastKey{hostID: agent.HostID, kind: k})
+	}
+
+	return agent.HostID, nil
+}
@@ -0,0 +1,300 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+type memoryAgents map[uint]models.AgentT
+
+func (m memoryAgents) CreateAgent(agent *models.AgentT) error {
+	agent.ID = uint(len(m) + 1)
+	m[agent.ID] = *agent
+	return nil
+}
+
+func (m memoryAgents) UpdateAgent(agent *models.AgentT) error {
+	m[agent.ID] = *agent
+	return nil
+}
+
+func (m memoryAgents) AgentById(id uint) (*models.AgentT, error) {
+	agent, ok := m[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &agent, nil
+}
+
+func (m memoryAgents) Agents() ([]models.AgentT, error) {
+	list := []models.AgentT{}
+	for _, agent := range m {
+		list = append(list, agent)
+	}
+	return list, nil
+}
+
+func (m memoryAgents) DeleteAgent(id uint) error {
+	delete(m, id)
+	return nil
+}
+
+func payload(hostID, secret string, ts time.Time) []byte {
+
+	data := json.RawMessage(`{"host":{"hostname":"node-1"}}`)
+
+	msg := signedMessage{ID: hostID, Data: data, Timestamp: ts.Unix()}
+	if secret != "" {
+		msg.Signature = Sign(secret, hostID, ts.Unix(), data)
+	}
+
+	b, _ := json.Marshal(msg)
+	return b
+}
+
+func TestApproval(t *testing.T) {
+
+	repo := memoryAgents{}
+	reg := New(repo, Options{})
+
+	if err := reg.Admit("host-1", payload("host-1", "", time.Now())); !errors.Is(err, ErrPending) {
+		t.Fatalf("error = %v, want %v", err, ErrPending)
+	}
+
+	// payload of other host id is rejected
+	if err := reg.Admit("host-2", payload("host-1", "", time.Now())); !errors.Is(err, ErrMessage) {
+		t.Fatalf("error = %v, want %v", err, ErrMessage)
+	}
+
+	pending := reg.Agents(true)
+	if len(pending) != 1 || pending[0].Name != "node-1" || pending[0].Approved || pending[0].FirstSeen == 0 {
+		t.Fatalf("pending = %+v", pending)
+	}
+
+	if _, err := reg.Approve(pending[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := reg.Admit("host-1", payload("host-1", "", time.Now())); err != nil || !reg.Approved("host-1") {
+		t.Fatalf("approved agent isn't admitted: %v", err)
+	}
+
+	obj, err := reg.Edit(pending[0].ID, "db", []string{"prod", "eu"})
+	if err != nil || obj.Name != "db" || len(obj.Tags) != 2 {
+		t.Fatalf("edited = %+v, %v", obj, err)
+	}
+
+	// registry is restored from repository
+	reg = New(repo, Options{})
+	if err := reg.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	if list := reg.Agents(false); len(list) != 1 || list[0].Name != "db" || !list[0].Approved {
+		t.Fatalf("agents = %+v", list)
+	}
+
+	if host, err := reg.Remove(pending[0].ID); err != nil || host != "host-1" || reg.Approved("host-1") {
+		t.Fatalf("remove = %s, %v", host, err)
+	}
+
+	if _, err := reg.Approve(pending[0].ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("error = %v", err)
+	}
+}
+
+func TestQueueLimit(t *testing.T) {
+
+	reg := New(memoryAgents{}, Options{})
+
+	for i := range MaxPending {
+		id := fmt.Sprintf("host-%d", i)
+		if err := reg.Admit(id, payload(id, "", time.Now())); !errors.Is(err, ErrPending) {
+			t.Fatal(err)
+		}
+	}
+
+	if err := reg.Admit("extra", payload("extra", "", time.Now())); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("error = %v, want %v", err, ErrQueueFull)
+	}
+}
+
+func TestSignature(t *testing.T) {
+
+	reg := New(memoryAgents{}, Options{AutoApprove: true})
+
+	if err := reg.Admit("host-1", payload("host-1", "", time.Now())); err != nil {
+		t.Fatal(err)
+	}
+
+	id := reg.Agents(false)[0].ID
+
+	secret, err := reg.GenerateSecret(id)
+	if err != nil || len(secret.Secret) != 2*secretSize {
+		t.Fatalf("secret = %+v, %v", secret, err)
+	}
+
+	now := time.Now()
+
+	cases := []struct {
+		name    string
+		payload []byte
+		err     error
+	}{
+		{"signed", payload("host-1", secret.Secret, now), nil},
+		{"unsigned", payload("host-1", "", now), ErrUnsigned},
+		{"spoofed", payload("host-1", "guess", now), ErrSignature},
+		{"replayed", payload("host-1", secret.Secret, now.Add(-2*SignatureSkew)), ErrSignature},
+	}
+
+	for _, c := range cases {
+		if err := reg.Admit("host-1", c.payload); !errors.Is(err, c.err) {
+			t.Errorf("%s: error = %v, want %v", c.name, err, c.err)
+		}
+	}
+
+	// changed data breaks signature
+	msg := signedMessage{}
+	json.Unmarshal(payload("host-1", secret.Secret, now), &msg)
+	msg.Data = json.RawMessage(`{"host":{"hostname":"evil"}}`)
+	tampered, _ := json.Marshal(msg)
+
+	if err := reg.Admit("host-1", tampered); !errors.Is(err, ErrSignature) {
+		t.Fatalf("tampered: error = %v, want %v", err, ErrSignature)
+	}
+
+	if obj, err := reg.ClearSecret(id); err != nil || obj.Signed {
+		t.Fatalf("cleared = %+v, %v", obj, err)
+	}
+
+	if err := reg.Admit("host-1", payload("host-1", "", now)); err != nil {
+		t.Fatal(err)
+	}
+
+	strict := New(memoryAgents{}, Options{AutoApprove: true, RequireSignature: true})
+	if err := strict.Admit("host-1", payload("host-1", "", now)); !errors.Is(err, ErrUnsigned) {
+		t.Fatalf("error = %v, want %v", err, ErrUnsigned)
+	}
+}
+
+func TestReplay(t *testing.T) {
+
+	reg := New(memoryAgents{}, Options{AutoApprove: true})
+	reg.Admit("host-1", payload("host-1", "", time.Now()))
+
+	secret, err := reg.GenerateSecret(reg.Agents(false)[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	first := payload("host-1", secret.Secret, now.Add(-time.Second))
+
+	if err := reg.Admit("host-1", first); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name    string
+		payload []byte
+		err     error
+	}{
+		{"same", first, ErrReplay},
+		{"older", payload("host-1", secret.Secret, now.Add(-2*time.Second)), ErrReplay},
+		{"newer", payload("host-1", secret.Secret, now), nil},
+	}
+
+	for _, c := range cases {
+		if err := reg.Admit("host-1", c.payload); !errors.Is(err, c.err) {
+			t.Errorf("%s: error = %v, want %v", c.name, err, c.err)
+		}
+	}
+
+	// stats timestamp doesn't block presence of the same second
+	ts := now.Unix()
+	state := models.PresenceOnline
+	if err := reg.VerifyPresence("host-1", ts, state, Sign(secret.Secret, "host-1", ts, PresenceData(state))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyPresence(t *testing.T) {
+
+	reg := New(memoryAgents{}, Options{})
+	reg.Admit("host-1", payload("host-1", "", time.Now()))
+
+	id := reg.Agents(true)[0].ID
+	ts := time.Now().Unix()
+
+	if err := reg.VerifyPresence("host-1", 0, models.PresenceOnline, ""); !errors.Is(err, ErrPending) {
+		t.Fatalf("pending: error = %v, want %v", err, ErrPending)
+	}
+
+	if err := reg.VerifyPresence("unknown", 0, models.PresenceOnline, ""); !errors.Is(err, ErrPending) {
+		t.Fatalf("unknown: error = %v, want %v", err, ErrPending)
+	}
+
+	if len(reg.Agents(false)) != 1 {
+		t.Fatal("presence message queued unknown agent")
+	}
+
+	reg.Approve(id)
+	secret, _ := reg.GenerateSecret(id)
+
+	offline := Sign(secret.Secret, "host-1", ts, PresenceData(models.PresenceOffline))
+
+	cases := []struct {
+		name      string
+		state     models.PresenceState
+		signature string
+		err       error
+	}{
+		{"unsigned", models.PresenceOffline, "", ErrUnsigned},
+		{"changed state", models.PresenceOnline, offline, ErrSignature},
+		{"signed", models.PresenceOffline, offline, nil},
+		{"replayed", models.PresenceOffline, offline, ErrReplay},
+	}
+
+	for _, c := range cases {
+		if err := reg.VerifyPresence("host-1", ts, c.state, c.signature); !errors.Is(err, c.err) {
+			t.Errorf("%s: error = %v, want %v", c.name, err, c.err)
+		}
+	}
+}
+
+func TestVerifyResponse(t *testing.T) {
+
+	reg := New(memoryAgents{}, Options{AutoApprove: true})
+	reg.Admit("host-1", payload("host-1", "", time.Now()))
+
+	secret, _ := reg.GenerateSecret(reg.Agents(false)[0].ID)
+
+	resp := models.AgentCommandResponse{
+		ID:        "call-1",
+		OK:        true,
+		Data:      json.RawMessage(`[{"pid":1}]`),
+		Timestamp: time.Now().Unix(),
+	}
+	resp.Signature = Sign(secret.Secret, "host-1", resp.Timestamp, ResponseData(resp))
+
+	failed := resp
+	failed.OK, failed.Error = false, "unit not found"
+
+	if err := reg.VerifyResponse("host-1", failed); !errors.Is(err, ErrSignature) {
+		t.Fatalf("tampered: error = %v, want %v", err, ErrSignature)
+	}
+
+	if err := reg.VerifyResponse("host-2", resp); !errors.Is(err, ErrPending) {
+		t.Fatalf("other host: error = %v, want %v", err, ErrPending)
+	}
+
+	if err := reg.VerifyResponse("host-1", resp); err != nil {
+		t.Fatal(err)
+	}
+}
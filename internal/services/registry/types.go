@@ -0,0 +1,61 @@
+package registry
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+)
+
+const (
+	// MaxPending - count of unknown agents kept in approval queue
+	MaxPending = 100
+	// SignatureSkew - allowed difference between signed payload timestamp and receiving time
+	SignatureSkew = time.Minute
+	// secretSize - bytes of generated agent secret
+	secretSize = 32
+)
+
+// kind - kind of signed agent message. Timestamps are tracked per kind, so agent
+// may send stats, presence and command response within the same second
+type kind uint8
+
+const (
+	kindStats kind = iota
+	kindPresence
+	kindResponse
+)
+
+type lastKey struct {
+	hostID string
+	kind   kind
+}
+
+type Repository interface {
+	CreateAgent(agent *models.AgentT) error
+	UpdateAgent(agent *models.AgentT) error
+	AgentById(id uint) (*models.AgentT, error)
+	Agents() ([]models.AgentT, error)
+	DeleteAgent(id uint) error
+}
+
+type Options struct {
+	// AutoApprove - approves unknown agents on their first payload
+	AutoApprove bool
+	// RequireSignature - rejects payloads of agents without secret
+	RequireSignature bool
+}
+
+// signedMessage - agent stats payload. Signature is hex HMAC-SHA256 made by Sign
+type signedMessage struct {
+	ID        string          `json:"host-id"`
+	Data      json.RawMessage `json:"data"`
+	Timestamp int64           `json:"timestamp"`
+	Signature string          `json:"signature"`
+}
+
+type hostData struct {
+	Host *struct {
+		Hostname string `json:"hostname"`
+	} `json:"host"`
+}
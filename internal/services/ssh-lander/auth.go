@@ -0,0 +1,115 @@
+package sshlander
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	ErrKeyPassphrase       = errors.New("private key is protected by passphrase")
+	ErrKeyWrongPassphrase  = errors.New("wrong private key passphrase")
+	ErrCertificateType     = errors.New("certificate isn't user certificate")
+	ErrCertificateMismatch = errors.New("certificate doesn't match private key")
+)
+
+// ParseSigner - parses private key, decrypting it with passphrase when it's set.
+// With certificate signer presents it instead of plain public key
+func ParseSigner(key, passphrase, certificate []byte) (ssh.Signer, error) {
+
+	var (
+		signer ssh.Signer
+		err    error
+	)
+
+	if len(passphrase) > 0 {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(key, passphrase)
+	} else {
+		signer, err = ssh.ParsePrivateKey(key)
+	}
+
+	var missing *ssh.PassphraseMissingError
+
+	switch {
+	case errors.As(err, &missing):
+		return nil, ErrKeyPassphrase
+	case errors.Is(err, x509.IncorrectPasswordError):
+		return nil, ErrKeyWrongPassphrase
+	case err != nil:
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+
+	if len(certificate) == 0 {
+		return signer, nil
+	}
+
+	cert, err := ParseCertificate(certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	certSigner, err := ssh.NewCertSigner(cert, signer)
+	if err != nil {
+		return nil, ErrCertificateMismatch
+	}
+
+	return certSigner, nil
+}
+
+// ParseCertificate - parses OpenSSH user certificate in authorized_keys format
+func ParseCertificate(data []byte) (*ssh.Certificate, error) {
+
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate: %w", err)
+	}
+
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok || cert.CertType != ssh.UserCert {
+		return nil, ErrCertificateType
+	}
+
+	return cert, nil
+}
+
+// authMethods - key is offered first, then password and keyboard-interactive with password answers
+func authMethods(creds SessionCredentials) ([]ssh.AuthMethod, error) {
+
+	var methods []ssh.AuthMethod
+
+	if creds.UsePrivateKey() {
+		signer, err := ParseSigner(creds.PrivateKey(), creds.Passphrase(), creds.Certificate())
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+
+	if password := creds.Password(); password != "" {
+		methods = append(methods,
+			ssh.Password(password),
+			ssh.KeyboardInteractive(passwordChallenge(password)),
+		)
+	}
+
+	return methods, nil
+}
+
+// passwordChallenge - answers hidden prompts with password. Prompts with echo
+// ask for something else than password, they're answered with empty string
+func passwordChallenge(password string) ssh.KeyboardInteractiveChallenge {
+	return func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+
+		answers := make([]string, len(questions))
+
+		for i := range questions {
+			if !echos[i] {
+				answers[i] = password
+			}
+		}
+
+		return answers, nil
+	}
+}
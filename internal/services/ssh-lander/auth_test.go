@@ -0,0 +1,190 @@
+package sshlander
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// keyCredentials - credentials authenticating with private key
+type keyCredentials struct {
+	testCredentials
+	key, passphrase, certificate []byte
+}
+
+func (c keyCredentials) UsePrivateKey() bool { return true }
+func (c keyCredentials) Password() string    { return "" }
+func (c keyCredentials) PrivateKey() []byte  { return c.key }
+func (c keyCredentials) Passphrase() []byte  { return c.passphrase }
+func (c keyCredentials) Certificate() []byte { return c.certificate }
+
+func generateKey(t *testing.T, passphrase string) (ssh.PublicKey, []byte) {
+	t.Helper()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var block *pem.Block
+	if passphrase != "" {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(priv, "", []byte(passphrase))
+	} else {
+		block, err = ssh.MarshalPrivateKey(priv, "")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return sshPub, pem.EncodeToMemory(block)
+}
+
+func signCertificate(t *testing.T, ca ssh.Signer, key ssh.PublicKey, certType uint32) []byte {
+	t.Helper()
+
+	cert := &ssh.Certificate{
+		Key:             key,
+		CertType:        certType,
+		KeyId:           "desky",
+		ValidPrincipals: []string{"desky"},
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatal(err)
+	}
+
+	return ssh.MarshalAuthorizedKey(cert)
+}
+
+func newCA(t *testing.T) ssh.Signer {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ca
+}
+
+func TestParseSigner(t *testing.T) {
+
+	pub, plain := generateKey(t, "")
+	_, protected := generateKey(t, "phrase")
+	otherPub, _ := generateKey(t, "")
+
+	ca := newCA(t)
+
+	tests := []struct {
+		name        string
+		key         []byte
+		passphrase  string
+		certificate []byte
+		want        error
+	}{
+		{"plain", plain, "", nil, nil},
+		{"passphrase", protected, "phrase", nil, nil},
+		{"missing passphrase", protected, "", nil, ErrKeyPassphrase},
+		{"wrong passphrase", protected, "wrong", nil, ErrKeyWrongPassphrase},
+		{"certificate", plain, "", signCertificate(t, ca, pub, ssh.UserCert), nil},
+		{"host certificate", plain, "", signCertificate(t, ca, pub, ssh.HostCert), ErrCertificateType},
+		{"other key certificate", plain, "", signCertificate(t, ca, otherPub, ssh.UserCert), ErrCertificateMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseSigner(tt.key, []byte(tt.passphrase), tt.certificate)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := ParseSigner([]byte("garbage"), nil, nil); err == nil {
+		t.Fatal("garbage key is parsed")
+	}
+}
+
+func TestCertificateAuth(t *testing.T) {
+
+	ca := newCA(t)
+	pub, key := generateKey(t, "phrase")
+
+	checker := &ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool {
+			return bytes.Equal(auth.Marshal(), ca.PublicKey().Marshal())
+		},
+	}
+
+	server := startTestServer(t, &ssh.ServerConfig{PublicKeyCallback: checker.Authenticate})
+
+	creds := keyCredentials{
+		testCredentials: server.credentials(),
+		key:             key,
+		passphrase:      []byte("phrase"),
+	}
+
+	if _, err := NewClient(creds, InsecureHostKeyPolicy(), 0); err == nil {
+		t.Fatal("plain key is accepted by certificate only server")
+	}
+
+	creds.certificate = signCertificate(t, ca, pub, ssh.UserCert)
+
+	client, err := NewClient(creds, InsecureHostKeyPolicy(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+}
+
+func TestKeyboardInteractiveAuth(t *testing.T) {
+
+	server := startTestServer(t, &ssh.ServerConfig{
+		KeyboardInteractiveCallback: func(c ssh.ConnMetadata, challenge ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+
+			answers, err := challenge("", "", []string{"Login: ", "Password: "}, []bool{true, false})
+			if err != nil {
+				return nil, err
+			}
+
+			if answers[0] != "" || answers[1] != "secret" {
+				return nil, errors.New("wrong answers")
+			}
+
+			return nil, nil
+		},
+	})
+
+	session, err := NewClientSession(server.credentials(), InsecureHostKeyPolicy(), [16]byte{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.CloseDial()
+
+	result, err := session.Exec(context.Background(), "echo ok", ExecOptions{Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(result.Stdout) != "ok\n" {
+		t.Fatalf("stdout = %q", result.Stdout)
+	}
+}
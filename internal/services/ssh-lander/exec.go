@@ -0,0 +1,121 @@
+package sshlander
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ExecOptions - limits of non-interactive command run
+type ExecOptions struct {
+	// Timeout - command is killed and session closed after it
+	Timeout time.Duration
+	// MaxOutput - bytes kept of stdout and stderr each, rest of output is discarded
+	MaxOutput int
+}
+
+// ExecResult - output of non-interactive command
+type ExecResult struct {
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int
+	Signal   string
+	Duration time.Duration
+
+	TimedOut  bool
+	Truncated bool
+}
+
+// Exec - runs command in session without PTY. Non-zero exit code isn't an error,
+// it's returned in result. Session can't be used after command run
+func (ss *SSHSession) Exec(ctx context.Context, command string, opts ExecOptions) (*ExecResult, error) {
+
+	stdout := &limitedBuffer{limit: opts.MaxOutput}
+	stderr := &limitedBuffer{limit: opts.MaxOutput}
+
+	ss.sshSession.Stdout = stdout
+	ss.sshSession.Stderr = stderr
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+
+	if err := ss.sshSession.Start(command); err != nil {
+		return nil, NewError(ss.uuid, fmt.Sprintf("failed to start command: %v", err))
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- ss.sshSession.Wait() }()
+
+	result := &ExecResult{}
+
+	var err error
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		result.TimedOut = true
+		ss.sshSession.Signal(ssh.SIGKILL)
+		ss.CloseDial()
+		err = <-done
+	}
+
+	result.Duration = time.Since(start)
+	result.Stdout, result.Stderr = stdout.Bytes(), stderr.Bytes()
+	result.Truncated = stdout.truncated || stderr.truncated
+
+	var exitErr *ssh.ExitError
+	var missingErr *ssh.ExitMissingError
+
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitStatus()
+		result.Signal = exitErr.Signal()
+	case errors.As(err, &missingErr), result.TimedOut:
+		result.ExitCode = -1
+	default:
+		return nil, NewError(ss.uuid, fmt.Sprintf("command run error: %v", err))
+	}
+
+	return result, nil
+}
+
+// limitedBuffer - keeps first bytes of output up to limit. Zero limit means no limit
+type limitedBuffer struct {
+	mu        sync.Mutex
+	data      []byte
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	chunk := p
+	if b.limit > 0 && len(b.data)+len(chunk) > b.limit {
+		chunk = chunk[:b.limit-len(b.data)]
+		b.truncated = true
+	}
+
+	b.data = append(b.data, chunk...)
+
+	// remote output is drained even over limit so command isn't blocked
+	return len(p), nil
+}
+
+func (b *limitedBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.data
+}
@@ -0,0 +1,60 @@
+package sshlander
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExec(t *testing.T) {
+
+	srv := newTestServer(t)
+
+	result, err := srv.session(t).Exec(context.Background(), "echo out; echo err >&2; exit 3", ExecOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(result.Stdout) != "out\n" || string(result.Stderr) != "err\n" || result.ExitCode != 3 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	if result.TimedOut || result.Truncated {
+		t.Fatalf("unexpected flags: %+v", result)
+	}
+
+	result, err = srv.session(t).Exec(context.Background(), "true", ExecOptions{})
+	if err != nil || result.ExitCode != 0 {
+		t.Fatalf("successful command: %+v, %v", result, err)
+	}
+}
+
+func TestExecLimits(t *testing.T) {
+
+	srv := newTestServer(t)
+
+	result, err := srv.session(t).Exec(context.Background(), "head -c 100000 /dev/zero", ExecOptions{MaxOutput: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Stdout) != 1000 || !result.Truncated || result.ExitCode != 0 {
+		t.Fatalf("truncated output: %d bytes, %+v", len(result.Stdout), result.ExitCode)
+	}
+
+	start := time.Now()
+
+	result, err = srv.session(t).Exec(context.Background(), "echo started; sleep 10", ExecOptions{Timeout: 300 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !result.TimedOut || result.ExitCode != -1 || time.Since(start) > 5*time.Second {
+		t.Fatalf("timed out command: %+v", result)
+	}
+
+	if !strings.Contains(string(result.Stdout), "started") {
+		t.Errorf("output before timeout is lost: %q", result.Stdout)
+	}
+}
@@ -0,0 +1,118 @@
+package sshlander
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pkg/sftp"
+)
+
+var (
+	ErrPath        = errors.New("invalid file path")
+	ErrOutsideRoot = errors.New("file path is outside of files root")
+)
+
+// FileSession - SFTP session to host. Every path is resolved inside files root
+type FileSession struct {
+	*SSHSession
+	*sftp.Client
+
+	root string
+}
+
+// ConnectFiles - starts SFTP in ssh session. Empty root means whole file system
+func ConnectFiles(session *SSHSession, root string) (*FileSession, error) {
+
+	client, err := sftp.NewClient(session.sshClient)
+	if err != nil {
+		session.CloseDial()
+		return nil, NewError(session.uuid, "sftp subsystem error: "+err.Error())
+	}
+
+	if root == "" {
+		root = "/"
+	}
+
+	return &FileSession{
+		SSHSession: session,
+		Client:     client,
+		root:       path.Clean("/" + root),
+	}, nil
+}
+
+func (fs *FileSession) Close() error {
+	fs.Client.Close()
+	return fs.CloseDial()
+}
+
+// Resolve - returns absolute remote path of user path. Path is cleaned and joined to files root,
+// so '..' can't leave root. With files root other than '/', symbolic links leading out of root are rejected.
+// Empty path is resolved to home directory or files root
+func (fs *FileSession) Resolve(p string) (string, error) {
+
+	if strings.ContainsFunc(p, isControl) {
+		return "", ErrPath
+	}
+
+	if p == "" {
+		return fs.home(), nil
+	}
+
+	full := path.Join(fs.root, path.Clean("/"+p))
+
+	if fs.root == "/" {
+		return full, nil
+	}
+
+	// path may not exist yet, then it's parent is checked
+	for checked := full; ; checked = path.Dir(checked) {
+
+		real, err := fs.RealPath(checked)
+		if err == nil {
+			if !within(fs.root, real) {
+				return "", ErrOutsideRoot
+			}
+			return full, nil
+		}
+
+		if !errors.Is(err, os.ErrNotExist) || checked == fs.root {
+			return "", err
+		}
+	}
+}
+
+// IsRoot - reports that resolved path is files root
+func (fs *FileSession) IsRoot(p string) bool {
+	return p == fs.root
+}
+
+func (fs *FileSession) home() string {
+
+	if fs.root != "/" {
+		return fs.root
+	}
+
+	home, err := fs.RealPath(".")
+	if err != nil || !strings.HasPrefix(home, "/") {
+		return fs.root
+	}
+
+	return home
+}
+
+// ValidFileName - reports that name is plain file name without path elements
+func ValidFileName(name string) bool {
+	return name != "" && name != "." && name != ".." &&
+		!strings.ContainsAny(name, `/\`) &&
+		!strings.ContainsFunc(name, isControl)
+}
+
+func within(root, p string) bool {
+	return root == "/" || p == root || strings.HasPrefix(p, root+"/")
+}
+
+func isControl(r rune) bool {
+	return r < 0x20 || r == 0x7f
+}
@@ -0,0 +1,122 @@
+package sshlander
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/sftp"
+)
+
+// localPaths - resolves real paths inside directory like OpenSSH server does,
+// built-in pkg/sftp realpath doesn't follow symbolic links
+type localPaths struct {
+	sftp.FileLister
+	dir string
+}
+
+func (lp localPaths) RealPath(p string) (string, error) {
+
+	real, err := filepath.EvalSymlinks(filepath.Join(lp.dir, path.Clean("/"+p)))
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(lp.dir, real)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", os.ErrPermission
+	}
+
+	return path.Join("/", filepath.ToSlash(rel)), nil
+}
+
+func testFiles(t *testing.T, root string) (*FileSession, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	server, client := net.Pipe()
+
+	handlers := sftp.InMemHandler()
+	handlers.FileList = localPaths{FileLister: handlers.FileList, dir: dir}
+
+	go sftp.NewRequestServer(server, handlers).Serve()
+
+	c, err := sftp.NewClientPipe(client, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	return &FileSession{Client: c, root: root}, dir
+}
+
+func TestResolve(t *testing.T) {
+
+	fs, dir := testFiles(t, "/srv")
+
+	os.MkdirAll(filepath.Join(dir, "srv", "app"), 0755)
+	os.Symlink("..", filepath.Join(dir, "srv", "up"))
+	os.Symlink("app", filepath.Join(dir, "srv", "current"))
+
+	tests := []struct {
+		path string
+		want string
+		err  error
+	}{
+		{"", "/srv", nil},
+		{"/", "/srv", nil},
+		{"app", "/srv/app", nil},
+		{"../../etc/passwd", "/srv/etc/passwd", nil},
+		{"/app/new.conf", "/srv/app/new.conf", nil},
+		{"current/new.conf", "/srv/current/new.conf", nil},
+		{"up", "", ErrOutsideRoot},
+		{"up/srv/app", "/srv/up/srv/app", nil},
+		{"up/missing", "", ErrOutsideRoot},
+		{"app\n", "", ErrPath},
+	}
+
+	for _, tt := range tests {
+		got, err := fs.Resolve(tt.path)
+		if !errors.Is(err, tt.err) || got != tt.want {
+			t.Errorf("resolve %q: got %q, %v; want %q, %v", tt.path, got, err, tt.want, tt.err)
+		}
+	}
+
+	if !fs.IsRoot("/srv") || fs.IsRoot("/srv/app") {
+		t.Error("wrong files root detection")
+	}
+}
+
+func TestResolveFullRoot(t *testing.T) {
+
+	fs, _ := testFiles(t, "/")
+
+	if got, err := fs.Resolve("/../etc/./hosts"); err != nil || got != "/etc/hosts" {
+		t.Errorf("resolve: got %q, %v", got, err)
+	}
+
+	if got, err := fs.Resolve(""); err != nil || got != "/" {
+		t.Errorf("home directory: got %q, %v", got, err)
+	}
+}
+
+func TestValidFileName(t *testing.T) {
+	for name, valid := range map[string]bool{
+		"app.conf": true,
+		".env":     true,
+		"":         false,
+		".":        false,
+		"..":       false,
+		"a/b":      false,
+		`a\b`:      false,
+		"a\x00b":   false,
+	} {
+		if ValidFileName(name) != valid {
+			t.Errorf("name %q: expected valid %v", name, valid)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package sshlander
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+)
+
+type HostKeyRepository interface {
+	HostKey(credentialsID uint) (*models.SSHHostKeyT, error)
+	TrustHostKey(key *models.SSHHostKeyT) error
+	SetPendingHostKey(credentialsID uint, keyType, key, fingerprint string) error
+}
+
+// HostKeyPolicy - host key callback with key algorithms accepted from server
+type HostKeyPolicy struct {
+	Callback   ssh.HostKeyCallback
+	Algorithms []string
+}
+
+// InsecureHostKeyPolicy - accepts any host key. Must be used only for tests
+func InsecureHostKeyPolicy() HostKeyPolicy {
+	return HostKeyPolicy{Callback: ssh.InsecureIgnoreHostKey()}
+}
+
+// HostKeyError - offered host key doesn't match trusted one
+type HostKeyError struct {
+	Host     string
+	Expected string
+	Offered  string
+}
+
+func (e *HostKeyError) Error() string {
+	return fmt.Sprintf(
+		"host key verification failed for %s: trusted key %s, offered key %s. "+
+			"host key was changed or connection is intercepted, review the key before connecting again",
+		e.Host, e.Expected, e.Offered,
+	)
+}
+
+func IsHostKeyError(e error) (*HostKeyError, bool) {
+	var hkErr *HostKeyError
+	ok := errors.As(e, &hkErr)
+	return hkErr, ok
+}
+
+// TrustOnFirstUse - returns policy that trusts host key of first connection
+// and rejects any other key of host after it. Rejected key is stored as pending for review
+func TrustOnFirstUse(r HostKeyRepository, credentialsID uint) (HostKeyPolicy, error) {
+
+	trusted, err := r.HostKey(credentialsID)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return HostKeyPolicy{}, err
+	}
+
+	policy := HostKeyPolicy{}
+
+	if trusted != nil {
+		policy.Algorithms = KeyAlgorithms(trusted.KeyType)
+	}
+
+	policy.Callback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+
+		if trusted == nil {
+			if err := r.TrustHostKey(NewHostKey(credentialsID, key, models.HostKeyFirstUse)); err != nil {
+				return err
+			}
+
+			// concurrent first connection could store another key
+			stored, err := r.HostKey(credentialsID)
+			if err != nil {
+				return err
+			}
+			trusted = stored
+		}
+
+		fingerprint := ssh.FingerprintSHA256(key)
+		if trusted.Fingerprint == fingerprint {
+			return nil
+		}
+
+		if err := r.SetPendingHostKey(credentialsID, key.Type(), MarshalKey(key), fingerprint); err != nil {
+			return err
+		}
+
+		return &HostKeyError{
+			Host:     hostname,
+			Expected: trusted.Fingerprint,
+			Offered:  fingerprint,
+		}
+	}
+
+	return policy, nil
+}
+
+// NewHostKey - creates trusted host key record
+func NewHostKey(credentialsID uint, key ssh.PublicKey, source models.HostKeySource) *models.SSHHostKeyT {
+	return &models.SSHHostKeyT{
+		CredentialsID: credentialsID,
+		KeyType:       key.Type(),
+		Key:           MarshalKey(key),
+		Fingerprint:   ssh.FingerprintSHA256(key),
+		Source:        source,
+		TrustedAt:     time.Now(),
+	}
+}
+
+// MarshalKey - formats key in authorized_keys format
+func MarshalKey(key ssh.PublicKey) string {
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+}
+
+// KeyAlgorithms - returns host key algorithms that server must use to present key of type
+func KeyAlgorithms(keyType string) []string {
+
+	switch keyType {
+	case "":
+		return nil
+	case ssh.KeyAlgoRSA:
+		return []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	default:
+		return []string{keyType}
+	}
+}
@@ -0,0 +1,122 @@
+package sshlander
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"gorm.io/gorm"
+)
+
+type memoryHostKeys map[uint]*models.SSHHostKeyT
+
+func (m memoryHostKeys) HostKey(id uint) (*models.SSHHostKeyT, error) {
+	if k, ok := m[id]; ok {
+		copied := *k
+		return &copied, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m memoryHostKeys) TrustHostKey(key *models.SSHHostKeyT) error {
+	if _, ok := m[key.CredentialsID]; !ok {
+		m[key.CredentialsID] = key
+	}
+	return nil
+}
+
+func (m memoryHostKeys) SetPendingHostKey(id uint, keyType, key, fingerprint string) error {
+	m[id].PendingType, m[id].PendingKey, m[id].PendingFingerprint = keyType, key, fingerprint
+	return nil
+}
+
+func newKey(t *testing.T) ssh.PublicKey {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestTrustOnFirstUse(t *testing.T) {
+
+	repo := memoryHostKeys{}
+	remote := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 22}
+	first, second := newKey(t), newKey(t)
+
+	policy, err := TrustOnFirstUse(repo, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := policy.Callback("10.0.0.1:22", remote, first); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+
+	policy, err = TrustOnFirstUse(repo, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(policy.Algorithms) != 1 || policy.Algorithms[0] != ssh.KeyAlgoED25519 {
+		t.Fatalf("algorithms of trusted key: %v", policy.Algorithms)
+	}
+
+	if err := policy.Callback("10.0.0.1:22", remote, first); err != nil {
+		t.Fatalf("trusted key: %v", err)
+	}
+
+	hkErr, ok := IsHostKeyError(policy.Callback("10.0.0.1:22", remote, second))
+	if !ok || hkErr.Offered != ssh.FingerprintSHA256(second) {
+		t.Fatalf("expected host key error, got %v", hkErr)
+	}
+
+	if stored := repo[1]; stored.Fingerprint != ssh.FingerprintSHA256(first) ||
+		stored.PendingFingerprint != ssh.FingerprintSHA256(second) {
+		t.Fatalf("unexpected stored key: %+v", stored)
+	}
+}
+
+func TestKnownHostsKeys(t *testing.T) {
+
+	plain, hashed, ported := newKey(t), newKey(t), newKey(t)
+
+	content := fmt.Sprintf(
+		"# comment\nalpha.lan,10.0.0.1 %s\n%s %s\n%s %s\n",
+		MarshalKey(plain),
+		knownhosts.HashHostname("beta.lan"), MarshalKey(hashed),
+		knownhosts.Normalize("gamma.lan:2222"), MarshalKey(ported),
+	)
+
+	addresses := []string{"alpha.lan:22", "beta.lan:22", "gamma.lan:2222", "gamma.lan:22"}
+
+	keys, err := KnownHostsKeys([]byte(content), addresses)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]ssh.PublicKey{
+		addresses[0]: plain,
+		addresses[1]: hashed,
+		addresses[2]: ported,
+	}
+
+	if len(keys) != len(expected) {
+		t.Fatalf("expected %d keys, got %d", len(expected), len(keys))
+	}
+
+	for address, key := range expected {
+		if keys[address] == nil || MarshalKey(keys[address]) != MarshalKey(key) {
+			t.Errorf("wrong key of %s", address)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package sshlander
+
+import (
+	"fmt"
+	"path"
+	"slices"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/pkg/sshconfig"
+)
+
+// ImportOptions - defaults of hosts imported from OpenSSH client config
+type ImportOptions struct {
+	System string
+	// User - used for hosts without User option
+	User string
+	// Password - used for hosts without resolved identity
+	Password string
+	// Identities - shared key ids by IdentityFile values
+	Identities map[string]uint
+	// Keys - shared keys. Key with base name of IdentityFile is used when it isn't in Identities
+	Keys []models.SSHKeyT
+
+	Group string
+	Tags  []string
+}
+
+// ImportPlan - hosts to add in creation order. Jump host is always added before hosts using it
+type ImportPlan struct {
+	Hosts   []models.SSHCredentialsT
+	Aliases []string
+	// Jumps - index of jump host in Hosts or -1 when host is direct or uses stored jump host
+	Jumps   []int
+	Skipped []models.SSHImportedObject
+}
+
+type importState int
+
+const (
+	importNew importState = iota
+	importVisiting
+	importDone
+)
+
+type importCandidate struct {
+	host  sshconfig.Host
+	creds models.SSHCredentialsT
+	// stored - id of stored host with same address
+	stored uint
+	skip   string
+
+	state importState
+	chain []importRef
+	index int
+}
+
+// importRef - imported or stored host
+type importRef struct {
+	candidate *importCandidate
+	stored    uint
+}
+
+type importPlanner struct {
+	stored     map[uint]*models.SSHCredentialsT
+	candidates []*importCandidate
+	plan       *ImportPlan
+}
+
+// PlanImport - resolves hosts of config. Hosts which already stored, have no credentials
+// or have ProxyJump that can't be mapped to imported or stored hosts are skipped
+func PlanImport(cfg *sshconfig.Config, stored []models.SSHCredentialsT, opts ImportOptions) *ImportPlan {
+
+	p := &importPlanner{
+		stored: make(map[uint]*models.SSHCredentialsT, len(stored)),
+		plan:   new(ImportPlan),
+	}
+
+	for i := range stored {
+		p.stored[stored[i].ID] = &stored[i]
+	}
+
+	for _, alias := range cfg.Aliases() {
+		p.candidates = append(p.candidates, p.candidate(cfg, alias, opts))
+	}
+
+	for _, c := range p.candidates {
+		p.visit(c)
+	}
+
+	for _, c := range p.candidates {
+		if c.skip != "" {
+			p.plan.Skipped = append(p.plan.Skipped, models.SSHImportedObject{
+				Alias:  c.host.Alias,
+				Host:   hostString(c.creds),
+				ID:     c.stored,
+				Reason: c.skip,
+			})
+		}
+	}
+
+	return p.plan
+}
+
+func (p *importPlanner) candidate(cfg *sshconfig.Config, alias string, opts ImportOptions) *importCandidate {
+
+	c := &importCandidate{index: -1}
+
+	host, err := cfg.Resolve(alias)
+	c.host = host
+	if err != nil {
+		c.skip = err.Error()
+		return c
+	}
+
+	c.creds = models.SSHCredentialsT{
+		OperationSystem: models.MakeSSHSystemTypesT(opts.System),
+
+		Username: host.User,
+		Host:     host.HostName,
+		Port:     host.Port,
+
+		Name:  alias,
+		Group: opts.Group,
+		Tags:  models.MakeSSHHostTags(opts.Tags),
+	}
+
+	if c.creds.Username == "" {
+		c.creds.Username = opts.User
+	}
+
+	if c.creds.Username == "" {
+		c.skip = "user isn't set"
+		return c
+	}
+
+	if id, ok := p.find(c.creds.Username, c.creds.Host, c.creds.Port); ok {
+		c.stored, c.skip = id, "host already exists"
+		return c
+	}
+
+	for _, other := range p.candidates {
+		if other.skip == "" && other.creds.Username == c.creds.Username && other.creds.Socket() == c.creds.Socket() {
+			c.skip = fmt.Sprintf("host is imported as %s", other.host.Alias)
+			return c
+		}
+	}
+
+	switch key := opts.identity(host.IdentityFile); {
+	case key != 0:
+		c.creds.Security = models.SSHSecureT{PrivateKeyUse: true, KeyID: &key}
+	case opts.Password != "":
+		c.creds.Security = models.MakeSSHSecureT(opts.Password, false, "")
+	default:
+		c.skip = "no shared key for identity file and no password"
+	}
+
+	return c
+}
+
+// visit - resolves jump chain of candidate and adds it to plan after it's jump host
+func (p *importPlanner) visit(c *importCandidate) {
+
+	switch {
+	case c.state == importVisiting:
+		c.skip = "jump host chain has a loop"
+		return
+	case c.state == importDone:
+		return
+	}
+
+	c.state = importVisiting
+	defer func() { c.state = importDone }()
+
+	if c.skip != "" {
+		return
+	}
+
+	var refs []importRef
+
+	for _, jump := range c.host.ProxyJump {
+
+		ref, ok := p.resolve(jump)
+		if !ok {
+			c.skip = fmt.Sprintf("unknown jump host %s", jump.Host)
+			return
+		}
+
+		refs = append(refs, ref)
+	}
+
+	if len(refs) > MaxJumpHops {
+		c.skip = ErrJumpHops.Error()
+		return
+	}
+
+	if len(refs) > 0 {
+
+		last := refs[len(refs)-1]
+
+		if last.candidate != nil {
+			p.visit(last.candidate)
+
+			if c.skip != "" {
+				return
+			}
+
+			if last.candidate.skip != "" {
+				c.skip = fmt.Sprintf("jump host %s is skipped", last.candidate.host.Alias)
+				return
+			}
+		}
+
+		// stored hosts keep single jump host, so ProxyJump list must repeat chain of last jump host
+		if !slices.Equal(p.chain(last), refs[:len(refs)-1]) {
+			c.skip = "ProxyJump chain differs from jump host settings"
+			return
+		}
+
+		if last.candidate != nil {
+			p.plan.Jumps = append(p.plan.Jumps, last.candidate.index)
+		} else {
+			p.plan.Jumps = append(p.plan.Jumps, -1)
+			c.creds.JumpHostID = &last.stored
+		}
+	} else {
+		p.plan.Jumps = append(p.plan.Jumps, -1)
+	}
+
+	c.chain = refs
+	c.index = len(p.plan.Hosts)
+
+	p.plan.Hosts = append(p.plan.Hosts, c.creds)
+	p.plan.Aliases = append(p.plan.Aliases, c.host.Alias)
+}
+
+// resolve - finds ProxyJump host by alias or by address of stored host
+func (p *importPlanner) resolve(jump sshconfig.Jump) (importRef, bool) {
+
+	for _, c := range p.candidates {
+
+		if c.host.Alias != jump.Host {
+			continue
+		}
+
+		if jump.User != "" && jump.User != c.creds.Username {
+			break
+		}
+
+		if c.stored != 0 {
+			return importRef{stored: c.stored}, true
+		}
+
+		return importRef{candidate: c}, true
+	}
+
+	for id, creds := range p.stored {
+		if creds.Host == jump.Host && creds.Port == jump.Port && (jump.User == "" || jump.User == creds.Username) {
+			return importRef{stored: id}, true
+		}
+	}
+
+	return importRef{}, false
+}
+
+// chain - returns jump hosts of host from the nearest to desky
+func (p *importPlanner) chain(ref importRef) []importRef {
+
+	if ref.candidate != nil {
+		return ref.candidate.chain
+	}
+
+	var chain []importRef
+
+	for next := p.stored[ref.stored].JumpHostID; next != nil && len(chain) <= MaxJumpHops; {
+
+		chain = append([]importRef{{stored: *next}}, chain...)
+
+		jump, ok := p.stored[*next]
+		if !ok {
+			break
+		}
+		next = jump.JumpHostID
+	}
+
+	return chain
+}
+
+func (p *importPlanner) find(user, host string, port uint16) (uint, bool) {
+	for id, creds := range p.stored {
+		if creds.Username == user && creds.Host == host && creds.Port == port {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// identity - returns shared key of the first identity file which has it
+func (o ImportOptions) identity(files []string) uint {
+
+	for _, file := range files {
+
+		if id, ok := o.Identities[file]; ok {
+			return id
+		}
+
+		for _, key := range o.Keys {
+			if key.Name == path.Base(file) {
+				return key.ID
+			}
+		}
+	}
+
+	return 0
+}
+
+func hostString(creds models.SSHCredentialsT) string {
+	if creds.Host == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s@%s", creds.Username, creds.Socket())
+}
@@ -0,0 +1,104 @@
+package sshlander
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/pkg/sshconfig"
+)
+
+const importConfig = `
+Host gw
+    HostName 198.51.100.1
+    User ops
+    IdentityFile ~/.ssh/ops_ed25519
+
+Host app
+    HostName 10.0.0.10
+    User deploy
+    ProxyJump gw
+
+Host db
+    HostName 10.0.0.20
+    ProxyJump gw,app
+
+Host old-db
+    HostName 10.0.0.30
+    ProxyJump app,gw
+
+Host stored-gw
+    HostName 192.0.2.1
+    User root
+
+Host behind-stored
+    HostName 10.1.0.1
+    ProxyJump root@192.0.2.1
+
+Host loop-a
+    ProxyJump loop-b
+
+Host loop-b
+    ProxyJump loop-a
+
+Host lost
+    ProxyJump nowhere
+`
+
+func TestPlanImport(t *testing.T) {
+
+	cfg, err := sshconfig.Parse(strings.NewReader(importConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored := []models.SSHCredentialsT{
+		{ID: 7, Username: "root", Host: "192.0.2.1", Port: 22},
+	}
+
+	plan := PlanImport(cfg, stored, ImportOptions{
+		System:   "linux",
+		User:     "admin",
+		Password: "secret",
+		Keys:     []models.SSHKeyT{{ID: 3, Name: "ops_ed25519"}},
+		Group:    "imported",
+	})
+
+	if want := []string{"gw", "app", "db", "behind-stored"}; !reflect.DeepEqual(plan.Aliases, want) {
+		t.Fatalf("aliases = %v, want %v", plan.Aliases, want)
+	}
+
+	if want := []int{-1, 0, 1, -1}; !reflect.DeepEqual(plan.Jumps, want) {
+		t.Fatalf("jumps = %v, want %v", plan.Jumps, want)
+	}
+
+	gw := plan.Hosts[0]
+	if gw.Security.KeyID == nil || *gw.Security.KeyID != 3 || gw.Security.Password != "" {
+		t.Fatalf("gw security = %+v", gw.Security)
+	}
+
+	db := plan.Hosts[2]
+	if db.Username != "admin" || db.Security.Password != "secret" || db.Group != "imported" {
+		t.Fatalf("db = %+v", db)
+	}
+
+	if behind := plan.Hosts[3]; behind.JumpHostID == nil || *behind.JumpHostID != 7 {
+		t.Fatalf("behind-stored jump = %v", behind.JumpHostID)
+	}
+
+	skipped := make(map[string]string)
+	for _, s := range plan.Skipped {
+		skipped[s.Alias] = s.Reason
+	}
+
+	for _, alias := range []string{"old-db", "stored-gw", "loop-a", "loop-b", "lost"} {
+		if skipped[alias] == "" {
+			t.Errorf("%s isn't skipped", alias)
+		}
+	}
+
+	if len(skipped) != 5 {
+		t.Fatalf("skipped = %v", skipped)
+	}
+}
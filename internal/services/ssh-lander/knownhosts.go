@@ -0,0 +1,82 @@
+package sshlander
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"slices"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// preferredKeyTypes - order of host key types chosen when known_hosts has several keys of host
+var preferredKeyTypes = []string{
+	ssh.KeyAlgoED25519,
+	ssh.KeyAlgoECDSA521,
+	ssh.KeyAlgoECDSA384,
+	ssh.KeyAlgoECDSA256,
+	ssh.KeyAlgoRSA,
+}
+
+// KnownHostsKeys - resolves host keys of 'host:port' addresses declared in OpenSSH known_hosts content.
+// Hashed hostnames, patterns and markers are supported. Addresses without keys are omitted
+func KnownHostsKeys(content []byte, addresses []string) (map[string]ssh.PublicKey, error) {
+
+	file, err := os.CreateTemp("", "desky-known-hosts-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(content)
+	file.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	callback, err := knownhosts.New(file.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	// probe key is never known, so check returns all known keys of address
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	probe, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	remote := &net.TCPAddr{IP: net.IPv4zero, Port: 22}
+	keys := make(map[string]ssh.PublicKey, len(addresses))
+
+	for _, address := range addresses {
+
+		keyErr, ok := callback(address, remote, probe).(*knownhosts.KeyError)
+		if !ok || len(keyErr.Want) == 0 {
+			continue
+		}
+
+		best := keyErr.Want[0].Key
+		for _, known := range keyErr.Want[1:] {
+			if keyRank(known.Key.Type()) < keyRank(best.Type()) {
+				best = known.Key
+			}
+		}
+
+		keys[address] = best
+	}
+
+	return keys, nil
+}
+
+func keyRank(keyType string) int {
+	if idx := slices.Index(preferredKeyTypes, keyType); idx >= 0 {
+		return idx
+	}
+	return len(preferredKeyTypes)
+}
@@ -0,0 +1,87 @@
+package sshlander
+
+import (
+	"encoding/binary"
+	"errors"
+	"slices"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// FrameType - first byte of binary frame of raw terminal protocol.
+// Text messages of raw protocol are handled as input bytes,
+// so terminal emulators like xterm.js can attach to socket directly
+type FrameType byte
+
+const (
+	// FrameInput - input bytes: 0x00 <data>
+	FrameInput FrameType = 0x00
+	// FrameResize - window size: 0x01 <cols uint16> <rows uint16>, big endian
+	FrameResize FrameType = 0x01
+	// FrameSignal - signal name without 'SIG' prefix: 0x02 <name>
+	FrameSignal FrameType = 0x02
+	// FrameKeepAlive - keeps session and ssh connection alive: 0x03
+	FrameKeepAlive FrameType = 0x03
+)
+
+var (
+	ErrFrameEmpty  = errors.New("empty terminal frame")
+	ErrFrameType   = errors.New("unknown terminal frame type")
+	ErrFrameResize = errors.New("invalid resize frame")
+	ErrFrameSignal = errors.New("unsupported signal")
+)
+
+// AllowedSignals - signals that client can send to remote process
+var AllowedSignals = []ssh.Signal{
+	ssh.SIGINT, ssh.SIGTERM, ssh.SIGHUP, ssh.SIGQUIT,
+	ssh.SIGKILL, ssh.SIGUSR1, ssh.SIGUSR2,
+}
+
+type Frame struct {
+	Type   FrameType
+	Data   []byte
+	Size   TerminalSize
+	Signal ssh.Signal
+}
+
+// ParseFrame - decodes binary frame of raw terminal protocol
+func ParseFrame(body []byte) (*Frame, error) {
+
+	if len(body) == 0 {
+		return nil, ErrFrameEmpty
+	}
+
+	frame := &Frame{Type: FrameType(body[0])}
+	payload := body[1:]
+
+	switch frame.Type {
+
+	case FrameInput:
+		frame.Data = payload
+
+	case FrameResize:
+		if len(payload) != 4 {
+			return nil, ErrFrameResize
+		}
+		frame.Size = TerminalSize{
+			Cols: binary.BigEndian.Uint16(payload[:2]),
+			Rows: binary.BigEndian.Uint16(payload[2:]),
+		}
+		if !frame.Size.Valid() {
+			return nil, ErrFrameResize
+		}
+
+	case FrameSignal:
+		frame.Signal = ssh.Signal(payload)
+		if !slices.Contains(AllowedSignals, frame.Signal) {
+			return nil, ErrFrameSignal
+		}
+
+	case FrameKeepAlive:
+
+	default:
+		return nil, ErrFrameType
+	}
+
+	return frame, nil
+}
@@ -0,0 +1,39 @@
+package sshlander
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestParseFrame(t *testing.T) {
+
+	frame, err := ParseFrame([]byte{byte(FrameResize), 0, 120, 0, 40})
+	if err != nil || frame.Size != (TerminalSize{Cols: 120, Rows: 40}) {
+		t.Fatalf("resize frame: %+v, err: %v", frame, err)
+	}
+
+	frame, err = ParseFrame(append([]byte{byte(FrameSignal)}, "INT"...))
+	if err != nil || frame.Signal != ssh.SIGINT {
+		t.Fatalf("signal frame: %+v, err: %v", frame, err)
+	}
+
+	frame, err = ParseFrame(append([]byte{byte(FrameInput)}, "ls\r"...))
+	if err != nil || string(frame.Data) != "ls\r" {
+		t.Fatalf("input frame: %+v, err: %v", frame, err)
+	}
+
+	rejected := map[string][]byte{
+		"empty":       {},
+		"unknown":     {0x7f},
+		"short size":  {byte(FrameResize), 0, 80},
+		"zero size":   {byte(FrameResize), 0, 0, 0, 40},
+		"signal name": append([]byte{byte(FrameSignal)}, "STOP"...),
+	}
+
+	for name, body := range rejected {
+		if _, err := ParseFrame(body); err == nil {
+			t.Errorf("%s: frame accepted", name)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package sshlander
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Registry - keeps running shared terminals by session UUID
+type Registry struct {
+	sessions   map[uuid.UUID]*SharedTerminal
+	grace      time.Duration
+	scrollback int
+
+	mu sync.RWMutex
+}
+
+// NewRegistry - creates registry. Terminals without clients are kept for grace period,
+// last scrollback bytes of output are replayed to reattached clients
+func NewRegistry(grace time.Duration, scrollback int) *Registry {
+	return &Registry{
+		sessions:   make(map[uuid.UUID]*SharedTerminal),
+		grace:      grace,
+		scrollback: scrollback,
+	}
+}
+
+// Share - registers terminal. Terminal is removed from registry when closed
+func (r *Registry) Share(id uuid.UUID, term Terminal, meta SharedMeta, tap SessionTap) *SharedTerminal {
+
+	st := newSharedTerminal(id, term, meta, tap, r.grace, r.scrollback)
+
+	r.mu.Lock()
+	r.sessions[id] = st
+	r.mu.Unlock()
+
+	st.OnClose(func() {
+		r.mu.Lock()
+		delete(r.sessions, id)
+		r.mu.Unlock()
+	})
+
+	return st
+}
+
+func (r *Registry) Session(id uuid.UUID) (*SharedTerminal, error) {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	st, ok := r.sessions[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+
+	return st, nil
+}
+
+// Sessions - returns running terminals from oldest to newest
+func (r *Registry) Sessions() []*SharedTerminal {
+
+	r.mu.RLock()
+	list := make([]*SharedTerminal, 0, len(r.sessions))
+	for _, st := range r.sessions {
+		list = append(list, st)
+	}
+	r.mu.RUnlock()
+
+	slices.SortFunc(list, func(a, b *SharedTerminal) int {
+		return a.started.Compare(b.started)
+	})
+
+	return list
+}
+
+// CloseAll - ends every running terminal
+func (r *Registry) CloseAll() {
+	for _, st := range r.Sessions() {
+		st.Close()
+	}
+}
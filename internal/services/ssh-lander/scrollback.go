@@ -0,0 +1,64 @@
+package sshlander
+
+// Scrollback - ring buffer keeping last terminal output for replay to reattached clients
+type Scrollback struct {
+	buf     []byte
+	start   int
+	wrapped bool
+}
+
+func NewScrollback(size int) *Scrollback {
+	return &Scrollback{
+		buf: make([]byte, 0, size),
+	}
+}
+
+func (s *Scrollback) Write(p []byte) (int, error) {
+
+	n := len(p)
+	size := cap(s.buf)
+
+	if size == 0 {
+		return n, nil
+	}
+
+	if len(p) > size {
+		p = p[len(p)-size:]
+	}
+
+	for len(p) > 0 {
+
+		if len(s.buf) < size {
+			free := min(size-len(s.buf), len(p))
+			s.buf = append(s.buf, p[:free]...)
+			p = p[free:]
+			continue
+		}
+
+		copied := copy(s.buf[s.start:], p)
+		s.start = (s.start + copied) % size
+		s.wrapped = true
+		p = p[copied:]
+	}
+
+	return n, nil
+}
+
+// Bytes - returns buffered output. Overwritten buffer is cut to first line start,
+// so replay doesn't begin in the middle of escape sequence or line
+func (s *Scrollback) Bytes() []byte {
+
+	data := make([]byte, 0, len(s.buf))
+	data = append(data, s.buf[s.start:]...)
+	data = append(data, s.buf[:s.start]...)
+
+	if s.wrapped {
+		for i, b := range data {
+			if b == '\n' {
+				return data[i+1:]
+			}
+		}
+	}
+
+	return data
+}
@@ -0,0 +1,207 @@
+package sshlander
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// testServer - local SSH server running exec requests with 'sh -c'
+type testServer struct {
+	addr string
+}
+
+type testCredentials struct {
+	addr string
+}
+
+func (c testCredentials) ValueUser() string   { return "desky" }
+func (c testCredentials) Socket() string      { return c.addr }
+func (c testCredentials) UsePrivateKey() bool { return false }
+func (c testCredentials) Password() string    { return "secret" }
+func (c testCredentials) PrivateKey() []byte  { return nil }
+func (c testCredentials) Passphrase() []byte  { return nil }
+func (c testCredentials) Certificate() []byte { return nil }
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	return startTestServer(t, &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if c.User() == "desky" && string(password) == "secret" {
+				return nil, nil
+			}
+			return nil, errors.New("wrong password")
+		},
+	})
+}
+
+// startTestServer - serves connections with config. Host key is generated
+func startTestServer(t *testing.T, config *ssh.ServerConfig) *testServer {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveTestConn(conn, config)
+		}
+	}()
+
+	return &testServer{addr: ln.Addr().String()}
+}
+
+func (s *testServer) credentials() testCredentials {
+	return testCredentials{addr: s.addr}
+}
+
+func (s *testServer) session(t *testing.T) *SSHSession {
+	t.Helper()
+
+	session, err := NewClientSession(s.credentials(), InsecureHostKeyPolicy(), [16]byte{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { session.CloseDial() })
+
+	return session
+}
+
+func serveTestConn(conn net.Conn, config *ssh.ServerConfig) {
+
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+
+	for nc := range channels {
+
+		switch nc.ChannelType() {
+		case "session":
+		case "direct-tcpip":
+			go forwardTestChannel(nc)
+			continue
+		default:
+			nc.Reject(ssh.UnknownChannelType, "unsupported channel")
+			continue
+		}
+
+		channel, requests, err := nc.Accept()
+		if err != nil {
+			continue
+		}
+
+		go serveTestSession(channel, requests)
+	}
+}
+
+func forwardTestChannel(nc ssh.NewChannel) {
+
+	var target struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := ssh.Unmarshal(nc.ExtraData(), &target); err != nil {
+		nc.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+
+	conn, err := net.Dial("tcp", net.JoinHostPort(target.Host, fmt.Sprint(target.Port)))
+	if err != nil {
+		nc.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+
+	channel, requests, err := nc.Accept()
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(requests)
+
+	go func() {
+		io.Copy(channel, conn)
+		channel.CloseWrite()
+	}()
+
+	io.Copy(conn, channel)
+	conn.Close()
+}
+
+func serveTestSession(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+
+	for req := range requests {
+
+		if req.Type != "exec" {
+			req.Reply(false, nil)
+			continue
+		}
+
+		var payload struct{ Command string }
+		if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+			req.Reply(false, nil)
+			return
+		}
+		req.Reply(true, nil)
+
+		cmd := exec.Command("sh", "-c", payload.Command)
+		cmd.Stdout, cmd.Stderr = channel, channel.Stderr()
+
+		stdin, _ := cmd.StdinPipe()
+		go func() { io.Copy(stdin, channel); stdin.Close() }()
+
+		code := 0
+		if err := cmd.Run(); err != nil {
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) {
+				fmt.Fprintln(channel.Stderr(), err)
+				code = 127
+			} else {
+				code = exitErr.ExitCode()
+			}
+		}
+
+		if code < 0 {
+			channel.SendRequest("exit-signal", false, ssh.Marshal(struct {
+				Signal     string
+				CoreDumped bool
+				Message    string
+				Lang       string
+			}{Signal: "KILL"}))
+			return
+		}
+
+		channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Code uint32 }{uint32(code)}))
+		return
+	}
+}
@@ -13,6 +13,9 @@ type SSHSession struct {
 
 	sshSession *ssh.Session
 	sshClient  *ssh.Client
+
+	// jumpClients - connections to jump hosts in dial order
+	jumpClients []*ssh.Client
 }
 
 func (ss *SSHSession) UUID() string {
@@ -32,5 +35,6 @@ func (ss *SSHSession) CloseDial() (err error) {
 	if ss.sshSession != nil {
 		err = ss.sshSession.Close()
 	}
+	closeChain(ss.jumpClients)
 	return err
 }
@@ -0,0 +1,445 @@
+package sshlander
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// OutputChunk - size of terminal output read at once
+	OutputChunk = 1024
+	// ClientQueue - count of output chunks queued for client.
+	// Client that doesn't keep up with terminal output is detached
+	ClientQueue = 256
+)
+
+var (
+	ErrSessionNotFound = errors.New("terminal session not found")
+	ErrSessionClosed   = errors.New("terminal session is closed")
+	ErrReadOnly        = errors.New("terminal session is attached read-only")
+)
+
+// AccessMode - access of client attached to shared terminal
+type AccessMode string
+
+const (
+	AccessReadWrite AccessMode = "write"
+	AccessReadOnly  AccessMode = "read"
+)
+
+// Terminal - interactive terminal shared by clients
+type Terminal interface {
+	io.Writer
+	Resize(size TerminalSize) error
+	Signal(sig ssh.Signal) error
+	KeepAlive() error
+	FromTerminalBytes(w io.Writer, wrSize int64) error
+	Exit() error
+}
+
+// SessionTap - receives events of shared terminal, e.g. session recording
+type SessionTap interface {
+	Output(data []byte)
+	Input(data []byte)
+	Resize(cols, rows int)
+}
+
+// SharedMeta - description of shared terminal
+type SharedMeta struct {
+	OwnerID       uint
+	Owner         string
+	CredentialsID uint
+	Host          string
+}
+
+// SharedTerminal - terminal session which outlives client connections.
+// Output is kept in scrollback and queued to every attached client,
+// clients are written by own goroutines, so slow client can't stall terminal.
+// Terminal is closed when shell exits or grace period passes without attached clients
+type SharedTerminal struct {
+	id      uuid.UUID
+	meta    SharedMeta
+	started time.Time
+	grace   time.Duration
+
+	term       Terminal
+	tap        SessionTap
+	scrollback *Scrollback
+
+	clients map[*Attachment]struct{}
+	timer   *time.Timer
+	closed  bool
+	onClose []func()
+	done    chan struct{}
+
+	mu sync.Mutex
+}
+
+func newSharedTerminal(id uuid.UUID, term Terminal, meta SharedMeta, tap SessionTap, grace time.Duration, scrollback int) *SharedTerminal {
+
+	st := &SharedTerminal{
+		id:         id,
+		meta:       meta,
+		started:    time.Now(),
+		grace:      grace,
+		term:       term,
+		tap:        tap,
+		scrollback: NewScrollback(scrollback),
+		clients:    make(map[*Attachment]struct{}),
+		done:       make(chan struct{}),
+	}
+
+	go func() {
+		defer st.Close()
+		st.term.FromTerminalBytes(outputWriter{st}, OutputChunk)
+	}()
+
+	return st
+}
+
+func (st *SharedTerminal) ID() uuid.UUID {
+	return st.id
+}
+
+func (st *SharedTerminal) Meta() SharedMeta {
+	return st.meta
+}
+
+// Done - closed when terminal session ends
+func (st *SharedTerminal) Done() <-chan struct{} {
+	return st.done
+}
+
+// OnClose - adds function called once when terminal session ends
+func (st *SharedTerminal) OnClose(f func()) {
+
+	st.mu.Lock()
+
+	if !st.closed {
+		st.onClose = append(st.onClose, f)
+		st.mu.Unlock()
+		return
+	}
+
+	st.mu.Unlock()
+	f()
+}
+
+// Attach - connects client to terminal. Scrollback is replayed to client before live output.
+// Detach is called with closed terminal, overflowed output queue or failed write,
+// it must unblock writes of client
+func (st *SharedTerminal) Attach(w io.Writer, mode AccessMode, detach func()) (*Attachment, error) {
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.closed {
+		return nil, ErrSessionClosed
+	}
+
+	if st.timer != nil {
+		st.timer.Stop()
+		st.timer = nil
+	}
+
+	a := &Attachment{
+		terminal: st,
+		mode:     mode,
+		w:        w,
+		detach:   detach,
+		queue:    make(chan []byte, ClientQueue),
+		stop:     make(chan struct{}),
+	}
+
+	if data := st.scrollback.Bytes(); len(data) > 0 {
+		a.queue <- data
+	}
+
+	st.clients[a] = struct{}{}
+	go a.send()
+
+	return a, nil
+}
+
+// Close - ends terminal session and disconnects clients after queued output is sent
+func (st *SharedTerminal) Close() error {
+
+	st.mu.Lock()
+
+	if st.closed {
+		st.mu.Unlock()
+		return nil
+	}
+
+	st.closed = true
+	close(st.done)
+
+	if st.timer != nil {
+		st.timer.Stop()
+	}
+
+	for a := range st.clients {
+		a.flush = true
+		st.remove(a, true)
+	}
+
+	hooks := st.onClose
+	st.onClose = nil
+	st.mu.Unlock()
+
+	err := st.term.Exit()
+
+	for _, f := range hooks {
+		f()
+	}
+
+	return err
+}
+
+func (st *SharedTerminal) Object() models.SSHSessionObject {
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	obj := models.SSHSessionObject{
+		ID:        st.id.String(),
+		HostID:    st.meta.CredentialsID,
+		Host:      st.meta.Host,
+		Owner:     st.meta.Owner,
+		StartedAt: st.started.Unix(),
+	}
+
+	for a := range st.clients {
+		obj.Clients++
+		if a.mode == AccessReadWrite {
+			obj.Writers++
+		}
+	}
+
+	return obj
+}
+
+// idle - starts grace period when terminal has no clients
+func (st *SharedTerminal) idle() {
+
+	if st.closed || len(st.clients) > 0 || st.timer != nil {
+		return
+	}
+
+	if st.grace <= 0 {
+		go st.Close()
+		return
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(st.grace, func() {
+		st.mu.Lock()
+		expired := st.timer == timer && len(st.clients) == 0
+		st.mu.Unlock()
+
+		if expired {
+			st.Close()
+		}
+	})
+	st.timer = timer
+}
+
+// remove - disconnects client and stops it's sender. Detach of ended client is called by sender
+func (st *SharedTerminal) remove(a *Attachment, ended bool) {
+
+	if _, ok := st.clients[a]; !ok {
+		return
+	}
+
+	delete(st.clients, a)
+	a.ended = ended
+	close(a.stop)
+}
+
+func (st *SharedTerminal) output(p []byte) {
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.tap != nil {
+		st.tap.Output(p)
+	}
+	st.scrollback.Write(p)
+
+	if len(st.clients) == 0 || len(p) == 0 {
+		return
+	}
+
+	// read buffer is reused by terminal
+	data := append([]byte(nil), p...)
+	dropped := false
+
+	for a := range st.clients {
+		select {
+		case a.queue <- data:
+		default:
+			// client can be blocked by write, detach unblocks it by closing connection
+			st.remove(a, true)
+			go a.end()
+			dropped = true
+		}
+	}
+
+	if dropped {
+		st.idle()
+	}
+}
+
+// failed - drops client after failed write
+func (st *SharedTerminal) failed(a *Attachment) {
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.remove(a, true)
+	st.idle()
+}
+
+type outputWriter struct {
+	st *SharedTerminal
+}
+
+func (o outputWriter) Write(p []byte) (int, error) {
+	o.st.output(p)
+	return len(p), nil
+}
+
+// Attachment - client connected to shared terminal
+type Attachment struct {
+	terminal *SharedTerminal
+	mode     AccessMode
+	w        io.Writer
+	detach   func()
+
+	queue chan []byte
+	stop  chan struct{}
+	// ended - client is disconnected by terminal and must be detached
+	ended bool
+	// flush - queued output is sent before detach
+	flush bool
+	once  sync.Once
+}
+
+// send - writes queued output to client until client is removed
+func (a *Attachment) send() {
+
+	for running := true; running; {
+		select {
+
+		case data := <-a.queue:
+			if _, err := a.w.Write(data); err != nil {
+				a.terminal.failed(a)
+				<-a.stop
+				running = false
+			}
+
+		case <-a.stop:
+			if a.flush {
+				a.drain()
+			}
+			running = false
+		}
+	}
+
+	if a.ended {
+		a.end()
+	}
+}
+
+// end - calls detach once
+func (a *Attachment) end() {
+	if a.detach != nil {
+		a.once.Do(a.detach)
+	}
+}
+
+func (a *Attachment) drain() {
+	for {
+		select {
+		case data := <-a.queue:
+			if _, err := a.w.Write(data); err != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (a *Attachment) Mode() AccessMode {
+	return a.mode
+}
+
+func (a *Attachment) Terminal() *SharedTerminal {
+	return a.terminal
+}
+
+// Write - sends input bytes to terminal
+func (a *Attachment) Write(p []byte) (int, error) {
+
+	if a.mode != AccessReadWrite {
+		return 0, ErrReadOnly
+	}
+
+	n, err := a.terminal.term.Write(p)
+	if err == nil && a.terminal.tap != nil {
+		a.terminal.tap.Input(p)
+	}
+
+	return n, err
+}
+
+// Resize - changes terminal window size
+func (a *Attachment) Resize(size TerminalSize) error {
+
+	if a.mode != AccessReadWrite {
+		return ErrReadOnly
+	}
+
+	err := a.terminal.term.Resize(size)
+	if err == nil && a.terminal.tap != nil {
+		a.terminal.tap.Resize(int(size.Cols), int(size.Rows))
+	}
+
+	return err
+}
+
+func (a *Attachment) Signal(sig ssh.Signal) error {
+
+	if a.mode != AccessReadWrite {
+		return ErrReadOnly
+	}
+
+	return a.terminal.term.Signal(sig)
+}
+
+func (a *Attachment) KeepAlive() error {
+	return a.terminal.term.KeepAlive()
+}
+
+// Detach - disconnects client. Terminal stays open for grace period
+func (a *Attachment) Detach() {
+
+	st := a.terminal
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if _, ok := st.clients[a]; !ok {
+		return
+	}
+
+	st.remove(a, false)
+	st.idle()
+}
@@ -0,0 +1,299 @@
+package sshlander
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/ssh"
+)
+
+// fakeTerminal - terminal with synchronous output, print returns after output is shared
+type fakeTerminal struct {
+	out  chan []byte
+	done chan struct{}
+
+	input  bytes.Buffer
+	size   TerminalSize
+	exited chan struct{}
+	mu     sync.Mutex
+}
+
+func newFakeTerminal() *fakeTerminal {
+	return &fakeTerminal{
+		out:    make(chan []byte),
+		done:   make(chan struct{}),
+		exited: make(chan struct{}),
+	}
+}
+
+func (f *fakeTerminal) print(t *testing.T, s string) {
+	f.out <- []byte(s)
+	// second send waits until first chunk is shared
+	f.out <- nil
+}
+
+// shellExit - ends terminal output like exited shell
+func (f *fakeTerminal) shellExit() {
+	close(f.out)
+}
+
+func (f *fakeTerminal) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.input.Write(p)
+}
+
+func (f *fakeTerminal) Resize(size TerminalSize) error {
+	f.size = size
+	return nil
+}
+
+func (f *fakeTerminal) Signal(sig ssh.Signal) error { return nil }
+func (f *fakeTerminal) KeepAlive() error            { return nil }
+
+func (f *fakeTerminal) FromTerminalBytes(w io.Writer, wrSize int64) error {
+	for {
+		select {
+		case data, ok := <-f.out:
+			if !ok {
+				return io.EOF
+			}
+			w.Write(data)
+		case <-f.done:
+			return nil
+		}
+	}
+}
+
+func (f *fakeTerminal) Exit() error {
+	close(f.done)
+	close(f.exited)
+	return nil
+}
+
+// syncBuffer - client output collected from terminal goroutine
+type syncBuffer struct {
+	buf bytes.Buffer
+	mu  sync.Mutex
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// waitOutput - waits until client receives output
+func waitOutput(t *testing.T, b *syncBuffer, want string) {
+
+	deadline := time.Now().Add(time.Second)
+
+	for b.String() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("client output %q, expected %q", b.String(), want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// blockedWriter - client that never reads output
+type blockedWriter struct {
+	release chan struct{}
+}
+
+func (b blockedWriter) Write(p []byte) (int, error) {
+	<-b.release
+	return len(p), nil
+}
+
+func TestScrollback(t *testing.T) {
+
+	s := NewScrollback(16)
+	s.Write([]byte("first\r\n"))
+	s.Write([]byte("second\r\n"))
+
+	if got := string(s.Bytes()); got != "first\r\nsecond\r\n" {
+		t.Fatalf("scrollback %q", got)
+	}
+
+	s.Write([]byte("third\r\n$ "))
+
+	if got := string(s.Bytes()); got != "third\r\n$ " {
+		t.Fatalf("wrapped scrollback %q", got)
+	}
+
+	s.Write(bytes.Repeat([]byte("x"), 40))
+	if got := len(s.Bytes()); got != 16 {
+		t.Fatalf("scrollback length %d", got)
+	}
+}
+
+func TestSharedTerminal(t *testing.T) {
+
+	reg := NewRegistry(time.Hour, 1024)
+	term := newFakeTerminal()
+	id := uuid.New()
+
+	st := reg.Share(id, term, SharedMeta{Owner: "admin"}, nil)
+
+	owner := new(syncBuffer)
+	a, err := st.Attach(owner, AccessReadWrite, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	term.print(t, "$ ")
+	a.Write([]byte("ls\r"))
+	term.print(t, "ls\r\nfile\r\n$ ")
+
+	// reattach replays scrollback
+	a.Detach()
+
+	if _, err := reg.Session(id); err != nil {
+		t.Fatalf("detached session is removed: %v", err)
+	}
+
+	viewer := new(syncBuffer)
+	ro, err := st.Attach(viewer, AccessReadOnly, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitOutput(t, viewer, "$ ls\r\nfile\r\n$ ")
+
+	if _, err := ro.Write([]byte("rm -rf /\r")); err != ErrReadOnly {
+		t.Fatalf("read-only client wrote input: %v", err)
+	}
+
+	if err := ro.Resize(TerminalSize{Cols: 10, Rows: 10}); err != ErrReadOnly {
+		t.Fatalf("read-only client resized terminal: %v", err)
+	}
+
+	rw, err := st.Attach(new(syncBuffer), AccessReadWrite, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if obj := st.Object(); obj.Clients != 2 || obj.Writers != 1 {
+		t.Fatalf("clients %d writers %d", obj.Clients, obj.Writers)
+	}
+
+	term.print(t, "top\r\n")
+	waitOutput(t, viewer, "$ ls\r\nfile\r\n$ top\r\n")
+
+	rw.Write([]byte("q"))
+	if got := term.input.String(); got != "ls\rq" {
+		t.Fatalf("terminal input %q", got)
+	}
+
+	detached := make(chan struct{})
+	ro2, _ := st.Attach(new(syncBuffer), AccessReadOnly, func() { close(detached) })
+	_ = ro2
+
+	// shell exit closes terminal for every client
+	term.shellExit()
+
+	select {
+	case <-detached:
+	case <-time.After(time.Second):
+		t.Fatal("client is not detached after terminal end")
+	}
+
+	<-st.Done()
+
+	if _, err := reg.Session(id); err != ErrSessionNotFound {
+		t.Fatalf("closed session is registered: %v", err)
+	}
+
+	if _, err := st.Attach(new(syncBuffer), AccessReadOnly, nil); err != ErrSessionClosed {
+		t.Fatalf("attached to closed session: %v", err)
+	}
+}
+
+func TestSharedTerminalGrace(t *testing.T) {
+
+	reg := NewRegistry(50*time.Millisecond, 1024)
+	term := newFakeTerminal()
+
+	st := reg.Share(uuid.New(), term, SharedMeta{}, nil)
+
+	a, err := st.Attach(new(syncBuffer), AccessReadWrite, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Detach()
+
+	// reattach in grace period keeps terminal
+	time.Sleep(20 * time.Millisecond)
+	a, err = st.Attach(new(syncBuffer), AccessReadWrite, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(80 * time.Millisecond)
+	if len(reg.Sessions()) != 1 {
+		t.Fatal("attached terminal is closed")
+	}
+
+	a.Detach()
+
+	select {
+	case <-term.exited:
+	case <-time.After(time.Second):
+		t.Fatal("terminal is not closed after grace period")
+	}
+
+	<-st.Done()
+	if len(reg.Sessions()) != 0 {
+		t.Fatal("expired terminal is registered")
+	}
+}
+
+func TestSharedTerminalSlowClient(t *testing.T) {
+
+	reg := NewRegistry(time.Hour, 1024)
+	term := newFakeTerminal()
+
+	st := reg.Share(uuid.New(), term, SharedMeta{}, nil)
+
+	slow := blockedWriter{release: make(chan struct{})}
+	defer close(slow.release)
+
+	detached := make(chan struct{})
+	if _, err := st.Attach(slow, AccessReadOnly, func() { close(detached) }); err != nil {
+		t.Fatal(err)
+	}
+
+	fast := new(syncBuffer)
+	if _, err := st.Attach(fast, AccessReadWrite, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// blocked client doesn't stall terminal and is dropped after queue overflow
+	for i := 0; i < ClientQueue+2; i++ {
+		term.print(t, "x")
+	}
+
+	select {
+	case <-detached:
+	case <-time.After(time.Second):
+		t.Fatal("slow client is not detached after queue overflow")
+	}
+
+	if obj := st.Object(); obj.Clients != 1 {
+		t.Fatalf("clients %d", obj.Clients)
+	}
+
+	waitOutput(t, fast, strings.Repeat("x", ClientQueue+2))
+}
@@ -1,48 +1,106 @@
 package sshlander
 
 import (
+	"errors"
 	"fmt"
+	"net"
+	"slices"
+	"time"
 
 	"github.com/google/uuid"
 	"golang.org/x/crypto/ssh"
 )
 
+const (
+	// MaxJumpHops - limit of jump hosts between desky and target host
+	MaxJumpHops = 8
+	// DefaultDialTimeout - timeout of connection and handshake with each hop
+	DefaultDialTimeout = 15 * time.Second
+)
+
+var (
+	ErrJumpHops    = fmt.Errorf("jump host chain is longer than %d hops", MaxJumpHops)
+	ErrDialTimeout = errors.New("ssh connection timeout")
+)
+
 type SessionCredentials interface {
 	ValueUser() string
 	Socket() string
 	UsePrivateKey() bool
 	Password() string
 	PrivateKey() []byte
+	Passphrase() []byte
+	Certificate() []byte
 }
 
-func newConfig(creds SessionCredentials, HostKeyCallBack ssh.HostKeyCallback) *ssh.ClientConfig {
+// Hop - jump host which connection to next host is forwarded through
+type Hop struct {
+	Credentials SessionCredentials
+	HostKeys    HostKeyPolicy
+}
 
-	var sshAuthMethods []ssh.AuthMethod
+func newConfig(creds SessionCredentials, hostKeys HostKeyPolicy) (*ssh.ClientConfig, error) {
 
-	signer, err := ssh.ParsePrivateKey(creds.PrivateKey())
-	if creds.UsePrivateKey() && err == nil {
-		sshAuthMethods = append(sshAuthMethods, ssh.PublicKeys(signer))
+	sshAuthMethods, err := authMethods(creds)
+	if err != nil {
+		return nil, err
 	}
 
-	sshAuthMethods = append(sshAuthMethods, ssh.Password(creds.Password()))
-
 	return &ssh.ClientConfig{
-		User:            creds.ValueUser(),
-		Auth:            sshAuthMethods,
-		HostKeyCallback: HostKeyCallBack,
+		User:              creds.ValueUser(),
+		Auth:              sshAuthMethods,
+		HostKeyCallback:   hostKeys.Callback,
+		HostKeyAlgorithms: hostKeys.Algorithms,
+	}, nil
+}
+
+// Client - ssh connection to host with connections to it's jump hosts
+type Client struct {
+	*ssh.Client
+	jumps []*ssh.Client
+}
+
+// NewClient - connects to host. With jump hosts connection goes through
+// each of them in order with direct-tcpip channels.
+// Timeout limits connection to each hop, zero timeout means DefaultDialTimeout
+func NewClient(creds SessionCredentials, hostKeys HostKeyPolicy, timeout time.Duration, jumps ...Hop) (*Client, error) {
+
+	if timeout <= 0 {
+		timeout = DefaultDialTimeout
+	}
+
+	chain, err := dialChain(slices.Concat(jumps, []Hop{{creds, hostKeys}}), timeout)
+	if err != nil {
+		return nil, err
 	}
+
+	return &Client{
+		Client: chain[len(chain)-1],
+		jumps:  chain[:len(chain)-1],
+	}, nil
+}
+
+// Close - closes host connection and then jump host connections
+func (c *Client) Close() error {
+	err := c.Client.Close()
+	closeChain(c.jumps)
+	return err
 }
 
-func NewClientSession(creds SessionCredentials, hostCallBack ssh.HostKeyCallback, uuid uuid.UUID) (*SSHSession, error) {
+// NewClientSession - connects to host through jump hosts and opens session
+func NewClientSession(creds SessionCredentials, hostKeys HostKeyPolicy, uuid uuid.UUID, timeout time.Duration, jumps ...Hop) (*SSHSession, error) {
 
-	tcpDial, err := ssh.Dial("tcp", creds.Socket(), newConfig(creds, hostCallBack))
+	client, err := NewClient(creds, hostKeys, timeout, jumps...)
+	if hkErr, ok := IsHostKeyError(err); ok {
+		return nil, hkErr
+	}
 	if err != nil {
 		return nil, NewError(uuid, fmt.Sprintf("tcp dial error: %v", err))
 	}
 
-	sshSession, err := tcpDial.NewSession()
+	sshSession, err := client.NewSession()
 	if err != nil {
-		tcpDial.Close()
+		client.Close()
 		return nil, NewError(uuid, fmt.Sprintf("failed to create session: %v", err))
 	}
 
@@ -50,7 +108,170 @@ func NewClientSession(creds SessionCredentials, hostCallBack ssh.HostKeyCallback
 		credentials: creds,
 		uuid:        uuid,
 
-		sshClient:  tcpDial,
-		sshSession: sshSession,
+		sshClient:   client.Client,
+		sshSession:  sshSession,
+		jumpClients: client.jumps,
 	}, nil
 }
+
+// Reachable - checks TCP connection to address from last jump host.
+// Without jump hosts address is dialed directly
+func Reachable(address string, jumps []Hop, timeout time.Duration) bool {
+
+	result := make(chan bool, 1)
+
+	go func() {
+
+		if len(jumps) == 0 {
+			conn, err := net.DialTimeout("tcp", address, timeout)
+			if err == nil {
+				conn.Close()
+			}
+			result <- err == nil
+			return
+		}
+
+		chain, err := dialChain(jumps, timeout)
+		if err != nil {
+			result <- false
+			return
+		}
+		defer closeChain(chain)
+
+		conn, err := chain[len(chain)-1].Dial("tcp", address)
+		if err == nil {
+			conn.Close()
+		}
+		result <- err == nil
+	}()
+
+	// chain is dialed hop by hop, each with own timeout
+	select {
+	case ok := <-result:
+		return ok
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// dialChain - connects to each hop through previous one. Clients are returned in dial order
+func dialChain(hops []Hop, timeout time.Duration) ([]*ssh.Client, error) {
+
+	if len(hops) > MaxJumpHops+1 {
+		return nil, ErrJumpHops
+	}
+
+	chain := make([]*ssh.Client, 0, len(hops))
+
+	for idx, hop := range hops {
+
+		var via *ssh.Client
+		if idx > 0 {
+			via = chain[idx-1]
+		}
+
+		client, err := dialHop(via, hop, timeout)
+		if err != nil {
+			closeChain(chain)
+			if idx < len(hops)-1 {
+				return nil, jumpError(hop.Credentials, err)
+			}
+			return nil, err
+		}
+
+		chain = append(chain, client)
+	}
+
+	return chain, nil
+}
+
+// dialHop - connects to hop directly or through previous connection of chain
+func dialHop(via *ssh.Client, hop Hop, timeout time.Duration) (*ssh.Client, error) {
+
+	config, err := newConfig(hop.Credentials, hop.HostKeys)
+	if err != nil {
+		return nil, err
+	}
+	config.Timeout = timeout
+
+	address := hop.Credentials.Socket()
+
+	var conn net.Conn
+	if via == nil {
+		conn, err = net.DialTimeout("tcp", address, timeout)
+	} else {
+		conn, err = dialThrough(via, address, timeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return handshake(conn, address, config, timeout)
+}
+
+// dialThrough - opens direct-tcpip channel of other connection
+func dialThrough(via *ssh.Client, address string, timeout time.Duration) (net.Conn, error) {
+
+	type dialed struct {
+		conn net.Conn
+		err  error
+	}
+
+	result := make(chan dialed, 1)
+
+	go func() {
+		conn, err := via.Dial("tcp", address)
+		result <- dialed{conn, err}
+	}()
+
+	select {
+
+	case d := <-result:
+		return d.conn, d.err
+
+	case <-time.After(timeout):
+		// late channel is closed when it opens
+		go func() {
+			if d := <-result; d.err == nil {
+				d.conn.Close()
+			}
+		}()
+		return nil, ErrDialTimeout
+	}
+}
+
+// handshake - opens ssh connection over conn. Conn is closed when handshake isn't done in timeout,
+// timer is used as channels of jump hosts don't support deadlines
+func handshake(conn net.Conn, address string, config *ssh.ClientConfig, timeout time.Duration) (*ssh.Client, error) {
+
+	timer := time.AfterFunc(timeout, func() { conn.Close() })
+
+	c, channels, requests, err := ssh.NewClientConn(conn, address, config)
+
+	if !timer.Stop() {
+		if err == nil {
+			c.Close()
+		}
+		return nil, ErrDialTimeout
+	}
+
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return ssh.NewClient(c, channels, requests), nil
+}
+
+func closeChain(chain []*ssh.Client) {
+	for i := len(chain) - 1; i >= 0; i-- {
+		chain[i].Close()
+	}
+}
+
+func jumpError(creds SessionCredentials, err error) error {
+	if _, ok := IsHostKeyError(err); ok {
+		return err
+	}
+	return fmt.Errorf("jump host %s@%s: %w", creds.ValueUser(), creds.Socket(), err)
+}
@@ -0,0 +1,110 @@
+package sshlander
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJumpHosts(t *testing.T) {
+
+	bastion, inner, target := newTestServer(t), newTestServer(t), newTestServer(t)
+
+	hops := []Hop{
+		{Credentials: bastion.credentials(), HostKeys: InsecureHostKeyPolicy()},
+		{Credentials: inner.credentials(), HostKeys: InsecureHostKeyPolicy()},
+	}
+
+	session, err := NewClientSession(target.credentials(), InsecureHostKeyPolicy(), [16]byte{}, 0, hops...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.CloseDial()
+
+	if len(session.jumpClients) != 2 {
+		t.Fatalf("expected 2 jump connections, got %d", len(session.jumpClients))
+	}
+
+	result, err := session.Exec(context.Background(), "echo through", ExecOptions{})
+	if err != nil || string(result.Stdout) != "through\n" {
+		t.Fatalf("exec through jump hosts: %+v, %v", result, err)
+	}
+
+	if !Reachable(target.addr, hops, 5*time.Second) {
+		t.Error("target must be reachable through jump hosts")
+	}
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	if Reachable(closedAddr, hops, 5*time.Second) {
+		t.Error("closed port must be unreachable")
+	}
+
+	broken := []Hop{{Credentials: testCredentials{addr: closedAddr}, HostKeys: InsecureHostKeyPolicy()}}
+
+	_, err = NewClientSession(target.credentials(), InsecureHostKeyPolicy(), [16]byte{}, 0, broken...)
+	if err == nil || !strings.Contains(err.Error(), "jump host desky@"+closedAddr) {
+		t.Fatalf("expected jump host error, got %v", err)
+	}
+
+	if Reachable(target.addr, broken, 5*time.Second) {
+		t.Error("target must be unreachable through broken jump host")
+	}
+}
+
+func TestJumpHostsLimit(t *testing.T) {
+
+	hops := make([]Hop, MaxJumpHops+1)
+	for i := range hops {
+		hops[i] = Hop{Credentials: testCredentials{addr: "127.0.0.1:1"}, HostKeys: InsecureHostKeyPolicy()}
+	}
+
+	_, err := NewClientSession(testCredentials{addr: "127.0.0.1:1"}, InsecureHostKeyPolicy(), [16]byte{}, 0, hops...)
+	if err == nil || !strings.Contains(err.Error(), ErrJumpHops.Error()) {
+		t.Fatalf("expected chain length error, got %v", err)
+	}
+}
+
+func TestHandshakeTimeout(t *testing.T) {
+
+	// silent server accepts connections and never answers
+	silent, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+
+	go func() {
+		for {
+			conn, err := silent.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	bastion := newTestServer(t)
+	hops := []Hop{{Credentials: bastion.credentials(), HostKeys: InsecureHostKeyPolicy()}}
+
+	for _, jumps := range [][]Hop{nil, hops} {
+
+		started := time.Now()
+
+		_, err := NewClient(testCredentials{addr: silent.Addr().String()}, InsecureHostKeyPolicy(), 200*time.Millisecond, jumps...)
+		if err == nil {
+			t.Fatal("connected to silent server")
+		}
+
+		if d := time.Since(started); d > 2*time.Second {
+			t.Fatalf("handshake with %d jumps isn't limited by timeout: %s", len(jumps), d)
+		}
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"strings"
 	"sync"
 
 	"golang.org/x/crypto/ssh"
@@ -18,8 +17,7 @@ var (
 )
 
 const (
-	CommandDetermit = "DESKY_DETERMIT_COMMANDLINE"
-	ExitCommand     = "exit"
+	ExitCommand = "exit"
 )
 
 type TerminalType string
@@ -29,6 +27,19 @@ const (
 	Xterm        TerminalType = "xterm"
 )
 
+// TerminalSize - terminal window size in characters
+type TerminalSize struct {
+	Cols uint16
+	Rows uint16
+}
+
+var DefaultTerminalSize = TerminalSize{Cols: 80, Rows: 40}
+
+// Valid - reports that size is in bounds accepted by terminals
+func (ts TerminalSize) Valid() bool {
+	return ts.Cols > 0 && ts.Rows > 0 && ts.Cols <= 1000 && ts.Rows <= 1000
+}
+
 type TerminalSession struct {
 	ctx    context.Context
 	closer context.CancelFunc
@@ -41,7 +52,11 @@ type TerminalSession struct {
 	sync.Mutex
 }
 
-func ConnectTerminal(ctx context.Context, session *SSHSession, terminal TerminalType) (*TerminalSession, error) {
+func ConnectTerminal(ctx context.Context, session *SSHSession, terminal TerminalType, size TerminalSize) (*TerminalSession, error) {
+
+	if !size.Valid() {
+		size = DefaultTerminalSize
+	}
 
 	terminalSettings := ssh.TerminalModes{
 		ssh.ECHO:          1,
@@ -49,7 +64,7 @@ func ConnectTerminal(ctx context.Context, session *SSHSession, terminal Terminal
 		ssh.TTY_OP_OSPEED: 14400,
 	}
 
-	if err := session.sshSession.RequestPty(string(terminal), 80, 40, terminalSettings); err != nil {
+	if err := session.sshSession.RequestPty(string(terminal), int(size.Rows), int(size.Cols), terminalSettings); err != nil {
 
 		session.CloseDial()
 		return nil, NewError(session.uuid, fmt.Sprintf("xterm connection error: %v", err))
@@ -169,37 +184,6 @@ func (term *TerminalSession) FromTerminalBytes(w io.Writer, wrSize int64) error
 	}
 }
 
-func (term *TerminalSession) TerminalRead() <-chan []byte {
-	readerChan := make(chan []byte, 10)
-
-	go func() {
-		defer close(readerChan)
-
-		r := bufio.NewReader(term.stdOut)
-
-		for {
-			select {
-			case <-term.ctx.Done():
-				return
-
-			default:
-
-				line, err := r.ReadString('\n')
-				if err != nil {
-					readerChan <- []byte(fmt.Sprintf("stdout error: %v", err))
-					return
-				}
-
-				if !strings.Contains(line, CommandDetermit) {
-					readerChan <- []byte(line)
-				}
-			}
-		}
-	}()
-
-	return readerChan
-}
-
 // =============== Terminal writing =======================
 
 // WriteLineBreak - sends '\n' to stdin
@@ -239,23 +223,53 @@ func (term *TerminalSession) Send(data []byte) error {
 	return nil
 }
 
-// SendCleared - send stream to terminal stdin with something end
-// Example: '; pwd; echo %s", data, CommandDetermit'
-func (term *TerminalSession) SendWithPostfix(data []byte, postfix string) error {
+// Write - sends raw bytes to terminal stdin without line break
+func (term *TerminalSession) Write(data []byte) (int, error) {
 
 	term.Lock()
 	defer term.Unlock()
 
 	if term.ctx.Err() != nil {
-		return NewError(term.uuid, fmt.Sprintf("terminal session is closed"))
+		return 0, NewError(term.uuid, "terminal session is closed")
 	}
 
-	if _, err := term.stdIn.Write(append(data, []byte(postfix)...)); err != nil {
-		return NewError(term.uuid, fmt.Sprintf("failed to send string: %v", err))
+	n, err := term.stdIn.Write(data)
+	if err != nil {
+		return n, NewError(term.uuid, fmt.Sprintf("failed to write input: %v", err))
 	}
 
-	if err := term.WriteLineBreak(); err != nil {
-		return err
+	return n, nil
+}
+
+// Resize - changes terminal window size
+func (term *TerminalSession) Resize(size TerminalSize) error {
+
+	if !size.Valid() {
+		return NewError(term.uuid, fmt.Sprintf("invalid terminal size: %dx%d", size.Cols, size.Rows))
+	}
+
+	if err := term.sshSession.WindowChange(int(size.Rows), int(size.Cols)); err != nil {
+		return NewError(term.uuid, fmt.Sprintf("failed to resize terminal: %v", err))
+	}
+
+	return nil
+}
+
+// Signal - delivers signal to remote process
+func (term *TerminalSession) Signal(sig ssh.Signal) error {
+
+	if err := term.sshSession.Signal(sig); err != nil {
+		return NewError(term.uuid, fmt.Sprintf("failed to send signal %s: %v", sig, err))
+	}
+
+	return nil
+}
+
+// KeepAlive - checks that ssh connection is alive
+func (term *TerminalSession) KeepAlive() error {
+
+	if _, _, err := term.sshClient.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+		return NewError(term.uuid, fmt.Sprintf("keepalive error: %v", err))
 	}
 
 	return nil
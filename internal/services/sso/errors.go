@@ -0,0 +1,33 @@
+package sso
+
+import (
+	"errors"
+	"fmt"
+)
+
+type SSOServiceError struct {
+	err error
+}
+
+func (e *SSOServiceError) Error() string {
+	return fmt.Sprintf("single sign-on error: %s", e.err.Error())
+}
+
+func IsSSOServiceError(e error) bool {
+	var serr *SSOServiceError
+	return errors.As(e, &serr)
+}
+
+var (
+	ErrState = &SSOServiceError{
+		err: errors.New("unknown or expired login state"),
+	}
+
+	ErrNoLogin = &SSOServiceError{
+		err: errors.New("id token has no subject or login claim"),
+	}
+
+	ErrNoRole = &SSOServiceError{
+		err: errors.New("user has no mapped desky role"),
+	}
+)
@@ -0,0 +1,193 @@
+// Package sso implements OpenID Connect login of desky users
+package sso
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/pkg/oidc"
+)
+
+// SSOService - keeps pending authorization requests and resolves
+// provider identities into desky users
+type SSOService struct {
+	config  oidc.Config
+	mapping Mapping
+	http    *http.Client
+
+	client  *oidc.Client
+	pending map[string]pending
+
+	now func() time.Time
+	mu  sync.Mutex
+}
+
+// New - creates service. Provider discovery is deferred until first login,
+// so unavailable provider doesn't block server start
+func New(config oidc.Config, mapping Mapping, httpClient *http.Client) *SSOService {
+
+	if mapping.LoginClaim == "" {
+		mapping.LoginClaim = DefaultLoginClaim
+	}
+
+	if mapping.RoleClaim == "" {
+		mapping.RoleClaim = DefaultRoleClaim
+	}
+
+	return &SSOService{
+		config:  config,
+		mapping: mapping,
+		http:    httpClient,
+		pending: make(map[string]pending),
+		now:     time.Now,
+	}
+}
+
+// Begin - starts authorization request and returns it's state with provider login URL.
+// Only MaxPending requests are kept, so unauthenticated login requests can't grow memory without limit
+func (s *SSOService) Begin(ctx context.Context) (state, target string, err error) {
+
+	client, err := s.provider(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	verifier, err := oidc.NewVerifier()
+	if err != nil {
+		return "", "", err
+	}
+
+	nonce, err := oidc.RandomString(16)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err = oidc.RandomString(16)
+	if err != nil {
+		return "", "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune()
+	if len(s.pending) >= MaxPending {
+		s.dropOldest()
+	}
+
+	s.pending[state] = pending{
+		verifier: verifier,
+		nonce:    nonce,
+		expires:  s.now().Add(StateTTL),
+	}
+
+	return state, client.AuthCodeURL(state, nonce, verifier), nil
+}
+
+// Complete - exchanges code of started request and returns verified identity.
+// Each state can be completed only once
+func (s *SSOService) Complete(ctx context.Context, state, code string) (*models.ExternalIdentity, error) {
+
+	s.mu.Lock()
+	req, ok := s.pending[state]
+	delete(s.pending, state)
+	s.mu.Unlock()
+
+	if !ok || s.now().After(req.expires) {
+		return nil, ErrState
+	}
+
+	client, err := s.provider(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := client.Exchange(ctx, code, req.verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := client.Verify(ctx, token.IDToken, req.nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.identity(client.Provider().Issuer, claims)
+}
+
+// identity - resolves desky login and role from claims
+func (s *SSOService) identity(issuer string, claims oidc.Claims) (*models.ExternalIdentity, error) {
+
+	login, subject := claims.String(s.mapping.LoginClaim), claims.String("sub")
+	if login == "" || subject == "" {
+		return nil, ErrNoLogin
+	}
+
+	role := s.mapping.DefaultRole
+	for _, value := range claims.Strings(s.mapping.RoleClaim) {
+		if mapped, ok := s.mapping.Roles[value]; ok && !role.Allows(mapped) {
+			role = mapped
+		}
+	}
+
+	if role == "" {
+		return nil, ErrNoRole
+	}
+
+	return &models.ExternalIdentity{
+		Provider: issuer,
+		Subject:  subject,
+		Login:    login,
+		Role:     role,
+	}, nil
+}
+
+// provider - returns discovered provider client
+func (s *SSOService) provider(ctx context.Context) (*oidc.Client, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.client != nil {
+		return s.client, nil
+	}
+
+	client, err := oidc.Discover(ctx, s.config, s.http)
+	if err != nil {
+		return nil, err
+	}
+	s.client = client
+
+	return client, nil
+}
+
+// prune - removes expired requests
+func (s *SSOService) prune() {
+
+	now := s.now()
+	for state, req := range s.pending {
+		if now.After(req.expires) {
+			delete(s.pending, state)
+		}
+	}
+}
+
+// dropOldest - removes request that expires first
+func (s *SSOService) dropOldest() {
+
+	var (
+		oldest  string
+		expires time.Time
+	)
+
+	for state, req := range s.pending {
+		if oldest == "" || req.expires.Before(expires) {
+			oldest, expires = state, req.expires
+		}
+	}
+
+	delete(s.pending, oldest)
+}
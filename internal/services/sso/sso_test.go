@@ -0,0 +1,147 @@
+package sso
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/pkg/oidc"
+	"github.com/eterline/desky-backend/pkg/oidc/oidctest"
+)
+
+func newService(t *testing.T, mapping Mapping) (*oidctest.Server, *SSOService) {
+
+	srv := oidctest.NewServer("desky", "secret")
+	t.Cleanup(srv.Close)
+
+	s := New(oidc.Config{
+		Issuer:       srv.Issuer(),
+		ClientID:     "desky",
+		ClientSecret: "secret",
+		RedirectURL:  "http://desky.local/api/auth/oidc/callback",
+	}, mapping, srv.Client())
+
+	return srv, s
+}
+
+// login - passes provider login page and returns authorization code
+func login(t *testing.T, srv *oidctest.Server, target string) string {
+
+	client := srv.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	resp, err := client.Get(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return loc.Query().Get("code")
+}
+
+func TestCompleteMapsHighestRole(t *testing.T) {
+
+	srv, s := newService(t, Mapping{
+		Roles: map[string]models.UserRole{
+			"ops":    models.RoleOperator,
+			"admins": models.RoleAdmin,
+			"staff":  models.RoleViewer,
+		},
+	})
+	srv.Subject = "user-42"
+	srv.Claims["preferred_username"] = "alice"
+	srv.Claims["groups"] = []string{"staff", "admins", "ops"}
+
+	state, target, err := s.Begin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	identity, err := s.Complete(context.Background(), state, login(t, srv, target))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if identity.Login != "alice" || identity.Subject != "user-42" ||
+		identity.Role != models.RoleAdmin || identity.Provider != srv.Issuer() {
+		t.Fatalf("unexpected identity: %+v", identity)
+	}
+
+	if _, err := s.Complete(context.Background(), state, "replay"); err != ErrState {
+		t.Fatalf("state reuse: expected ErrState, got %v", err)
+	}
+}
+
+func TestCompleteRejectsUnmapped(t *testing.T) {
+
+	srv, s := newService(t, Mapping{
+		Roles: map[string]models.UserRole{"admins": models.RoleAdmin},
+	})
+	srv.Claims["preferred_username"] = "bob"
+	srv.Claims["groups"] = "guests"
+
+	state, target, err := s.Begin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Complete(context.Background(), state, login(t, srv, target)); err != ErrNoRole {
+		t.Fatalf("expected ErrNoRole, got %v", err)
+	}
+}
+
+func TestDefaultRole(t *testing.T) {
+
+	_, s := newService(t, Mapping{DefaultRole: models.RoleViewer})
+
+	identity, err := s.identity("issuer", oidc.Claims{"sub": "1", "preferred_username": "carol"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if identity.Role != models.RoleViewer {
+		t.Fatalf("expected viewer, got %s", identity.Role)
+	}
+
+	if _, err := s.identity("issuer", oidc.Claims{"sub": "1"}); err != ErrNoLogin {
+		t.Fatalf("expected ErrNoLogin, got %v", err)
+	}
+}
+
+func TestPendingLimit(t *testing.T) {
+
+	_, s := newService(t, Mapping{DefaultRole: models.RoleViewer})
+
+	now := time.Unix(1700000000, 0)
+	s.now = func() time.Time { return now }
+
+	first, _, err := s.Begin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < MaxPending; i++ {
+		now = now.Add(time.Millisecond)
+		if _, _, err := s.Begin(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := len(s.pending); n != MaxPending {
+		t.Fatalf("pending requests = %d, want %d", n, MaxPending)
+	}
+
+	if _, ok := s.pending[first]; ok {
+		t.Fatal("the oldest request isn't dropped")
+	}
+}
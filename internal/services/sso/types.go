@@ -0,0 +1,35 @@
+package sso
+
+import (
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+)
+
+const (
+	// StateTTL - time given to user for login on provider side
+	StateTTL = 10 * time.Minute
+	// MaxPending - count of kept authorization requests. The oldest request is dropped above it
+	MaxPending = 1024
+
+	DefaultLoginClaim = "preferred_username"
+	DefaultRoleClaim  = "groups"
+)
+
+// Mapping - rules of desky user resolving from id token claims
+type Mapping struct {
+	// LoginClaim - claim used as desky login
+	LoginClaim string
+	// RoleClaim - string or string list claim with provider groups or roles
+	RoleClaim string
+	// Roles - claim values mapped to desky roles. The highest matched role is used
+	Roles map[string]models.UserRole
+	// DefaultRole - role of users without matched claims. Empty value rejects such users
+	DefaultRole models.UserRole
+}
+
+type pending struct {
+	verifier string
+	nonce    string
+	expires  time.Time
+}
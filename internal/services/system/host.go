@@ -5,6 +5,7 @@ import (
 	"time"
 
 	cpuPs "github.com/shirou/gopsutil/v4/cpu"
+	"github.com/shirou/gopsutil/v4/disk"
 	"github.com/shirou/gopsutil/v4/load"
 	"github.com/shirou/gopsutil/v4/sensors"
 
@@ -142,3 +143,31 @@ func (hs *SystemService) Load() (avg *AverageLoad) {
 
 	return avg
 }
+
+func (hs *SystemService) Partitions() (data []PartitionInfo) {
+
+	parts, err := disk.PartitionsWithContext(hs.ctx, false)
+	if err != nil {
+		return data
+	}
+
+	for _, part := range parts {
+
+		usage, err := disk.UsageWithContext(hs.ctx, part.Mountpoint)
+		if err != nil {
+			continue
+		}
+
+		data = append(data, PartitionInfo{
+			Device:      part.Device,
+			Mountpoint:  part.Mountpoint,
+			FS:          part.Fstype,
+			Total:       usage.Total,
+			Free:        usage.Free,
+			Used:        usage.Used,
+			UsedPercent: usage.UsedPercent,
+		})
+	}
+
+	return data
+}
@@ -66,3 +66,15 @@ type (
 		Output  string `json:"output,omitempty"`
 	}
 )
+
+type (
+	PartitionInfo struct {
+		Device      string  `json:"device"`
+		Mountpoint  string  `json:"mountpoint"`
+		FS          string  `json:"fs"`
+		Total       uint64  `json:"total"`
+		Free        uint64  `json:"free"`
+		Used        uint64  `json:"used"`
+		UsedPercent float64 `json:"used-percent"`
+	}
+)
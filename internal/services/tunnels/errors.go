@@ -0,0 +1,28 @@
+package tunnels
+
+import (
+	"errors"
+	"fmt"
+)
+
+type TunnelServiceError struct {
+	err error
+}
+
+func (e *TunnelServiceError) Error() string {
+	return fmt.Sprintf("ssh tunnel error: %s", e.err.Error())
+}
+
+func IsTunnelServiceError(e error) bool {
+	var terr *TunnelServiceError
+	return errors.As(e, &terr)
+}
+
+var (
+	ErrNameExists = &TunnelServiceError{
+		err: errors.New("tunnel with this name already exists"),
+	}
+	ErrConnectionLost = &TunnelServiceError{
+		err: errors.New("ssh connection lost"),
+	}
+)
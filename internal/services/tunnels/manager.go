@@ -0,0 +1,264 @@
+package tunnels
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"github.com/eterline/desky-backend/pkg/logger"
+)
+
+// Manager - keeps started tunnels. Enabled tunnels are started again after restart by Restore
+type Manager struct {
+	ctx  context.Context
+	repo Repository
+	dial Dialer
+
+	mu      sync.Mutex
+	running map[uint]*tunnel
+}
+
+func New(ctx context.Context, r Repository, dial Dialer) *Manager {
+	return &Manager{
+		ctx:     ctx,
+		repo:    r,
+		dial:    dial,
+		running: make(map[uint]*tunnel),
+	}
+}
+
+// Restore - starts tunnels which were enabled before restart
+func (m *Manager) Restore() error {
+
+	list, err := m.repo.Tunnels()
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, def := range list {
+		if _, ok := m.running[def.ID]; def.Enabled && !ok {
+			m.running[def.ID] = startTunnel(m.ctx, def, m.dial)
+		}
+	}
+
+	return nil
+}
+
+// RunRestore - restores tunnels in background and logs failure
+func (m *Manager) RunRestore() {
+	go func() {
+		if err := m.Restore(); err != nil {
+			logger.ReturnEntry().Logger.Errorf("ssh tunnels restore error: %v", err)
+		}
+	}()
+}
+
+// Tunnels - returns all tunnels with state of started ones
+func (m *Manager) Tunnels() ([]models.SSHTunnelObject, error) {
+
+	list, err := m.repo.Tunnels()
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make([]models.SSHTunnelObject, len(list))
+
+	for i := range list {
+		if t, ok := m.running[list[i].ID]; ok {
+			result[i] = t.object()
+			continue
+		}
+		result[i] = object(&list[i])
+	}
+
+	return result, nil
+}
+
+// Tunnel - returns tunnel with it's state
+func (m *Manager) Tunnel(id uint) (models.SSHTunnelObject, error) {
+
+	def, err := m.repo.TunnelById(id)
+	if err != nil {
+		return models.SSHTunnelObject{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if t, ok := m.running[id]; ok {
+		return t.object(), nil
+	}
+
+	return object(def), nil
+}
+
+// Create - stores new stopped tunnel
+func (m *Manager) Create(def *models.SSHTunnelT) error {
+
+	if err := m.checkName(def); err != nil {
+		return err
+	}
+
+	def.Enabled = false
+
+	return m.repo.CreateTunnel(def)
+}
+
+// Update - changes tunnel. Started tunnel is restarted with new settings
+func (m *Manager) Update(def *models.SSHTunnelT) error {
+
+	stored, err := m.repo.TunnelById(def.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := m.checkName(def); err != nil {
+		return err
+	}
+
+	def.Enabled, def.CreatedAt = stored.Enabled, stored.CreatedAt
+
+	if err := m.repo.UpdateTunnel(def); err != nil {
+		return err
+	}
+
+	if m.stopRunning(def.ID) {
+		m.mu.Lock()
+		if _, ok := m.running[def.ID]; !ok {
+			m.running[def.ID] = startTunnel(m.ctx, *def, m.dial)
+		}
+		m.mu.Unlock()
+	}
+
+	return nil
+}
+
+// Delete - stops and removes tunnel
+func (m *Manager) Delete(id uint) error {
+
+	if _, err := m.repo.TunnelById(id); err != nil {
+		return err
+	}
+
+	m.stopRunning(id)
+
+	return m.repo.DeleteTunnel(id)
+}
+
+// Start - starts tunnel and enables it's restore after restart
+func (m *Manager) Start(id uint) error {
+
+	def, err := m.repo.TunnelById(id)
+	if err != nil {
+		return err
+	}
+
+	if err := m.repo.SetTunnelEnabled(id, true); err != nil {
+		return err
+	}
+	def.Enabled = true
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.running[id]; !ok {
+		m.running[id] = startTunnel(m.ctx, *def, m.dial)
+	}
+
+	return nil
+}
+
+// Stop - stops tunnel and disables it's restore after restart
+func (m *Manager) Stop(id uint) error {
+
+	if _, err := m.repo.TunnelById(id); err != nil {
+		return err
+	}
+
+	if err := m.repo.SetTunnelEnabled(id, false); err != nil {
+		return err
+	}
+
+	m.stopRunning(id)
+
+	return nil
+}
+
+// StopAll - stops all tunnels without changing their restore state
+func (m *Manager) StopAll() {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, t := range m.running {
+		t.stop()
+		delete(m.running, id)
+	}
+}
+
+// Watch - sends tunnels state every StatusInterval until it's canceled
+func (m *Manager) Watch() (<-chan []models.SSHTunnelObject, context.CancelFunc) {
+
+	ch := make(chan []models.SSHTunnelObject)
+	ctx, cancel := context.WithCancel(m.ctx)
+
+	go func() {
+		tick := time.NewTicker(StatusInterval)
+		defer tick.Stop()
+		defer close(ch)
+
+		for {
+			if list, err := m.Tunnels(); err == nil {
+				select {
+				case ch <- list:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+			}
+		}
+	}()
+
+	return ch, cancel
+}
+
+// stopRunning - stops tunnel if it's started and reports it
+func (m *Manager) stopRunning(id uint) bool {
+
+	m.mu.Lock()
+	t, ok := m.running[id]
+	delete(m.running, id)
+	m.mu.Unlock()
+
+	if ok {
+		t.stop()
+	}
+
+	return ok
+}
+
+func (m *Manager) checkName(def *models.SSHTunnelT) error {
+
+	same, err := m.repo.TunnelByName(def.Name)
+	if err != nil {
+		return err
+	}
+
+	if same != nil && same.ID != def.ID {
+		return ErrNameExists
+	}
+
+	return nil
+}
@@ -0,0 +1,364 @@
+package tunnels
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+type memoryRepo struct {
+	mu   sync.Mutex
+	next uint
+	list map[uint]models.SSHTunnelT
+}
+
+func newMemoryRepo() *memoryRepo {
+	return &memoryRepo{list: make(map[uint]models.SSHTunnelT)}
+}
+
+func (r *memoryRepo) CreateTunnel(t *models.SSHTunnelT) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.next++
+	t.ID = r.next
+	r.list[t.ID] = *t
+	return nil
+}
+
+func (r *memoryRepo) UpdateTunnel(t *models.SSHTunnelT) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.list[t.ID] = *t
+	return nil
+}
+
+func (r *memoryRepo) SetTunnelEnabled(id uint, enabled bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := r.list[id]
+	t.Enabled = enabled
+	r.list[id] = t
+	return nil
+}
+
+func (r *memoryRepo) TunnelById(id uint) (*models.SSHTunnelT, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.list[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &t, nil
+}
+
+func (r *memoryRepo) TunnelByName(name string) (*models.SSHTunnelT, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range r.list {
+		if t.Name == name {
+			return &t, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryRepo) Tunnels() ([]models.SSHTunnelT, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := []models.SSHTunnelT{}
+	for id := uint(1); id <= r.next; id++ {
+		if t, ok := r.list[id]; ok {
+			list = append(list, t)
+		}
+	}
+	return list, nil
+}
+
+func (r *memoryRepo) DeleteTunnel(id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.list, id)
+	return nil
+}
+
+// fakeConn - ssh connection stand-in which dials and listens locally
+type fakeConn struct {
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (c *fakeConn) Dial(network, address string) (net.Conn, error) {
+	return net.Dial(network, address)
+}
+
+func (c *fakeConn) Listen(network, address string) (net.Listener, error) {
+	return net.Listen(network, address)
+}
+
+func (c *fakeConn) Wait() error {
+	<-c.closed
+	return nil
+}
+
+func (c *fakeConn) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return nil
+}
+
+type fakeDialer struct {
+	mu    sync.Mutex
+	conns []*fakeConn
+	fail  bool
+}
+
+func (d *fakeDialer) dial(uint) (Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.fail {
+		return nil, errors.New("host is down")
+	}
+
+	c := &fakeConn{closed: make(chan struct{})}
+	d.conns = append(d.conns, c)
+	return c, nil
+}
+
+func (d *fakeDialer) last() *fakeConn {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.conns[len(d.conns)-1]
+}
+
+func echoServer(t *testing.T) uint16 {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(c, c)
+				c.Close()
+			}()
+		}
+	}()
+
+	return uint16(ln.Addr().(*net.TCPAddr).Port)
+}
+
+func freePort(t *testing.T) uint16 {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return uint16(ln.Addr().(*net.TCPAddr).Port)
+}
+
+func waitState(t *testing.T, m *Manager, id uint, state models.SSHTunnelState) models.SSHTunnelObject {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		obj, err := m.Tunnel(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if obj.State == state {
+			return obj
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("tunnel state %s, expected %s: %s", obj.State, state, obj.Error)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func roundTrip(t *testing.T, address, msg string) {
+	t.Helper()
+
+	c, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if _, err := io.WriteString(c, msg+"\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	line, err := bufio.NewReader(c).ReadString('\n')
+	if err != nil || line != msg+"\n" {
+		t.Fatalf("echo through tunnel: %q, %v", line, err)
+	}
+}
+
+func TestTunnelForwarding(t *testing.T) {
+
+	for _, kind := range []models.SSHTunnelType{models.TunnelLocal, models.TunnelRemote} {
+		t.Run(string(kind), func(t *testing.T) {
+
+			repo, dialer := newMemoryRepo(), &fakeDialer{}
+			m := New(context.Background(), repo, dialer.dial)
+			defer m.StopAll()
+
+			def := &models.SSHTunnelT{
+				Name: "echo", CredentialsID: 1, Type: kind,
+				BindAddress: "127.0.0.1", BindPort: freePort(t),
+				TargetHost: "127.0.0.1", TargetPort: echoServer(t),
+			}
+
+			if err := m.Create(def); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := m.Create(&models.SSHTunnelT{Name: "echo"}); err != ErrNameExists {
+				t.Fatalf("expected name error, got %v", err)
+			}
+
+			if err := m.Start(def.ID); err != nil {
+				t.Fatal(err)
+			}
+			waitState(t, m, def.ID, models.TunnelRunning)
+
+			roundTrip(t, def.Bind(), "hello")
+			roundTrip(t, def.Bind(), "again")
+
+			obj, _ := m.Tunnel(def.ID)
+			if obj.Accepted != 2 || obj.BytesIn != 12 || obj.BytesOut != 12 || !obj.Enabled {
+				t.Fatalf("unexpected stats: %+v", obj)
+			}
+
+			if err := m.Stop(def.ID); err != nil {
+				t.Fatal(err)
+			}
+
+			if obj, _ := m.Tunnel(def.ID); obj.State != models.TunnelStopped || obj.Enabled {
+				t.Fatalf("stopped tunnel: %+v", obj)
+			}
+
+			if _, err := net.Dial("tcp", def.Bind()); err == nil {
+				t.Fatal("stopped tunnel still listens")
+			}
+		})
+	}
+}
+
+func TestTunnelReconnect(t *testing.T) {
+
+	repo, dialer := newMemoryRepo(), &fakeDialer{}
+	m := New(context.Background(), repo, dialer.dial)
+	defer m.StopAll()
+
+	def := &models.SSHTunnelT{
+		Name: "db", CredentialsID: 1, Type: models.TunnelLocal,
+		BindAddress: "127.0.0.1", BindPort: freePort(t),
+		TargetHost: "127.0.0.1", TargetPort: echoServer(t),
+	}
+	m.Create(def)
+	m.Start(def.ID)
+
+	waitState(t, m, def.ID, models.TunnelRunning)
+
+	dialer.mu.Lock()
+	dialer.fail = true
+	dialer.mu.Unlock()
+
+	dialer.last().Close()
+
+	obj := waitState(t, m, def.ID, models.TunnelReconnecting)
+	if obj.Error == "" || obj.Reconnects == 0 {
+		t.Fatalf("reconnecting tunnel: %+v", obj)
+	}
+
+	dialer.mu.Lock()
+	dialer.fail = false
+	dialer.mu.Unlock()
+
+	waitState(t, m, def.ID, models.TunnelRunning)
+	roundTrip(t, def.Bind(), "back")
+}
+
+func TestTunnelRestore(t *testing.T) {
+
+	repo, dialer := newMemoryRepo(), &fakeDialer{}
+
+	enabled := &models.SSHTunnelT{
+		Name: "web", CredentialsID: 1, Type: models.TunnelLocal,
+		BindAddress: "127.0.0.1", BindPort: freePort(t),
+		TargetHost: "127.0.0.1", TargetPort: echoServer(t),
+	}
+	stopped := &models.SSHTunnelT{Name: "idle", CredentialsID: 1, Type: models.TunnelLocal}
+
+	repo.CreateTunnel(enabled)
+	repo.CreateTunnel(stopped)
+	repo.SetTunnelEnabled(enabled.ID, true)
+
+	m := New(context.Background(), repo, dialer.dial)
+	defer m.StopAll()
+
+	if err := m.Restore(); err != nil {
+		t.Fatal(err)
+	}
+
+	waitState(t, m, enabled.ID, models.TunnelRunning)
+	roundTrip(t, enabled.Bind(), "restored")
+
+	if obj, _ := m.Tunnel(stopped.ID); obj.State != models.TunnelStopped {
+		t.Fatalf("disabled tunnel is started: %+v", obj)
+	}
+
+	if err := m.Delete(enabled.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if list, _ := m.Tunnels(); len(list) != 1 || list[0].ID != stopped.ID {
+		t.Fatalf("tunnels after delete: %+v", list)
+	}
+}
+
+func TestTunnelStopWhileDialing(t *testing.T) {
+
+	release := make(chan struct{})
+	defer close(release)
+
+	// host doesn't answer until test ends
+	hanging := func(uint) (Conn, error) {
+		<-release
+		return nil, errors.New("host is down")
+	}
+
+	repo := newMemoryRepo()
+	m := New(context.Background(), repo, hanging)
+
+	def := &models.SSHTunnelT{Name: "slow", CredentialsID: 1, Type: models.TunnelLocal}
+	m.Create(def)
+	m.Start(def.ID)
+
+	stopped := make(chan struct{})
+	go func() {
+		m.StopAll()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("stop waits for dial")
+	}
+}
@@ -0,0 +1,313 @@
+package tunnels
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+)
+
+// tunnel - started tunnel. It reconnects until it's stopped
+type tunnel struct {
+	def  models.SSHTunnelT
+	dial Dialer
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu    sync.Mutex
+	state models.SSHTunnelState
+	err   error
+	since time.Time
+
+	reconnects atomic.Int64
+	active     atomic.Int64
+	accepted   atomic.Int64
+	bytesIn    atomic.Int64
+	bytesOut   atomic.Int64
+}
+
+func startTunnel(ctx context.Context, def models.SSHTunnelT, dial Dialer) *tunnel {
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	t := &tunnel{
+		def:    def,
+		dial:   dial,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	t.setState(models.TunnelConnecting, nil)
+
+	go t.run(ctx)
+
+	return t
+}
+
+// stop - closes tunnel with it's connections and waits for it
+func (t *tunnel) stop() {
+	t.cancel()
+	<-t.done
+}
+
+func (t *tunnel) run(ctx context.Context) {
+	defer close(t.done)
+
+	backoff := MinBackoff
+
+	for {
+		started := time.Now()
+
+		err := t.serve(ctx)
+		if ctx.Err() != nil {
+			t.setState(models.TunnelStopped, nil)
+			return
+		}
+
+		// tunnel that worked for a while reconnects quickly
+		if time.Since(started) > MaxBackoff {
+			backoff = MinBackoff
+		}
+
+		t.reconnects.Add(1)
+		t.setState(models.TunnelReconnecting, err)
+
+		select {
+		case <-ctx.Done():
+			t.setState(models.TunnelStopped, nil)
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, MaxBackoff)
+	}
+}
+
+// serve - forwards connections until ssh connection is lost or tunnel is stopped
+func (t *tunnel) serve(ctx context.Context) error {
+
+	conn, err := t.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	lost := make(chan struct{})
+	go func() {
+		conn.Wait()
+		close(lost)
+	}()
+
+	// stopped tunnel closes connection, so listen on ssh host doesn't block stop
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-lost:
+		}
+	}()
+
+	var (
+		listener net.Listener
+		forward  func() (net.Conn, error)
+	)
+
+	switch t.def.Type {
+	case models.TunnelRemote:
+		listener, err = conn.Listen("tcp", t.def.Bind())
+		forward = func() (net.Conn, error) {
+			return net.DialTimeout("tcp", t.def.Target(), DialTimeout)
+		}
+	default:
+		listener, err = net.Listen("tcp", t.def.Bind())
+		forward = func() (net.Conn, error) {
+			return conn.Dial("tcp", t.def.Target())
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	t.setState(models.TunnelRunning, nil)
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		opened = make(map[net.Conn]struct{})
+	)
+
+	// connection is closed by stop too
+	go func() {
+		<-lost
+
+		listener.Close()
+
+		mu.Lock()
+		for c := range opened {
+			c.Close()
+		}
+		mu.Unlock()
+	}()
+
+	for {
+		client, err := listener.Accept()
+		if err != nil {
+			break
+		}
+
+		mu.Lock()
+		opened[client] = struct{}{}
+		mu.Unlock()
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			t.forward(client, forward)
+
+			mu.Lock()
+			delete(opened, client)
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return ErrConnectionLost
+}
+
+// connect - dials ssh host. Stopped tunnel doesn't wait for dial, late connection is closed
+func (t *tunnel) connect(ctx context.Context) (Conn, error) {
+
+	type dialed struct {
+		conn Conn
+		err  error
+	}
+
+	result := make(chan dialed, 1)
+
+	go func() {
+		conn, err := t.dial(t.def.CredentialsID)
+		result <- dialed{conn, err}
+	}()
+
+	select {
+
+	case d := <-result:
+		return d.conn, d.err
+
+	case <-ctx.Done():
+		go func() {
+			if d := <-result; d.err == nil {
+				d.conn.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// forward - copies data between accepted connection and target
+func (t *tunnel) forward(client net.Conn, dial func() (net.Conn, error)) {
+	defer client.Close()
+
+	t.accepted.Add(1)
+
+	target, err := dial()
+	if err != nil {
+		return
+	}
+	defer target.Close()
+
+	t.active.Add(1)
+	defer t.active.Add(-1)
+
+	done := make(chan struct{})
+
+	go func() {
+		io.Copy(&countWriter{target, &t.bytesOut}, client)
+		closeWrite(target)
+		close(done)
+	}()
+
+	io.Copy(&countWriter{client, &t.bytesIn}, target)
+	closeWrite(client)
+
+	<-done
+}
+
+func (t *tunnel) setState(state models.SSHTunnelState, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.state != state {
+		t.since = time.Now()
+	}
+
+	t.state, t.err = state, err
+}
+
+func (t *tunnel) object() models.SSHTunnelObject {
+
+	obj := object(&t.def)
+
+	t.mu.Lock()
+	obj.State = t.state
+	obj.Since = t.since.Unix()
+	if t.err != nil {
+		obj.Error = t.err.Error()
+	}
+	t.mu.Unlock()
+
+	obj.Reconnects = t.reconnects.Load()
+	obj.Connections = t.active.Load()
+	obj.Accepted = t.accepted.Load()
+	obj.BytesIn = t.bytesIn.Load()
+	obj.BytesOut = t.bytesOut.Load()
+
+	return obj
+}
+
+func object(def *models.SSHTunnelT) models.SSHTunnelObject {
+	return models.SSHTunnelObject{
+		ID:      def.ID,
+		Name:    def.Name,
+		HostID:  def.CredentialsID,
+		Type:    def.Type,
+		Bind:    def.Bind(),
+		Target:  def.Target(),
+		Enabled: def.Enabled,
+		State:   models.TunnelStopped,
+	}
+}
+
+// closeWrite - half-closes connection, so other side gets EOF and can finish response
+func closeWrite(c net.Conn) {
+	if cw, ok := c.(interface{ CloseWrite() error }); ok {
+		cw.CloseWrite()
+		return
+	}
+	c.Close()
+}
+
+type countWriter struct {
+	w     io.Writer
+	count *atomic.Int64
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.count.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,42 @@
+package tunnels
+
+import (
+	"net"
+	"time"
+
+	"github.com/eterline/desky-backend/internal/models"
+)
+
+const (
+	// MinBackoff and MaxBackoff - limits of delay between reconnect attempts
+	MinBackoff = time.Second
+	MaxBackoff = time.Minute
+	// StatusInterval - period of tunnel status updates for watchers
+	StatusInterval = time.Second
+	// DialTimeout - timeout of connection to forwarding target
+	DialTimeout = 10 * time.Second
+)
+
+type Repository interface {
+	CreateTunnel(t *models.SSHTunnelT) error
+	UpdateTunnel(t *models.SSHTunnelT) error
+	SetTunnelEnabled(id uint, enabled bool) error
+	TunnelById(id uint) (*models.SSHTunnelT, error)
+	TunnelByName(name string) (*models.SSHTunnelT, error)
+	Tunnels() ([]models.SSHTunnelT, error)
+	DeleteTunnel(id uint) error
+}
+
+// Conn - ssh connection carrying tunnel traffic
+type Conn interface {
+	// Dial - connects to address from ssh host
+	Dial(network, address string) (net.Conn, error)
+	// Listen - listens address on ssh host
+	Listen(network, address string) (net.Listener, error)
+	// Wait - blocks until connection is closed
+	Wait() error
+	Close() error
+}
+
+// Dialer - connects to stored ssh host
+type Dialer func(credentialsID uint) (Conn, error)
@@ -19,11 +19,12 @@ type RequestProvide struct {
 	request  *http.Request
 	client   http.Client
 	response *http.Response
+	cancel   context.CancelFunc
 }
 
 func Make(url string, opts *RequestOptions) (*RequestProvide, error) {
 
-	ctx, _ := context.WithTimeout(
+	ctx, cancel := context.WithTimeout(
 		context.Background(),
 		func() time.Duration {
 			if opts != nil && opts.Timeout != 0 {
@@ -35,6 +36,7 @@ func Make(url string, opts *RequestOptions) (*RequestProvide, error) {
 
 	r, err := http.NewRequestWithContext(ctx, "", url, nil)
 	if err != nil {
+		cancel()
 		return nil, err
 	}
 
@@ -54,6 +56,7 @@ func Make(url string, opts *RequestOptions) (*RequestProvide, error) {
 		}},
 		request:  r,
 		response: nil,
+		cancel:   cancel,
 	}, nil
 }
 
@@ -100,11 +103,13 @@ func (rp *RequestProvide) fetch(r *http.Request) (*http.Response, error) {
 }
 
 func (rp *RequestProvide) Resolve(v any) error {
+	defer rp.cancel()
 	defer rp.response.Body.Close()
 	return json.NewDecoder(rp.response.Body).Decode(v)
 }
 
 func (rp *RequestProvide) BodyString() string {
+	defer rp.cancel()
 	data, _ := io.ReadAll(rp.response.Body)
 	return string(data)
 }
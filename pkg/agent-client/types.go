@@ -123,6 +123,7 @@ type (
 
 	Partition struct {
 		Device      string  `json:"device"`
+		Mountpoint  string  `json:"mountpoint"`
 		FS          string  `json:"fs"`
 		Total       uint64  `json:"total"`
 		Free        uint64  `json:"free"`
@@ -0,0 +1,139 @@
+// Package asciicast implements writer of asciicast v2 terminal recordings.
+// Format: https://docs.asciinema.org/manual/asciicast/v2/
+package asciicast
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const Version = 2
+
+type EventType string
+
+const (
+	Output EventType = "o"
+	Input  EventType = "i"
+	Resize EventType = "r"
+	Marker EventType = "m"
+)
+
+type Header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Writer - writes header and timestamped events as newline delimited JSON.
+// Incomplete UTF-8 sequences at the end of chunk are carried to next event of same type
+type Writer struct {
+	w     io.Writer
+	start time.Time
+	carry map[EventType][]byte
+
+	now func() time.Time
+	mu  sync.Mutex
+}
+
+func NewWriter(w io.Writer, h Header) (*Writer, error) {
+
+	wr := &Writer{
+		w:     w,
+		carry: make(map[EventType][]byte),
+		now:   time.Now,
+	}
+	wr.start = wr.now()
+
+	h.Version = Version
+	if h.Timestamp == 0 {
+		h.Timestamp = wr.start.Unix()
+	}
+
+	if err := wr.line(h); err != nil {
+		return nil, err
+	}
+
+	return wr, nil
+}
+
+func (wr *Writer) Output(data []byte) error {
+	return wr.stream(Output, data)
+}
+
+func (wr *Writer) Input(data []byte) error {
+	return wr.stream(Input, data)
+}
+
+func (wr *Writer) Resize(cols, rows int) error {
+	return wr.Event(Resize, fmt.Sprintf("%dx%d", cols, rows))
+}
+
+func (wr *Writer) Marker(label string) error {
+	return wr.Event(Marker, label)
+}
+
+// Event - writes event with time elapsed since recording start
+func (wr *Writer) Event(t EventType, data string) error {
+
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	return wr.event(t, data)
+}
+
+func (wr *Writer) event(t EventType, data string) error {
+	elapsed := float64(wr.now().Sub(wr.start).Microseconds()) / 1e6
+	return wr.line([]any{elapsed, t, data})
+}
+
+func (wr *Writer) stream(t EventType, data []byte) error {
+
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	data = append(wr.carry[t], data...)
+
+	cut := incompleteTail(data)
+	wr.carry[t] = append([]byte(nil), data[cut:]...)
+
+	if cut == 0 {
+		return nil
+	}
+
+	return wr.event(t, string(data[:cut]))
+}
+
+func (wr *Writer) line(v any) error {
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = wr.w.Write(append(data, '\n'))
+	return err
+}
+
+// incompleteTail - returns index of trailing bytes that start unfinished UTF-8 sequence
+func incompleteTail(data []byte) int {
+
+	for i := 1; i < utf8.UTFMax && i <= len(data); i++ {
+
+		b := data[len(data)-i]
+		if utf8.RuneStart(b) {
+			if !utf8.FullRune(data[len(data)-i:]) {
+				return len(data) - i
+			}
+			break
+		}
+	}
+
+	return len(data)
+}
@@ -0,0 +1,70 @@
+package asciicast
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestWriter(t *testing.T) {
+
+	buf := new(bytes.Buffer)
+
+	wr, err := NewWriter(buf, Header{Width: 80, Height: 24, Title: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := wr.start
+	wr.now = func() time.Time { return start.Add(1500 * time.Millisecond) }
+
+	euro := []byte("€")
+
+	wr.Output([]byte("ls\r\n"))
+	wr.Output(append([]byte("price: "), euro[:2]...))
+	wr.Output(euro[2:])
+	wr.Input([]byte("q"))
+	wr.Resize(120, 40)
+
+	scanner := bufio.NewScanner(buf)
+
+	scanner.Scan()
+	header := Header{}
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		t.Fatal(err)
+	}
+
+	if header.Version != 2 || header.Width != 80 || header.Timestamp == 0 {
+		t.Fatalf("unexpected header: %+v", header)
+	}
+
+	expected := [][]any{
+		{1.5, "o", "ls\r\n"},
+		{1.5, "o", "price: "},
+		{1.5, "o", "€"},
+		{1.5, "i", "q"},
+		{1.5, "r", "120x40"},
+	}
+
+	for _, want := range expected {
+
+		if !scanner.Scan() {
+			t.Fatalf("missing event %v", want)
+		}
+
+		event := []any{}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(event) != 3 || event[0] != want[0] || event[1] != want[1] || event[2] != want[2] {
+			t.Errorf("expected %v, got %v", want, event)
+		}
+	}
+
+	if scanner.Scan() {
+		t.Errorf("unexpected event: %s", scanner.Text())
+	}
+}
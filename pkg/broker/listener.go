@@ -62,7 +62,6 @@ func (s *ListenerMQTT) Connect(timeout time.Duration) error {
 	defer cancel()
 
 	done := make(chan error, 1)
-	defer close(done)
 
 	go func() {
 		token := s.mq.Connect()
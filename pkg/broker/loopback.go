@@ -0,0 +1,111 @@
+package broker
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Loopback - in-process MQTT stand-in. Published messages are delivered to handlers of matching
+// topic filters before Publish returns, so senders and listeners can be tested without real broker
+type Loopback struct {
+	mu   sync.RWMutex
+	subs []loopbackSub
+}
+
+type loopbackSub struct {
+	filter string
+	handle func(Message)
+}
+
+func NewLoopback() *Loopback {
+	return &Loopback{}
+}
+
+func (l *Loopback) ListenTopic(topic string, msgHandle func(Message)) error {
+
+	if !ValidFilter(topic) {
+		return fmt.Errorf("mqtt loopback invalid topic filter: %s", topic)
+	}
+
+	l.mu.Lock()
+	l.subs = append(l.subs, loopbackSub{topic, msgHandle})
+	l.mu.Unlock()
+
+	return nil
+}
+
+func (l *Loopback) Publish(topic string, payload []byte) error {
+
+	if topic == "" || strings.ContainsAny(topic, "+#") {
+		return fmt.Errorf("mqtt loopback invalid topic: %s", topic)
+	}
+
+	l.mu.RLock()
+	handlers := make([]func(Message), 0, len(l.subs))
+	for _, sub := range l.subs {
+		if MatchTopic(sub.filter, topic) {
+			handlers = append(handlers, sub.handle)
+		}
+	}
+	l.mu.RUnlock()
+
+	for _, handle := range handlers {
+		handle(&loopbackMessage{topic: topic, payload: payload})
+	}
+
+	return nil
+}
+
+type loopbackMessage struct {
+	topic   string
+	payload []byte
+}
+
+func (m *loopbackMessage) Duplicate() bool   { return false }
+func (m *loopbackMessage) Qos() byte         { return 0 }
+func (m *loopbackMessage) Retained() bool    { return false }
+func (m *loopbackMessage) Topic() string     { return m.topic }
+func (m *loopbackMessage) MessageID() uint16 { return 0 }
+func (m *loopbackMessage) Payload() []byte   { return m.payload }
+func (m *loopbackMessage) Ack()              {}
+
+// ValidFilter - reports that topic filter has '+' and '#' wildcards only as whole levels and '#' only at the end
+func ValidFilter(filter string) bool {
+
+	if filter == "" {
+		return false
+	}
+
+	levels := strings.Split(filter, "/")
+	for i, level := range levels {
+		switch {
+		case level == "#" && i != len(levels)-1:
+			return false
+		case level != "#" && level != "+" && strings.ContainsAny(level, "+#"):
+			return false
+		}
+	}
+
+	return true
+}
+
+// MatchTopic - reports that topic matches MQTT topic filter
+func MatchTopic(filter, topic string) bool {
+
+	fl := strings.Split(filter, "/")
+	tl := strings.Split(topic, "/")
+
+	for i, level := range fl {
+		switch {
+		case level == "#":
+			return true
+		case i >= len(tl):
+			return false
+		case level != "+" && level != tl[i]:
+			return false
+		}
+	}
+
+	return len(fl) == len(tl)
+}
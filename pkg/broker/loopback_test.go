@@ -0,0 +1,52 @@
+package broker
+
+import "testing"
+
+func TestMatchTopic(t *testing.T) {
+
+	cases := []struct {
+		filter, topic string
+		match         bool
+	}{
+		{"/agent/stats", "/agent/stats", true},
+		{"/agent/stats", "/agent/stats/x", false},
+		{"/agent/+/response", "/agent/host-1/response", true},
+		{"/agent/+/response", "/agent/host-1/command", false},
+		{"/agent/+", "/agent", false},
+		{"/agent/#", "/agent/host-1/response", true},
+		{"/agent/#", "/agent", true},
+		{"#", "/agent/stats", true},
+	}
+
+	for _, c := range cases {
+		if MatchTopic(c.filter, c.topic) != c.match {
+			t.Errorf("MatchTopic(%q, %q) = %v", c.filter, c.topic, !c.match)
+		}
+	}
+}
+
+func TestLoopback(t *testing.T) {
+
+	lb := NewLoopback()
+
+	var got []string
+	lb.ListenTopic("/agent/+/command", func(m Message) {
+		got = append(got, m.Topic()+" "+string(m.Payload()))
+		lb.Publish("/agent/host-1/response", []byte("pong"))
+	})
+	lb.ListenTopic("/agent/host-1/response", func(m Message) {
+		got = append(got, m.Topic()+" "+string(m.Payload()))
+	})
+
+	if err := lb.ListenTopic("/agent/x#", func(Message) {}); err == nil {
+		t.Fatal("invalid filter is accepted")
+	}
+
+	if err := lb.Publish("/agent/host-1/command", []byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 2 || got[0] != "/agent/host-1/command ping" || got[1] != "/agent/host-1/response pong" {
+		t.Fatalf("delivered = %q", got)
+	}
+}
@@ -55,7 +55,6 @@ func (s *SenderMQTT) Connect(timeout time.Duration) error {
 	defer cancel()
 
 	done := make(chan error, 1)
-	defer close(done)
 
 	go func() {
 		token := s.mq.Connect()
@@ -163,3 +162,10 @@ func (t *SenderMQTTTopic) PushJSON(v any) error {
 
 	return t.Push(data)
 }
+
+// Publish - pushes not retained message to topic
+func (s *SenderMQTT) Publish(topic string, payload []byte) error {
+	t := s.InitTopic(topic)
+	t.UnRetain()
+	return t.Push(payload)
+}
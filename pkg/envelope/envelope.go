@@ -0,0 +1,207 @@
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// Prefix - marks encrypted values. Values without prefix are treated as plaintext
+const Prefix = "enc:v1:"
+
+// Keeper - envelope encryption of string values.
+// Every value is sealed with random data key, data key is sealed with master key.
+// Encrypted value format: 'enc:v1:<master key id>:<sealed data key>:<sealed value>'
+type Keeper struct {
+	primary string
+	keys    map[string][]byte
+}
+
+// New - creates keeper with master key used for encryption.
+// Previous master keys are used only for decryption during rotation
+func New(master []byte, previous ...[]byte) (*Keeper, error) {
+
+	k := &Keeper{
+		keys: make(map[string][]byte, len(previous)+1),
+	}
+
+	for _, key := range append([][]byte{master}, previous...) {
+		if _, err := checkKey(key); err != nil {
+			return nil, err
+		}
+		k.keys[KeyID(key)] = key
+	}
+
+	k.primary = KeyID(master)
+
+	return k, nil
+}
+
+// KeyID - returns short fingerprint of master key
+func KeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4])
+}
+
+// IsEncrypted - reports that value has encrypted format
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+// Current - reports that value is empty or encrypted with primary master key
+func (k *Keeper) Current(value string) bool {
+
+	if value == "" {
+		return true
+	}
+
+	id, _, _, err := split(value)
+	return err == nil && id == k.primary
+}
+
+// Encrypt - seals value. Empty values are kept empty
+func (k *Keeper) Encrypt(value string) (string, error) {
+
+	if value == "" {
+		return "", nil
+	}
+
+	dataKey := make([]byte, KeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+
+	sealedKey, err := seal(k.keys[k.primary], dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	sealedValue, err := seal(dataKey, []byte(value))
+	if err != nil {
+		return "", err
+	}
+
+	return Prefix + k.primary + ":" +
+		base64.RawStdEncoding.EncodeToString(sealedKey) + ":" +
+		base64.RawStdEncoding.EncodeToString(sealedValue), nil
+}
+
+// Decrypt - opens sealed value. Plaintext values are returned as is
+func (k *Keeper) Decrypt(value string) (string, error) {
+
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	id, sealedKey, sealedValue, err := split(value)
+	if err != nil {
+		return "", err
+	}
+
+	master, ok := k.keys[id]
+	if !ok {
+		return "", ErrUnknownKey
+	}
+
+	dataKey, err := open(master, sealedKey)
+	if err != nil {
+		return "", err
+	}
+
+	plain, err := open(dataKey, sealedValue)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plain), nil
+}
+
+// Rewrap - returns value encrypted with primary master key.
+// Plaintext and values of previous master keys are re-encrypted
+func (k *Keeper) Rewrap(value string) (string, bool, error) {
+
+	if k.Current(value) {
+		return value, false, nil
+	}
+
+	plain, err := k.Decrypt(value)
+	if err != nil {
+		return "", false, err
+	}
+
+	sealed, err := k.Encrypt(plain)
+	if err != nil {
+		return "", false, err
+	}
+
+	return sealed, true, nil
+}
+
+func split(value string) (id string, sealedKey, sealedValue []byte, err error) {
+
+	parts := strings.Split(strings.TrimPrefix(value, Prefix), ":")
+	if !IsEncrypted(value) || len(parts) != 3 {
+		return "", nil, nil, ErrFormat
+	}
+
+	sealedKey, err = base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", nil, nil, ErrFormat
+	}
+
+	sealedValue, err = base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, nil, ErrFormat
+	}
+
+	return parts[0], sealedKey, sealedValue, nil
+}
+
+// seal - encrypts data with AES-256-GCM. Result is nonce followed by ciphertext
+func seal(key, data []byte) ([]byte, error) {
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, data, nil), nil
+}
+
+func open(key, data []byte) ([]byte, error) {
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < aead.NonceSize() {
+		return nil, ErrFormat
+	}
+
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrDecryption
+	}
+
+	return plain, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,101 @@
+package envelope
+
+import (
+	"strings"
+	"testing"
+)
+
+func mustKeeper(t *testing.T, keys ...[]byte) *Keeper {
+	k, err := New(keys[0], keys[1:]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func mustKey(t *testing.T) []byte {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+
+	k := mustKeeper(t, mustKey(t))
+
+	sealed, err := k.Encrypt("root-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !IsEncrypted(sealed) || strings.Contains(sealed, "root-password") {
+		t.Fatalf("value is not sealed: %s", sealed)
+	}
+
+	plain, err := k.Decrypt(sealed)
+	if err != nil || plain != "root-password" {
+		t.Fatalf("decrypted '%s', err: %v", plain, err)
+	}
+
+	if plain, _ := k.Decrypt("legacy"); plain != "legacy" {
+		t.Error("plaintext value must pass through decryption")
+	}
+
+	if sealed, _ := k.Encrypt(""); sealed != "" {
+		t.Error("empty value must stay empty")
+	}
+
+	if _, err := mustKeeper(t, mustKey(t)).Decrypt(sealed); err != ErrUnknownKey {
+		t.Errorf("foreign key decryption error: %v", err)
+	}
+
+	tampered := sealed[:len(sealed)-2] + "AA"
+	if _, err := k.Decrypt(tampered); err == nil {
+		t.Error("tampered value decrypted")
+	}
+}
+
+func TestRewrap(t *testing.T) {
+
+	oldKey, newKey := mustKey(t), mustKey(t)
+
+	sealed, err := mustKeeper(t, oldKey).Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := mustKeeper(t, newKey, oldKey)
+
+	rewrapped, changed, err := rotated.Rewrap(sealed)
+	if err != nil || !changed {
+		t.Fatalf("rewrap changed: %v, err: %v", changed, err)
+	}
+
+	if plain, err := mustKeeper(t, newKey).Decrypt(rewrapped); err != nil || plain != "secret" {
+		t.Fatalf("rotated value decrypted '%s', err: %v", plain, err)
+	}
+
+	if _, changed, _ := rotated.Rewrap(rewrapped); changed {
+		t.Error("current value must not be rewrapped")
+	}
+
+	if migrated, changed, _ := rotated.Rewrap("plain"); !changed || !IsEncrypted(migrated) {
+		t.Error("plaintext value must be encrypted on rewrap")
+	}
+}
+
+func TestParseKey(t *testing.T) {
+
+	key := mustKey(t)
+
+	parsed, err := ParseKey(EncodeKey(key) + "\n")
+	if err != nil || string(parsed) != string(key) {
+		t.Fatalf("base64 key parse error: %v", err)
+	}
+
+	if _, err := ParseKey("c2hvcnQ="); err != ErrKeySize {
+		t.Errorf("short key error: %v", err)
+	}
+}
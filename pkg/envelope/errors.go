@@ -0,0 +1,13 @@
+package envelope
+
+import "errors"
+
+var (
+	ErrKeySize      = errors.New("master key must be 32 bytes")
+	ErrKeyEncoding  = errors.New("master key must be base64 or hex encoded")
+	ErrFormat       = errors.New("invalid encrypted value format")
+	ErrUnknownKey   = errors.New("value encrypted with unknown master key")
+	ErrDecryption   = errors.New("value decryption failed")
+	ErrNoMasterKey  = errors.New("master key is not configured")
+	ErrKeyFileExist = errors.New("key file already exists")
+)
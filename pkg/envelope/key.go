@@ -0,0 +1,91 @@
+package envelope
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+const KeySize = 32
+
+// GenerateKey - returns random master key
+func GenerateKey() ([]byte, error) {
+
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// ParseKey - decodes base64 or hex encoded master key
+func ParseKey(value string) ([]byte, error) {
+
+	value = strings.TrimSpace(value)
+	decoded := false
+
+	for _, decode := range []func(string) ([]byte, error){
+		hex.DecodeString,
+		base64.StdEncoding.DecodeString,
+		base64.RawURLEncoding.DecodeString,
+	} {
+		key, err := decode(value)
+		if err != nil {
+			continue
+		}
+		if len(key) == KeySize {
+			return key, nil
+		}
+		decoded = true
+	}
+
+	if decoded {
+		return nil, ErrKeySize
+	}
+
+	return nil, ErrKeyEncoding
+}
+
+// EncodeKey - encodes master key to base64
+func EncodeKey(key []byte) string {
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// ReadKeyFile - reads encoded master key from file
+func ReadKeyFile(path string) ([]byte, error) {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseKey(string(data))
+}
+
+// WriteKeyFile - writes encoded master key to new file readable only by owner
+func WriteKeyFile(path string, key []byte) error {
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if errors.Is(err, fs.ErrExist) {
+		return ErrKeyFileExist
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(EncodeKey(key) + "\n")
+	return err
+}
+
+func checkKey(key []byte) ([]byte, error) {
+	if len(key) != KeySize {
+		return nil, ErrKeySize
+	}
+	return key, nil
+}
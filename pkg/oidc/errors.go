@@ -0,0 +1,31 @@
+package oidc
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrDiscovery       = errors.New("provider discovery failed")
+	ErrVerify          = errors.New("id token verification failed")
+	ErrUnknownKey      = errors.New("unknown id token signing key")
+	ErrSignature       = errors.New("invalid id token signature")
+	ErrExpired         = errors.New("id token expired")
+	ErrNonce           = errors.New("id token nonce mismatch")
+	ErrNoIDToken       = errors.New("token response has no id token")
+	ErrExchange        = errors.New("authorization code exchange failed")
+	ErrUnsupportedPKCE = errors.New("provider doesn't support S256 code challenge")
+)
+
+// ProviderError - error response of token endpoint
+type ProviderError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *ProviderError) Error() string {
+	if e.Description == "" {
+		return fmt.Sprintf("oidc provider error: %s", e.Code)
+	}
+	return fmt.Sprintf("oidc provider error: %s: %s", e.Code, e.Description)
+}
@@ -0,0 +1,171 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+)
+
+// keySet - provider signing keys. Key set is reloaded when id token is signed with unknown key,
+// but not more often than KeysRefreshInterval
+type keySet struct {
+	uri  string
+	http *http.Client
+
+	keys    []jose.JSONWebKey
+	fetched time.Time
+	mu      sync.Mutex
+
+	now func() time.Time
+}
+
+func newKeySet(uri string, httpClient *http.Client, now func() time.Time) *keySet {
+	return &keySet{
+		uri:  uri,
+		http: httpClient,
+		now:  now,
+	}
+}
+
+// VerifySignature - checks JWS signature with matching provider key and returns it's payload.
+// Implements go-oidc KeySet
+func (s *keySet) VerifySignature(ctx context.Context, raw string) ([]byte, error) {
+
+	algs := make([]jose.SignatureAlgorithm, len(SigningAlgorithms))
+	for i, alg := range SigningAlgorithms {
+		algs[i] = jose.SignatureAlgorithm(alg)
+	}
+
+	jws, err := jose.ParseSigned(raw, algs)
+	if err != nil || len(jws.Signatures) != 1 {
+		return nil, ErrSignature
+	}
+
+	header := jws.Signatures[0].Header
+
+	keys, err := s.lookup(ctx, header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+
+		if !keyFits(key, header.Algorithm) {
+			continue
+		}
+
+		if payload, err := jws.Verify(key); err == nil {
+			return payload, nil
+		}
+	}
+
+	return nil, ErrSignature
+}
+
+// lookup - returns signing keys with key ID, all keys when ID is empty
+func (s *keySet) lookup(ctx context.Context, kid string) ([]jose.JSONWebKey, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if keys := s.match(kid); len(keys) > 0 {
+		return keys, nil
+	}
+
+	now := s.now()
+	if !s.fetched.IsZero() && now.Before(s.fetched.Add(KeysRefreshInterval)) {
+		return nil, ErrUnknownKey
+	}
+	s.fetched = now
+
+	if err := s.fetch(ctx); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownKey, err)
+	}
+
+	if keys := s.match(kid); len(keys) > 0 {
+		return keys, nil
+	}
+
+	return nil, ErrUnknownKey
+}
+
+func (s *keySet) match(kid string) []jose.JSONWebKey {
+
+	if kid == "" {
+		return s.keys
+	}
+
+	for _, key := range s.keys {
+		if key.KeyID == kid {
+			return []jose.JSONWebKey{key}
+		}
+	}
+
+	return nil
+}
+
+// fetch - reloads signature keys from provider. Must be called with lock held
+func (s *keySet) fetch(ctx context.Context) error {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.uri, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: status %d", s.uri, resp.StatusCode)
+	}
+
+	set := jose.JSONWebKeySet{}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return err
+	}
+
+	s.keys = s.keys[:0]
+	for _, key := range set.Keys {
+		if key.Valid() && key.IsPublic() && (key.Use == "" || key.Use == "sig") {
+			s.keys = append(s.keys, key)
+		}
+	}
+
+	return nil
+}
+
+// keyFits - reports that key can verify signature of algorithm.
+// EC key must be on the curve of algorithm, so ES256 isn't accepted with P-384 key
+func keyFits(key jose.JSONWebKey, alg string) bool {
+
+	if key.Algorithm != "" && key.Algorithm != alg {
+		return false
+	}
+
+	pub, ok := key.Key.(*ecdsa.PublicKey)
+	if !ok {
+		return true
+	}
+
+	switch alg {
+	case string(jose.ES256):
+		return pub.Curve == elliptic.P256()
+	case string(jose.ES384):
+		return pub.Curve == elliptic.P384()
+	case string(jose.ES512):
+		return pub.Curve == elliptic.P521()
+	}
+
+	return false
+}
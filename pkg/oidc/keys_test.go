@@ -0,0 +1,39 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	jose "github.com/go-jose/go-jose/v4"
+)
+
+func TestKeyFits(t *testing.T) {
+
+	p256, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	cases := []struct {
+		name string
+		key  jose.JSONWebKey
+		alg  string
+		fits bool
+	}{
+		{"ES256 with P-256", jose.JSONWebKey{Key: &p256.PublicKey}, "ES256", true},
+		{"ES256 with P-384", jose.JSONWebKey{Key: &p384.PublicKey}, "ES256", false},
+		{"ES384 with P-384", jose.JSONWebKey{Key: &p384.PublicKey}, "ES384", true},
+		{"ES512 with P-384", jose.JSONWebKey{Key: &p384.PublicKey}, "ES512", false},
+		{"RS256 with EC key", jose.JSONWebKey{Key: &p256.PublicKey}, "RS256", false},
+		{"RS256 with RSA key", jose.JSONWebKey{Key: &rsaKey.PublicKey}, "RS256", true},
+		{"key algorithm mismatch", jose.JSONWebKey{Key: &rsaKey.PublicKey, Algorithm: "PS256"}, "RS256", false},
+	}
+
+	for _, c := range cases {
+		if fits := keyFits(c.key, c.alg); fits != c.fits {
+			t.Errorf("%s: fits = %v, want %v", c.name, fits, c.fits)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+// Package oidc implements OpenID Connect authorization code flow with PKCE
+// on top of go-oidc and oauth2: provider discovery, token exchange
+// and id token verification with provider JWKS.
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+const (
+	DiscoveryPath = "/.well-known/openid-configuration"
+	// ClockSkew - allowed difference between provider and local clocks
+	ClockSkew = time.Minute
+	// KeysRefreshInterval - minimal interval between key set reloads caused by unknown keys
+	KeysRefreshInterval = time.Minute
+)
+
+// SigningAlgorithms - accepted id token signature algorithms
+var SigningAlgorithms = []string{
+	gooidc.RS256, gooidc.RS384, gooidc.RS512,
+	gooidc.PS256, gooidc.PS384, gooidc.PS512,
+	gooidc.ES256, gooidc.ES384, gooidc.ES512,
+}
+
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Provider - discovered provider metadata
+type Provider struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	UserinfoEndpoint      string   `json:"userinfo_endpoint"`
+	CodeChallengeMethods  []string `json:"code_challenge_methods_supported"`
+}
+
+// Claims - verified id token claims
+type Claims map[string]any
+
+// String - returns string claim value or empty string
+func (c Claims) String(name string) string {
+	v, _ := c[name].(string)
+	return v
+}
+
+// Strings - returns string or string list claim value
+func (c Claims) Strings(name string) []string {
+
+	switch v := c[name].(type) {
+
+	case string:
+		return []string{v}
+
+	case []any:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+
+	default:
+		return nil
+	}
+}
+
+type Token struct {
+	AccessToken string
+	TokenType   string
+	IDToken     string
+	Expiry      time.Time
+}
+
+type Client struct {
+	provider Provider
+	oauth    oauth2.Config
+	verifier *gooidc.IDTokenVerifier
+	http     *http.Client
+
+	now func() time.Time
+}
+
+// Discover - loads provider metadata and creates client
+func Discover(ctx context.Context, config Config, httpClient *http.Client) (*Client, error) {
+
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 15 * time.Second}
+	}
+
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "profile", "email"}
+	}
+
+	if !slices.Contains(config.Scopes, gooidc.ScopeOpenID) {
+		config.Scopes = append([]string{gooidc.ScopeOpenID}, config.Scopes...)
+	}
+
+	discovered, err := gooidc.NewProvider(gooidc.ClientContext(ctx, httpClient), config.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDiscovery, err)
+	}
+
+	provider := Provider{}
+	if err := discovered.Claims(&provider); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDiscovery, err)
+	}
+
+	if len(provider.CodeChallengeMethods) > 0 && !slices.Contains(provider.CodeChallengeMethods, "S256") {
+		return nil, ErrUnsupportedPKCE
+	}
+
+	c := &Client{
+		provider: provider,
+		oauth: oauth2.Config{
+			ClientID:     config.ClientID,
+			ClientSecret: config.ClientSecret,
+			RedirectURL:  config.RedirectURL,
+			Endpoint:     discovered.Endpoint(),
+			Scopes:       config.Scopes,
+		},
+		http: httpClient,
+		now:  time.Now,
+	}
+
+	keys := newKeySet(provider.JWKSURI, httpClient, func() time.Time { return c.now() })
+
+	c.verifier = gooidc.NewVerifier(provider.Issuer, keys, &gooidc.Config{
+		ClientID:             config.ClientID,
+		SupportedSigningAlgs: SigningAlgorithms,
+		Now:                  func() time.Time { return c.now().Add(-ClockSkew) },
+	})
+
+	return c, nil
+}
+
+func (c *Client) Provider() Provider {
+	return c.provider
+}
+
+// AuthCodeURL - returns provider login url with state, nonce and S256 PKCE challenge
+func (c *Client) AuthCodeURL(state, nonce, verifier string) string {
+	return c.oauth.AuthCodeURL(state, gooidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+}
+
+// Exchange - exchanges authorization code with PKCE verifier for tokens
+func (c *Client) Exchange(ctx context.Context, code, verifier string) (*Token, error) {
+
+	token, err := c.oauth.Exchange(
+		context.WithValue(ctx, oauth2.HTTPClient, c.http),
+		code, oauth2.VerifierOption(verifier),
+	)
+	if err != nil {
+		var rerr *oauth2.RetrieveError
+		if errors.As(err, &rerr) && rerr.ErrorCode != "" {
+			return nil, &ProviderError{Code: rerr.ErrorCode, Description: rerr.ErrorDescription}
+		}
+		return nil, fmt.Errorf("%w: %v", ErrExchange, err)
+	}
+
+	idToken, _ := token.Extra("id_token").(string)
+	if idToken == "" {
+		return nil, ErrNoIDToken
+	}
+
+	return &Token{
+		AccessToken: token.AccessToken,
+		TokenType:   token.TokenType,
+		IDToken:     idToken,
+		Expiry:      token.Expiry,
+	}, nil
+}
+
+// Verify - checks id token signature, issuer, audience, expiration and nonce
+func (c *Client) Verify(ctx context.Context, rawIDToken, nonce string) (Claims, error) {
+
+	token, err := c.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		var expired *gooidc.TokenExpiredError
+		if errors.As(err, &expired) {
+			return nil, ErrExpired
+		}
+		return nil, fmt.Errorf("%w: %v", ErrVerify, err)
+	}
+
+	if token.Nonce != nonce {
+		return nil, ErrNonce
+	}
+
+	claims := Claims{}
+	if err := token.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrVerify, err)
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,178 @@
+package oidc_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/eterline/desky-backend/pkg/oidc"
+	"github.com/eterline/desky-backend/pkg/oidc/oidctest"
+)
+
+func mustVerifier(t *testing.T) string {
+	v, err := oidc.NewVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func setup(t *testing.T) (*oidctest.Server, *oidc.Client) {
+
+	srv := oidctest.NewServer("desky", "secret")
+	t.Cleanup(srv.Close)
+
+	client, err := oidc.Discover(context.Background(), oidc.Config{
+		Issuer:       srv.Issuer(),
+		ClientID:     "desky",
+		ClientSecret: "secret",
+		RedirectURL:  "http://desky.local/api/auth/oidc/callback",
+	}, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return srv, client
+}
+
+// authorize - follows provider login and returns code and state from callback
+func authorize(t *testing.T, srv *oidctest.Server, target string) (code, state string) {
+
+	httpClient := srv.Client()
+	httpClient.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	resp, err := httpClient.Get(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize status: %d", resp.StatusCode)
+	}
+
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return loc.Query().Get("code"), loc.Query().Get("state")
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+
+	srv, client := setup(t)
+	srv.Claims["preferred_username"] = "alice"
+	srv.Claims["groups"] = []string{"desky-admins", "staff"}
+
+	verifier := mustVerifier(t)
+	code, state := authorize(t, srv, client.AuthCodeURL("state-1", "nonce-1", verifier))
+
+	if state != "state-1" {
+		t.Fatalf("state: %s", state)
+	}
+
+	token, err := client.Exchange(context.Background(), code, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := client.Verify(context.Background(), token.IDToken, "nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if claims.String("sub") != "oidctest-user" || claims.String("preferred_username") != "alice" {
+		t.Fatalf("unexpected claims: %v", claims)
+	}
+
+	if groups := claims.Strings("groups"); len(groups) != 2 || groups[0] != "desky-admins" {
+		t.Fatalf("groups claim: %v", groups)
+	}
+}
+
+func TestExchangeWrongVerifier(t *testing.T) {
+
+	srv, client := setup(t)
+
+	code, _ := authorize(t, srv, client.AuthCodeURL("s", "n", mustVerifier(t)))
+
+	_, err := client.Exchange(context.Background(), code, mustVerifier(t))
+
+	var perr *oidc.ProviderError
+	if !errors.As(err, &perr) || perr.Code != "invalid_grant" {
+		t.Fatalf("expected invalid_grant, got: %v", err)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+
+	srv, client := setup(t)
+
+	base := func() map[string]any {
+		return map[string]any{
+			"iss":   srv.Issuer(),
+			"sub":   "user",
+			"aud":   "desky",
+			"exp":   time.Now().Add(time.Minute).Unix(),
+			"nonce": "n",
+		}
+	}
+
+	cases := map[string]struct {
+		edit func(map[string]any)
+		want error
+	}{
+		"audience": {func(c map[string]any) { c["aud"] = "other" }, oidc.ErrVerify},
+		"issuer":   {func(c map[string]any) { c["iss"] = "https://evil.example" }, oidc.ErrVerify},
+		"expired":  {func(c map[string]any) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, oidc.ErrExpired},
+		"nonce":    {func(c map[string]any) { c["nonce"] = "replayed" }, oidc.ErrNonce},
+	}
+
+	for name, tc := range cases {
+		claims := base()
+		tc.edit(claims)
+
+		if _, err := client.Verify(context.Background(), srv.Sign(claims), "n"); !errors.Is(err, tc.want) {
+			t.Errorf("%s: expected %v, got %v", name, tc.want, err)
+		}
+	}
+
+	token := srv.Sign(base())
+	tampered := token[:len(token)-4] + "AAAA"
+
+	if _, err := client.Verify(context.Background(), tampered, "n"); !errors.Is(err, oidc.ErrVerify) {
+		t.Errorf("tampered: expected signature error, got %v", err)
+	}
+}
+
+func TestUnknownKeyRefresh(t *testing.T) {
+
+	srv, client := setup(t)
+
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","kid":"rotated","typ":"JWT"}`))
+	payload, _ := json.Marshal(map[string]any{
+		"iss": srv.Issuer(),
+		"sub": "user",
+		"aud": "desky",
+		"exp": time.Now().Add(time.Minute).Unix(),
+	})
+	token := header + "." + base64.RawURLEncoding.EncodeToString(payload) + ".AAAA"
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.Verify(context.Background(), token, "n"); !errors.Is(err, oidc.ErrVerify) {
+			t.Fatalf("expected verification error, got %v", err)
+		}
+	}
+
+	if n := srv.KeyRequests(); n != 1 {
+		t.Errorf("unknown keys must not reload key set every time: %d requests", n)
+	}
+}
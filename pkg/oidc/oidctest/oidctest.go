@@ -0,0 +1,253 @@
+// Package oidctest provides local stand-in OpenID Connect provider for tests.
+// Provider authorizes every request immediately and issues RS256 signed id tokens.
+package oidctest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const KeyID = "oidctest"
+
+type authRequest struct {
+	nonce       string
+	challenge   string
+	redirectURI string
+}
+
+type Server struct {
+	ClientID     string
+	ClientSecret string
+
+	// Claims - extra claims added into every issued id token
+	Claims map[string]any
+	// Subject - "sub" claim of issued id tokens
+	Subject string
+	// TTL - id token lifetime
+	TTL time.Duration
+
+	srv   *httptest.Server
+	key   *rsa.PrivateKey
+	codes map[string]authRequest
+	jwksN int
+	mu    sync.Mutex
+}
+
+// NewServer - starts stand-in provider for client
+func NewServer(clientID, clientSecret string) *Server {
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	s := &Server{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Claims:       map[string]any{},
+		Subject:      "oidctest-user",
+		TTL:          5 * time.Minute,
+		key:          key,
+		codes:        make(map[string]authRequest),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/jwks", s.jwks)
+	mux.HandleFunc("/authorize", s.authorize)
+	mux.HandleFunc("/token", s.token)
+
+	s.srv = httptest.NewServer(mux)
+
+	return s
+}
+
+// Issuer - returns provider issuer URL
+func (s *Server) Issuer() string {
+	return s.srv.URL
+}
+
+func (s *Server) Client() *http.Client {
+	return s.srv.Client()
+}
+
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Sign - returns RS256 signed JWT with claims
+func (s *Server) Sign(claims map[string]any) string {
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": KeyID, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+
+	signed := encode(header) + "." + encode(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		panic(err)
+	}
+
+	return signed + "." + encode(sig)
+}
+
+// KeyRequests - returns count of served key set requests
+func (s *Server) KeyRequests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jwksN
+}
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                           s.Issuer(),
+		"authorization_endpoint":           s.Issuer() + "/authorize",
+		"token_endpoint":                   s.Issuer() + "/token",
+		"jwks_uri":                         s.Issuer() + "/jwks",
+		"code_challenge_methods_supported": []string{"S256"},
+	})
+}
+
+func (s *Server) jwks(w http.ResponseWriter, r *http.Request) {
+
+	s.mu.Lock()
+	s.jwksN++
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": KeyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   encode(s.key.N.Bytes()),
+			"e":   encode(big.NewInt(int64(s.key.E)).Bytes()),
+		}},
+	})
+}
+
+// authorize - accepts login immediately and redirects back with code
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+
+	q := r.URL.Query()
+
+	if q.Get("client_id") != s.ClientID || q.Get("response_type") != "code" ||
+		q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	code := randomString()
+
+	s.mu.Lock()
+	s.codes[code] = authRequest{
+		nonce:       q.Get("nonce"),
+		challenge:   q.Get("code_challenge"),
+		redirectURI: q.Get("redirect_uri"),
+	}
+	s.mu.Unlock()
+
+	values := redirect.Query()
+	values.Set("code", code)
+	values.Set("state", q.Get("state"))
+	redirect.RawQuery = values.Encode()
+
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, "invalid_request")
+		return
+	}
+
+	id, secret, ok := r.BasicAuth()
+	if ok {
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+
+	if id != s.ClientID || secret != s.ClientSecret {
+		tokenError(w, "invalid_client")
+		return
+	}
+
+	code := r.PostForm.Get("code")
+
+	s.mu.Lock()
+	req, exists := s.codes[code]
+	delete(s.codes, code)
+	s.mu.Unlock()
+
+	if !exists || r.PostForm.Get("grant_type") != "authorization_code" ||
+		r.PostForm.Get("redirect_uri") != req.redirectURI {
+		tokenError(w, "invalid_grant")
+		return
+	}
+
+	digest := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if encode(digest[:]) != req.challenge {
+		tokenError(w, "invalid_grant")
+		return
+	}
+
+	now := time.Now()
+	claims := map[string]any{
+		"iss":   s.Issuer(),
+		"sub":   s.Subject,
+		"aud":   s.ClientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(s.TTL).Unix(),
+		"nonce": req.nonce,
+	}
+	for k, v := range s.Claims {
+		claims[k] = v
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   int64(s.TTL.Seconds()),
+		"id_token":     s.Sign(claims),
+	})
+}
+
+func tokenError(w http.ResponseWriter, code string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func randomString() string {
+	data := make([]byte, 16)
+	rand.Read(data)
+	return encode(data)
+}
@@ -0,0 +1,24 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+
+	"golang.org/x/oauth2"
+)
+
+// RandomString - returns url safe random string of n random bytes
+func RandomString(n int) (string, error) {
+
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// NewVerifier - returns PKCE code verifier (RFC 7636)
+func NewVerifier() (string, error) {
+	return oauth2.GenerateVerifier(), nil
+}
@@ -0,0 +1,350 @@
+// Package sshconfig reads host entries of OpenSSH client configuration file.
+// Only keywords needed to connect to host are resolved: HostName, User, Port, IdentityFile and ProxyJump.
+// Like in OpenSSH, first obtained value of keyword is used. Match blocks and Include are skipped
+package sshconfig
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const DefaultPort = 22
+
+var (
+	ErrQuote   = errors.New("unterminated quote")
+	ErrNoValue = errors.New("keyword has no value")
+	ErrPort    = errors.New("invalid port")
+	ErrJump    = errors.New("invalid jump host")
+)
+
+// SyntaxError - error of config line
+type SyntaxError struct {
+	Line int
+	Err  error
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("ssh config line %d: %v", e.Line, e.Err)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// Host - resolved host entry
+type Host struct {
+	Alias        string
+	HostName     string
+	User         string
+	Port         uint16
+	IdentityFile []string
+	ProxyJump    []Jump
+}
+
+// Jump - element of ProxyJump list
+type Jump struct {
+	User string
+	Host string
+	Port uint16
+}
+
+type block struct {
+	patterns []string
+	options  [][2]string
+}
+
+// Config - parsed configuration file
+type Config struct {
+	blocks  []block
+	aliases []string
+}
+
+// Parse - reads configuration. Global options before first Host block apply to all hosts
+func Parse(r io.Reader) (*Config, error) {
+
+	cfg := &Config{
+		blocks: []block{{patterns: []string{"*"}}},
+	}
+
+	seen := make(map[string]bool)
+	current := &cfg.blocks[0]
+
+	sc := bufio.NewScanner(r)
+	line := 0
+
+	for sc.Scan() {
+		line++
+
+		keyword, args, err := splitLine(sc.Text())
+		if err != nil {
+			return nil, &SyntaxError{line, err}
+		}
+
+		if keyword == "" {
+			continue
+		}
+
+		if len(args) == 0 {
+			return nil, &SyntaxError{line, fmt.Errorf("%w: %s", ErrNoValue, keyword)}
+		}
+
+		switch keyword {
+
+		case "host":
+			cfg.blocks = append(cfg.blocks, block{patterns: args})
+			current = &cfg.blocks[len(cfg.blocks)-1]
+
+			for _, alias := range args {
+				if isConcrete(alias) && !seen[alias] {
+					seen[alias] = true
+					cfg.aliases = append(cfg.aliases, alias)
+				}
+			}
+
+		case "match":
+			// match criteria can't be evaluated without connection, block is skipped
+			cfg.blocks = append(cfg.blocks, block{})
+			current = &cfg.blocks[len(cfg.blocks)-1]
+
+		default:
+			current.options = append(current.options, [2]string{keyword, strings.Join(args, " ")})
+		}
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Aliases - returns host aliases without wildcards in order of appearance
+func (c *Config) Aliases() []string {
+	return c.aliases
+}
+
+// Resolve - collects options of all blocks matching alias
+func (c *Config) Resolve(alias string) (Host, error) {
+
+	host := Host{Alias: alias}
+	values := make(map[string]string)
+
+	for _, b := range c.blocks {
+
+		if !matchPatterns(b.patterns, alias) {
+			continue
+		}
+
+		for _, opt := range b.options {
+
+			if opt[0] == "identityfile" {
+				host.IdentityFile = append(host.IdentityFile, opt[1])
+				continue
+			}
+
+			if _, ok := values[opt[0]]; !ok {
+				values[opt[0]] = opt[1]
+			}
+		}
+	}
+
+	host.HostName = alias
+	if v, ok := values["hostname"]; ok {
+		host.HostName = expandHost(v, alias)
+	}
+
+	host.User = values["user"]
+	host.Port = DefaultPort
+
+	if v, ok := values["port"]; ok {
+		port, err := parsePort(v)
+		if err != nil {
+			return host, err
+		}
+		host.Port = port
+	}
+
+	if v, ok := values["proxyjump"]; ok {
+		jumps, err := ParseJumps(v)
+		if err != nil {
+			return host, err
+		}
+		host.ProxyJump = jumps
+	}
+
+	return host, nil
+}
+
+// ParseJumps - parses comma separated ProxyJump list. 'none' means direct connection
+func ParseJumps(value string) ([]Jump, error) {
+
+	if strings.EqualFold(value, "none") {
+		return nil, nil
+	}
+
+	var jumps []Jump
+
+	for _, item := range strings.Split(value, ",") {
+
+		item = strings.TrimPrefix(strings.TrimSpace(item), "ssh://")
+		if item == "" {
+			return nil, ErrJump
+		}
+
+		jump := Jump{Port: DefaultPort}
+
+		if at := strings.LastIndex(item, "@"); at >= 0 {
+			jump.User, item = item[:at], item[at+1:]
+		}
+
+		if host, port, err := net.SplitHostPort(item); err == nil {
+			if jump.Port, err = parsePort(port); err != nil {
+				return nil, err
+			}
+			item = host
+		}
+
+		jump.Host = strings.Trim(item, "[]")
+		if jump.Host == "" {
+			return nil, ErrJump
+		}
+
+		jumps = append(jumps, jump)
+	}
+
+	return jumps, nil
+}
+
+// splitLine - returns lowercase keyword and arguments. Keyword can be separated by '='
+func splitLine(text string) (string, []string, error) {
+
+	text = strings.TrimSpace(text)
+	if text == "" || strings.HasPrefix(text, "#") {
+		return "", nil, nil
+	}
+
+	end := strings.IndexAny(text, " \t=")
+	if end < 0 {
+		return strings.ToLower(text), nil, nil
+	}
+
+	keyword := strings.ToLower(text[:end])
+
+	rest := strings.TrimLeft(text[end:], " \t")
+	rest = strings.TrimPrefix(rest, "=")
+
+	args, err := splitArgs(rest)
+	return keyword, args, err
+}
+
+// splitArgs - splits arguments by whitespace keeping quoted ones whole
+func splitArgs(text string) ([]string, error) {
+
+	var (
+		args    []string
+		current strings.Builder
+		quoted  bool
+		started bool
+	)
+
+	for _, r := range text {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			started = true
+		case !quoted && (r == ' ' || r == '\t'):
+			if started {
+				args = append(args, current.String())
+				current.Reset()
+				started = false
+			}
+		case !quoted && r == '#' && !started:
+			// comment after arguments
+			return args, nil
+		default:
+			current.WriteRune(r)
+			started = true
+		}
+	}
+
+	if quoted {
+		return nil, ErrQuote
+	}
+
+	if started {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
+
+func matchPatterns(patterns []string, alias string) bool {
+
+	matched := false
+
+	for _, p := range patterns {
+		if negated, ok := strings.CutPrefix(p, "!"); ok {
+			if matchWildcard(negated, alias) {
+				return false
+			}
+			continue
+		}
+
+		if matchWildcard(p, alias) {
+			matched = true
+		}
+	}
+
+	return matched
+}
+
+// matchWildcard - matches name with pattern of '*' and '?' wildcards
+func matchWildcard(pattern, name string) bool {
+
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(name); i >= 0; i-- {
+				if matchWildcard(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if name == "" {
+				return false
+			}
+		default:
+			if name == "" || !strings.EqualFold(pattern[:1], name[:1]) {
+				return false
+			}
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return name == ""
+}
+
+func isConcrete(alias string) bool {
+	return !strings.ContainsAny(alias, "*?!")
+}
+
+// expandHost - replaces %h with alias and %% with percent sign
+func expandHost(value, alias string) string {
+	return strings.NewReplacer("%h", alias, "%%", "%").Replace(value)
+}
+
+func parsePort(value string) (uint16, error) {
+
+	port, err := strconv.ParseUint(value, 10, 16)
+	if err != nil || port == 0 {
+		return 0, fmt.Errorf("%w: %s", ErrPort, value)
+	}
+
+	return uint16(port), nil
+}
@@ -0,0 +1,144 @@
+package sshconfig
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testConfig = `
+# global options
+User admin
+
+Host bastion
+    HostName 203.0.113.10
+    Port 2222
+    IdentityFile ~/.ssh/bastion
+
+Host db-* !db-legacy
+    ProxyJump bastion
+    User postgres
+
+Host db-main db-replica
+    HostName %h.internal
+    IdentityFile ~/.ssh/db
+
+Host db-legacy
+    HostName=10.0.0.9
+    ProxyJump ops@bastion:2222,[2001:db8::1]:22
+
+Match host *.internal
+    User ignored
+
+Host *
+    IdentityFile ~/.ssh/id_ed25519
+    Port 22
+`
+
+func TestResolve(t *testing.T) {
+
+	cfg, err := Parse(strings.NewReader(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if aliases := cfg.Aliases(); !reflect.DeepEqual(aliases, []string{"bastion", "db-main", "db-replica", "db-legacy"}) {
+		t.Fatalf("aliases = %v", aliases)
+	}
+
+	tests := []Host{
+		{
+			Alias:        "bastion",
+			HostName:     "203.0.113.10",
+			User:         "admin",
+			Port:         2222,
+			IdentityFile: []string{"~/.ssh/bastion", "~/.ssh/id_ed25519"},
+		},
+		{
+			Alias:        "db-main",
+			HostName:     "db-main.internal",
+			User:         "admin",
+			Port:         22,
+			IdentityFile: []string{"~/.ssh/db", "~/.ssh/id_ed25519"},
+			ProxyJump:    []Jump{{Host: "bastion", Port: 22}},
+		},
+		{
+			Alias:        "db-legacy",
+			HostName:     "10.0.0.9",
+			User:         "admin",
+			Port:         22,
+			IdentityFile: []string{"~/.ssh/id_ed25519"},
+			ProxyJump:    []Jump{{User: "ops", Host: "bastion", Port: 2222}, {Host: "2001:db8::1", Port: 22}},
+		},
+		{
+			Alias:        "unknown",
+			HostName:     "unknown",
+			User:         "admin",
+			Port:         22,
+			IdentityFile: []string{"~/.ssh/id_ed25519"},
+		},
+	}
+
+	for _, want := range tests {
+		t.Run(want.Alias, func(t *testing.T) {
+			got, err := cfg.Resolve(want.Alias)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+
+	tests := []struct {
+		config string
+		want   error
+	}{
+		{"Host a\n  HostName \"unterminated\n", ErrQuote},
+		{"Host\n", ErrNoValue},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(strings.NewReader(tt.config))
+
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) || !errors.Is(err, tt.want) {
+			t.Fatalf("config %q: error = %v, want %v", tt.config, err, tt.want)
+		}
+	}
+
+	cfg, err := Parse(strings.NewReader("Host a\n  Port 99999\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cfg.Resolve("a"); !errors.Is(err, ErrPort) {
+		t.Fatalf("error = %v, want %v", err, ErrPort)
+	}
+}
+
+func TestParseJumps(t *testing.T) {
+
+	jumps, err := ParseJumps("none")
+	if err != nil || jumps != nil {
+		t.Fatalf("none = %v, %v", jumps, err)
+	}
+
+	jumps, err = ParseJumps("ssh://root@gw:2200")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []Jump{{User: "root", Host: "gw", Port: 2200}}; !reflect.DeepEqual(jumps, want) {
+		t.Fatalf("got %+v, want %+v", jumps, want)
+	}
+
+	if _, err := ParseJumps("gw,,other"); !errors.Is(err, ErrJump) {
+		t.Fatalf("error = %v, want %v", err, ErrJump)
+	}
+}
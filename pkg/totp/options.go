@@ -0,0 +1,96 @@
+package totp
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"time"
+)
+
+type Algorithm string
+
+const (
+	AlgorithmSHA1   Algorithm = "SHA1"
+	AlgorithmSHA256 Algorithm = "SHA256"
+	AlgorithmSHA512 Algorithm = "SHA512"
+)
+
+func (a Algorithm) hash() func() hash.Hash {
+	switch a {
+	case AlgorithmSHA256:
+		return sha256.New
+	case AlgorithmSHA512:
+		return sha512.New
+	default:
+		return sha1.New
+	}
+}
+
+type (
+	OptionFunc func(o *Options)
+
+	Options struct {
+		period    uint64
+		digits    int
+		skew      uint64
+		algorithm Algorithm
+		clock     func() time.Time
+	}
+)
+
+// WithPeriod - sets code time step. Default: 30s
+func WithPeriod(d time.Duration) OptionFunc {
+	return func(o *Options) {
+		if sec := uint64(d / time.Second); sec > 0 {
+			o.period = sec
+		}
+	}
+}
+
+// WithDigits - sets code length. Default: 6
+func WithDigits(n int) OptionFunc {
+	return func(o *Options) {
+		if n >= 6 && n <= 10 {
+			o.digits = n
+		}
+	}
+}
+
+// WithSkew - sets count of accepted steps before and after current. Default: 1
+func WithSkew(steps uint) OptionFunc {
+	return func(o *Options) {
+		o.skew = uint64(steps)
+	}
+}
+
+// WithAlgorithm - sets HMAC algorithm. Default: SHA1
+func WithAlgorithm(a Algorithm) OptionFunc {
+	return func(o *Options) {
+		o.algorithm = a
+	}
+}
+
+// WithClock - sets time source. Used for tests with fixed time
+func WithClock(clock func() time.Time) OptionFunc {
+	return func(o *Options) {
+		o.clock = clock
+	}
+}
+
+func mustOptions(options ...OptionFunc) *Options {
+
+	o := &Options{
+		period:    30,
+		digits:    6,
+		skew:      1,
+		algorithm: AlgorithmSHA1,
+		clock:     time.Now,
+	}
+
+	for _, option := range options {
+		option(o)
+	}
+
+	return o
+}
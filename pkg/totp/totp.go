@@ -0,0 +1,135 @@
+// Package totp implements RFC 4226 HOTP and RFC 6238 TOTP one-time passwords
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type TOTP struct {
+	opts *Options
+}
+
+func New(options ...OptionFunc) *TOTP {
+	return &TOTP{
+		opts: mustOptions(options...),
+	}
+}
+
+// GenerateSecret - generates random base32 secret with size bytes of entropy
+func GenerateSecret(size int) (string, error) {
+
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return encoding.EncodeToString(buf), nil
+}
+
+// Counter - returns current time step number
+func (t *TOTP) Counter() uint64 {
+	return uint64(t.opts.clock().Unix()) / t.opts.period
+}
+
+// Code - returns code for current time step
+func (t *TOTP) Code(secret string) (string, error) {
+	return t.CodeAt(secret, t.Counter())
+}
+
+// CodeAt - returns HOTP code for counter value
+func (t *TOTP) CodeAt(secret string, counter uint64) (string, error) {
+
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return hotp(key, counter, t.opts), nil
+}
+
+// Validate - checks code in skew window and returns matched counter.
+// Caller must reject counters that are not greater than last used one to prevent replays
+func (t *TOTP) Validate(secret, code string) (uint64, bool) {
+
+	key, err := decodeSecret(secret)
+	if err != nil || len(code) != t.opts.digits {
+		return 0, false
+	}
+
+	current := t.Counter()
+
+	for delta := uint64(0); delta <= t.opts.skew; delta++ {
+
+		counters := []uint64{current + delta}
+		if delta > 0 && current >= delta {
+			counters = append(counters, current-delta)
+		}
+
+		for _, counter := range counters {
+			expected := hotp(key, counter, t.opts)
+			if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+				return counter, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// URI - returns otpauth provisioning URI for authenticator apps
+func (t *TOTP) URI(issuer, account, secret string) string {
+
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", string(t.opts.algorithm))
+	params.Set("digits", strconv.Itoa(t.opts.digits))
+	params.Set("period", strconv.FormatUint(t.opts.period, 10))
+
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+
+	return fmt.Sprintf("otpauth://totp/%s?%s", label, params.Encode())
+}
+
+func hotp(key []byte, counter uint64, opts *Options) string {
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(opts.algorithm.hash(), key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	var mod uint64 = 1
+	for i := 0; i < opts.digits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", opts.digits, uint64(value)%mod)
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	secret = strings.TrimRight(secret, "=")
+
+	key, err := encoding.DecodeString(secret)
+	if err != nil {
+		return nil, ErrInvalidSecret
+	}
+
+	return key, nil
+}
@@ -0,0 +1,101 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B test vectors
+func TestRFC6238Vectors(t *testing.T) {
+
+	secrets := map[Algorithm]string{
+		AlgorithmSHA1:   "12345678901234567890",
+		AlgorithmSHA256: "12345678901234567890123456789012",
+		AlgorithmSHA512: "1234567890123456789012345678901234567890123456789012345678901234",
+	}
+
+	vectors := []struct {
+		unix int64
+		algo Algorithm
+		code string
+	}{
+		{59, AlgorithmSHA1, "94287082"},
+		{59, AlgorithmSHA256, "46119246"},
+		{59, AlgorithmSHA512, "90693936"},
+		{1111111109, AlgorithmSHA1, "07081804"},
+		{1111111111, AlgorithmSHA256, "67062674"},
+		{1234567890, AlgorithmSHA512, "93441116"},
+		{2000000000, AlgorithmSHA1, "69279037"},
+		{20000000000, AlgorithmSHA256, "77737706"},
+	}
+
+	for _, v := range vectors {
+
+		at := time.Unix(v.unix, 0)
+		secret := base32.StdEncoding.EncodeToString([]byte(secrets[v.algo]))
+
+		gen := New(
+			WithDigits(8),
+			WithAlgorithm(v.algo),
+			WithClock(func() time.Time { return at }),
+		)
+
+		code, err := gen.Code(secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if code != v.code {
+			t.Errorf("time %d %s: expected %s, got %s", v.unix, v.algo, v.code, code)
+		}
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+
+	secret, err := GenerateSecret(20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	clock := func() time.Time { return now }
+	gen := New(WithClock(clock))
+
+	prev, _ := gen.CodeAt(secret, gen.Counter()-1)
+	next, _ := gen.CodeAt(secret, gen.Counter()+1)
+	far, _ := gen.CodeAt(secret, gen.Counter()+3)
+
+	if counter, ok := gen.Validate(secret, prev); !ok || counter != gen.Counter()-1 {
+		t.Error("previous step code must be accepted")
+	}
+
+	if _, ok := gen.Validate(secret, next); !ok {
+		t.Error("next step code must be accepted")
+	}
+
+	if _, ok := gen.Validate(secret, far); ok {
+		t.Error("code outside of skew window accepted")
+	}
+
+	if _, ok := gen.Validate(secret, "12345"); ok {
+		t.Error("short code accepted")
+	}
+}
+
+func TestURI(t *testing.T) {
+
+	uri := New().URI("desky", "admin", "JBSWY3DPEHPK3PXP")
+
+	if !strings.HasPrefix(uri, "otpauth://totp/desky:admin?") {
+		t.Errorf("unexpected uri: %s", uri)
+	}
+
+	for _, part := range []string{"secret=JBSWY3DPEHPK3PXP", "issuer=desky", "digits=6", "period=30"} {
+		if !strings.Contains(uri, part) {
+			t.Errorf("uri %s does not contain %s", uri, part)
+		}
+	}
+}